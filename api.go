@@ -0,0 +1,325 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/common/model"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/history"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/notify"
+)
+
+// openAPISpec is the OpenAPI document of the JSON and admin API
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// openAPIHandler serves the OpenAPI document
+func openAPIHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/yaml", openAPISpec)
+}
+
+// parseValueFilter reads a value filter from the query. The source, target
+// and metric parameters filter the values, and every label=name=value
+// parameter requires a label to have that value.
+func parseValueFilter(c *gin.Context) (collector.ValueFilter, error) {
+	filter := collector.ValueFilter{
+		Source: c.Query("source"),
+		Target: c.Query("target"),
+		Metric: c.Query("metric"),
+		Labels: make(map[string]string),
+	}
+	for _, label := range c.QueryArray("label") {
+		name, value, ok := strings.Cut(label, "=")
+		if !ok {
+			return filter, fmt.Errorf("label filter must be name=value, got %q", label)
+		}
+		filter.Labels[name] = value
+	}
+	return filter, nil
+}
+
+// valuesHandler serves the latest collected readings as JSON
+func valuesHandler(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseValueFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		values, err := col.Values(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		lastCollect, lastSuccess := col.GetHealthStatus()
+		c.JSON(http.StatusOK, gin.H{
+			"last_collect": lastCollect.Format(time.RFC3339),
+			"last_success": lastSuccess,
+			"values":       values,
+		})
+	}
+}
+
+// parsedHandler serves the structures parsed by the most recent scrape of a
+// target, to debug how page contents are mapped to labels and values
+func parsedHandler(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target := c.Query("target")
+		parsed, ok := col.Parsed(target)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no parsed data for target %q", target)})
+			return
+		}
+		c.JSON(http.StatusOK, parsed)
+	}
+}
+
+// collectHandler triggers an immediate collection, of everything or of the
+// source or CDU target given in the query, and responds once it finished
+func collectHandler(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !col.IsLeader() {
+			c.JSON(http.StatusConflict, gin.H{"error": "this replica is on standby, collect on the leader"})
+			return
+		}
+		source, target := c.Query("source"), c.Query("target")
+		// The collection logs with the request ID
+		ctx := collector.WithCycleID(c.Request.Context(), c.GetString(requestIDKey))
+
+		var err error
+		switch {
+		case target != "":
+			err = col.CollectTarget(ctx, target)
+		case source != "":
+			if !slices.Contains(collector.Sources, source) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown source %q", source)})
+				return
+			}
+			err = col.CollectSource(ctx, source)
+		default:
+			col.Collect(ctx)
+			if _, ok := col.GetHealthStatus(); !ok {
+				err = errors.New("collection failed, see the exporter logs")
+			}
+		}
+
+		switch {
+		case errors.Is(err, collector.ErrUnknownTarget):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusOK, gin.H{"status": "collected"})
+		}
+	}
+}
+
+// pauseHandler pauses or resumes the periodic collection of the sources in
+// the query, or of every source if none are given
+func pauseHandler(col *collector.Collector, pause bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sources := c.QueryArray("source")
+
+		var err error
+		if pause {
+			err = col.Pause(sources...)
+		} else {
+			err = col.Resume(sources...)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"paused": col.PausedSources()})
+	}
+}
+
+// pprofHandler serves the net/http/pprof endpoints under /debug/pprof
+func pprofHandler(c *gin.Context) {
+	switch c.Param("profile") {
+	case "/cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "/profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "/symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "/trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// targetsHandler lists the scrape targets and their state
+func targetsHandler(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"targets": col.Targets()})
+	}
+}
+
+// sdHandler serves the scrape targets, or the ones of the source in the
+// query, in the Prometheus HTTP service discovery format
+func sdHandler(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		source := c.Query("source")
+		if source != "" && !slices.Contains(collector.Sources, source) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown source %q", source)})
+			return
+		}
+		c.JSON(http.StatusOK, col.TargetGroups(source))
+	}
+}
+
+// eventsHandler serves the recorded alarm events, including the ones read
+// from the alarm histories, optionally of one target and after the RFC 3339
+// time in since
+func eventsHandler(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var since time.Time
+		if s := c.Query("since"); s != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339, s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid since %q: %v", s, err)})
+				return
+			}
+		}
+		events := append(col.Events(c.Query("target"), since), col.HistoryEvents(c.Query("target"), since)...)
+		sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+		c.JSON(http.StatusOK, gin.H{"events": events})
+	}
+}
+
+// availabilityHandler serves the share of collections that returned data for
+// every sensor and CDU over the window, such as 30d
+func availabilityHandler(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		retention := col.Config().AvailabilityRetention
+		window := retention
+		if w := c.Query("window"); w != "" {
+			d, err := model.ParseDuration(w)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid window %q: %v", w, err)})
+				return
+			}
+			window = time.Duration(d)
+		}
+		if window <= 0 || window > retention {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("window must be between 0 and the retention of %s", model.Duration(retention))})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"window":       model.Duration(window).String(),
+			"availability": col.Availability(window, c.Query("source"), c.Query("target")),
+		})
+	}
+}
+
+// sensorsHandler serves the inventory of the sensors, CDUs and racks
+// observed, optionally of one kind
+func sensorsHandler(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := c.Query("kind")
+		if kind != "" && !slices.Contains(collector.InventoryKinds, kind) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown kind %q", kind)})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"sensors": col.Inventory(kind)})
+	}
+}
+
+// historyHandler serves the recorded history of a metric between from and to,
+// by default over the last hour
+func historyHandler(hist *history.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseValueFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if filter.Metric == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "metric is required"})
+			return
+		}
+
+		to := time.Now()
+		if s := c.Query("to"); s != "" {
+			if to, err = time.Parse(time.RFC3339, s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid to %q: %v", s, err)})
+				return
+			}
+		}
+		from := to.Add(-time.Hour)
+		if s := c.Query("from"); s != "" {
+			if from, err = time.Parse(time.RFC3339, s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid from %q: %v", s, err)})
+				return
+			}
+		}
+		if from.After(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+			return
+		}
+
+		series, err := hist.Query(filter, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"series": series})
+	}
+}
+
+// silencesHandler lists the pending and active silences
+func silencesHandler(silences *notify.Silences) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"silences": silences.List()})
+	}
+}
+
+// createSilenceHandler creates a silence from the JSON body
+func createSilenceHandler(silences *notify.Silences) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var s config.Silence
+		if err := c.ShouldBindJSON(&s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		s, err := silences.Add(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"silence": s})
+	}
+}
+
+// expireSilenceHandler ends the silence named by the id parameter
+func expireSilenceHandler(silences *notify.Silences) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := silences.Expire(c.Param("id"))
+		switch {
+		case errors.Is(err, notify.ErrSilenceNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, notify.ErrFileSilence):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		default:
+			c.Status(http.StatusNoContent)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Outcomes of an admin API call
+const (
+	auditSuccess = "success"
+	auditDenied  = "denied"
+	auditFailure = "failure"
+)
+
+var adminActionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bdx_admin_actions_total",
+	Help: "Number of admin API calls by route and outcome",
+}, []string{"method", "route", "outcome"})
+
+// adminCallerKey is the key of the identity of the admin caller in the gin
+// context
+const adminCallerKey = "admin_caller"
+
+// auditEntry is a line of the audit log
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id"`
+	Caller     string    `json:"caller"`
+	RemoteAddr string    `json:"remote_addr"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Outcome    string    `json:"outcome"`
+	Duration   float64   `json:"duration_seconds"`
+}
+
+// auditLog records the admin API calls, one JSON object per line, to w or
+// to the log when w is nil
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// write records an entry of the audit log
+func (a *auditLog) write(entry auditEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode audit entry: %v", err)
+		return
+	}
+	if a.w == nil {
+		log.Printf("[audit] %s", data)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.w.Write(append(data, '\n')); err != nil {
+		log.Printf("Failed to write audit entry: %v", err)
+	}
+}
+
+// audit records every admin API call with its caller and outcome, including
+// the ones rejected by the allowlist or for a missing token
+func audit(a *auditLog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		outcome := auditSuccess
+		switch {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			outcome = auditDenied
+		case status >= http.StatusBadRequest:
+			outcome = auditFailure
+		}
+		caller := c.GetString(adminCallerKey)
+		if caller == "" {
+			caller = "anonymous"
+		}
+		path := c.Request.URL.Path
+		if c.Request.URL.RawQuery != "" {
+			path += "?" + c.Request.URL.RawQuery
+		}
+
+		adminActionsCounter.WithLabelValues(c.Request.Method, c.FullPath(), outcome).Inc()
+		a.write(auditEntry{
+			Time:       start,
+			RequestID:  c.GetString(requestIDKey),
+			Caller:     caller,
+			RemoteAddr: c.RemoteIP(),
+			UserAgent:  c.Request.UserAgent(),
+			Method:     c.Request.Method,
+			Path:       path,
+			Status:     status,
+			Outcome:    outcome,
+			Duration:   time.Since(start).Seconds(),
+		})
+	}
+}
+
+// tokenFingerprint identifies an admin token in the audit log without
+// revealing it
+func tokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:4])
+}
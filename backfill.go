@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/sink"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/tunnel"
+)
+
+// backfill reads the history of the TRH sensors from the trend pages of the
+// portal and writes it to a remote_write endpoint, so a new deployment
+// starts with the past readings
+func backfill(args []string) int {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	remoteWriteURL := fs.String("remote-write-url", "", "Remote write endpoint, e.g. http://prometheus:9090/api/v1/write (required)")
+	fromFlag := fs.String("from", "7d", "Start of the history, as an RFC 3339 time or a duration before -to such as 30d")
+	toFlag := fs.String("to", "", "End of the history as an RFC 3339 time, defaults to now")
+	chunk := fs.Duration("chunk", 24*time.Hour, "Length of the history read from the portal and written in a single request")
+	timezone := fs.String("timezone", "Local", "Time zone of the times of the trend pages, e.g. Asia/Jakarta")
+	extra := labelFlags{}
+	fs.Var(extra, "label", "Label added to every series as name=value, may be repeated, e.g. job=bdx to match the scrape configuration")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	if *remoteWriteURL == "" {
+		fmt.Fprintln(os.Stderr, "-remote-write-url is required")
+		return 1
+	}
+	if *chunk <= 0 {
+		fmt.Fprintln(os.Stderr, "-chunk must be greater than zero")
+		return 1
+	}
+	loc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -timezone %q: %v\n", *timezone, err)
+		return 1
+	}
+	from, to, err := backfillRange(*fromFlag, *toFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	writer, err := sink.NewRemoteWriter(*remoteWriteURL, cfg.HTTPTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	stopTunnel, err := tunnel.Start(context.Background(), cfg.Tunnel, cfg.HTTPTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start SSH tunnel: %v\n", err)
+		return 1
+	}
+	defer stopTunnel()
+
+	ctx := context.Background()
+	col := collector.NewCollector(cfg)
+	samples, failures := 0, 0
+	for _, target := range cfg.TRHTargets {
+		sensors, err := col.TRHSensors(ctx, target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list the sensors of %s: %v\n", target.URL, err)
+			failures++
+			continue
+		}
+		for _, sensor := range sensors {
+			labels := col.TRHSeriesLabels(target, sensor)
+			maps.Copy(labels, extra)
+			for start := from; start.Before(to); start = start.Add(*chunk) {
+				end := start.Add(*chunk)
+				if end.After(to) {
+					end = to
+				}
+				points, err := col.TRHTrend(ctx, target, sensor, start, end, loc)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to read the history of %s from %s to %s: %v\n", sensor, start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+					failures++
+					continue
+				}
+				series := trendSeries(cfg, labels, points)
+				if len(series) == 0 {
+					continue
+				}
+				if err := writer.Write(ctx, series); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to write the history of %s from %s to %s: %v\n", sensor, start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+					failures++
+					continue
+				}
+				for _, s := range series {
+					samples += len(s.Samples)
+				}
+			}
+		}
+	}
+
+	fmt.Printf("Wrote %d samples from %s to %s\n", samples, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	if failures > 0 {
+		fmt.Fprintf(os.Stderr, "%d requests failed\n", failures)
+		return 1
+	}
+	return 0
+}
+
+// backfillRange parses the -from and -to flags of backfill
+func backfillRange(fromFlag, toFlag string) (time.Time, time.Time, error) {
+	to := time.Now()
+	if toFlag != "" {
+		t, err := time.Parse(time.RFC3339, toFlag)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid -to %q: %w", toFlag, err)
+		}
+		to = t
+	}
+	from, err := time.Parse(time.RFC3339, fromFlag)
+	if err != nil {
+		d, derr := model.ParseDuration(fromFlag)
+		if derr != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid -from %q: must be an RFC 3339 time or a duration", fromFlag)
+		}
+		from = to.Add(-time.Duration(d))
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("-from must be before -to")
+	}
+	return from, to, nil
+}
+
+// trendSeries turns the readings of a sensor into the series the exporter
+// would have exported, in the configured temperature units
+func trendSeries(cfg *config.Config, labels map[string]string, points []collector.TrendPoint) []sink.TimeSeries {
+	var temperature, fahrenheit, humidity, up []sink.Sample
+	for _, p := range points {
+		if !p.TempOK || !p.RHOK {
+			up = append(up, sink.Sample{Time: p.Time, Value: 0})
+			continue
+		}
+		up = append(up, sink.Sample{Time: p.Time, Value: 1})
+		if cfg.Temperature.Celsius() {
+			temperature = append(temperature, sink.Sample{Time: p.Time, Value: p.Temp})
+		}
+		if cfg.Temperature.Fahrenheit() {
+			fahrenheit = append(fahrenheit, sink.Sample{Time: p.Time, Value: collector.Fahrenheit(p.Temp)})
+		}
+		humidity = append(humidity, sink.Sample{Time: p.Time, Value: p.RH})
+	}
+
+	var series []sink.TimeSeries
+	for _, s := range []struct {
+		name    string
+		samples []sink.Sample
+	}{
+		{"bdx_temperature", temperature},
+		{"bdx_temperature_fahrenheit", fahrenheit},
+		{"bdx_humidity", humidity},
+		{"bdx_sensor_up", up},
+	} {
+		if len(s.samples) == 0 {
+			continue
+		}
+		seriesLabels := maps.Clone(labels)
+		seriesLabels["__name__"] = s.name
+		series = append(series, sink.TimeSeries{Labels: seriesLabels, Samples: s.samples})
+	}
+	return series
+}
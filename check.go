@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/checkmk"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/tunnel"
+)
+
+// Nagios plugin exit codes
+const (
+	nagiosOK = iota
+	nagiosWarning
+	nagiosCritical
+	nagiosUnknown
+)
+
+var nagiosStatus = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// nagiosRange is a threshold range in the Nagios plugin format: 10 alerts
+// outside 0..10, 10: below 10, ~:10 above 10, 10:20 outside 10..20 and
+// @10:20 inside 10..20
+type nagiosRange struct {
+	start, end float64
+	inside     bool
+}
+
+// parseNagiosRange parses a threshold range
+func parseNagiosRange(s string) (nagiosRange, error) {
+	r := nagiosRange{start: 0, end: math.Inf(1)}
+	spec := s
+	if strings.HasPrefix(spec, "@") {
+		r.inside = true
+		spec = spec[1:]
+	}
+
+	start, end, hasColon := strings.Cut(spec, ":")
+	if !hasColon {
+		start, end = "", spec
+	}
+	var err error
+	if start == "~" {
+		r.start = math.Inf(-1)
+	} else if start != "" {
+		if r.start, err = strconv.ParseFloat(start, 64); err != nil {
+			return r, fmt.Errorf("invalid range %q: %w", s, err)
+		}
+	}
+	if end != "" {
+		if r.end, err = strconv.ParseFloat(end, 64); err != nil {
+			return r, fmt.Errorf("invalid range %q: %w", s, err)
+		}
+	} else if !hasColon {
+		return r, fmt.Errorf("invalid range %q", s)
+	}
+	if r.start > r.end {
+		return r, fmt.Errorf("invalid range %q: start is greater than end", s)
+	}
+	return r, nil
+}
+
+// alert reports whether the value is outside the range, or inside it for @
+// ranges
+func (r nagiosRange) alert(v float64) bool {
+	within := v >= r.start && v <= r.end
+	return within == r.inside
+}
+
+// labelFlags collects repeated -label name=value flags
+type labelFlags map[string]string
+
+func (l labelFlags) String() string {
+	return formatLabelPairs(l)
+}
+
+func (l labelFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("label must be name=value, got %q", s)
+	}
+	l[name] = value
+	return nil
+}
+
+// check collects once, or reads the values of a running exporter, and
+// reports the values of a metric against thresholds as a Nagios plugin
+func check(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	filter := collector.ValueFilter{Labels: labelFlags{}}
+	fs.StringVar(&filter.Metric, "metric", "", "Metric to check, e.g. bdx_liquid (required)")
+	fs.StringVar(&filter.Target, "target", "", "Sensor, CDU or rack name")
+	fs.StringVar(&filter.Source, "source", "", "Source of the metric: trh, cdu or liquid")
+	fs.Var(labelFlags(filter.Labels), "label", "Label filter as name=value, may be repeated")
+	warnFlag := fs.String("warn", "", "Warning threshold range, e.g. 10:30")
+	critFlag := fs.String("crit", "", "Critical threshold range, e.g. 5:35")
+	exporterURL := fs.String("exporter-url", "", "Read the values of a running exporter, e.g. http://localhost:8080, instead of collecting")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return checkResult(nagiosUnknown, fmt.Sprintf("failed to load config: %v", err), "")
+	}
+	if filter.Metric == "" {
+		return checkResult(nagiosUnknown, "-metric is required", "")
+	}
+	var warn, crit *nagiosRange
+	for _, t := range []struct {
+		spec  string
+		dst   **nagiosRange
+		label string
+	}{{*warnFlag, &warn, "-warn"}, {*critFlag, &crit, "-crit"}} {
+		if t.spec == "" {
+			continue
+		}
+		r, err := parseNagiosRange(t.spec)
+		if err != nil {
+			return checkResult(nagiosUnknown, fmt.Sprintf("%s: %v", t.label, err), "")
+		}
+		*t.dst = &r
+	}
+
+	var values []collector.Value
+	if *exporterURL != "" {
+		values, err = fetchValues(*exporterURL, filter, cfg.HTTPTimeout)
+	} else {
+		stopTunnel, err := tunnel.Start(context.Background(), cfg.Tunnel, cfg.HTTPTimeout)
+		if err != nil {
+			return checkResult(nagiosUnknown, fmt.Sprintf("failed to start SSH tunnel: %v", err), "")
+		}
+		defer stopTunnel()
+
+		col := collector.NewCollector(cfg)
+		if err := col.Discover(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to discover CDU targets: %v\n", err)
+		}
+		col.Collect(context.Background())
+		values, err = col.Values(filter)
+	}
+	if err != nil {
+		return checkResult(nagiosUnknown, err.Error(), "")
+	}
+	if len(values) == 0 {
+		return checkResult(nagiosUnknown, fmt.Sprintf("no %s values found", filter.Metric), "")
+	}
+
+	status := nagiosOK
+	var alerts, perfdata []string
+	for _, v := range values {
+		label := valueLabel(v)
+		valueStatus := nagiosOK
+		if crit != nil && crit.alert(v.Value) {
+			valueStatus = nagiosCritical
+		} else if warn != nil && warn.alert(v.Value) {
+			valueStatus = nagiosWarning
+		}
+		if valueStatus != nagiosOK {
+			alerts = append(alerts, fmt.Sprintf("%s = %g (%s)", label, v.Value, strings.ToLower(nagiosStatus[valueStatus])))
+		}
+		status = max(status, valueStatus)
+
+		label = strings.ReplaceAll(strings.ReplaceAll(label, "'", "''"), "=", "_")
+		perfdata = append(perfdata, fmt.Sprintf("'%s'=%g;%s;%s;;", label, v.Value, *warnFlag, *critFlag))
+	}
+
+	var message string
+	switch {
+	case len(values) == 1:
+		message = fmt.Sprintf("%s %s = %g", filter.Metric, valueLabel(values[0]), values[0].Value)
+	case len(alerts) > 0:
+		message = fmt.Sprintf("%d of %d %s values outside thresholds: %s", len(alerts), len(values), filter.Metric, strings.Join(alerts, ", "))
+	default:
+		message = fmt.Sprintf("%d %s values within thresholds", len(values), filter.Metric)
+	}
+	return checkResult(status, message, strings.Join(perfdata, " "))
+}
+
+// checkmkLocal collects once and prints the Checkmk local checks, to run the
+// exporter as a local check of a Checkmk agent
+func checkmkLocal(args []string) int {
+	fs := flag.NewFlagSet("checkmk", flag.ExitOnError)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		fmt.Printf("3 \"BDX Exporter\" - Failed to load config: %v\n", err)
+		return 1
+	}
+
+	stopTunnel, err := tunnel.Start(context.Background(), cfg.Tunnel, cfg.HTTPTimeout)
+	if err != nil {
+		fmt.Printf("3 \"BDX Exporter\" - Failed to start SSH tunnel: %v\n", err)
+		return 1
+	}
+	defer stopTunnel()
+
+	col := collector.NewCollector(cfg)
+	if err := col.Discover(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to discover CDU targets: %v\n", err)
+	}
+	col.Collect(context.Background())
+	values, err := col.Values(collector.ValueFilter{})
+	if err != nil {
+		fmt.Printf("3 \"BDX Exporter\" - Failed to read values: %v\n", err)
+		return 1
+	}
+	for _, line := range checkmk.LocalChecks(cfg.Checkmk.Levels, values, col.Targets()) {
+		fmt.Println(line)
+	}
+	return 0
+}
+
+// checkResult prints the plugin output and returns the status as exit code
+func checkResult(status int, message, perfdata string) int {
+	if perfdata != "" {
+		message += " | " + perfdata
+	}
+	fmt.Printf("BDX %s - %s\n", nagiosStatus[status], message)
+	return status
+}
+
+// fetchValues reads the matching values from /api/v1/values of an exporter
+func fetchValues(base string, filter collector.ValueFilter, timeout time.Duration) ([]collector.Value, error) {
+	query := url.Values{}
+	query.Set("metric", filter.Metric)
+	if filter.Target != "" {
+		query.Set("target", filter.Target)
+	}
+	if filter.Source != "" {
+		query.Set("source", filter.Source)
+	}
+	for name, value := range filter.Labels {
+		query.Add("label", name+"="+value)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(strings.TrimSuffix(base, "/") + "/api/v1/values?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read values: %s", resp.Status)
+	}
+
+	var body struct {
+		Values []collector.Value `json:"values"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode values: %w", err)
+	}
+	return body.Values, nil
+}
+
+// valueLabel names a value by its target and the label telling the readings
+// of a target apart, such as "CDU_1.1 tcs_temp_sup" or "CDU_1.1 Pump 1 Speed"
+func valueLabel(v collector.Value) string {
+	parts := []string{}
+	if v.Target != "" {
+		parts = append(parts, v.Target)
+	}
+	if item := v.Labels["item"]; item != "" {
+		parts = append(parts, item)
+	} else if typ := v.Labels["type"]; typ != "" {
+		parts = append(parts, typ)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatLabelPairs formats labels as name=value pairs sorted by name
+func formatLabelPairs(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
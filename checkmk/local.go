@@ -0,0 +1,191 @@
+package checkmk
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// Service states
+const (
+	stateOK = iota
+	stateWarn
+	stateCrit
+	stateUnknown
+)
+
+// stateMarkers flag the state of a part of a summary, as Checkmk checks do
+var stateMarkers = [...]string{"", "(!)", "(!!)", "(?)"}
+
+// sourceNames names the sources in service names
+var sourceNames = map[string]string{
+	"trh":    "TRH",
+	"cdu":    "CDU",
+	"liquid": "Liquid",
+}
+
+// service is a local check being built
+type service struct {
+	name     string
+	state    int
+	metrics  []string
+	used     map[string]bool
+	problems []string
+	readings int
+}
+
+// LocalChecks returns the Checkmk local check lines for the readings and the
+// scrape targets, sorted by service name. Every target of the readings is a
+// service "BDX <source> <target>" with its readings as metrics, critical
+// while a CDU alarm is active and otherwise in the worst state of its
+// readings against the levels. Every scrape target is a service "BDX Scrape
+// <source>", critical while its last scrape failed.
+func LocalChecks(levels []config.CheckmkLevels, values []collector.Value, targets []collector.TargetStatus) []string {
+	services := make(map[string]*service)
+	get := func(name string) *service {
+		s, ok := services[name]
+		if !ok {
+			s = &service{name: name, used: make(map[string]bool)}
+			services[name] = s
+		}
+		return s
+	}
+
+	for _, v := range values {
+		s := get(serviceName("BDX", v.Source, v.Target))
+		if v.Metric == "bdx_cdu" && v.Labels["type"] == "alarm" {
+			s.state = max(s.state, stateCrit)
+			s.problems = append(s.problems, fmt.Sprintf("alarm %s: %s%s", v.Labels["item"], v.Labels["status"], stateMarkers[stateCrit]))
+			continue
+		}
+		s.readings++
+		s.add(v, levels)
+	}
+
+	for _, t := range targets {
+		s := get(serviceName("BDX Scrape", t.Source, t.Name))
+		switch {
+		case t.Paused:
+			s.problems = append(s.problems, "Collection is paused")
+		case t.Health == "up":
+			s.problems = append(s.problems, fmt.Sprintf("Last scrape took %.1fs", t.LastDuration))
+			s.metrics = append(s.metrics, fmt.Sprintf("duration=%g", t.LastDuration))
+		case t.Health == "down":
+			s.state = stateCrit
+			s.problems = append(s.problems, fmt.Sprintf("Last scrape failed: %s%s", t.LastError, stateMarkers[stateCrit]))
+		default:
+			s.state = stateUnknown
+			s.problems = append(s.problems, "Not scraped yet")
+		}
+	}
+
+	lines := make([]string, 0, len(services))
+	for _, s := range services {
+		lines = append(lines, s.line())
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return strings.SplitN(lines[i], " ", 2)[1] < strings.SplitN(lines[j], " ", 2)[1]
+	})
+	return lines
+}
+
+// add adds a reading to the metrics of the service and checks it against
+// the first levels selecting it
+func (s *service) add(v collector.Value, levels []config.CheckmkLevels) {
+	name := metricName(v)
+	for n := 2; s.used[name]; n++ {
+		name = fmt.Sprintf("%s_%d", metricName(v), n)
+	}
+	s.used[name] = true
+
+	if math.IsNaN(v.Value) || math.IsInf(v.Value, 0) {
+		return
+	}
+	perf := fmt.Sprintf("%s=%g", name, v.Value)
+
+	for _, l := range levels {
+		if !(collector.ValueFilter{Metric: l.Metric, Labels: l.Labels}).Matches(v) {
+			continue
+		}
+		state, level := stateOK, ""
+		if len(l.Upper) == 2 {
+			perf += fmt.Sprintf(";%g;%g", l.Upper[0], l.Upper[1])
+			switch {
+			case v.Value >= l.Upper[1]:
+				state, level = stateCrit, fmt.Sprintf("crit at %g", l.Upper[1])
+			case v.Value >= l.Upper[0]:
+				state, level = stateWarn, fmt.Sprintf("warn at %g", l.Upper[0])
+			}
+		}
+		if len(l.Lower) == 2 && state == stateOK {
+			switch {
+			case v.Value <= l.Lower[1]:
+				state, level = stateCrit, fmt.Sprintf("crit below %g", l.Lower[1])
+			case v.Value <= l.Lower[0]:
+				state, level = stateWarn, fmt.Sprintf("warn below %g", l.Lower[0])
+			}
+		}
+		if state != stateOK {
+			s.state = max(s.state, state)
+			s.problems = append(s.problems, fmt.Sprintf("%s %g (%s)%s", name, v.Value, level, stateMarkers[state]))
+		}
+		break
+	}
+	s.metrics = append(s.metrics, perf)
+}
+
+// line formats the service as a local check line
+func (s *service) line() string {
+	metrics := "-"
+	if len(s.metrics) > 0 {
+		metrics = strings.Join(s.metrics, "|")
+	}
+	summary := strings.Join(s.problems, ", ")
+	if summary == "" {
+		summary = fmt.Sprintf("%d readings OK", s.readings)
+	}
+	summary = strings.NewReplacer("\r", " ", "\n", " ").Replace(summary)
+	return fmt.Sprintf("%d \"%s\" %s %s", s.state, s.name, metrics, summary)
+}
+
+// serviceName names the service of a source and target
+func serviceName(prefix, source, target string) string {
+	name := prefix
+	if n, ok := sourceNames[source]; ok {
+		name += " " + n
+	} else if source != "" {
+		name += " " + source
+	}
+	if target != "" {
+		name += " " + target
+	}
+	return strings.ReplaceAll(name, `"`, "'")
+}
+
+// metricName names a reading within its service after its item or type, such
+// as supply_temperature or tcs_temp_sup, or after the metric for sensors
+func metricName(v collector.Value) string {
+	name := strings.TrimPrefix(v.Metric, "bdx_")
+	if item := v.Labels["item"]; item != "" {
+		name = item
+	} else if typ := v.Labels["type"]; typ != "" {
+		name = typ
+		if v.Metric == "bdx_liquid_rack" && !strings.HasPrefix(typ, "rack_") {
+			name = "rack_" + typ
+		}
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+var (
+	aisleTemperatureGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_aisle_temperature",
+		Help: "Average temperature in Celsius of the TRH sensors of an aisle of a row",
+	}, []string{"row", "aisle"})
+
+	aisleDeltaGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_aisle_delta_temperature",
+		Help: "Average temperature of the hot aisle minus that of the cold aisle of a row, in Celsius",
+	}, []string{"row"})
+
+	aisleDeltaFahrenheitGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_aisle_delta_temperature_fahrenheit",
+		Help: "Average temperature of the hot aisle minus that of the cold aisle of a row, in Fahrenheit",
+	}, []string{"row"})
+)
+
+// aisleReadings collects the temperatures of a collection by row and aisle
+type aisleReadings map[string]map[string]*summary
+
+// add adds the temperature of a sensor to its row and aisle
+func (a aisleReadings) add(row, aisle string, temperature float64) {
+	if a[row] == nil {
+		a[row] = make(map[string]*summary)
+	}
+	if a[row][aisle] == nil {
+		a[row][aisle] = &summary{}
+	}
+	a[row][aisle].add(temperature)
+}
+
+// setAisleMetrics replaces the aisle metrics with the readings of the last
+// collection. The delta-T of a row needs both a hot and a cold aisle sensor.
+func setAisleMetrics(cfg *config.Config, aisles aisleReadings) {
+	aisleTemperatureGauge.Reset()
+	aisleDeltaGauge.Reset()
+	aisleDeltaFahrenheitGauge.Reset()
+
+	for row, byAisle := range aisles {
+		if cfg.Temperature.Celsius() {
+			for aisle, s := range byAisle {
+				aisleTemperatureGauge.WithLabelValues(row, aisle).Set(s.avg())
+			}
+		}
+		hot, cold := byAisle[config.AisleHot], byAisle[config.AisleCold]
+		if hot == nil || cold == nil {
+			continue
+		}
+		delta := hot.avg() - cold.avg()
+		if cfg.Temperature.Celsius() {
+			aisleDeltaGauge.WithLabelValues(row).Set(delta)
+		}
+		if cfg.Temperature.Fahrenheit() {
+			// A difference of temperatures scales without the offset
+			aisleDeltaFahrenheitGauge.WithLabelValues(row).Set(delta * 9 / 5)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+// Sources of the alarm events
+const (
+	// EventSourceHistory marks the events read from the alarm history tab of
+	// a CDU dashboard, rather than seen between two scrapes
+	EventSourceHistory = "history"
+)
+
+var alarmHistoryCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bdx_cdu_alarm_history_events_total",
+	Help: "Number of alarm events read from the alarm history of a CDU dashboard, by whether the alarm was raised or cleared",
+}, []string{"name", "state"})
+
+// alarmHistoryState is what was read from the alarm history of a CDU, so
+// the next scrape only records the entries added since
+type alarmHistoryState struct {
+	scraped time.Time
+	// newest is the time of the newest entry read and seen the entries at
+	// that time, which a later page may list again
+	newest time.Time
+	seen   map[scraper.AlarmHistoryEntry]bool
+}
+
+// collectAlarmHistory reads the alarm history of a CDU when it is due and
+// records the entries that are new since the previous read as events.
+// Failures are logged only, as the history doesn't make the CDU unavailable.
+func (c *Collector) collectAlarmHistory(cfg *config.Config, target config.CDUTarget, name string, browser scraper.Browser) {
+	if !cfg.AlarmHistory.Enabled() {
+		return
+	}
+	now := time.Now()
+	c.mu.Lock()
+	if c.alarmHistory == nil {
+		c.alarmHistory = make(map[string]*alarmHistoryState)
+	}
+	state, ok := c.alarmHistory[target.URL]
+	if !ok {
+		state = &alarmHistoryState{}
+		c.alarmHistory[target.URL] = state
+	}
+	if now.Sub(state.scraped) < cfg.AlarmHistory.Interval {
+		c.mu.Unlock()
+		return
+	}
+	// Set before scraping so a failing history is retried at the interval
+	// rather than every scrape
+	state.scraped = now
+	since := state.newest
+	c.mu.Unlock()
+
+	release, err := c.acquirePage(cfg, target.URL)
+	if err != nil {
+		c.logf("Failed to scrape the alarm history of %s: %v", name, err)
+		return
+	}
+	entries, err := scraper.ScrapeCDUAlarmHistory(target.URL, browser, cfg.SessMap, cfg.PHPSessID, cfg.ScrapeTimeout, scraper.AlarmHistoryOptions{
+		MaxPages: cfg.AlarmHistory.Pages,
+		Since:    since,
+		Location: cfg.AlarmHistory.Location(),
+	})
+	release()
+	if err != nil {
+		// Keep the entries of the pages read before the failure
+		c.logf("Failed to scrape the alarm history of %s: %v", name, err)
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var events []AlarmEvent
+	newest := state.newest
+	for _, entry := range entries {
+		if entry.Time.Before(state.newest) || state.seen[entry] {
+			continue
+		}
+		if entry.Time.After(newest) {
+			newest = entry.Time
+		}
+		// A history without a raised or cleared column lists the alarms
+		// that occurred
+		if entry.State == "" {
+			entry.State = "raised"
+		}
+		events = append(events, AlarmEvent{Time: entry.Time, Target: name, Item: entry.Item, Status: entry.Status, State: entry.State, Source: EventSourceHistory})
+		alarmHistoryCounter.WithLabelValues(name, entry.State).Inc()
+	}
+
+	if newest.After(state.newest) {
+		state.seen = make(map[scraper.AlarmHistoryEntry]bool)
+	}
+	for _, entry := range entries {
+		if entry.Time.Equal(newest) {
+			state.seen[entry] = true
+		}
+	}
+	state.newest = newest
+
+	if len(events) == 0 {
+		return
+	}
+	c.logf("Read %d alarm events from the history of %s", len(events), name)
+	// The histories of the CDUs are read at different times, keep the
+	// buffer in time order
+	c.histEvents = append(c.histEvents, events...)
+	sort.SliceStable(c.histEvents, func(i, j int) bool { return c.histEvents[i].Time.Before(c.histEvents[j].Time) })
+	if size := cfg.EventBufferSize; len(c.histEvents) > size {
+		c.histEvents = append([]AlarmEvent(nil), c.histEvents[len(c.histEvents)-size:]...)
+	}
+}
+
+// HistoryEvents returns the events read from the alarm history of the target
+// after since, oldest first. An empty target returns the events of every
+// target. Unlike Events, their times are the ones of the portal, so they are
+// not meant to be followed with a cursor.
+func (c *Collector) HistoryEvents(target string, since time.Time) []AlarmEvent {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	events := []AlarmEvent{}
+	for _, e := range c.histEvents {
+		if (target == "" || e.Target == target) && e.Time.After(since) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
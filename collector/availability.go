@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"sort"
+	"time"
+)
+
+// availabilityKey identifies a sensor, CDU or liquid cooling CDU
+type availabilityKey struct {
+	source, name string
+}
+
+// availabilityBucket counts the collections of one hour
+type availabilityBucket struct {
+	start      time.Time
+	samples    int
+	successful int
+}
+
+// Availability is the share of collections that returned data for a sensor
+// or CDU
+type Availability struct {
+	Source     string  `json:"source"`
+	Name       string  `json:"name"`
+	Samples    int     `json:"samples"`
+	Successful int     `json:"successful"`
+	Percent    float64 `json:"availability_percent"`
+}
+
+// recordAvailable records whether a collection returned data for a sensor or
+// CDU, and drops the counts that are older than the retention
+func (c *Collector) recordAvailable(source, name string, ok bool) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.availability == nil {
+		c.availability = make(map[availabilityKey][]availabilityBucket)
+	}
+
+	key := availabilityKey{source, name}
+	buckets := c.availability[key]
+	hour := now.Truncate(time.Hour)
+	if len(buckets) == 0 || !buckets[len(buckets)-1].start.Equal(hour) {
+		buckets = append(buckets, availabilityBucket{start: hour})
+	}
+	buckets[len(buckets)-1].samples++
+	if ok {
+		buckets[len(buckets)-1].successful++
+	}
+
+	cutoff := now.Add(-c.config.AvailabilityRetention)
+	for len(buckets) > 0 && buckets[0].start.Add(time.Hour).Before(cutoff) {
+		buckets = buckets[1:]
+	}
+	c.availability[key] = buckets
+}
+
+// recordSourceAvailable records the sensors or CDUs a collection of a whole
+// source returned. The ones seen before that are missing count as
+// unavailable, so a failed collection counts against all of them.
+func (c *Collector) recordSourceAvailable(source string, available map[string]bool) {
+	c.mu.RLock()
+	var missing []string
+	for key := range c.availability {
+		if _, ok := available[key.name]; key.source == source && !ok {
+			missing = append(missing, key.name)
+		}
+	}
+	c.mu.RUnlock()
+
+	for name, ok := range available {
+		c.recordAvailable(source, name, ok)
+	}
+	for _, name := range missing {
+		c.recordAvailable(source, name, false)
+	}
+}
+
+// Availability returns the availability of every sensor and CDU over the
+// window, with hourly resolution. A non-empty source or name selects them.
+func (c *Collector) Availability(window time.Duration, source, name string) []Availability {
+	cutoff := time.Now().Add(-window).Truncate(time.Hour)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := []Availability{}
+	for key, buckets := range c.availability {
+		if (source != "" && key.source != source) || (name != "" && key.name != name) {
+			continue
+		}
+		a := Availability{Source: key.source, Name: key.name}
+		for _, b := range buckets {
+			if !b.start.Before(cutoff) {
+				a.Samples += b.samples
+				a.Successful += b.successful
+			}
+		}
+		if a.Samples == 0 {
+			continue
+		}
+		a.Percent = 100 * float64(a.Successful) / float64(a.Samples)
+		result = append(result, a)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Source != result[j].Source {
+			return result[i].Source < result[j].Source
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+var (
+	browserContextsDesc = prometheus.NewDesc("bdx_browser_contexts",
+		"Number of browser contexts currently open by scrapes", nil, nil)
+	browserProcessesDesc = prometheus.NewDesc("bdx_browser_processes",
+		"Number of live browser processes started by the exporter", nil, nil)
+	browserMemoryDesc = prometheus.NewDesc("bdx_browser_memory_bytes",
+		"Resident memory of the live browser processes", nil, nil)
+	browserCPUDesc = prometheus.NewDesc("bdx_browser_cpu_seconds_total",
+		"CPU time spent by the browser processes, including the ones that exited", nil, nil)
+)
+
+func init() {
+	prometheus.MustRegister(browserCollector{})
+}
+
+// browserStats is the resource usage of the browser processes
+type browserStats struct {
+	pids      []int
+	processes int
+	memory    float64
+	cpu       float64
+}
+
+// browserCollector reports the resource usage of the Chrome processes the
+// scrapes start, read when the metrics are gathered
+type browserCollector struct{}
+
+func (browserCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- browserContextsDesc
+	ch <- browserProcessesDesc
+	ch <- browserMemoryDesc
+	ch <- browserCPUDesc
+}
+
+func (browserCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(browserContextsDesc, prometheus.GaugeValue, float64(scraper.BrowserContexts()))
+
+	// Process usage is only available on Linux
+	stats, ok := readBrowserStats()
+	if !ok {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(browserProcessesDesc, prometheus.GaugeValue, float64(stats.processes))
+	ch <- prometheus.MustNewConstMetric(browserMemoryDesc, prometheus.GaugeValue, stats.memory)
+	ch <- prometheus.MustNewConstMetric(browserCPUDesc, prometheus.CounterValue, stats.cpu)
+}
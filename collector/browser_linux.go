@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// clockTicks is the unit of the CPU times in /proc, USER_HZ
+const clockTicks = 100
+
+// readBrowserStats sums the usage of the descendant processes of the
+// exporter, which are the browsers of the scrapes, from /proc. The CPU time
+// of the exited ones is taken from the resource usage of the waited-for
+// children.
+func readBrowserStats() (browserStats, bool) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return browserStats{}, false
+	}
+
+	type process struct {
+		ppid     int
+		rssPages int64
+		cpuTicks int64
+	}
+	processes := make(map[int]process)
+	children := make(map[int][]int)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile("/proc/" + e.Name() + "/stat")
+		if err != nil {
+			continue
+		}
+		// The command name in parentheses may contain spaces
+		i := strings.LastIndexByte(string(data), ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(data[i+1:]))
+		if len(fields) < 22 || fields[0] == "Z" {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		utime, _ := strconv.ParseInt(fields[11], 10, 64)
+		stime, _ := strconv.ParseInt(fields[12], 10, 64)
+		rss, _ := strconv.ParseInt(fields[21], 10, 64)
+		processes[pid] = process{ppid: ppid, rssPages: rss, cpuTicks: utime + stime}
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	var stats browserStats
+	var rusage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_CHILDREN, &rusage); err == nil {
+		stats.cpu = float64(rusage.Utime.Nano()+rusage.Stime.Nano()) / 1e9
+	}
+
+	pageSize := int64(os.Getpagesize())
+	queue := children[os.Getpid()]
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = append(queue[1:], children[pid]...)
+		p := processes[pid]
+		stats.pids = append(stats.pids, pid)
+		stats.processes++
+		stats.memory += float64(p.rssPages * pageSize)
+		stats.cpu += float64(p.cpuTicks) / clockTicks
+	}
+	return stats, true
+}
+
+// killBrowsers kills the browser processes still running and returns how
+// many there were
+func killBrowsers() int {
+	stats, ok := readBrowserStats()
+	if !ok {
+		return 0
+	}
+	for _, pid := range stats.pids {
+		syscall.Kill(pid, syscall.SIGKILL)
+	}
+	return len(stats.pids)
+}
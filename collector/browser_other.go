@@ -0,0 +1,13 @@
+//go:build !linux
+
+package collector
+
+// readBrowserStats is not supported outside Linux
+func readBrowserStats() (browserStats, bool) {
+	return browserStats{}, false
+}
+
+// killBrowsers is not supported outside Linux
+func killBrowsers() int {
+	return 0
+}
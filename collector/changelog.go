@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+// logValues logs a line of collected values. In the changes log mode the
+// line is only logged when one of the values moved by at least the change
+// delta since the line was last logged, so slow drifts are still logged
+// once they add up.
+func (c *Collector) logValues(cfg *config.Config, key string, values []float64, format string, args ...any) {
+	if cfg.Logging.Mode == config.LogModeChanges && !c.valuesChanged(key, values, cfg.Logging.ChangeDelta) {
+		return
+	}
+	c.logf(format, args...)
+}
+
+// valuesChanged reports whether the values of a line moved by at least delta
+// since it was last logged, and remembers them if so
+func (c *Collector) valuesChanged(key string, values []float64, delta float64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last, ok := c.loggedValues[key]
+	if ok && len(last) == len(values) {
+		changed := false
+		for i, v := range values {
+			if d := math.Abs(v - last[i]); d >= delta && d > 0 {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return false
+		}
+	}
+	if c.loggedValues == nil {
+		c.loggedValues = make(map[string][]float64)
+	}
+	c.loggedValues[key] = slices.Clone(values)
+	return true
+}
+
+// logAlarms logs the alarms reported for a CDU. In the changes log mode only
+// the alarms raised since the previous scrape of the CDU are logged, along
+// with the ones cleared.
+func (c *Collector) logAlarms(cfg *config.Config, name string, alarms []scraper.CDUAlarm) {
+	if cfg.Logging.Mode != config.LogModeChanges {
+		for _, alarm := range alarms {
+			c.logf("CDU Alarm - %s (%s): %s (%s)", name, alarm.Item, alarm.Status, alarm.Status)
+		}
+		return
+	}
+
+	// Acknowledging an alarm neither raises nor clears it
+	current := make(map[scraper.CDUAlarm]bool, len(alarms))
+	for _, alarm := range alarms {
+		current[scraper.CDUAlarm{Item: alarm.Item, Status: alarm.Status}] = true
+	}
+	c.mu.Lock()
+	previous, seen := c.loggedAlarms[name]
+	if c.loggedAlarms == nil {
+		c.loggedAlarms = make(map[string]map[scraper.CDUAlarm]bool)
+	}
+	c.loggedAlarms[name] = current
+	c.mu.Unlock()
+
+	for _, alarm := range alarms {
+		if !previous[scraper.CDUAlarm{Item: alarm.Item, Status: alarm.Status}] {
+			c.logf("CDU Alarm raised - %s (%s): %s", name, alarm.Item, alarm.Status)
+		}
+	}
+	if !seen {
+		return
+	}
+	var cleared []scraper.CDUAlarm
+	for alarm := range previous {
+		if !current[alarm] {
+			cleared = append(cleared, alarm)
+		}
+	}
+	slices.SortFunc(cleared, func(a, b scraper.CDUAlarm) int {
+		return cmp.Or(strings.Compare(a.Item, b.Item), strings.Compare(a.Status, b.Status))
+	})
+	for _, alarm := range cleared {
+		c.logf("CDU Alarm cleared - %s (%s): %s", name, alarm.Item, alarm.Status)
+	}
+}
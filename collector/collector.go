@@ -1,13 +1,15 @@
 package collector
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,30 +20,15 @@ import (
 )
 
 var (
-	temperatureGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "bdx_temperature",
-		Help: "Current temperature reading in Celsius",
-	}, []string{"name"})
-
-	humidityGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "bdx_humidity",
-		Help: "Current relative humidity percentage",
-	}, []string{"name"})
-
-	cduGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "bdx_cdu",
-		Help: "CDU metrics including alarms and parameters",
-	}, []string{"name", "type", "item", "status", "metrix_type"})
-
-	liquidGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "bdx_liquid",
-		Help: "Liquid cooling CDU metrics",
-	}, []string{"name", "type", "metrix_type"})
-
-	liquidRackGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "bdx_liquid_rack",
-		Help: "Liquid cooling rack metrics",
-	}, []string{"name", "type", "metrix_type"})
+	maintenanceGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_maintenance_active",
+		Help: "CDU targets currently in a planned maintenance window",
+	}, []string{"name", "cabinet_id", "window"})
+
+	alarmAcknowledgedGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu_alarm_acknowledged",
+		Help: "Whether an active CDU alarm was acknowledged on the portal (1) or not (0)",
+	}, []string{"name", "cabinet_id", "item", "status"})
 )
 
 // SensorData represents the sensor data from the API
@@ -55,16 +42,60 @@ type SensorData struct {
 type Collector struct {
 	config       *config.Config
 	client       *http.Client
+	limiter      *hostLimiter
+	cduGauge     *prometheus.GaugeVec
+	cduLabels    []string
+	trhGauges    trhGauges
+	trhLabels    []string
+	liquidGauges liquidGauges
+	paramGauges  parameterGauges
+	inventory    map[inventoryKey]*InventoryItem
+	targets      []config.CDUTarget
+	discovered   map[string]string
 	lastCollect  time.Time
 	lastSuccess  bool
+	parsedCDU    map[string]ParsedCDU
+	parsedLiquid ParsedLiquid
+	paused       map[string]bool
+	scrapes      map[string]scrapeResult
+	lastCycle    time.Time
+	alarms       map[alarmKey]bool
+	alarmStates  map[alarmKey]*alarmState
+	events       []AlarmEvent
+	alarmHistory map[string]*alarmHistoryState
+	// histEvents are the events read from the alarm histories, kept
+	// apart from events as they are not transitions seen by this exporter
+	histEvents   []AlarmEvent
+	ruleStates   map[string]*ruleState
+	ruleEvents   []RuleEvent
+	availability map[availabilityKey][]availabilityBucket
+	cduNames     map[string]string
+	loggedValues map[string][]float64
+	loggedAlarms map[string]map[scraper.CDUAlarm]bool
+	subscribers  map[chan struct{}]struct{}
+	cycleID      string
+	busySince    time.Time
+	ready        map[string]bool
+	standby      bool
+	cycle        sync.Mutex
 	mu           sync.RWMutex
 }
 
+// errNoReading is returned by parseValue for a sensor that reports no
+// reading, such as a disconnected probe
+var errNoReading = errors.New("no reading")
+
 // parseValue converts interface{} to float64, handling string and float64 types
 func parseValue(v interface{}) (float64, error) {
 	switch val := v.(type) {
+	case nil:
+		return 0, errNoReading
 	case string:
-		return strconv.ParseFloat(val, 64)
+		switch strings.ToUpper(strings.TrimSpace(val)) {
+		case "", "N/A", "NA", "-", "--":
+			return 0, errNoReading
+		}
+		return strconv.ParseFloat(strings.TrimSpace(val), 64)
 	case float64:
 		return val, nil
 	default:
@@ -74,40 +105,184 @@ func parseValue(v interface{}) (float64, error) {
 
 // NewCollector creates a new collector
 func NewCollector(cfg *config.Config) *Collector {
-	return &Collector{
-		config: cfg,
-		client: &http.Client{Timeout: cfg.HTTPTimeout},
+	cduLabels := cduLabelNames(cfg)
+	trhLabels := cfg.TRHLabelNames()
+	liquidLabels := cfg.RuleLabelNames(config.LabelRuleCDU)
+	rackLabels := cfg.RuleLabelNames(config.LabelRuleRack)
+	limiter := newHostLimiter()
+	c := &Collector{
+		config:       cfg,
+		client:       newHTTPClient(cfg, limiter),
+		limiter:      limiter,
+		cduGauge:     newCDUGauge(cduLabels),
+		cduLabels:    cduLabels,
+		trhGauges:    newTRHGauges(trhLabels),
+		trhLabels:    trhLabels,
+		liquidGauges: newLiquidGauges(liquidLabels, rackLabels),
+		paramGauges:  newParameterGauges(cfg.ParameterMetrics.Metrics, cduLabels),
+		targets:      cfg.CDUTargets,
+		discovered:   make(map[string]string),
+	}
+	prometheus.MustRegister(configuredCollector{c})
+	return c
+}
+
+// newCDUGauge creates the CDU metric. It carries the extra labels
+// configured on the targets, so its label set is only known once the
+// configuration is loaded.
+func newCDUGauge(extraLabels []string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu",
+		Help: "CDU metrics including alarms and parameters",
+	}, append([]string{"name", "cabinet_id", "type", "item", "status", "metrix_type"}, extraLabels...))
+}
+
+// cduLabelNames returns the extra labels of the CDU metric: the labels
+// configured on the targets and derived by the label rules, plus
+// in_maintenance when maintenance windows tag rather than suppress alarms
+func cduLabelNames(cfg *config.Config) []string {
+	labels := cfg.CDULabelNames()
+	for _, name := range cfg.RuleLabelNames(config.LabelRuleCDU) {
+		if !slices.Contains(labels, name) {
+			labels = append(labels, name)
+		}
+	}
+	slices.Sort(labels)
+	if cfg.Maintenance.Enabled() && cfg.Maintenance.Mode == config.MaintenanceTag {
+		labels = append(labels, "in_maintenance")
 	}
+	return labels
 }
 
-// Collect collects data from all sources
-func (c *Collector) Collect() {
-	log.Println("Starting data collection cycle")
+// ApplyConfig replaces the configuration of a running collector. A cycle
+// that is already in progress finishes with the previous configuration.
+func (c *Collector) ApplyConfig(cfg *config.Config) {
+	cduLabels := cduLabelNames(cfg)
+	trhLabels := cfg.TRHLabelNames()
+	liquidLabels := cfg.RuleLabelNames(config.LabelRuleCDU)
+	rackLabels := cfg.RuleLabelNames(config.LabelRuleRack)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !slices.Equal(cduLabels, c.cduLabels) {
+		c.cduGauge = newCDUGauge(cduLabels)
+		c.cduLabels = cduLabels
+	}
+	if !slices.Equal(trhLabels, c.trhLabels) {
+		c.trhGauges = newTRHGauges(trhLabels)
+		c.trhLabels = trhLabels
+	}
+	if !slices.Equal(liquidLabels, c.liquidGauges.cduLabels) || !slices.Equal(rackLabels, c.liquidGauges.rackLabels) {
+		c.liquidGauges = newLiquidGauges(liquidLabels, rackLabels)
+	}
+	if !c.paramGauges.matches(cfg.ParameterMetrics.Metrics, cduLabels) {
+		c.paramGauges = newParameterGauges(cfg.ParameterMetrics.Metrics, cduLabels)
+	}
+
+	// Rebuild the targets from the new configuration, keeping the ones found
+	// by discovery so they don't disappear until the next discovery run
+	targets := append([]config.CDUTarget(nil), cfg.CDUTargets...)
+	known := make(map[string]bool)
+	for _, t := range targets {
+		known[targetKey(t)] = true
+	}
+	for key, url := range c.discovered {
+		if known[key] {
+			delete(c.discovered, key)
+			continue
+		}
+		targets = append(targets, cfg.NewCDUTarget(url))
+	}
+
+	if len(c.events) > cfg.EventBufferSize {
+		c.events = append([]AlarmEvent(nil), c.events[len(c.events)-cfg.EventBufferSize:]...)
+	}
+	if len(c.histEvents) > cfg.EventBufferSize {
+		c.histEvents = append([]AlarmEvent(nil), c.histEvents[len(c.histEvents)-cfg.EventBufferSize:]...)
+	}
+
+	// In-flight requests finish on the connections of the old client,
+	// only its idle ones are closed
+	if c.client != nil {
+		c.client.CloseIdleConnections()
+	}
+	c.config = cfg
+	c.client = newHTTPClient(cfg, c.limiter)
+	c.targets = targets
+}
+
+// Config returns the configuration currently in use
+func (c *Collector) Config() *config.Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
 
+// settings returns the configuration and HTTP client currently in use
+func (c *Collector) settings() (*config.Config, *http.Client) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config, c.client
+}
+
+// Collect collects data from all sources. The cycle logs with the ID ctx
+// carries, see WithCycleID.
+func (c *Collector) Collect(ctx context.Context) {
+	c.cycle.Lock()
+	defer c.cycle.Unlock()
+	c.beginCycle(ctx)
+	defer c.endCycle()
+
+	if !c.IsLeader() {
+		c.logf("Standby replica, skipping cycle")
+		return
+	}
+	if len(c.PausedSources()) == len(Sources) {
+		c.logf("Collection is paused, skipping cycle")
+		return
+	}
+
+	c.logf("Starting data collection cycle")
+
+	c.mu.Lock()
+	c.lastCycle = time.Now()
+	c.mu.Unlock()
+
+	cfg, client := c.settings()
 	success := true
 
 	// Collect temperature and humidity
-	if err := c.collectTRH(); err != nil {
-		log.Printf("Failed to collect TRH data: %v", err)
+	if c.isPaused("trh") {
+		c.logf("TRH collection is paused, keeping previous data")
+	} else if err := c.collectTRH(cfg, client); err != nil {
+		c.logf("Failed to collect TRH data: %v", err)
 		success = false
 	} else {
-		log.Println("Successfully collected TRH data")
+		c.logf("Successfully collected TRH data")
+		c.markReady("trh")
 	}
 
 	// Collect CDU data
-	if err := c.collectCDU(); err != nil {
-		log.Printf("Failed to collect CDU data: %v", err)
+	if c.isPaused("cdu") {
+		c.logf("CDU collection is paused, keeping previous data")
+	} else if err := c.collectCDU(cfg); err != nil {
+		c.logf("Failed to collect CDU data: %v", err)
 		success = false
 	} else {
-		log.Println("Successfully collected CDU data")
+		c.logf("Successfully collected CDU data")
+		c.markReady("cdu")
 	}
 
 	// Collect liquid cooling data
-	if err := c.collectLiquidCooling(); err != nil {
-		log.Printf("Failed to collect liquid data: %v", err)
+	if c.isPaused("liquid") {
+		c.logf("Liquid collection is paused, keeping previous data")
+	} else if err := c.collectLiquidCooling(cfg); err != nil {
+		c.logf("Failed to collect liquid data: %v", err)
 		success = false
 	} else {
-		log.Println("Successfully collected liquid data")
+		c.logf("Successfully collected liquid data")
+		c.markReady("liquid")
 	}
 
 	// Update health status
@@ -115,8 +290,9 @@ func (c *Collector) Collect() {
 	c.lastCollect = time.Now()
 	c.lastSuccess = success
 	c.mu.Unlock()
+	c.cycleCompleted()
 
-	log.Println("Data collection cycle completed")
+	c.logf("Data collection cycle completed")
 }
 
 // GetHealthStatus returns the current health status
@@ -126,152 +302,451 @@ func (c *Collector) GetHealthStatus() (time.Time, bool) {
 	return c.lastCollect, c.lastSuccess
 }
 
-// collectTRH collects temperature and humidity data
-func (c *Collector) collectTRH() error {
-	req, err := http.NewRequest("POST", c.config.TRHURL, bytes.NewBufferString("action=inf"))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
+// CDUTargets returns the CDU targets currently being scraped
+func (c *Collector) CDUTargets() []config.CDUTarget {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]config.CDUTarget(nil), c.targets...)
+}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Referer", c.config.Referer)
-	req.Header.Set("Cookie", fmt.Sprintf("sess_map=%s; PHPSESSID=%s", c.config.SessMap, c.config.PHPSessID))
+// collectTRH collects temperature and humidity data from every TRH endpoint
+func (c *Collector) collectTRH(cfg *config.Config, client *http.Client) error {
+	available := make(map[string]bool)
+	defer c.recordSourceAvailable("trh", available)
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to make HTTP request: %w", err)
+	c.mu.RLock()
+	gauges, labels := c.trhGauges, c.trhLabels
+	c.mu.RUnlock()
+
+	sensors := 0
+	successfulScrapes := 0
+	aggregates := newTRHAggregates()
+	for _, target := range cfg.TRHTargets {
+		n, err := c.collectTRHTarget(cfg, client, target, gauges, labels, available, aggregates)
+		if err != nil {
+			c.logf("Failed to collect TRH data from %s: %v", target.URL, err)
+			continue
+		}
+		sensors += n
+		successfulScrapes++
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP request failed with status: %s", resp.Status)
+	if successfulScrapes == 0 {
+		return fmt.Errorf("failed to collect any TRH data")
 	}
+	aggregates.set(cfg)
+	c.logf("Collected TRH data for %d sensors from %d endpoints", sensors, successfulScrapes)
+	return nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// collectTRHTarget collects the sensors of a single TRH endpoint and sets
+// their metrics with the labels of the target, adding their readings to the
+// aggregates. It returns the number of sensors collected.
+func (c *Collector) collectTRHTarget(cfg *config.Config, client *http.Client, target config.TRHTarget, gauges trhGauges, labels []string, available map[string]bool, aggregates trhAggregates) (_ int, err error) {
+	defer c.recordScrape("trh", target.URL, time.Now(), &err)
+
+	body, err := postTRH(context.Background(), cfg, client, target, url.Values{"action": {"inf"}})
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		return 0, err
 	}
 
 	var sensors []SensorData
 	if err := json.Unmarshal(body, &sensors); err != nil {
-		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+		return 0, fmt.Errorf("%w: failed to unmarshal JSON: %w", errPayload, err)
 	}
 
-	// Reset gauges before setting new values
-	temperatureGauge.Reset()
-	humidityGauge.Reset()
+	sensors = c.resolveDuplicates(cfg, target.URL, sensors)
+
+	// Drop the previous series of the endpoint before setting new values
+	gauges.deleteTarget(target)
 
 	for _, sensor := range sensors {
+		values := append([]string{sensor.Label}, trhLabelValues(cfg, target, sensor.Label, labels)...)
+
 		// Convert temperature to float64
 		temp, err := parseValue(sensor.Temp)
 		if err != nil {
-			log.Printf("Error parsing temperature for sensor %s: %v", sensor.Label, err)
+			if !errors.Is(err, errNoReading) {
+				c.logf("Error parsing temperature for sensor %s: %v", sensor.Label, err)
+			}
+			gauges.up.WithLabelValues(values...).Set(0)
+			available[sensor.Label] = false
+			c.observe(InventorySensor, sensor.Label, labelMap(labels, values[1:]), nil)
 			continue
 		}
 
 		// Convert humidity to float64
 		humidity, err := parseValue(sensor.RH)
 		if err != nil {
-			log.Printf("Error parsing humidity for sensor %s: %v", sensor.Label, err)
+			if !errors.Is(err, errNoReading) {
+				c.logf("Error parsing humidity for sensor %s: %v", sensor.Label, err)
+			}
+			gauges.up.WithLabelValues(values...).Set(0)
+			available[sensor.Label] = false
+			c.observe(InventorySensor, sensor.Label, labelMap(labels, values[1:]), nil)
 			continue
 		}
 
-		// Set metrics with sensor name as label
-		temperatureGauge.WithLabelValues(sensor.Label).Set(temp)
-		humidityGauge.WithLabelValues(sensor.Label).Set(humidity)
+		// Set metrics with sensor name and target labels
+		gauges.up.WithLabelValues(values...).Set(1)
+		if cfg.Temperature.Celsius() {
+			gauges.temperature.WithLabelValues(values...).Set(temp)
+		}
+		if cfg.Temperature.Fahrenheit() {
+			gauges.fahrenheit.WithLabelValues(values...).Set(Fahrenheit(temp))
+		}
+		gauges.humidity.WithLabelValues(values...).Set(humidity)
+		available[sensor.Label] = true
+		sensorLabels := labelMap(labels, values[1:])
+		c.observe(InventorySensor, sensor.Label, sensorLabels, map[string]float64{"temperature": temp, "humidity": humidity})
+		aggregates.add(cfg, sensor.Label, sensorLabels, temp, humidity)
 
-		log.Printf("Sensor %s: temp=%.2f°C, humidity=%.2f%%", sensor.Label, temp, humidity)
+		c.logValues(cfg, "trh/"+sensor.Label, []float64{temp, humidity}, "Sensor %s: temp=%.2f°C, humidity=%.2f%%", sensor.Label, temp, humidity)
 	}
 
-	log.Printf("Collected TRH data for %d sensors", len(sensors))
-	return nil
+	return len(sensors), nil
+}
+
+// postTRH posts a form to a TRH endpoint and returns the JSON it responds
+// with
+func postTRH(ctx context.Context, cfg *config.Config, client *http.Client, target config.TRHTarget, form url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", target.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", cfg.Referer)
+	req.Header.Set("Cookie", fmt.Sprintf("sess_map=%s; PHPSESSID=%s", cfg.SessMap, cfg.PHPSessID))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	body, err := readBody(cfg, "trh", resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkJSON(resp, body, cfg.LoginURL); err != nil {
+		return nil, err
+	}
+	return body, nil
 }
 
 // collectCDU collects CDU data using scraper for multiple URLs
-func (c *Collector) collectCDU() error {
+func (c *Collector) collectCDU(cfg *config.Config) error {
+	c.mu.Lock()
+	cduGauge, cduLabels, paramGauges := c.cduGauge, c.cduLabels, c.paramGauges
+	c.parsedCDU = make(map[string]ParsedCDU)
+	c.mu.Unlock()
+
 	// Reset gauge
 	cduGauge.Reset()
+	paramGauges.reset()
+	maintenanceGauge.Reset()
+	alarmAcknowledgedGauge.Reset()
+	leakGauge.Reset()
+	pumpSpeedPercentGauge.Reset()
+	pumpSpeedRPMGauge.Reset()
+	valvePositionGauge.Reset()
+	filterPressureGauge.Reset()
+	conductivityGauge.Reset()
+	phGauge.Reset()
+	glycolGauge.Reset()
+	parameterActualGauge.Reset()
+	parameterSetpointGauge.Reset()
+	parameterDeviationGauge.Reset()
 
 	totalAlarms := 0
 	totalParams := 0
 	successfulScrapes := 0
 
-	for _, url := range c.config.CDUURLs {
-		name, alarms, params, err := scraper.ScrapeCDU(url, c.config.SessMap, c.config.PHPSessID, c.config.ScrapeTimeout)
+	for _, target := range c.CDUTargets() {
+		alarmCount, paramCount, err := c.collectCDUTarget(cfg, target, cduGauge, cduLabels)
 		if err != nil {
-			log.Printf("Failed to scrape CDU data from %s: %v", url, err)
+			c.logf("Failed to scrape CDU data from %s: %v", target.URL, err)
 			continue
 		}
 
-		// Set alarm data
-		alarmCount := 0
-		for _, alarm := range alarms {
-			// Item and status are already normalized in scraper
-			item := alarm.Item
-			status := alarm.Status
-			cduGauge.WithLabelValues(name, "alarm", item, status, "").Set(1)
-			alarmCount++
-			log.Printf("CDU Alarm - %s (%s): %s (%s)", name, alarm.Item, alarm.Status, status)
-		}
-
-		// Set parameter data
-		paramCount := 0
-		for _, param := range params {
-			// Item is already normalized in scraper
-			item := param.Item
-			// Use unit as is
-			unit := param.Unit
-			cduGauge.WithLabelValues(name, "parameter", item, "normal", unit).Set(param.Value)
-			paramCount++
-			log.Printf("CDU Parameter - %s (%s): %.2f %s", name, param.Item, param.Value, param.Unit)
-		}
-
 		totalAlarms += alarmCount
 		totalParams += paramCount
 		successfulScrapes++
-		log.Printf("Collected CDU data for %s: %d alarms, %d parameters", name, alarmCount, paramCount)
 	}
 
 	if successfulScrapes == 0 {
 		return fmt.Errorf("failed to scrape any CDU data")
 	}
 
-	log.Printf("Total CDU data collected: %d successful scrapes, %d alarms, %d parameters", successfulScrapes, totalAlarms, totalParams)
+	c.logf("Total CDU data collected: %d successful scrapes, %d alarms, %d parameters", successfulScrapes, totalAlarms, totalParams)
 	return nil
 }
 
+// collectCDUTarget scrapes a single CDU target and sets its metrics. It
+// returns the number of alarms and parameters collected.
+func (c *Collector) collectCDUTarget(cfg *config.Config, target config.CDUTarget, cduGauge *prometheus.GaugeVec, cduLabels []string) (_ int, _ int, err error) {
+	defer c.recordScrape("cdu", target.URL, time.Now(), &err)
+
+	browser, err := BrowserOptions(cfg, target.URL, target.Proxy)
+	if err != nil {
+		return 0, 0, err
+	}
+	release, err := c.acquirePage(cfg, target.URL)
+	if err != nil {
+		return 0, 0, err
+	}
+	var tabs []scraper.CDUTab
+	for _, tab := range cfg.TabsFor(target) {
+		tabs = append(tabs, scraper.CDUTab{Name: tab.Name, Clicks: tab.Clicks, Table: tab.Table})
+	}
+	pageName, alarms, params, stats, err := scraper.ScrapeCDU(target.URL, browser, cfg.SessMap, cfg.PHPSessID, cfg.ScrapeTimeout, tabs)
+	release()
+	countOversizedPage("cdu", stats, err)
+	if err != nil {
+		// Count the failure against the name the CDU had when it was last seen
+		c.mu.RLock()
+		name, ok := c.cduNames[target.URL]
+		c.mu.RUnlock()
+		if !ok {
+			name = cduName(target, "")
+		}
+		c.recordAvailable("cdu", name, false)
+		return 0, 0, err
+	}
+
+	name := cduName(target, pageName)
+	c.mu.Lock()
+	c.parsedCDU[name] = ParsedCDU{URL: target.URL, PageName: pageName, Alarms: alarms, Parameters: params, ScrapedAt: time.Now()}
+	if c.cduNames == nil {
+		c.cduNames = make(map[string]string)
+	}
+	c.cduNames[target.URL] = name
+	c.mu.Unlock()
+	c.recordAvailable("cdu", name, true)
+	recordPageStats("cdu", name, stats)
+
+	// Drop the previous series of the target, in case it is collected on its own
+	cduGauge.DeletePartialMatch(prometheus.Labels{"name": name})
+	c.mu.RLock()
+	paramGauges := c.paramGauges
+	c.mu.RUnlock()
+	paramGauges.deleteTarget(name)
+	maintenanceGauge.DeletePartialMatch(prometheus.Labels{"name": name})
+	alarmAcknowledgedGauge.DeletePartialMatch(prometheus.Labels{"name": name})
+
+	// Report the alarms that persisted for long enough, rather than the ones
+	// of this scrape
+	alarms = c.debounceAlarms(cfg, name, alarms)
+
+	window := cfg.Maintenance.Active(name, target.CabinetID, time.Now())
+	if window != "" {
+		maintenanceGauge.WithLabelValues(name, target.CabinetID, window).Set(1)
+	}
+
+	fromRules := cfg.RuleLabels(config.LabelRuleCDU, name)
+	extra := make([]string, len(cduLabels))
+	for i, label := range cduLabels {
+		if label == "in_maintenance" {
+			extra[i] = strconv.FormatBool(window != "")
+			continue
+		}
+		if value, ok := target.Labels[label]; ok {
+			extra[i] = value
+		} else {
+			extra[i] = fromRules[label]
+		}
+	}
+
+	// Set alarm data, unless the target is in a maintenance window that
+	// suppresses them
+	alarmCount := 0
+	if window != "" && cfg.Maintenance.Mode == config.MaintenanceSuppress {
+		c.logf("CDU %s is in maintenance window %s, suppressing %d alarms", name, window, len(alarms))
+		alarms = nil
+	}
+	for _, alarm := range alarms {
+		// Item and status are already normalized in scraper
+		item := alarm.Item
+		status := alarm.Status
+		cduGauge.WithLabelValues(append([]string{name, target.CabinetID, "alarm", item, status, ""}, extra...)...).Set(1)
+		acknowledged := 0.0
+		if alarm.Acknowledged {
+			acknowledged = 1
+		}
+		alarmAcknowledgedGauge.WithLabelValues(name, target.CabinetID, item, status).Set(acknowledged)
+		alarmCount++
+	}
+	c.logAlarms(cfg, name, alarms)
+	c.collectAlarmHistory(cfg, target, name, browser)
+
+	// Set parameter data
+	paramCount := 0
+	readings := make(map[string]float64, len(params))
+	for _, param := range params {
+		// Item is already normalized in scraper
+		item := param.Item
+		// Use unit as is
+		unit := param.Unit
+		// The readings of the tabs of the dashboard have the tab as type
+		kind := "parameter"
+		if param.Tab != "" {
+			kind = param.Tab
+			item = param.Tab + "_" + item
+		}
+		// Mapped parameters have a metric of their own, and stay on the
+		// CDU metric too if both are wanted
+		metric, named := cfg.ParameterMetrics.Lookup(kind, param.Item)
+		if named {
+			paramGauges.set(metric, name, target.CabinetID, param.Item, extra, param.Value)
+		}
+		if !named || cfg.ParameterMetrics.Mode == config.ParameterMetricsBoth {
+			cduGauge.WithLabelValues(append([]string{name, target.CabinetID, kind, param.Item, "normal", unit}, extra...)...).Set(param.Value)
+		}
+		readings[item] = param.Value
+		paramCount++
+		c.logValues(cfg, "cdu/"+name+"/"+item, []float64{param.Value}, "CDU Parameter - %s (%s): %.2f %s", name, item, param.Value, param.Unit)
+	}
+
+	setPumpValveMetrics(name, target.CabinetID, params)
+	setFilterMetrics(name, target.CabinetID, params)
+	setCoolantMetrics(name, target.CabinetID, params)
+	setLeakMetrics(name, target.CabinetID, alarms, params)
+	setSetpointMetrics(name, target.CabinetID, params)
+
+	inventoryLabels := labelMap(cduLabels, extra)
+	delete(inventoryLabels, "in_maintenance")
+	if target.CabinetID != "" {
+		inventoryLabels["cabinet_id"] = target.CabinetID
+	}
+	c.observe(InventoryCDU, name, inventoryLabels, readings)
+
+	c.logf("Collected CDU data for %s: %d alarms, %d parameters", name, alarmCount, paramCount)
+	return alarmCount, paramCount, nil
+}
+
+// cduName picks the name used for a CDU target: the configured alias, then
+// the dashboard title, then the cabinet ID
+func cduName(target config.CDUTarget, pageName string) string {
+	if target.Name != "" {
+		return target.Name
+	}
+	if pageName != "" {
+		return pageName
+	}
+	if target.CabinetID != "" {
+		return "cabinet_" + target.CabinetID
+	}
+	return target.URL
+}
+
 // collectLiquidCooling collects liquid cooling data
-func (c *Collector) collectLiquidCooling() error {
+func (c *Collector) collectLiquidCooling(cfg *config.Config) (err error) {
+	defer c.recordScrape("liquid", cfg.LiquidCoolingURL, time.Now(), &err)
+	available := make(map[string]bool)
+	defer c.recordSourceAvailable("liquid", available)
+
+	c.mu.RLock()
+	gauges := c.liquidGauges
+	c.mu.RUnlock()
+
 	// Reset gauges
-	liquidGauge.Reset()
-	liquidRackGauge.Reset()
+	gauges.cdu.Reset()
+	gauges.rack.Reset()
+	liquidPumpGauge.Reset()
+	liquidManifoldGauge.Reset()
 
-	cdus, racks, err := scraper.ScrapeLiquidCooling(c.config.LiquidCoolingURL, c.config.SessMap, c.config.PHPSessID, c.config.ScrapeTimeout)
+	browser, err := BrowserOptions(cfg, cfg.LiquidCoolingURL, "")
+	if err != nil {
+		return err
+	}
+	browser.ScrollPasses = cfg.LiquidScrollPasses
+	release, err := c.acquirePage(cfg, cfg.LiquidCoolingURL)
+	if err != nil {
+		return err
+	}
+	cdus, racks, sections, stats, err := scraper.ScrapeLiquidCooling(cfg.LiquidCoolingURL, browser, cfg.SessMap, cfg.PHPSessID, cfg.ScrapeTimeout)
+	release()
+	countOversizedPage("liquid", stats, err)
 	if err != nil {
 		return fmt.Errorf("failed to scrape liquid data: %w", err)
 	}
 
+	c.mu.Lock()
+	c.parsedLiquid = ParsedLiquid{CDUs: cdus, Racks: racks, Sections: sections, ScrapedAt: time.Now()}
+	c.mu.Unlock()
+	recordPageStats("liquid", "", stats)
+
 	// Set CDU metrics
 	for _, cdu := range cdus {
-		liquidGauge.WithLabelValues(cdu.Name, "status", "percentage").Set(cdu.Status)
-		liquidGauge.WithLabelValues(cdu.Name, "fws_flow", "l/min").Set(cdu.FWSFlow)
-		liquidGauge.WithLabelValues(cdu.Name, "fws_temp_sup", "C").Set(cdu.FWSTempSup)
-		liquidGauge.WithLabelValues(cdu.Name, "fws_temp_ret", "C").Set(cdu.FWSTempRet)
-		liquidGauge.WithLabelValues(cdu.Name, "tcs_flow", "l/min").Set(cdu.TCSFlow)
-		liquidGauge.WithLabelValues(cdu.Name, "tcs_temp_sup", "C").Set(cdu.TCSTempSup)
-		liquidGauge.WithLabelValues(cdu.Name, "tcs_temp_ret", "C").Set(cdu.TCSTempRet)
-		log.Printf("Liquid CDU %s: status=%.2f%%, fws_flow=%.2f l/min, fws_temp_sup=%.2f°C, fws_temp_ret=%.2f°C, tcs_flow=%.2f l/min, tcs_temp_sup=%.2f°C, tcs_temp_ret=%.2f°C", cdu.Name, cdu.Status, cdu.FWSFlow, cdu.FWSTempSup, cdu.FWSTempRet, cdu.TCSFlow, cdu.TCSTempSup, cdu.TCSTempRet)
+		available[cdu.Name] = true
+		gauges.cdu.WithLabelValues(gauges.cduValues(cfg, cdu.Name, "status", "percentage")...).Set(cdu.Status)
+		gauges.cdu.WithLabelValues(gauges.cduValues(cfg, cdu.Name, "fws_flow", "l/min")...).Set(cdu.FWSFlow)
+		gauges.setCDUTemperature(cfg, cdu.Name, "fws_temp_sup", cdu.FWSTempSup)
+		gauges.setCDUTemperature(cfg, cdu.Name, "fws_temp_ret", cdu.FWSTempRet)
+		gauges.cdu.WithLabelValues(gauges.cduValues(cfg, cdu.Name, "tcs_flow", "l/min")...).Set(cdu.TCSFlow)
+		gauges.setCDUTemperature(cfg, cdu.Name, "tcs_temp_sup", cdu.TCSTempSup)
+		gauges.setCDUTemperature(cfg, cdu.Name, "tcs_temp_ret", cdu.TCSTempRet)
+		c.observe(InventoryCDU, cdu.Name, cfg.RuleLabels(config.LabelRuleCDU, cdu.Name), map[string]float64{
+			"status":       cdu.Status,
+			"fws_flow":     cdu.FWSFlow,
+			"fws_temp_sup": cdu.FWSTempSup,
+			"fws_temp_ret": cdu.FWSTempRet,
+			"tcs_flow":     cdu.TCSFlow,
+			"tcs_temp_sup": cdu.TCSTempSup,
+			"tcs_temp_ret": cdu.TCSTempRet,
+		})
+		c.logValues(cfg, "liquid/cdu/"+cdu.Name, []float64{cdu.Status, cdu.FWSFlow, cdu.FWSTempSup, cdu.FWSTempRet, cdu.TCSFlow, cdu.TCSTempSup, cdu.TCSTempRet}, "Liquid CDU %s: status=%.2f%%, fws_flow=%.2f l/min, fws_temp_sup=%.2f°C, fws_temp_ret=%.2f°C, tcs_flow=%.2f l/min, tcs_temp_sup=%.2f°C, tcs_temp_ret=%.2f°C", cdu.Name, cdu.Status, cdu.FWSFlow, cdu.FWSTempSup, cdu.FWSTempRet, cdu.TCSFlow, cdu.TCSTempSup, cdu.TCSTempRet)
 	}
 
 	// Set rack metrics
 	for _, rack := range racks {
-		liquidRackGauge.WithLabelValues(rack.RackNumber, "rack_liquid_cooling", "kW").Set(rack.RackLiquidCooling)
-		liquidRackGauge.WithLabelValues(rack.RackNumber, "tcs_flow", "l/min").Set(rack.TCSFlow)
-		liquidRackGauge.WithLabelValues(rack.RackNumber, "tcs_delta_temp", "C").Set(rack.TCSDeltaTemp)
-		liquidRackGauge.WithLabelValues(rack.RackNumber, "tcs_temp_supply", "C").Set(rack.TCSTempSupply)
-		log.Printf("Liquid Rack %s: rack_liquid_cooling=%.2f kW, tcs_flow=%.2f l/min, tcs_delta_temp=%.2f°C, tcs_temp_supply=%.2f°C", rack.RackNumber, rack.RackLiquidCooling, rack.TCSFlow, rack.TCSDeltaTemp, rack.TCSTempSupply)
+		gauges.rack.WithLabelValues(gauges.rackValues(cfg, rack.RackNumber, "rack_liquid_cooling", "kW")...).Set(rack.RackLiquidCooling)
+		gauges.rack.WithLabelValues(gauges.rackValues(cfg, rack.RackNumber, "tcs_flow", "l/min")...).Set(rack.TCSFlow)
+		gauges.setRackTemperature(cfg, rack.RackNumber, "tcs_delta_temp", rack.TCSDeltaTemp, true)
+		gauges.setRackTemperature(cfg, rack.RackNumber, "tcs_temp_supply", rack.TCSTempSupply, false)
+		readings := map[string]float64{
+			"rack_liquid_cooling": rack.RackLiquidCooling,
+			"tcs_flow":            rack.TCSFlow,
+			"tcs_delta_temp":      rack.TCSDeltaTemp,
+			"tcs_temp_supply":     rack.TCSTempSupply,
+		}
+		values := []float64{rack.RackLiquidCooling, rack.TCSFlow, rack.TCSDeltaTemp, rack.TCSTempSupply}
+		format := "Liquid Rack %s: rack_liquid_cooling=%.2f kW, tcs_flow=%.2f l/min, tcs_delta_temp=%.2f°C, tcs_temp_supply=%.2f°C"
+		// The energy valve detail rows are only exported and logged for the
+		// racks that have them
+		if rack.ValvePosition != nil {
+			gauges.rack.WithLabelValues(gauges.rackValues(cfg, rack.RackNumber, "valve_position", "percentage")...).Set(*rack.ValvePosition)
+			readings["valve_position"] = *rack.ValvePosition
+			values = append(values, *rack.ValvePosition)
+			format += ", valve_position=%.2f%%"
+		}
+		if rack.ValvePower != nil {
+			gauges.rack.WithLabelValues(gauges.rackValues(cfg, rack.RackNumber, "valve_power", "kW")...).Set(*rack.ValvePower)
+			readings["valve_power"] = *rack.ValvePower
+			values = append(values, *rack.ValvePower)
+			format += ", valve_power=%.2f kW"
+		}
+		if rack.TCSTempReturn != nil {
+			gauges.setRackTemperature(cfg, rack.RackNumber, "tcs_temp_return", *rack.TCSTempReturn, false)
+			readings["tcs_temp_return"] = *rack.TCSTempReturn
+			values = append(values, *rack.TCSTempReturn)
+			format += ", tcs_temp_return=%.2f°C"
+		}
+		c.observe(InventoryRack, rack.RackNumber, cfg.RuleLabels(config.LabelRuleRack, rack.RackNumber), readings)
+		args := []any{rack.RackNumber}
+		for _, v := range values {
+			args = append(args, v)
+		}
+		c.logValues(cfg, "liquid/rack/"+rack.RackNumber, values, format, args...)
 	}
 
-	log.Printf("Collected liquid data: %d CDUs, %d racks", len(cdus), len(racks))
+	setLiquidSectionMetrics(cfg, sections)
+
+	c.logf("Collected liquid data: %d CDUs, %d racks, %d pump and manifold readings", len(cdus), len(racks), len(sections))
 	return nil
 }
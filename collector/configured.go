@@ -0,0 +1,29 @@
+package collector
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// configuredCollector serves the metrics whose labels come from the
+// configuration. It describes no metrics, as the registry rejects a metric
+// whose labels change, which they do when a reload changes the labels of
+// the targets, the label rules or the parameter metrics.
+type configuredCollector struct {
+	c *Collector
+}
+
+// Describe implements prometheus.Collector
+func (configuredCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (t configuredCollector) Collect(ch chan<- prometheus.Metric) {
+	t.c.mu.RLock()
+	cduGauge, trh, liquid, params := t.c.cduGauge, t.c.trhGauges, t.c.liquidGauges, t.c.paramGauges
+	t.c.mu.RUnlock()
+	cduGauge.Collect(ch)
+	params.collect(ch)
+	trh.temperature.Collect(ch)
+	trh.fahrenheit.Collect(ch)
+	trh.humidity.Collect(ch)
+	trh.up.Collect(ch)
+	liquid.cdu.Collect(ch)
+	liquid.rack.Collect(ch)
+}
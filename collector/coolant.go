@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+var (
+	conductivityGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu_coolant_conductivity_microsiemens_per_cm",
+		Help: "Electrical conductivity of the coolant of a CDU in µS/cm",
+	}, []string{"name", "cabinet_id", "sensor"})
+
+	phGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu_coolant_ph",
+		Help: "pH of the coolant of a CDU",
+	}, []string{"name", "cabinet_id", "sensor"})
+
+	glycolGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu_coolant_glycol_percent",
+		Help: "Glycol concentration of the coolant of a CDU in percent",
+	}, []string{"name", "cabinet_id", "sensor"})
+)
+
+// microsiemensPerCM are the µS/cm in a unit of conductivity the dashboards
+// report, spelled with the micro sign or the Greek mu
+var microsiemensPerCM = map[string]float64{
+	"µs/cm": 1,
+	"μs/cm": 1,
+	"us/cm": 1,
+	"ms/cm": 1000,
+}
+
+// Words of the item of a parameter that tell what it measures rather than
+// which probe it is
+var coolantWords = []string{"conductivity", "cond", "ph", "glycol", "concentration", "conc", "percent", "pct", "coolant", "water", "quality"}
+
+// setCoolantMetrics exports the water quality readings among the parameters
+// of a CDU: conductivity converted to µS/cm, pH, and glycol concentration in
+// percent. The sensor label is the item without the words telling what it
+// measures, empty for the only probe of a CDU. Readings in an unknown unit
+// are left out.
+func setCoolantMetrics(name, cabinetID string, params []scraper.CDUParameter) {
+	for _, g := range []*prometheus.GaugeVec{conductivityGauge, phGauge, glycolGauge} {
+		g.DeletePartialMatch(prometheus.Labels{"name": name})
+	}
+
+	for _, param := range params {
+		words := strings.Split(strings.ToLower(param.Item), "_")
+		unit := strings.ToLower(strings.TrimSpace(param.Unit))
+		sensor := itemWithout(param.Item, coolantWords)
+		switch {
+		case hasWordPrefix(words, "conductiv") || slices.Contains(words, "cond"):
+			if factor, ok := microsiemensPerCM[unit]; ok {
+				conductivityGauge.WithLabelValues(name, cabinetID, sensor).Set(param.Value * factor)
+			}
+		case slices.Contains(words, "ph"):
+			phGauge.WithLabelValues(name, cabinetID, sensor).Set(param.Value)
+		case hasWordPrefix(words, "glycol"):
+			if unit == "%" || unit == "vol%" || unit == "% vol" || unit == "percent" {
+				glycolGauge.WithLabelValues(name, cabinetID, sensor).Set(param.Value)
+			}
+		}
+	}
+}
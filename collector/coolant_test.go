@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+func TestSetCoolantMetrics(t *testing.T) {
+	tests := []struct {
+		name         string
+		params       []scraper.CDUParameter
+		conductivity map[string]float64
+		ph           map[string]float64
+		glycol       map[string]float64
+	}{
+		{
+			name: "single probe",
+			params: []scraper.CDUParameter{
+				{Item: "Conductivity", Value: 2.5, Unit: "µS/cm"},
+				{Item: "pH", Value: 7.2},
+				{Item: "Glycol_Concentration", Value: 25, Unit: "%"},
+			},
+			conductivity: map[string]float64{"": 2.5},
+			ph:           map[string]float64{"": 7.2},
+			glycol:       map[string]float64{"": 25},
+		},
+		{
+			name: "probes and units",
+			params: []scraper.CDUParameter{
+				{Item: "Coolant_Cond_1", Value: 0.002, Unit: "mS/cm"},
+				{Item: "Coolant_Conductivity_2", Value: 3, Unit: "μS/cm"},
+				{Item: "Water_Quality_PH_1", Value: 8.1, Tab: "Coolant"},
+				{Item: "Glycol_Pct", Value: 30, Unit: "vol%"},
+			},
+			conductivity: map[string]float64{"1": 2, "2": 3},
+			ph:           map[string]float64{"1": 8.1},
+			glycol:       map[string]float64{"": 30},
+		},
+		{
+			name: "unknown units",
+			params: []scraper.CDUParameter{
+				{Item: "Conductivity", Value: 10, Unit: "ppm"},
+				{Item: "Glycol", Value: 1.04, Unit: "g/cm3"},
+			},
+		},
+		{
+			name: "other parameters",
+			params: []scraper.CDUParameter{
+				{Item: "Phase_Current", Value: 12, Unit: "A"},
+				{Item: "Supply_Temp", Value: 18, Unit: "°C"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, g := range []*prometheus.GaugeVec{conductivityGauge, phGauge, glycolGauge} {
+				g.Reset()
+			}
+			setCoolantMetrics("CDU_01", "38329", tt.params)
+			if got := seriesValues(t, conductivityGauge, "sensor"); !equalValues(got, tt.conductivity) {
+				t.Errorf("bdx_cdu_coolant_conductivity_microsiemens_per_cm = %v, want %v", got, tt.conductivity)
+			}
+			if got := seriesValues(t, phGauge, "sensor"); !equalValues(got, tt.ph) {
+				t.Errorf("bdx_cdu_coolant_ph = %v, want %v", got, tt.ph)
+			}
+			if got := seriesValues(t, glycolGauge, "sensor"); !equalValues(got, tt.glycol) {
+				t.Errorf("bdx_cdu_coolant_glycol_percent = %v, want %v", got, tt.glycol)
+			}
+		})
+	}
+}
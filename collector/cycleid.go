@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+// cycleIDKey is the context key of the ID a collection cycle logs with
+type cycleIDKey struct{}
+
+// WithCycleID returns a context making the collection it is passed to log
+// with the given ID, such as the ID of the request that triggered it
+func WithCycleID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, cycleIDKey{}, id)
+}
+
+// NewID returns a random ID for a collection cycle or a request
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// CycleID returns the ID of the running or last collection cycle
+func (c *Collector) CycleID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cycleID
+}
+
+// beginCycle sets the ID of a new collection cycle from the context, or a
+// random one. The caller holds the cycle lock.
+func (c *Collector) beginCycle(ctx context.Context) {
+	id, _ := ctx.Value(cycleIDKey{}).(string)
+	if id == "" {
+		id = NewID()
+	}
+	c.mu.Lock()
+	c.cycleID = id
+	c.busySince = time.Now()
+	c.mu.Unlock()
+}
+
+// endCycle marks the end of the collection cycle. The caller holds the cycle
+// lock.
+func (c *Collector) endCycle() {
+	c.mu.Lock()
+	c.busySince = time.Time{}
+	c.mu.Unlock()
+}
+
+// BusySince returns when the running collection cycle started, or the zero
+// time when none is running
+func (c *Collector) BusySince() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.busySince
+}
+
+// logf logs a message of the running collection cycle, prefixed with its ID
+func (c *Collector) logf(format string, args ...any) {
+	log.Printf("[cycle %s] "+format, append([]any{c.CycleID()}, args...)...)
+}
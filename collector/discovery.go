@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+var (
+	discoveredTargetsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bdx_discovered_targets",
+		Help: "Number of CDU targets found by the last successful discovery",
+	})
+
+	lastDiscoveryGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bdx_last_discovery_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful target discovery",
+	})
+)
+
+// Discover renders the discovery page in the browser and updates the CDU
+// targets: newly linked dashboards are added and previously discovered ones
+// that are no longer linked are removed. Statically configured targets are
+// never removed.
+func (c *Collector) Discover() error {
+	cfg, _ := c.settings()
+	if cfg.DiscoveryURL == "" || !c.IsLeader() {
+		return nil
+	}
+
+	browser, err := BrowserOptions(cfg, cfg.DiscoveryURL, "")
+	if err != nil {
+		return err
+	}
+	browser.ScrollPasses = cfg.LiquidScrollPasses
+	release, err := c.acquirePage(cfg, cfg.DiscoveryURL)
+	if err != nil {
+		return err
+	}
+	links, stats, err := scraper.ScrapeCDULinks(cfg.DiscoveryURL, browser, cfg.SessMap, cfg.PHPSessID, cfg.ScrapeTimeout)
+	release()
+	countOversizedPage("discovery", stats, err)
+	if err != nil {
+		return fmt.Errorf("failed to scrape the discovery page: %w", err)
+	}
+	if len(links) == 0 {
+		// An empty page usually means an expired session rather than a
+		// portal without CDUs, so keep the current targets
+		return fmt.Errorf("no CDU dashboard links found on %s", cfg.DiscoveryURL)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Use the configuration current at this point, a reload may have
+	// happened while the page was being fetched
+	cfg = c.config
+
+	found := make(map[string]bool)
+	for _, link := range links {
+		found[targetKey(cfg.NewCDUTarget(link))] = true
+	}
+
+	// Keep static targets and discovered targets that are still linked
+	var targets []config.CDUTarget
+	known := make(map[string]bool)
+	for _, t := range c.targets {
+		key := targetKey(t)
+		if _, ok := c.discovered[key]; ok && !found[key] {
+			log.Printf("Discovery: removing CDU target %s", t.URL)
+			delete(c.discovered, key)
+			continue
+		}
+		known[key] = true
+		targets = append(targets, t)
+	}
+
+	for _, link := range links {
+		t := cfg.NewCDUTarget(link)
+		key := targetKey(t)
+		if known[key] {
+			continue
+		}
+		log.Printf("Discovery: adding CDU target %s", t.URL)
+		known[key] = true
+		c.discovered[key] = t.URL
+		targets = append(targets, t)
+	}
+
+	c.targets = targets
+	discoveredTargetsGauge.Set(float64(len(links)))
+	lastDiscoveryGauge.SetToCurrentTime()
+	log.Printf("Discovery found %d CDU dashboards, scraping %d targets", len(links), len(targets))
+	return nil
+}
+
+// targetKey identifies a CDU target by cabinet ID, falling back to the URL
+func targetKey(t config.CDUTarget) string {
+	if t.CabinetID != "" {
+		return t.CabinetID
+	}
+	return t.URL
+}
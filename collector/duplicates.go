@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+var duplicateCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bdx_duplicate_labels_total",
+	Help: "Number of TRH sensors reported under the name of another sensor of the same response, by the policy applied",
+}, []string{"policy"})
+
+// resolveDuplicates applies the duplicate policy of cfg to sensors of the
+// same name in a TRH response and returns the sensors to export, in the
+// order they were first reported
+func (c *Collector) resolveDuplicates(cfg *config.Config, url string, sensors []SensorData) []SensorData {
+	var order []string
+	groups := make(map[string][]SensorData)
+	for _, sensor := range sensors {
+		if _, ok := groups[sensor.Label]; !ok {
+			order = append(order, sensor.Label)
+		}
+		groups[sensor.Label] = append(groups[sensor.Label], sensor)
+	}
+	if len(order) == len(sensors) {
+		return sensors
+	}
+
+	policy := cfg.SensorNames.Duplicates
+	resolved := make([]SensorData, 0, len(order))
+	for _, name := range order {
+		group := groups[name]
+		if len(group) == 1 {
+			resolved = append(resolved, group[0])
+			continue
+		}
+		duplicateCounter.WithLabelValues(policy).Add(float64(len(group) - 1))
+
+		switch policy {
+		case config.DuplicateSuffix:
+			resolved = append(resolved, group[0])
+			for i, sensor := range group[1:] {
+				sensor.Label = fmt.Sprintf("%s_%d", name, i+2)
+				resolved = append(resolved, sensor)
+			}
+		case config.DuplicateAverage:
+			resolved = append(resolved, SensorData{
+				Label: name,
+				Temp:  averageReading(group, func(s SensorData) interface{} { return s.Temp }),
+				RH:    averageReading(group, func(s SensorData) interface{} { return s.RH }),
+			})
+		case config.DuplicateDrop:
+			c.logf("Warning: dropping sensor %s of %s, reported %d times", name, url, len(group))
+		default:
+			resolved = append(resolved, group[0])
+		}
+	}
+	return resolved
+}
+
+// averageReading returns the average of a reading of the sensors, skipping
+// those without one. It returns the reading of the first sensor when none
+// can be parsed, so the sensor is reported as it would be on its own.
+func averageReading(sensors []SensorData, reading func(SensorData) interface{}) interface{} {
+	var sum float64
+	n := 0
+	for _, sensor := range sensors {
+		value, err := parseValue(reading(sensor))
+		if err != nil {
+			continue
+		}
+		sum += value
+		n++
+	}
+	if n == 0 {
+		return reading(sensors[0])
+	}
+	return sum / float64(n)
+}
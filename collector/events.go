@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"sort"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+// AlarmEvent is a CDU alarm that was raised or cleared
+type AlarmEvent struct {
+	Time   time.Time `json:"time"`
+	Target string    `json:"target"`
+	Item   string    `json:"item"`
+	Status string    `json:"status"`
+	State  string    `json:"state"`
+	// Source is EventSourceHistory for the events read from the alarm
+	// history of the dashboard, empty for the ones seen between scrapes
+	Source string `json:"source,omitempty"`
+}
+
+// Alarm is a CDU alarm that is currently active
+type Alarm struct {
+	Target string `json:"target"`
+	Item   string `json:"item"`
+	Status string `json:"status"`
+}
+
+// alarmKey identifies an active alarm
+type alarmKey struct {
+	target, item, status string
+}
+
+// alarmState debounces an alarm: it counts the consecutive scrapes the alarm
+// was present in, or absent from once it is active. It keeps whether the
+// alarm was acknowledged in the latest scrape it was present in.
+type alarmState struct {
+	active       bool
+	present      int
+	absent       int
+	acknowledged bool
+}
+
+// debounceAlarms updates the alarm states of a CDU with the alarms of its
+// latest scrape and returns the alarms to report, sorted by item and status.
+// An alarm becomes active once it was present in AlarmRaiseCycles scrapes in
+// a row and clears once it was absent from AlarmClearCycles scrapes in a
+// row. The alarms of the first collection are active right away, like the
+// baseline of trackAlarms.
+func (c *Collector) debounceAlarms(cfg *config.Config, name string, alarms []scraper.CDUAlarm) []scraper.CDUAlarm {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.alarmStates == nil {
+		c.alarmStates = make(map[alarmKey]*alarmState)
+	}
+	present := make(map[alarmKey]bool, len(alarms))
+	for _, alarm := range alarms {
+		key := alarmKey{name, alarm.Item, alarm.Status}
+		present[key] = true
+		state, ok := c.alarmStates[key]
+		if !ok {
+			state = &alarmState{}
+			c.alarmStates[key] = state
+		}
+		state.present++
+		state.absent = 0
+		state.acknowledged = alarm.Acknowledged
+		if state.present >= cfg.AlarmRaiseCycles || c.alarms == nil {
+			state.active = true
+		}
+	}
+
+	var reported []scraper.CDUAlarm
+	for key, state := range c.alarmStates {
+		if key.target != name {
+			continue
+		}
+		if !present[key] {
+			state.present = 0
+			state.absent++
+			if !state.active || state.absent >= cfg.AlarmClearCycles {
+				delete(c.alarmStates, key)
+				continue
+			}
+		}
+		if state.active {
+			reported = append(reported, scraper.CDUAlarm{Item: key.item, Status: key.status, Acknowledged: state.acknowledged})
+		}
+	}
+	sort.Slice(reported, func(i, j int) bool {
+		if reported[i].Item != reported[j].Item {
+			return reported[i].Item < reported[j].Item
+		}
+		return reported[i].Status < reported[j].Status
+	})
+	return reported
+}
+
+// trackAlarms compares the debounced alarms of every CDU with the ones active
+// before and records the transitions. Targets that were not scraped
+// successfully keep their previous alarms, so a failed scrape doesn't look
+// like every alarm cleared. Alarms suppressed by maintenance windows are
+// still tracked.
+func (c *Collector) trackAlarms() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	active := make(map[alarmKey]bool)
+	for key, state := range c.alarmStates {
+		if state.active {
+			active[key] = true
+		}
+	}
+
+	var events []AlarmEvent
+	for key := range active {
+		if !c.alarms[key] {
+			events = append(events, AlarmEvent{Time: now, Target: key.target, Item: key.item, Status: key.status, State: "raised"})
+		}
+	}
+	for key := range c.alarms {
+		if !active[key] {
+			events = append(events, AlarmEvent{Time: now, Target: key.target, Item: key.item, Status: key.status, State: "cleared"})
+		}
+	}
+
+	// The alarms found by the first scrape are the baseline, not events
+	if c.alarms != nil {
+		c.events = append(c.events, events...)
+		if size := c.config.EventBufferSize; len(c.events) > size {
+			c.events = append([]AlarmEvent(nil), c.events[len(c.events)-size:]...)
+		}
+	}
+	c.alarms = active
+}
+
+// Events returns the alarm events of the target seen between scrapes after
+// since, oldest first. An empty target returns the events of every target.
+func (c *Collector) Events(target string, since time.Time) []AlarmEvent {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	events := []AlarmEvent{}
+	for _, e := range c.events {
+		if (target == "" || e.Target == target) && e.Time.After(since) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// ActiveAlarms returns the CDU alarms that are currently active, sorted by
+// target, item and status
+func (c *Collector) ActiveAlarms() []Alarm {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	alarms := make([]Alarm, 0, len(c.alarms))
+	for key := range c.alarms {
+		alarms = append(alarms, Alarm{Target: key.target, Item: key.item, Status: key.status})
+	}
+	sort.Slice(alarms, func(i, j int) bool {
+		a, b := alarms[i], alarms[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Item != b.Item {
+			return a.Item < b.Item
+		}
+		return a.Status < b.Status
+	})
+	return alarms
+}
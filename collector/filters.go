@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+var filterPressureGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "bdx_cdu_filter_differential_pressure_kpa",
+	Help: "Pressure drop across a CDU filter in kilopascals, rising as the filter clogs",
+}, []string{"name", "cabinet_id", "filter"})
+
+// kilopascals are the kilopascals in a unit of pressure the dashboards
+// report
+var kilopascals = map[string]float64{
+	"kpa":  1,
+	"pa":   0.001,
+	"bar":  100,
+	"mbar": 0.1,
+	"psi":  6.894757,
+}
+
+// Words of the item of a parameter that tell it is a differential pressure
+// or its unit rather than which filter it is
+var filterPressureWords = []string{"diff", "differential", "dp", "δp", "delta", "press", "pressure", "kpa", "psi", "bar"}
+
+// setFilterMetrics exports the differential pressures of the filters among
+// the parameters of a CDU, converted to kPa. A parameter is one when its
+// item mentions a filter and a pressure difference, such as Filter_Diff_Press
+// or Filter_1_ΔP. Readings in an unknown unit are left out.
+func setFilterMetrics(name, cabinetID string, params []scraper.CDUParameter) {
+	filterPressureGauge.DeletePartialMatch(prometheus.Labels{"name": name})
+
+	for _, param := range params {
+		words := strings.Split(strings.ToLower(param.Item), "_")
+		if !hasWordPrefix(words, "filter") {
+			continue
+		}
+		if !hasWordPrefix(words, "diff") && !slices.Contains(words, "dp") && !slices.Contains(words, "δp") && !slices.Contains(words, "delta") {
+			continue
+		}
+		factor, ok := kilopascals[strings.ToLower(strings.TrimSpace(param.Unit))]
+		if !ok {
+			continue
+		}
+		filterPressureGauge.WithLabelValues(name, cabinetID, itemWithout(param.Item, filterPressureWords)).Set(param.Value * factor)
+	}
+}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+func TestSetFilterMetrics(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []scraper.CDUParameter
+		want   map[string]float64
+	}{
+		{
+			name:   "kPa",
+			params: []scraper.CDUParameter{{Item: "Filter_Diff_Press", Value: 35, Unit: "kPa"}},
+			want:   map[string]float64{"Filter": 35},
+		},
+		{
+			name: "converted units",
+			params: []scraper.CDUParameter{
+				{Item: "Filter_1_ΔP", Value: 0.5, Unit: "bar"},
+				{Item: "Filter_2_DP", Value: 2, Unit: "psi"},
+				{Item: "Filter_3_Differential_Pressure", Value: 1500, Unit: "Pa"},
+				{Item: "Strainer_Filters_Delta", Value: 250, Unit: " mbar "},
+			},
+			want: map[string]float64{"Filter_1": 50, "Filter_2": 13.789514, "Filter_3": 1.5, "Strainer_Filters": 25},
+		},
+		{
+			name:   "unknown unit",
+			params: []scraper.CDUParameter{{Item: "Filter_Diff_Press", Value: 140, Unit: "inH2O"}},
+			want:   map[string]float64{},
+		},
+		{
+			name: "other parameters",
+			params: []scraper.CDUParameter{
+				{Item: "Filter_Status", Value: 1},
+				{Item: "Supply_Pressure", Value: 250, Unit: "kPa"},
+				{Item: "Pump_Diff_Press", Value: 80, Unit: "kPa"},
+			},
+			want: map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filterPressureGauge.Reset()
+			setFilterMetrics("CDU_01", "38329", tt.params)
+			if got := seriesValues(t, filterPressureGauge, "filter"); !equalValues(got, tt.want) {
+				t.Errorf("bdx_cdu_filter_differential_pressure_kpa = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
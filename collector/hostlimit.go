@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+var (
+	portalInFlightGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_portal_requests_in_flight",
+		Help: "Number of requests and page loads to a portal host in progress",
+	}, []string{"host"})
+
+	portalWaitCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bdx_portal_limit_wait_seconds_total",
+		Help: "Time requests and page loads waited for the concurrency and rate limits of a portal host",
+	}, []string{"host"})
+)
+
+// hostLimiter caps the concurrent requests and the request rate per portal
+// host across every source. It outlives config reloads, so the limits keep
+// counting the requests in progress.
+type hostLimiter struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+	// next is when the next request to a host may start under the rate
+	// limit
+	next map[string]time.Time
+	// released is closed and replaced whenever a request finishes, waking
+	// the requests waiting for a slot
+	released chan struct{}
+}
+
+func newHostLimiter() *hostLimiter {
+	return &hostLimiter{
+		inFlight: make(map[string]int),
+		next:     make(map[string]time.Time),
+		released: make(chan struct{}),
+	}
+}
+
+// acquire waits until a request to host is allowed by the limits, or ctx is
+// done. The returned function releases the slot of the request.
+func (l *hostLimiter) acquire(ctx context.Context, host string, limits config.PortalLimitConfig) (func(), error) {
+	start := time.Now()
+	defer func() {
+		if waited := time.Since(start); waited > time.Millisecond {
+			portalWaitCounter.WithLabelValues(host).Add(waited.Seconds())
+		}
+	}()
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		var delay time.Duration
+		if next := l.next[host]; now.Before(next) {
+			delay = next.Sub(now)
+		}
+		full := limits.MaxConcurrency > 0 && l.inFlight[host] >= limits.MaxConcurrency
+		if delay == 0 && !full {
+			l.inFlight[host]++
+			if limits.RequestsPerMinute > 0 {
+				l.next[host] = now.Add(time.Minute / time.Duration(limits.RequestsPerMinute))
+			}
+			portalInFlightGauge.WithLabelValues(host).Inc()
+			l.mu.Unlock()
+			var once sync.Once
+			return func() { once.Do(func() { l.release(host) }) }, nil
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		// Wait for the rate limit, or for a slot when all are taken
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !full {
+			timer = time.NewTimer(delay)
+			expired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-released:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// acquirePage waits for a slot to load the page at pageURL in the browser,
+// at most for the scrape timeout
+func (c *Collector) acquirePage(cfg *config.Config, pageURL string) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ScrapeTimeout)
+	defer cancel()
+	release, err := c.limiter.acquireURL(ctx, pageURL, cfg.PortalLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for the portal limits: %w", err)
+	}
+	return release, nil
+}
+
+// release frees the slot of a finished request to host
+func (l *hostLimiter) release(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight[host]--
+	if l.inFlight[host] == 0 {
+		delete(l.inFlight, host)
+	}
+	portalInFlightGauge.WithLabelValues(host).Dec()
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// acquireURL waits for a slot of the host of rawURL
+func (l *hostLimiter) acquireURL(ctx context.Context, rawURL string, limits config.PortalLimitConfig) (func(), error) {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return l.acquire(ctx, host, limits)
+}
+
+// limitTransport holds a slot of the host limiter for every request until
+// its response body is closed
+type limitTransport struct {
+	base    http.RoundTripper
+	limiter *hostLimiter
+	limits  config.PortalLimitConfig
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limiter.acquire(req.Context(), req.URL.Host, t.limits)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseBody releases the slot of a request when its body is closed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close implements io.Closer
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"maps"
+	"sort"
+	"time"
+)
+
+// Kinds of the items of the inventory
+const (
+	InventorySensor = "sensor"
+	InventoryCDU    = "cdu"
+	InventoryRack   = "rack"
+)
+
+// InventoryKinds are the kinds of the items of the inventory
+var InventoryKinds = []string{InventorySensor, InventoryCDU, InventoryRack}
+
+// InventoryItem is a TRH sensor, CDU or liquid cooling rack the collector
+// has observed since it started
+type InventoryItem struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Labels are the labels of its series other than the name, such as the
+	// labels of its target and those derived from its name
+	Labels    map[string]string `json:"labels"`
+	FirstSeen time.Time         `json:"first_seen"`
+	LastSeen  time.Time         `json:"last_seen"`
+	// LastValues are its latest readings by item, such as temperature or
+	// tcs_flow. A sensor that reports no reading keeps its previous ones.
+	LastValues map[string]float64 `json:"last_values"`
+}
+
+// inventoryKey identifies an item of the inventory
+type inventoryKey struct {
+	kind, name string
+}
+
+// observe records that an item was seen with its labels and readings
+func (c *Collector) observe(kind, name string, labels map[string]string, values map[string]float64) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inventory == nil {
+		c.inventory = make(map[inventoryKey]*InventoryItem)
+	}
+	key := inventoryKey{kind, name}
+	item, ok := c.inventory[key]
+	if !ok {
+		item = &InventoryItem{
+			Kind:       kind,
+			Name:       name,
+			Labels:     make(map[string]string),
+			FirstSeen:  now,
+			LastValues: make(map[string]float64),
+		}
+		c.inventory[key] = item
+	}
+	item.LastSeen = now
+	maps.Copy(item.Labels, labels)
+	maps.Copy(item.LastValues, values)
+}
+
+// Inventory returns the items observed since the collector started, sorted
+// by kind and name. A non-empty kind selects the items of that kind.
+func (c *Collector) Inventory(kind string) []InventoryItem {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	items := []InventoryItem{}
+	for key, item := range c.inventory {
+		if kind != "" && key.kind != kind {
+			continue
+		}
+		copied := *item
+		copied.Labels = maps.Clone(item.Labels)
+		copied.LastValues = maps.Clone(item.LastValues)
+		items = append(items, copied)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Kind != items[j].Kind {
+			return items[i].Kind < items[j].Kind
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// labelMap pairs label names with their values, leaving out empty values
+func labelMap(names, values []string) map[string]string {
+	labels := make(map[string]string, len(names))
+	for i, name := range names {
+		if values[i] != "" {
+			labels[name] = values[i]
+		}
+	}
+	return labels
+}
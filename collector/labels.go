@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Gatherer returns a gatherer for the default registry that adds the
+// configured constant labels to every metric
+func (c *Collector) Gatherer() prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := prometheus.DefaultGatherer.Gather()
+
+		cfg, _ := c.settings()
+		if len(cfg.ConstantLabels) == 0 {
+			return mfs, err
+		}
+
+		names := make([]string, 0, len(cfg.ConstantLabels))
+		for name := range cfg.ConstantLabels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				for _, name := range names {
+					// A label set by the metric itself takes precedence
+					if slices.ContainsFunc(m.Label, func(lp *dto.LabelPair) bool { return lp.GetName() == name }) {
+						continue
+					}
+					value := cfg.ConstantLabels[name]
+					m.Label = append(m.Label, &dto.LabelPair{Name: &name, Value: &value})
+				}
+				sort.Slice(m.Label, func(i, j int) bool {
+					return m.Label[i].GetName() < m.Label[j].GetName()
+				})
+			}
+		}
+		return mfs, err
+	})
+}
+
+// ConstantLabels returns the constant labels added to every metric
+func (c *Collector) ConstantLabels() map[string]string {
+	cfg, _ := c.settings()
+	return cfg.ConstantLabels
+}
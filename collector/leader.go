@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "bdx_leader",
+	Help: "Whether this replica is the leader that scrapes the portal, 0 on a standby",
+})
+
+func init() {
+	leaderGauge.Set(1)
+}
+
+// SetLeader makes the replica scrape the portal as the leader, or stop
+// scraping as a standby. A standby keeps serving the metrics it collected
+// last.
+func (c *Collector) SetLeader(leader bool) {
+	c.mu.Lock()
+	c.standby = !leader
+	c.mu.Unlock()
+	if leader {
+		leaderGauge.Set(1)
+	} else {
+		leaderGauge.Set(0)
+	}
+}
+
+// IsLeader reports whether the replica scrapes the portal. It is always the
+// case without leader election.
+func (c *Collector) IsLeader() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !c.standby
+}
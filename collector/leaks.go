@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+var leakGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "bdx_cdu_leak_detected",
+	Help: "Whether a leak sensor of a CDU detects a leak (1) or not (0)",
+}, []string{"name", "cabinet_id", "location"})
+
+// Words of the item of a leak sensor that don't tell where it is
+var leakWords = []string{"leak", "leakage", "detection", "detector", "detected", "sensor", "status", "alarm"}
+
+// setLeakMetrics exports the leak sensors of a CDU: the rows of the alarm
+// table and the parameters whose item mentions a leak. An alarm row detects
+// a leak unless its status is normal; a parameter, such as a state on a
+// tab, unless it is 0.
+func setLeakMetrics(name, cabinetID string, alarms []scraper.CDUAlarm, params []scraper.CDUParameter) {
+	leakGauge.DeletePartialMatch(prometheus.Labels{"name": name})
+
+	detected := make(map[string]bool)
+	for _, alarm := range alarms {
+		if hasWordPrefix(strings.Split(strings.ToLower(alarm.Item), "_"), "leak") {
+			location := itemWithout(alarm.Item, leakWords)
+			detected[location] = detected[location] || !leakStatusNormal(alarm.Status)
+		}
+	}
+	for _, param := range params {
+		if hasWordPrefix(strings.Split(strings.ToLower(param.Item), "_"), "leak") {
+			location := itemWithout(param.Item, leakWords)
+			detected[location] = detected[location] || param.Value != 0
+		}
+	}
+
+	for location, leak := range detected {
+		value := 0.0
+		if leak {
+			value = 1
+		}
+		leakGauge.WithLabelValues(name, cabinetID, location).Set(value)
+	}
+}
+
+// leakStatusNormal reports whether the status of a leak sensor row means no
+// leak
+func leakStatusNormal(status string) bool {
+	switch status {
+	case "normal", "ok", "no leak", "none", "clear", "cleared":
+		return true
+	}
+	return false
+}
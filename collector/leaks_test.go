@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+func TestSetLeakMetrics(t *testing.T) {
+	tests := []struct {
+		name   string
+		alarms []scraper.CDUAlarm
+		params []scraper.CDUParameter
+		want   map[string]float64
+	}{
+		{
+			name:   "normal alarm row",
+			alarms: []scraper.CDUAlarm{{Item: "Leak_Detection_Tray", Status: "normal"}},
+			want:   map[string]float64{"Tray": 0},
+		},
+		{
+			name:   "alarm row",
+			alarms: []scraper.CDUAlarm{{Item: "Leak_Detection_Tray", Status: "alarm"}},
+			want:   map[string]float64{"Tray": 1},
+		},
+		{
+			name:   "unknown status reads as a leak",
+			alarms: []scraper.CDUAlarm{{Item: "Leakage", Status: "warning"}},
+			want:   map[string]float64{"": 1},
+		},
+		{
+			name: "any row of a location",
+			alarms: []scraper.CDUAlarm{
+				{Item: "Leak_Floor", Status: "no leak"},
+				{Item: "Leak_Sensor_Floor", Status: "active"},
+				{Item: "Leak_Detection_Tray", Status: "cleared"},
+			},
+			want: map[string]float64{"Floor": 1, "Tray": 0},
+		},
+		{
+			name: "parameters",
+			params: []scraper.CDUParameter{
+				{Item: "Leak_Sensor_Floor", Value: 0, Tab: "Status"},
+				{Item: "Leak_Detected_Tray", Value: 1, Tab: "Status"},
+			},
+			want: map[string]float64{"Floor": 0, "Tray": 1},
+		},
+		{
+			name:   "alarm row and parameter",
+			alarms: []scraper.CDUAlarm{{Item: "Leak_Tray", Status: "normal"}},
+			params: []scraper.CDUParameter{{Item: "Leak_Tray_Status", Value: 1}},
+			want:   map[string]float64{"Tray": 1},
+		},
+		{
+			name:   "other alarms",
+			alarms: []scraper.CDUAlarm{{Item: "Pump_1_Fault", Status: "alarm"}},
+			params: []scraper.CDUParameter{{Item: "Supply_Temp", Value: 18}},
+			want:   map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leakGauge.Reset()
+			setLeakMetrics("CDU_01", "38329", tt.alarms, tt.params)
+			if got := seriesValues(t, leakGauge, "location"); !equalValues(got, tt.want) {
+				t.Errorf("bdx_cdu_leak_detected = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+var (
+	responseSizeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_response_size_bytes",
+		Help: "Size of the body of the last HTTP response of the portal read by a source",
+	}, []string{"source"})
+
+	oversizedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bdx_response_size_limit_exceeded_total",
+		Help: "Number of portal responses and pages over the size limit, by the action taken",
+	}, []string{"source", "action"})
+)
+
+// errResponseTooLarge is returned for an HTTP response over the size limit
+// when truncating is not allowed
+var errResponseTooLarge = errors.New("response too large")
+
+// readBody reads the body of a portal response of source, at most
+// cfg.Limits.MaxResponseSize bytes. A larger body fails the scrape, or is
+// cut to the limit when the limit action is truncate.
+func readBody(cfg *config.Config, source string, resp *http.Response) ([]byte, error) {
+	limit := cfg.Limits.MaxResponseSize
+	truncate := cfg.Limits.Action == config.LimitActionTruncate
+	if resp.ContentLength > limit && !truncate {
+		oversizedCounter.WithLabelValues(source, config.LimitActionAbort).Inc()
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", errResponseTooLarge, resp.ContentLength, limit)
+	}
+
+	// The transport only decompresses the responses it asked to be
+	// compressed, not those to an Accept-Encoding of request_headers or
+	// compressed unasked. The limit applies to the decompressed body.
+	reader := io.Reader(resp.Body)
+	if !resp.Uncompressed && strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid gzip body: %w", errPayload, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	// Read one byte past the limit to tell a body of exactly the limit from
+	// a larger one
+	body, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if int64(len(body)) > limit {
+		if !truncate {
+			oversizedCounter.WithLabelValues(source, config.LimitActionAbort).Inc()
+			return nil, fmt.Errorf("%w: more than %d bytes", errResponseTooLarge, limit)
+		}
+		oversizedCounter.WithLabelValues(source, config.LimitActionTruncate).Inc()
+		log.Printf("Response of %s exceeds %d bytes, truncating it", source, limit)
+		body = body[:limit]
+	}
+	responseSizeGauge.WithLabelValues(source).Set(float64(len(body)))
+	return body, nil
+}
+
+// countOversizedPage counts a page of source that exceeded the size limit,
+// from the error or the statistics of its scrape
+func countOversizedPage(source string, stats scraper.PageStats, err error) {
+	switch {
+	case errors.Is(err, scraper.ErrPageTooLarge):
+		oversizedCounter.WithLabelValues(source, config.LimitActionAbort).Inc()
+	case err == nil && stats.Truncated:
+		oversizedCounter.WithLabelValues(source, config.LimitActionTruncate).Inc()
+	}
+}
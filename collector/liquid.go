@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// liquidGauges are the liquid cooling metrics. They carry the labels derived
+// by the label rules of CDUs and racks, so their label sets are only known
+// once the configuration is loaded.
+type liquidGauges struct {
+	cdu        *prometheus.GaugeVec
+	rack       *prometheus.GaugeVec
+	cduLabels  []string
+	rackLabels []string
+}
+
+// newLiquidGauges creates the liquid cooling metrics with the extra labels
+// of CDUs and racks
+func newLiquidGauges(cduLabels, rackLabels []string) liquidGauges {
+	return liquidGauges{
+		cdu: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bdx_liquid",
+			Help: "Liquid cooling CDU metrics",
+		}, append([]string{"name", "type", "metrix_type"}, cduLabels...)),
+		rack: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bdx_liquid_rack",
+			Help: "Liquid cooling rack metrics",
+		}, append([]string{"name", "type", "metrix_type"}, rackLabels...)),
+		cduLabels:  cduLabels,
+		rackLabels: rackLabels,
+	}
+}
+
+// cduValues returns the label values of a CDU metric
+func (g liquidGauges) cduValues(cfg *config.Config, name, typ, metrixType string) []string {
+	return ruleLabelValues(cfg, config.LabelRuleCDU, g.cduLabels, name, typ, metrixType)
+}
+
+// rackValues returns the label values of a rack metric
+func (g liquidGauges) rackValues(cfg *config.Config, rack, typ, metrixType string) []string {
+	return ruleLabelValues(cfg, config.LabelRuleRack, g.rackLabels, rack, typ, metrixType)
+}
+
+// ruleLabelValues returns the name, type and metrix_type of a metric
+// followed by the values the label rules of source derive from name
+func ruleLabelValues(cfg *config.Config, source string, labels []string, name, typ, metrixType string) []string {
+	fromRules := cfg.RuleLabels(source, name)
+	values := []string{name, typ, metrixType}
+	for _, label := range labels {
+		values = append(values, fromRules[label])
+	}
+	return values
+}
+
+// setCDUTemperature sets a CDU temperature in the configured units, told
+// apart by a metrix_type of C or F
+func (g liquidGauges) setCDUTemperature(cfg *config.Config, name, typ string, celsius float64) {
+	if cfg.Temperature.Celsius() {
+		g.cdu.WithLabelValues(g.cduValues(cfg, name, typ, "C")...).Set(celsius)
+	}
+	if cfg.Temperature.Fahrenheit() {
+		g.cdu.WithLabelValues(g.cduValues(cfg, name, typ, "F")...).Set(Fahrenheit(celsius))
+	}
+}
+
+// setRackTemperature sets a rack temperature in the configured units. A
+// delta is a temperature difference, which is scaled but not offset.
+func (g liquidGauges) setRackTemperature(cfg *config.Config, rack, typ string, celsius float64, delta bool) {
+	if cfg.Temperature.Celsius() {
+		g.rack.WithLabelValues(g.rackValues(cfg, rack, typ, "C")...).Set(celsius)
+	}
+	if cfg.Temperature.Fahrenheit() {
+		value := Fahrenheit(celsius)
+		if delta {
+			value = celsius * 9 / 5
+		}
+		g.rack.WithLabelValues(g.rackValues(cfg, rack, typ, "F")...).Set(value)
+	}
+}
+
+// Fahrenheit converts a temperature from Celsius
+func Fahrenheit(celsius float64) float64 {
+	return celsius*9/5 + 32
+}
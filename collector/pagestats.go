@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+var (
+	pageSizeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_page_size_bytes",
+		Help: "Size of the HTML of the last successful scrape of a page",
+	}, []string{"source", "target"})
+
+	pageTablesGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_page_tables",
+		Help: "Number of tables on the last successfully scraped page",
+	}, []string{"source", "target"})
+
+	pageRowsParsedGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_page_rows_parsed",
+		Help: "Number of data rows parsed from the last successfully scraped page",
+	}, []string{"source", "target"})
+
+	pageRowsSkippedGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_page_rows_skipped",
+		Help: "Number of data rows of the last successfully scraped page dropped because a cell could not be parsed",
+	}, []string{"source", "target"})
+
+	pageRowsMisalignedGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_page_rows_misaligned",
+		Help: "Number of rack rows of the last successfully scraped page whose cells could not be aligned with the rack columns",
+	}, []string{"source", "target"})
+)
+
+// recordPageStats sets the page metrics of a target from its last scrape
+func recordPageStats(source, target string, stats scraper.PageStats) {
+	pageSizeGauge.WithLabelValues(source, target).Set(float64(stats.Size))
+	pageTablesGauge.WithLabelValues(source, target).Set(float64(stats.Tables))
+	pageRowsParsedGauge.WithLabelValues(source, target).Set(float64(stats.Rows))
+	pageRowsSkippedGauge.WithLabelValues(source, target).Set(float64(stats.SkippedRows))
+	pageRowsMisalignedGauge.WithLabelValues(source, target).Set(float64(stats.MisalignedRows))
+}
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+func init() {
+	config.ReservedMetric = reservedMetric
+}
+
+// unregisteredMetrics are the metrics of the exporter served unchecked, which
+// the default registry doesn't know
+var unregisteredMetrics = []string{"bdx_sensor_up"}
+
+// metricProbe describes a metric without ever collecting it, to find out
+// whether the default registry has another metric of the same name
+type metricProbe struct {
+	desc *prometheus.Desc
+}
+
+// Describe implements prometheus.Collector
+func (p metricProbe) Describe(ch chan<- *prometheus.Desc) { ch <- p.desc }
+
+// Collect implements prometheus.Collector
+func (metricProbe) Collect(chan<- prometheus.Metric) {}
+
+// reservedMetric reports whether name is a metric of the exporter, which a
+// parameter metric would conflict with and fail the whole /metrics
+func reservedMetric(name string) bool {
+	if _, ok := MetricSources[name]; ok || slices.Contains(unregisteredMetrics, name) {
+		return true
+	}
+	probe := metricProbe{prometheus.NewDesc(name, "Probe of a parameter metric name", nil, nil)}
+	if err := prometheus.DefaultRegisterer.Register(probe); err != nil {
+		return true
+	}
+	prometheus.DefaultRegisterer.Unregister(probe)
+	return false
+}
+
+// parameterGauges are the metrics the CDU parameters are exported as when
+// they are named after them, by metric name. They carry the item of the
+// parameter and the extra labels of bdx_cdu.
+type parameterGauges struct {
+	gauges map[string]*prometheus.GaugeVec
+	// help is the help of every metric, to tell when a reload changes them
+	help   map[string]string
+	labels []string
+}
+
+// newParameterGauges creates the metrics of the parameter mapping with the
+// extra labels of the CDU metric
+func newParameterGauges(metrics []config.ParameterMetric, extraLabels []string) parameterGauges {
+	g := parameterGauges{
+		gauges: make(map[string]*prometheus.GaugeVec, len(metrics)),
+		help:   make(map[string]string, len(metrics)),
+		labels: extraLabels,
+	}
+	for _, m := range metrics {
+		g.gauges[m.Metric] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: m.Metric,
+			Help: m.Help,
+		}, append([]string{"name", "cabinet_id", "item"}, extraLabels...))
+		g.help[m.Metric] = m.Help
+	}
+	return g
+}
+
+// matches reports whether the metrics were created for the mapping and
+// extra labels
+func (g parameterGauges) matches(metrics []config.ParameterMetric, extraLabels []string) bool {
+	if len(metrics) != len(g.help) || !slices.Equal(extraLabels, g.labels) {
+		return false
+	}
+	for _, m := range metrics {
+		if help, ok := g.help[m.Metric]; !ok || help != m.Help {
+			return false
+		}
+	}
+	return true
+}
+
+// set sets the metric of a parameter
+func (g parameterGauges) set(m config.ParameterMetric, name, cabinetID, item string, extra []string, value float64) {
+	if gauge, ok := g.gauges[m.Metric]; ok {
+		gauge.WithLabelValues(append([]string{name, cabinetID, item}, extra...)...).Set(value * m.Scale)
+	}
+}
+
+// deleteTarget drops the series of a CDU
+func (g parameterGauges) deleteTarget(name string) {
+	for _, gauge := range g.gauges {
+		gauge.DeletePartialMatch(prometheus.Labels{"name": name})
+	}
+}
+
+// reset drops every series
+func (g parameterGauges) reset() {
+	for _, gauge := range g.gauges {
+		gauge.Reset()
+	}
+}
+
+// collect sends the series of every metric
+func (g parameterGauges) collect(ch chan<- prometheus.Metric) {
+	for _, gauge := range g.gauges {
+		gauge.Collect(ch)
+	}
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+// ParsedCDU holds the structures parsed from a CDU dashboard, before they are
+// mapped to metrics
+type ParsedCDU struct {
+	URL        string
+	PageName   string
+	Alarms     []scraper.CDUAlarm
+	Parameters []scraper.CDUParameter
+	ScrapedAt  time.Time
+}
+
+// ParsedLiquid holds the structures parsed from the liquid cooling overview
+type ParsedLiquid struct {
+	CDUs      []scraper.LiquidCDU
+	Racks     []scraper.LiquidRack
+	Sections  []scraper.LiquidReading
+	ScrapedAt time.Time
+}
+
+// Parsed returns the structures parsed by the most recent successful scrape
+// of the named target. The target is a CDU name, a liquid cooling CDU name or
+// a rack number; an empty target returns everything.
+func (c *Collector) Parsed(target string) (map[string]any, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if target == "" {
+		// Copy the map, the next cycle replaces it while the caller encodes it
+		cdus := make(map[string]ParsedCDU, len(c.parsedCDU))
+		for name, cdu := range c.parsedCDU {
+			cdus[name] = cdu
+		}
+		return map[string]any{"cdu": cdus, "liquid": c.parsedLiquid}, true
+	}
+
+	if cdu, ok := c.parsedCDU[target]; ok {
+		return map[string]any{"cdu": map[string]ParsedCDU{target: cdu}}, true
+	}
+
+	liquid := ParsedLiquid{ScrapedAt: c.parsedLiquid.ScrapedAt}
+	for _, cdu := range c.parsedLiquid.CDUs {
+		if cdu.Name == target {
+			liquid.CDUs = append(liquid.CDUs, cdu)
+		}
+	}
+	for _, rack := range c.parsedLiquid.Racks {
+		if rack.RackNumber == target {
+			liquid.Racks = append(liquid.Racks, rack)
+		}
+	}
+	if len(liquid.CDUs) == 0 && len(liquid.Racks) == 0 {
+		return nil, false
+	}
+	return map[string]any{"liquid": liquid}, true
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var pausedGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "bdx_collection_paused",
+	Help: "Whether periodic collection of a source is paused",
+}, []string{"source"})
+
+func init() {
+	for _, source := range Sources {
+		pausedGauge.WithLabelValues(source).Set(0)
+	}
+}
+
+// Pause stops the periodic collection of the given sources, or of every
+// source if none are given. The last collected metrics keep being served.
+func (c *Collector) Pause(sources ...string) error {
+	return c.setPaused(true, sources)
+}
+
+// Resume restarts the periodic collection of the given sources, or of every
+// source if none are given
+func (c *Collector) Resume(sources ...string) error {
+	return c.setPaused(false, sources)
+}
+
+// setPaused updates the pause state of the given sources
+func (c *Collector) setPaused(paused bool, sources []string) error {
+	if len(sources) == 0 {
+		sources = Sources
+	}
+	for _, source := range sources {
+		if !slices.Contains(Sources, source) {
+			return fmt.Errorf("unknown source %q", source)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused == nil {
+		c.paused = make(map[string]bool)
+	}
+	for _, source := range sources {
+		c.paused[source] = paused
+		if paused {
+			pausedGauge.WithLabelValues(source).Set(1)
+		} else {
+			pausedGauge.WithLabelValues(source).Set(0)
+		}
+	}
+	return nil
+}
+
+// PausedSources returns the sources whose collection is paused
+func (c *Collector) PausedSources() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	paused := []string{}
+	for _, source := range Sources {
+		if c.paused[source] {
+			paused = append(paused, source)
+		}
+	}
+	return paused
+}
+
+// isPaused reports whether collection of the source is paused
+func (c *Collector) isPaused(source string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.paused[source]
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+// Classes of scrape errors
+const (
+	errorClassAuth     = "auth"
+	errorClassTimeout  = "timeout"
+	errorClassTooLarge = "too_large"
+	errorClassPayload  = "payload"
+	errorClassOther    = "other"
+)
+
+// errAuth marks a scrape that failed because the portal didn't accept the
+// session, usually because it expired
+var errAuth = errors.New("not authenticated, the session probably expired")
+
+// errPayload marks a response that is not in the expected format
+var errPayload = errors.New("unexpected response")
+
+// errorClass returns the class of a scrape error, so an expired session is
+// told apart from an unreachable or changed portal
+func errorClass(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errAuth), errors.Is(err, scraper.ErrSessionExpired):
+		return errorClassAuth
+	case errors.Is(err, errResponseTooLarge), errors.Is(err, scraper.ErrPageTooLarge):
+		return errorClassTooLarge
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errorClassTimeout
+	case errors.Is(err, errPayload):
+		return errorClassPayload
+	default:
+		return errorClassOther
+	}
+}
+
+// checkStatus fails for a response that is not 200 OK, as an auth error
+// for 401 and 403
+func checkStatus(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: HTTP request failed with status: %s", errAuth, resp.Status)
+	default:
+		return fmt.Errorf("HTTP request failed with status: %s", resp.Status)
+	}
+}
+
+// checkJSON checks that the body of a response is JSON. The portal answers
+// requests with an expired session with its login page, which is reported
+// as an auth error rather than a JSON syntax error. The content type isn't
+// required to be JSON, as the portal serves JSON as text/html.
+func checkJSON(resp *http.Response, body []byte, loginURL string) error {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return nil
+	}
+	if isLoginPage(resp, body, loginURL) {
+		return errAuth
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if len(trimmed) > 0 && trimmed[0] == '<' {
+		return fmt.Errorf("%w: HTML page instead of JSON (%s)", errPayload, mediaType)
+	}
+	return fmt.Errorf("%w: %s instead of JSON: %q", errPayload, mediaType, truncate(trimmed, 64))
+}
+
+// isLoginPage reports whether a response is the login page of the portal:
+// it was redirected to the login URL, or it contains a password field
+func isLoginPage(resp *http.Response, body []byte, loginURL string) bool {
+	if resp.Request != nil && loginURL != "" {
+		if login, err := url.Parse(loginURL); err == nil && resp.Request.URL.Path == login.Path {
+			return true
+		}
+	}
+	return bytes.Contains(bytes.ToLower(body), []byte(`type="password"`))
+}
+
+// truncate returns the first n bytes of b
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+var (
+	pumpSpeedPercentGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu_pump_speed_percent",
+		Help: "Speed of a CDU pump in percent of its maximum",
+	}, []string{"name", "cabinet_id", "pump"})
+
+	pumpSpeedRPMGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu_pump_speed_rpm",
+		Help: "Speed of a CDU pump in revolutions per minute",
+	}, []string{"name", "cabinet_id", "pump"})
+
+	valvePositionGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu_valve_position_percent",
+		Help: "Opening of a CDU control valve in percent",
+	}, []string{"name", "cabinet_id", "valve"})
+)
+
+// Words of the item of a parameter that tell what it measures or its unit
+// rather than which pump or valve it is
+var (
+	pumpSpeedWords     = []string{"speed", "rpm", "percent", "pct"}
+	valvePositionWords = []string{"position", "pos", "opening", "open", "percent", "pct"}
+)
+
+// setPumpValveMetrics exports the pump speeds and control valve positions
+// among the parameters of a CDU, read from the parameter table or its tabs.
+// A parameter is a pump speed when its item mentions a pump and its speed,
+// such as Pump_1_Speed, and a valve position when it mentions a valve and
+// its position or opening. The pump or valve label is the item without
+// those words, e.g. Pump_1.
+func setPumpValveMetrics(name, cabinetID string, params []scraper.CDUParameter) {
+	pumpSpeedPercentGauge.DeletePartialMatch(prometheus.Labels{"name": name})
+	pumpSpeedRPMGauge.DeletePartialMatch(prometheus.Labels{"name": name})
+	valvePositionGauge.DeletePartialMatch(prometheus.Labels{"name": name})
+
+	for _, param := range params {
+		words := strings.Split(strings.ToLower(param.Item), "_")
+		unit := strings.ToLower(strings.TrimSpace(param.Unit))
+		percent := unit == "%" || unit == "percent" || slices.Contains(words, "percent") || slices.Contains(words, "pct")
+		rpm := unit == "rpm" || unit == "r/min" || slices.Contains(words, "rpm")
+
+		switch {
+		case hasWordPrefix(words, "pump") && (slices.Contains(words, "speed") || slices.Contains(words, "rpm")):
+			pump := itemWithout(param.Item, pumpSpeedWords)
+			if percent {
+				pumpSpeedPercentGauge.WithLabelValues(name, cabinetID, pump).Set(param.Value)
+			} else if rpm {
+				pumpSpeedRPMGauge.WithLabelValues(name, cabinetID, pump).Set(param.Value)
+			}
+		case hasWordPrefix(words, "valve") && (slices.Contains(words, "position") || slices.Contains(words, "pos") || slices.Contains(words, "opening") || slices.Contains(words, "open")):
+			if percent {
+				valvePositionGauge.WithLabelValues(name, cabinetID, itemWithout(param.Item, valvePositionWords)).Set(param.Value)
+			}
+		}
+	}
+}
+
+// hasWordPrefix reports whether a word starts with prefix, so pump also
+// matches pumps and pump1
+func hasWordPrefix(words []string, prefix string) bool {
+	return slices.ContainsFunc(words, func(w string) bool { return strings.HasPrefix(w, prefix) })
+}
+
+// itemWithout returns the item without the words, keeping its case
+func itemWithout(item string, drop []string) string {
+	var kept []string
+	for _, w := range strings.Split(item, "_") {
+		if !slices.Contains(drop, strings.ToLower(w)) {
+			kept = append(kept, w)
+		}
+	}
+	return strings.Join(kept, "_")
+}
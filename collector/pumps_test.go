@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+// seriesValues returns the values of the series of g, by the value of their
+// label
+func seriesValues(t *testing.T, g *prometheus.GaugeVec, label string) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric)
+	go func() {
+		g.Collect(ch)
+		close(ch)
+	}()
+	values := make(map[string]float64)
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range pb.GetLabel() {
+			if l.GetName() == label {
+				values[l.GetValue()] = pb.GetGauge().GetValue()
+			}
+		}
+	}
+	return values
+}
+
+// equalValues reports whether the series values are the same, allowing for
+// the rounding of unit conversions
+func equalValues(got, want map[string]float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for key, v := range want {
+		if g, ok := got[key]; !ok || math.Abs(g-v) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSetPumpValveMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  []scraper.CDUParameter
+		percent map[string]float64
+		rpm     map[string]float64
+		valve   map[string]float64
+	}{
+		{
+			name:    "pump speed in percent",
+			params:  []scraper.CDUParameter{{Item: "Pump_1_Speed", Value: 45, Unit: "%"}},
+			percent: map[string]float64{"Pump_1": 45},
+		},
+		{
+			name:   "pump speed in rpm",
+			params: []scraper.CDUParameter{{Item: "Pump_2_Speed", Value: 2900, Unit: "rpm"}},
+			rpm:    map[string]float64{"Pump_2": 2900},
+		},
+		{
+			name:   "rpm in the item",
+			params: []scraper.CDUParameter{{Item: "Pumps_RPM", Value: 1500}},
+			rpm:    map[string]float64{"Pumps": 1500},
+		},
+		{
+			name:   "pump speed in an unknown unit",
+			params: []scraper.CDUParameter{{Item: "Pump_1_Speed", Value: 50, Unit: "Hz"}},
+		},
+		{
+			name:   "pump state",
+			params: []scraper.CDUParameter{{Item: "Pump_1_State", Value: 1, Tab: "Pumps"}},
+		},
+		{
+			name: "valve position",
+			params: []scraper.CDUParameter{
+				{Item: "Valve_Position", Value: 62.5, Unit: "%"},
+				{Item: "Bypass_Valve_Opening_Pct", Value: 10},
+			},
+			valve: map[string]float64{"Valve": 62.5, "Bypass_Valve": 10},
+		},
+		{
+			name:   "valve state",
+			params: []scraper.CDUParameter{{Item: "Valve_Open", Value: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, g := range []*prometheus.GaugeVec{pumpSpeedPercentGauge, pumpSpeedRPMGauge, valvePositionGauge} {
+				g.Reset()
+			}
+			setPumpValveMetrics("CDU_01", "38329", tt.params)
+			if got := seriesValues(t, pumpSpeedPercentGauge, "pump"); !equalValues(got, tt.percent) {
+				t.Errorf("bdx_cdu_pump_speed_percent = %v, want %v", got, tt.percent)
+			}
+			if got := seriesValues(t, pumpSpeedRPMGauge, "pump"); !equalValues(got, tt.rpm) {
+				t.Errorf("bdx_cdu_pump_speed_rpm = %v, want %v", got, tt.rpm)
+			}
+			if got := seriesValues(t, valvePositionGauge, "valve"); !equalValues(got, tt.valve) {
+				t.Errorf("bdx_cdu_valve_position_percent = %v, want %v", got, tt.valve)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package collector
+
+// markReady records that a source was collected successfully at least once
+func (c *Collector) markReady(source string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ready == nil {
+		c.ready = make(map[string]bool)
+	}
+	c.ready[source] = true
+}
+
+// PendingSources returns the enabled sources that were not collected
+// successfully yet. Paused sources and sources without a page to scrape
+// don't hold back readiness.
+func (c *Collector) PendingSources() []string {
+	cfg := c.Config()
+	enabled := map[string]bool{
+		"trh":    len(cfg.TRHTargets) > 0,
+		"cdu":    len(c.CDUTargets()) > 0,
+		"liquid": cfg.LiquidCoolingURL != "",
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pending := []string{}
+	for _, source := range Sources {
+		if enabled[source] && !c.paused[source] && !c.ready[source] {
+			pending = append(pending, source)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Threshold rule event states
+const (
+	RuleFiring   = "firing"
+	RuleResolved = "resolved"
+)
+
+var thresholdBreachGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "bdx_threshold_breach",
+	Help: "Readings breaching a threshold rule: 1 once the rule fires, 0 while the breach is shorter than its duration",
+}, []string{"rule", "severity", "source", "name", "item"})
+
+// RuleEvent is a threshold rule that started or stopped firing for a reading
+type RuleEvent struct {
+	Time     time.Time `json:"time"`
+	Rule     string    `json:"rule"`
+	Severity string    `json:"severity"`
+	Value    Value     `json:"value"`
+	State    string    `json:"state"`
+}
+
+// ruleState is a reading breaching a threshold rule
+type ruleState struct {
+	rule     string
+	severity string
+	since    time.Time
+	firing   bool
+	firedAt  time.Time
+	value    Value
+}
+
+// evaluateRules checks the latest readings against the threshold rules and
+// records the rules that started or stopped firing. Readings missing from
+// the collection, such as those of a target that failed to scrape, keep
+// their state.
+func (c *Collector) evaluateRules() {
+	cfg := c.Config()
+	values, err := c.Values(ValueFilter{})
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var events []RuleEvent
+	states := make(map[string]*ruleState)
+	for _, rule := range cfg.ThresholdRules {
+		filter := ValueFilter{Source: rule.Source, Target: rule.Target, Metric: rule.Metric, Labels: rule.Labels}
+		seen := make(map[string]bool)
+		for _, v := range values {
+			if !filter.Matches(v) {
+				continue
+			}
+			key := ruleKey(rule.Name, v)
+			seen[key] = true
+			prev := c.ruleStates[key]
+
+			if !rule.Breached(v.Value) {
+				if prev != nil && prev.firing {
+					events = append(events, RuleEvent{Time: now, Rule: rule.Name, Severity: rule.Severity, Value: v, State: RuleResolved})
+				}
+				continue
+			}
+
+			state := &ruleState{rule: rule.Name, severity: rule.Severity, since: now, value: v}
+			if prev != nil {
+				state.since, state.firing, state.firedAt = prev.since, prev.firing, prev.firedAt
+			}
+			if !state.firing && now.Sub(state.since) >= rule.For {
+				state.firing, state.firedAt = true, now
+				events = append(events, RuleEvent{Time: now, Rule: rule.Name, Severity: rule.Severity, Value: v, State: RuleFiring})
+			}
+			states[key] = state
+		}
+
+		for key, prev := range c.ruleStates {
+			if prev.rule == rule.Name && prev.severity == rule.Severity && !seen[key] {
+				states[key] = prev
+			}
+		}
+	}
+
+	thresholdBreachGauge.Reset()
+	for _, state := range states {
+		item := state.value.Labels["item"]
+		if item == "" {
+			item = state.value.Labels["type"]
+		}
+		firing := 0.0
+		if state.firing {
+			firing = 1
+		}
+		thresholdBreachGauge.WithLabelValues(state.rule, state.severity, state.value.Source, state.value.Target, item).Set(firing)
+	}
+
+	c.ruleStates = states
+	c.ruleEvents = append(c.ruleEvents, events...)
+	if size := cfg.EventBufferSize; len(c.ruleEvents) > size {
+		c.ruleEvents = append([]RuleEvent(nil), c.ruleEvents[len(c.ruleEvents)-size:]...)
+	}
+}
+
+// RuleEvents returns the threshold rule events after since, oldest first
+func (c *Collector) RuleEvents(since time.Time) []RuleEvent {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	events := []RuleEvent{}
+	for _, e := range c.ruleEvents {
+		if e.Time.After(since) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// FiringRules returns the threshold rules that are firing, as the events
+// that made them fire, sorted by rule and reading
+func (c *Collector) FiringRules() []RuleEvent {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0, len(c.ruleStates))
+	for key, state := range c.ruleStates {
+		if state.firing {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	events := make([]RuleEvent, 0, len(keys))
+	for _, key := range keys {
+		state := c.ruleStates[key]
+		events = append(events, RuleEvent{Time: state.firedAt, Rule: state.rule, Severity: state.severity, Value: state.value, State: RuleFiring})
+	}
+	return events
+}
+
+// ruleKey identifies the state of a rule for a reading. JSON sorts the label
+// names.
+func ruleKey(rule string, v Value) string {
+	labels, _ := json.Marshal(v.Labels)
+	return rule + "\x00" + v.Metric + "\x00" + string(labels)
+}
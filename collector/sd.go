@@ -0,0 +1,51 @@
+package collector
+
+// TargetGroup is a group of targets in the Prometheus HTTP service discovery
+// format
+type TargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// TargetGroups returns the scrape targets, or the ones of a source, as
+// Prometheus HTTP service discovery groups. Every group holds the URL of a
+// portal page with its source, name, cabinet ID and target labels as meta
+// labels.
+func (c *Collector) TargetGroups(source string) []TargetGroup {
+	cdus := make(map[string]TargetStatus)
+	for _, t := range c.Targets() {
+		if t.Source == "cdu" {
+			cdus[t.URL] = t
+		}
+	}
+
+	groups := []TargetGroup{}
+	add := func(src, url string, labels map[string]string) {
+		if source != "" && source != src {
+			return
+		}
+		labels["__meta_bdx_source"] = src
+		groups = append(groups, TargetGroup{Targets: []string{url}, Labels: labels})
+	}
+
+	cfg := c.Config()
+	for _, t := range cfg.TRHTargets {
+		labels := map[string]string{}
+		for name, value := range t.Labels {
+			labels["__meta_bdx_label_"+name] = value
+		}
+		add("trh", t.URL, labels)
+	}
+	for _, t := range c.CDUTargets() {
+		labels := map[string]string{"__meta_bdx_name": cdus[t.URL].Name}
+		if t.CabinetID != "" {
+			labels["__meta_bdx_cabinet_id"] = t.CabinetID
+		}
+		for name, value := range t.Labels {
+			labels["__meta_bdx_label_"+name] = value
+		}
+		add("cdu", t.URL, labels)
+	}
+	add("liquid", cfg.LiquidCoolingURL, map[string]string{})
+	return groups
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+var (
+	liquidPumpGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_liquid_pump",
+		Help: "Secondary loop pump and pump skid metrics of the liquid cooling overview",
+	}, []string{"section", "name", "type", "metrix_type"})
+
+	liquidManifoldGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_liquid_manifold",
+		Help: "Manifold metrics of the liquid cooling overview",
+	}, []string{"section", "name", "type", "metrix_type"})
+)
+
+// setLiquidSectionMetrics sets the metrics of the secondary loop pumps and
+// manifolds, with temperatures in the configured units like the CDU and
+// rack tables. The metrics are reset with the other liquid metrics before
+// the scrape, so a failed one leaves none.
+func setLiquidSectionMetrics(cfg *config.Config, readings []scraper.LiquidReading) {
+	for _, r := range readings {
+		gauge := liquidManifoldGauge
+		if r.Kind == scraper.SectionPump {
+			gauge = liquidPumpGauge
+		}
+		if r.Unit != "C" {
+			gauge.WithLabelValues(r.Section, r.Name, r.Item, r.Unit).Set(r.Value)
+			continue
+		}
+		if cfg.Temperature.Celsius() {
+			gauge.WithLabelValues(r.Section, r.Name, r.Item, "C").Set(r.Value)
+		}
+		if cfg.Temperature.Fahrenheit() {
+			gauge.WithLabelValues(r.Section, r.Name, r.Item, "F").Set(Fahrenheit(r.Value))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+var (
+	parameterActualGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu_parameter_actual",
+		Help: "Actual value of a CDU parameter that has a setpoint",
+	}, []string{"name", "cabinet_id", "parameter", "metrix_type"})
+
+	parameterSetpointGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu_parameter_setpoint",
+		Help: "Setpoint of a CDU parameter",
+	}, []string{"name", "cabinet_id", "parameter", "metrix_type"})
+
+	parameterDeviationGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_cdu_parameter_deviation",
+		Help: "Actual value minus setpoint of a CDU parameter",
+	}, []string{"name", "cabinet_id", "parameter", "metrix_type"})
+)
+
+// Words of the item of a parameter that tell it is a setpoint, or the
+// actual value of a parameter with a setpoint
+var (
+	setpointWords = []string{"setpoint", "set", "point", "sp"}
+	actualWords   = []string{"actual", "act", "pv", "value"}
+)
+
+// setSetpointMetrics exports the CDU parameters that have a setpoint with
+// their actual value, setpoint and deviation. The setpoint is either listed
+// next to the value, or a parameter of its own named like the actual one
+// with setpoint or sp added, such as Supply_Temp_Setpoint for Supply_Temp
+// or Supply_Temp_Actual.
+func setSetpointMetrics(name, cabinetID string, params []scraper.CDUParameter) {
+	for _, g := range []*prometheus.GaugeVec{parameterActualGauge, parameterSetpointGauge, parameterDeviationGauge} {
+		g.DeletePartialMatch(prometheus.Labels{"name": name})
+	}
+
+	// Setpoints of their own, by the type and item of their parameter
+	setpoints := make(map[[2]string]float64)
+	for _, param := range params {
+		if isSetpoint(param.Item) {
+			setpoints[[2]string{param.Tab, strings.ToLower(itemWithout(param.Item, setpointWords))}] = param.Value
+		}
+	}
+
+	for _, param := range params {
+		if isSetpoint(param.Item) {
+			continue
+		}
+		parameter := itemWithout(param.Item, actualWords)
+		setpoint, ok := setpoints[[2]string{param.Tab, strings.ToLower(parameter)}]
+		if param.Setpoint != nil {
+			setpoint, ok = *param.Setpoint, true
+		}
+		if !ok {
+			continue
+		}
+		parameterActualGauge.WithLabelValues(name, cabinetID, parameter, param.Unit).Set(param.Value)
+		parameterSetpointGauge.WithLabelValues(name, cabinetID, parameter, param.Unit).Set(setpoint)
+		parameterDeviationGauge.WithLabelValues(name, cabinetID, parameter, param.Unit).Set(param.Value - setpoint)
+	}
+}
+
+// isSetpoint reports whether the item of a parameter is a setpoint, such
+// as Supply_Temp_Setpoint, Supply_Temp_Set_Point or Supply_Temp_SP
+func isSetpoint(item string) bool {
+	words := strings.Split(strings.ToLower(item), "_")
+	if slices.Contains(words, "setpoint") || slices.Contains(words, "sp") {
+		return true
+	}
+	i := slices.Index(words, "set")
+	return i >= 0 && i+1 < len(words) && words[i+1] == "point"
+}
@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+func TestSetSetpointMetrics(t *testing.T) {
+	column := 110.0
+	tests := []struct {
+		name      string
+		params    []scraper.CDUParameter
+		actual    map[string]float64
+		setpoint  map[string]float64
+		deviation map[string]float64
+	}{
+		{
+			name: "setpoint parameter",
+			params: []scraper.CDUParameter{
+				{Item: "Supply_Temp", Value: 18.5, Unit: "°C"},
+				{Item: "Supply_Temp_Setpoint", Value: 18, Unit: "°C"},
+			},
+			actual:    map[string]float64{"Supply_Temp": 18.5},
+			setpoint:  map[string]float64{"Supply_Temp": 18},
+			deviation: map[string]float64{"Supply_Temp": 0.5},
+		},
+		{
+			name: "actual and sp",
+			params: []scraper.CDUParameter{
+				{Item: "Return_Temp_SP", Value: 30},
+				{Item: "Return_Temp_Actual", Value: 28},
+				{Item: "DP_Set_Point", Value: 100},
+				{Item: "DP_PV", Value: 104},
+			},
+			actual:    map[string]float64{"Return_Temp": 28, "DP": 104},
+			setpoint:  map[string]float64{"Return_Temp": 30, "DP": 100},
+			deviation: map[string]float64{"Return_Temp": -2, "DP": 4},
+		},
+		{
+			name:      "setpoint column",
+			params:    []scraper.CDUParameter{{Item: "Flow", Value: 100, Unit: "l/min", Setpoint: &column}},
+			actual:    map[string]float64{"Flow": 100},
+			setpoint:  map[string]float64{"Flow": 110},
+			deviation: map[string]float64{"Flow": -10},
+		},
+		{
+			name: "setpoint of another tab",
+			params: []scraper.CDUParameter{
+				{Item: "Supply_Temp", Value: 18.5},
+				{Item: "Supply_Temp_Setpoint", Value: 18, Tab: "Settings"},
+			},
+		},
+		{
+			name: "no setpoint",
+			params: []scraper.CDUParameter{
+				{Item: "Supply_Temp", Value: 18.5},
+				{Item: "Setup_Mode", Value: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, g := range []*prometheus.GaugeVec{parameterActualGauge, parameterSetpointGauge, parameterDeviationGauge} {
+				g.Reset()
+			}
+			setSetpointMetrics("CDU_01", "38329", tt.params)
+			if got := seriesValues(t, parameterActualGauge, "parameter"); !equalValues(got, tt.actual) {
+				t.Errorf("bdx_cdu_parameter_actual = %v, want %v", got, tt.actual)
+			}
+			if got := seriesValues(t, parameterSetpointGauge, "parameter"); !equalValues(got, tt.setpoint) {
+				t.Errorf("bdx_cdu_parameter_setpoint = %v, want %v", got, tt.setpoint)
+			}
+			if got := seriesValues(t, parameterDeviationGauge, "parameter"); !equalValues(got, tt.deviation) {
+				t.Errorf("bdx_cdu_parameter_deviation = %v, want %v", got, tt.deviation)
+			}
+		})
+	}
+}
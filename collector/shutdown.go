@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"log"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+// abortTimeout bounds the wait for a collection cycle to return once its
+// scrapes were aborted
+const abortTimeout = 10 * time.Second
+
+// Shutdown waits up to grace for the running collection cycle to finish and
+// keeps new ones from starting. When the grace period runs out, the scrapes
+// in progress are aborted, which closes their browsers. Browser processes
+// still running afterwards are killed.
+func (c *Collector) Shutdown(grace time.Duration) {
+	// The cycle lock is never released, so no collection starts anymore
+	idle := make(chan struct{})
+	go func() {
+		c.cycle.Lock()
+		close(idle)
+	}()
+
+	if busy := c.BusySince(); !busy.IsZero() {
+		c.logf("Waiting up to %s for the running collection cycle to finish", grace)
+	}
+	select {
+	case <-idle:
+	case <-time.After(grace):
+		c.logf("Collection cycle did not finish within %s, aborting its scrapes", grace)
+		scraper.AbortScrapes()
+		select {
+		case <-idle:
+		case <-time.After(abortTimeout):
+			c.logf("Collection cycle did not return within %s of aborting its scrapes", abortTimeout)
+		}
+	}
+	scraper.AbortScrapes()
+
+	if n := killBrowsers(); n > 0 {
+		log.Printf("Killed %d browser processes left behind by the scrapes", n)
+	}
+}
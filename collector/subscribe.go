@@ -0,0 +1,41 @@
+package collector
+
+// Subscribe returns a channel that receives a notification after every
+// collection cycle, and a function that cancels the subscription. A slow
+// subscriber misses notifications rather than blocking collection.
+func (c *Collector) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	c.mu.Lock()
+	if c.subscribers == nil {
+		c.subscribers = make(map[chan struct{}]struct{})
+	}
+	c.subscribers[ch] = struct{}{}
+	c.mu.Unlock()
+
+	return ch, func() {
+		c.mu.Lock()
+		delete(c.subscribers, ch)
+		c.mu.Unlock()
+	}
+}
+
+// notify wakes up every subscriber
+func (c *Collector) notify() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for ch := range c.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// cycleCompleted records the alarm transitions of a finished collection,
+// evaluates the threshold rules and wakes up the subscribers
+func (c *Collector) cycleCompleted() {
+	c.trackAlarms()
+	c.evaluateRules()
+	c.notify()
+}
@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	scrapeDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bdx_scrape_duration_seconds",
+		Help:    "Duration of the scrapes of a page of the portal, successful or not",
+		Buckets: []float64{0.25, 0.5, 1, 2, 5, 10, 15, 20, 30, 60},
+	}, []string{"source", "target"})
+
+	scrapeErrorsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bdx_scrape_errors_total",
+		Help: "Number of failed scrapes of a page of the portal, by error class",
+	}, []string{"source", "class"})
+)
+
+// scrapeResult is the outcome of the last scrape of a target
+type scrapeResult struct {
+	time     time.Time
+	duration time.Duration
+	err      error
+}
+
+// TargetStatus is the state of a scrape target, like a row of the Prometheus
+// targets page
+type TargetStatus struct {
+	Source       string     `json:"source"`
+	Name         string     `json:"name,omitempty"`
+	URL          string     `json:"url"`
+	Health       string     `json:"health"`
+	Paused       bool       `json:"paused"`
+	LastScrape   *time.Time `json:"last_scrape,omitempty"`
+	LastDuration float64    `json:"last_scrape_duration_seconds"`
+	LastError    string     `json:"last_error"`
+	ErrorClass   string     `json:"error_class,omitempty"`
+	NextScrape   *time.Time `json:"next_scrape,omitempty"`
+}
+
+// recordScrape records the outcome of a scrape of a source that started at
+// start
+func (c *Collector) recordScrape(source, url string, start time.Time, err *error) {
+	duration := time.Since(start)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scrapes == nil {
+		c.scrapes = make(map[string]scrapeResult)
+	}
+	c.scrapes[url] = scrapeResult{time: start, duration: duration, err: *err}
+	scrapeDurationHistogram.WithLabelValues(source, c.scrapeTarget(source, url)).Observe(duration.Seconds())
+	if *err != nil {
+		scrapeErrorsCounter.WithLabelValues(source, errorClass(*err)).Inc()
+	}
+}
+
+// scrapeTarget returns the target label of a scrape: the name of a CDU, or
+// empty for the single page of the other sources. The caller holds the lock.
+func (c *Collector) scrapeTarget(source, url string) string {
+	if source != "cdu" {
+		return ""
+	}
+	if name, ok := c.cduNames[url]; ok {
+		return name
+	}
+	for _, t := range c.targets {
+		if t.URL == url {
+			return cduName(t, "")
+		}
+	}
+	return url
+}
+
+// Targets returns the state of every scrape target
+func (c *Collector) Targets() []TargetStatus {
+	cfg := c.Config()
+	var targets []TargetStatus
+	for _, t := range cfg.TRHTargets {
+		targets = append(targets, TargetStatus{Source: "trh", URL: t.URL})
+	}
+	for _, t := range c.CDUTargets() {
+		targets = append(targets, TargetStatus{Source: "cdu", Name: t.Name, URL: t.URL})
+	}
+	targets = append(targets, TargetStatus{Source: "liquid", URL: cfg.LiquidCoolingURL})
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var next *time.Time
+	if !c.lastCycle.IsZero() {
+		t := c.lastCycle.Add(cfg.ScrapeInterval)
+		next = &t
+	}
+
+	for i := range targets {
+		t := &targets[i]
+		t.Paused = c.paused[t.Source]
+		if !t.Paused {
+			t.NextScrape = next
+		}
+
+		// Unnamed CDUs are known by the name found on their page
+		if t.Name == "" && t.Source == "cdu" {
+			for name, parsed := range c.parsedCDU {
+				if parsed.URL == t.URL {
+					t.Name = name
+				}
+			}
+		}
+
+		result, ok := c.scrapes[t.URL]
+		switch {
+		case !ok:
+			t.Health = "unknown"
+			continue
+		case result.err != nil:
+			t.Health = "down"
+			t.LastError = result.err.Error()
+			t.ErrorClass = errorClass(result.err)
+		default:
+			t.Health = "up"
+		}
+		t.LastScrape = &result.time
+		t.LastDuration = result.duration.Seconds()
+	}
+	return targets
+}
+
+// SessionExpired reports whether the last scrape of a target failed because
+// the portal didn't accept the session
+func (c *Collector) SessionExpired() bool {
+	for _, t := range c.Targets() {
+		if t.ErrorClass == errorClassAuth {
+			return true
+		}
+	}
+	return false
+}
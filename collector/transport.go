@@ -0,0 +1,219 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"sort"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+// chromeTLSVersions maps the TLS version names to the values of Chrome's
+// --ssl-version-min
+var chromeTLSVersions = map[string]string{
+	"TLS10": "tls1",
+	"TLS11": "tls1.1",
+	"TLS12": "tls1.2",
+	"TLS13": "tls1.3",
+}
+
+// newHTTPClient creates the HTTP client of the portal, which connects
+// through the configured proxy with the configured TLS and connection
+// settings, within the limits of limiter
+func newHTTPClient(cfg *config.Config, limiter *hostLimiter) *http.Client {
+	limited := &limitTransport{base: newTransport(cfg), limiter: limiter, limits: cfg.PortalLimit}
+	headers := cfg.PortalHeaders()
+	if len(headers) == 0 {
+		return &http.Client{Timeout: cfg.HTTPTimeout, Transport: limited}
+	}
+	return &http.Client{Timeout: cfg.HTTPTimeout, Transport: &headerTransport{base: limited, headers: headers}}
+}
+
+// newTransport creates the transport of the portal connections with the
+// configured proxy, TLS, DNS and connection settings
+func newTransport(cfg *config.Config) *http.Transport {
+	proxy := cfg.Proxy.Func()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+	transport.IdleConnTimeout = cfg.Transport.IdleConnTimeout
+	transport.MaxIdleConns = cfg.Transport.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.Transport.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = cfg.Transport.MaxConnsPerHost
+	transport.DisableKeepAlives = cfg.Transport.DisableKeepAlives
+	dialer := &net.Dialer{Timeout: cfg.Transport.DialTimeout, KeepAlive: cfg.Transport.KeepAlive}
+	transport.DialContext = dialer.DialContext
+	// The settings are validated on load, fall back to the defaults if a
+	// file became unreadable since
+	if tlsConfig, err := cfg.TargetTLS.ClientConfig(); err != nil {
+		log.Printf("Failed to set up TLS of the portal client, using the defaults: %v", err)
+	} else {
+		transport.TLSClientConfig = tlsConfig
+	}
+	if cfg.DNS.Enabled() {
+		dialer.Resolver = cfg.DNS.Resolver()
+		overrides := cfg.DNS.HostOverrides
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if host, port, err := net.SplitHostPort(addr); err == nil {
+				if ip, ok := overrides[host]; ok {
+					addr = net.JoinHostPort(ip, port)
+				}
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+	return transport
+}
+
+// headerTransport adds the configured headers to the requests that don't
+// set them already
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// hostResolverRules returns the --host-resolver-rules of the browser: the
+// host overrides, and the host of pageURL resolved by the custom DNS server
+// unless the page is reached through a proxy, which resolves it itself
+func hostResolverRules(cfg *config.Config, pageURL string, proxied bool) ([]string, error) {
+	if !cfg.DNS.Enabled() {
+		return nil, nil
+	}
+	ips := make(map[string]string)
+	for host, ip := range cfg.DNS.HostOverrides {
+		ips[host] = ip
+	}
+	if u, err := url.Parse(pageURL); err == nil && !proxied && cfg.DNS.Server != "" {
+		host := u.Hostname()
+		if _, err := netip.ParseAddr(host); err != nil && ips[host] == "" {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPTimeout)
+			defer cancel()
+			ip, err := cfg.DNS.Lookup(ctx, host)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+			}
+			ips[host] = ip
+		}
+	}
+
+	rules := make([]string, 0, len(ips))
+	for host, ip := range ips {
+		if addr, err := netip.ParseAddr(ip); err == nil && addr.Is6() {
+			ip = "[" + ip + "]"
+		}
+		rules = append(rules, "MAP "+host+" "+ip)
+	}
+	sort.Strings(rules)
+	return rules, nil
+}
+
+// BrowserOptions returns the settings of the browser that renders pageURL.
+// A target override of the proxy takes precedence over the configured one,
+// see config.ProxyConfig.For.
+func BrowserOptions(cfg *config.Config, pageURL, proxyOverride string) (scraper.Browser, error) {
+	proxy, err := cfg.Proxy.For(pageURL, proxyOverride)
+	if err != nil {
+		return scraper.Browser{}, err
+	}
+	spkis, err := cfg.TargetTLS.CASPKIHashes()
+	if err != nil {
+		return scraper.Browser{}, err
+	}
+	if len(spkis) > 0 && !cfg.TargetTLS.InsecureSkipVerify {
+		chain, err := chainSPKIHashes(cfg, pageURL, proxy)
+		if err != nil {
+			return scraper.Browser{}, err
+		}
+		spkis = append(spkis, chain...)
+		slices.Sort(spkis)
+		spkis = slices.Compact(spkis)
+	}
+	rules, err := hostResolverRules(cfg, pageURL, proxy != "")
+	if err != nil {
+		return scraper.Browser{}, err
+	}
+	// The User-Agent is set on the browser so scripts see it as well
+	headers := cfg.PortalHeaders()
+	userAgent := headers["User-Agent"]
+	delete(headers, "User-Agent")
+	return scraper.Browser{
+		Proxy:              proxy,
+		HostResolverRules:  rules,
+		UserAgent:          userAgent,
+		Headers:            headers,
+		LoginURL:           cfg.LoginURL,
+		MaxPageSize:        cfg.Limits.MaxPageSize,
+		TruncatePage:       cfg.Limits.Action == config.LimitActionTruncate,
+		TrustedSPKIs:       spkis,
+		MinTLSVersion:      chromeTLSVersions[cfg.TargetTLS.MinVersion],
+		InsecureSkipVerify: cfg.TargetTLS.InsecureSkipVerify,
+	}, nil
+}
+
+// chainSPKIHashes connects to the host of pageURL through proxy and returns
+// the hashes of the certificate chain it presents, once verified against the
+// configured CAs. Chrome only matches --ignore-certificate-errors-spki-list
+// against the certificates of the chain it could build, which lacks the CA
+// when the server doesn't send it, so the leaf and intermediates are trusted
+// as well. The hashes are read for every browser, following renewals.
+func chainSPKIHashes(cfg *config.Config, pageURL, proxy string) ([]string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", pageURL, err)
+	}
+	if u.Scheme != "https" {
+		return nil, nil
+	}
+
+	transport := newTransport(cfg)
+	defer transport.CloseIdleConnections()
+	transport.Proxy = nil
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %w", proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the certificate chain of %s: %w", u.Host, err)
+	}
+	resp.Body.Close()
+
+	var hashes []string
+	if resp.TLS != nil {
+		for _, chain := range resp.TLS.VerifiedChains {
+			for _, cert := range chain {
+				hashes = append(hashes, config.SPKIHash(cert))
+			}
+		}
+	}
+	return hashes, nil
+}
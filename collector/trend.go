@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// trendTimeFormat is the format of the times of the trend page of the portal
+const trendTimeFormat = "2006-01-02 15:04:05"
+
+// TrendPoint is a past reading of a TRH sensor
+type TrendPoint struct {
+	Time time.Time
+	// Temp and RH are not valid when the sensor reported no reading at that
+	// time, as TempOK and RHOK tell
+	Temp   float64
+	TempOK bool
+	RH     float64
+	RHOK   bool
+}
+
+// trendData is a point of the trend data of the portal
+type trendData struct {
+	Time interface{} `json:"time"`
+	Temp interface{} `json:"temp"`
+	RH   interface{} `json:"rh"`
+}
+
+// TRHSensors returns the names of the sensors of a TRH endpoint
+func (c *Collector) TRHSensors(ctx context.Context, target config.TRHTarget) ([]string, error) {
+	cfg, client := c.settings()
+	body, err := postTRH(ctx, cfg, client, target, url.Values{"action": {"inf"}})
+	if err != nil {
+		return nil, err
+	}
+	var sensors []SensorData
+	if err := json.Unmarshal(body, &sensors); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal JSON: %w", errPayload, err)
+	}
+	names := make([]string, 0, len(sensors))
+	seen := make(map[string]bool)
+	for _, sensor := range sensors {
+		if !seen[sensor.Label] {
+			seen[sensor.Label] = true
+			names = append(names, sensor.Label)
+		}
+	}
+	return names, nil
+}
+
+// TRHTrend returns the readings of a sensor of a TRH endpoint from the trend
+// page of the portal, in time order, from from up to but not including to.
+// Times without a zone are in loc, the time zone of the portal.
+func (c *Collector) TRHTrend(ctx context.Context, target config.TRHTarget, sensor string, from, to time.Time, loc *time.Location) ([]TrendPoint, error) {
+	cfg, client := c.settings()
+	body, err := postTRH(ctx, cfg, client, target, url.Values{
+		"action": {"trend"},
+		"label":  {sensor},
+		"start":  {from.In(loc).Format(trendTimeFormat)},
+		"end":    {to.In(loc).Format(trendTimeFormat)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var data []trendData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal JSON: %w", errPayload, err)
+	}
+	points := make([]TrendPoint, 0, len(data))
+	for _, d := range data {
+		t, err := parseTrendTime(d.Time, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errPayload, err)
+		}
+		if t.Before(from) || !t.Before(to) {
+			continue
+		}
+		p := TrendPoint{Time: t}
+		p.Temp, err = parseValue(d.Temp)
+		p.TempOK = err == nil
+		p.RH, err = parseValue(d.RH)
+		p.RHOK = err == nil
+		points = append(points, p)
+	}
+	slices.SortFunc(points, func(a, b TrendPoint) int { return a.Time.Compare(b.Time) })
+	return points, nil
+}
+
+// parseTrendTime parses the time of a trend point, either a Unix timestamp
+// in seconds or a time in the format of the portal
+func parseTrendTime(v interface{}, loc *time.Location) (time.Time, error) {
+	switch val := v.(type) {
+	case float64:
+		return time.Unix(int64(val), 0), nil
+	case string:
+		if sec, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return time.Unix(sec, 0), nil
+		}
+		if t, err := time.Parse(time.RFC3339, val); err == nil {
+			return t, nil
+		}
+		t, err := time.ParseInLocation(trendTimeFormat, val, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid trend time %q: %w", val, err)
+		}
+		return t, nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported trend time type: %T", v)
+	}
+}
+
+// TRHSeriesLabels returns the labels of the series of a sensor of a TRH
+// endpoint, other than the metric name, as the exporter would export them
+func (c *Collector) TRHSeriesLabels(target config.TRHTarget, sensor string) map[string]string {
+	cfg, _ := c.settings()
+	c.mu.RLock()
+	names := c.trhLabels
+	c.mu.RUnlock()
+
+	labels := maps.Clone(cfg.ConstantLabels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels["name"] = sensor
+	for i, value := range trhLabelValues(cfg, target, sensor, names) {
+		// Empty labels are the same as missing ones in Prometheus
+		if value != "" {
+			labels[names[i]] = value
+		}
+	}
+	return labels
+}
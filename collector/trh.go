@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// trhGauges are the temperature, humidity and sensor up metrics. They carry
+// the labels configured on the TRH targets, so their label set is only known
+// once the configuration is loaded.
+type trhGauges struct {
+	temperature *prometheus.GaugeVec
+	fahrenheit  *prometheus.GaugeVec
+	humidity    *prometheus.GaugeVec
+	up          *prometheus.GaugeVec
+}
+
+// newTRHGauges creates the TRH metrics with the extra labels
+func newTRHGauges(extraLabels []string) trhGauges {
+	labels := append([]string{"name"}, extraLabels...)
+	return trhGauges{
+		temperature: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bdx_temperature",
+			Help: "Current temperature reading in Celsius",
+		}, labels),
+		fahrenheit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bdx_temperature_fahrenheit",
+			Help: "Current temperature reading in Fahrenheit",
+		}, labels),
+		humidity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bdx_humidity",
+			Help: "Current relative humidity percentage",
+		}, labels),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bdx_sensor_up",
+			Help: "Whether the TRH sensor reported a reading (1) or none, such as N/A (0)",
+		}, labels),
+	}
+}
+
+// deleteTarget drops the series of the sensors of a TRH target, all of them
+// when the target has no labels as it is the only one
+func (g trhGauges) deleteTarget(target config.TRHTarget) {
+	if len(target.Labels) == 0 {
+		g.temperature.Reset()
+		g.fahrenheit.Reset()
+		g.humidity.Reset()
+		g.up.Reset()
+		return
+	}
+	g.temperature.DeletePartialMatch(target.Labels)
+	g.fahrenheit.DeletePartialMatch(target.Labels)
+	g.humidity.DeletePartialMatch(target.Labels)
+	g.up.DeletePartialMatch(target.Labels)
+}
+
+// trhAggregates collects the readings of a TRH collection for the zone and
+// aisle metrics
+type trhAggregates struct {
+	zones  zoneReadings
+	aisles aisleReadings
+}
+
+// newTRHAggregates returns empty aggregates
+func newTRHAggregates() trhAggregates {
+	return trhAggregates{zones: make(zoneReadings), aisles: make(aisleReadings)}
+}
+
+// add adds the readings of a sensor with labels to its zone and aisle
+func (a trhAggregates) add(cfg *config.Config, sensor string, labels map[string]string, temperature, humidity float64) {
+	if zone := cfg.Zones.Zone(sensor, labels); zone != "" {
+		a.zones.add(zone, temperature, humidity)
+	}
+	if aisle, row := labels["aisle"], labels[cfg.Aisles.RowLabel]; cfg.Aisles.Enabled() && aisle != "" && row != "" {
+		a.aisles.add(row, aisle, temperature)
+	}
+}
+
+// set replaces the zone and aisle metrics with the aggregates
+func (a trhAggregates) set(cfg *config.Config) {
+	setZoneMetrics(cfg, a.zones)
+	setAisleMetrics(cfg, a.aisles)
+}
+
+// trhLabelValues returns the values of the extra labels of a sensor of
+// target. The labels of the target take precedence over the aisle and those
+// of the label rules, which take precedence over those split from the
+// sensor name.
+func trhLabelValues(cfg *config.Config, target config.TRHTarget, sensor string, labels []string) []string {
+	fromRules := cfg.RuleLabels(config.LabelRuleSensor, sensor)
+	if aisle := cfg.Aisles.Aisle(sensor); aisle != "" {
+		if fromRules == nil {
+			fromRules = make(map[string]string)
+		}
+		fromRules["aisle"] = aisle
+	}
+	fromName := cfg.SensorNames.Labels(sensor)
+	values := make([]string, len(labels))
+	for i, name := range labels {
+		if value, ok := target.Labels[name]; ok {
+			values[i] = value
+		} else if value, ok := fromRules[name]; ok {
+			values[i] = value
+		} else {
+			values[i] = fromName[name]
+		}
+	}
+	return values
+}
@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// Sources are the data sources collected by every cycle
+var Sources = []string{"trh", "cdu", "liquid"}
+
+// ErrUnknownTarget is returned when collecting a target that is not configured
+var ErrUnknownTarget = errors.New("unknown CDU target")
+
+// CollectSource collects a single source out of band. It waits for a cycle
+// that is already running to finish.
+func (c *Collector) CollectSource(ctx context.Context, source string) error {
+	c.cycle.Lock()
+	defer c.cycle.Unlock()
+	c.beginCycle(ctx)
+	defer c.endCycle()
+
+	cfg, client := c.settings()
+	var err error
+	switch source {
+	case "trh":
+		err = c.collectTRH(cfg, client)
+	case "cdu":
+		err = c.collectCDU(cfg)
+	case "liquid":
+		err = c.collectLiquidCooling(cfg)
+	default:
+		return fmt.Errorf("unknown source %q", source)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to collect %s data: %w", source, err)
+	}
+	c.markReady(source)
+
+	c.cycleCompleted()
+	return nil
+}
+
+// CollectTarget collects a single CDU target out of band. The target is given
+// by its name, cabinet ID or URL.
+func (c *Collector) CollectTarget(ctx context.Context, name string) error {
+	c.cycle.Lock()
+	defer c.cycle.Unlock()
+	c.beginCycle(ctx)
+	defer c.endCycle()
+
+	target, ok := c.findCDUTarget(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTarget, name)
+	}
+
+	cfg, _ := c.settings()
+	c.mu.Lock()
+	cduGauge, cduLabels := c.cduGauge, c.cduLabels
+	if c.parsedCDU == nil {
+		c.parsedCDU = make(map[string]ParsedCDU)
+	}
+	c.mu.Unlock()
+
+	if _, _, err := c.collectCDUTarget(cfg, target, cduGauge, cduLabels); err != nil {
+		return fmt.Errorf("failed to collect CDU %s: %w", name, err)
+	}
+	c.markReady("cdu")
+
+	c.cycleCompleted()
+	return nil
+}
+
+// findCDUTarget looks up a CDU target by name, cabinet ID or URL. Targets
+// without an alias are matched by the name their last scrape found.
+func (c *Collector) findCDUTarget(name string) (config.CDUTarget, bool) {
+	c.mu.RLock()
+	scraped, ok := c.parsedCDU[name]
+	c.mu.RUnlock()
+
+	for _, t := range c.CDUTargets() {
+		if t.Name == name || t.CabinetID == name || t.URL == name || (ok && t.URL == scraped.URL) {
+			return t, true
+		}
+	}
+	return config.CDUTarget{}, false
+}
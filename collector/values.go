@@ -0,0 +1,97 @@
+package collector
+
+// MetricSources maps the metrics holding collected readings to their source
+var MetricSources = map[string]string{
+	"bdx_temperature":                                  "trh",
+	"bdx_temperature_fahrenheit":                       "trh",
+	"bdx_humidity":                                     "trh",
+	"bdx_cdu":                                          "cdu",
+	"bdx_cdu_pump_speed_percent":                       "cdu",
+	"bdx_cdu_pump_speed_rpm":                           "cdu",
+	"bdx_cdu_valve_position_percent":                   "cdu",
+	"bdx_cdu_filter_differential_pressure_kpa":         "cdu",
+	"bdx_cdu_coolant_conductivity_microsiemens_per_cm": "cdu",
+	"bdx_cdu_coolant_ph":                               "cdu",
+	"bdx_cdu_coolant_glycol_percent":                   "cdu",
+	"bdx_cdu_leak_detected":                            "cdu",
+	"bdx_cdu_parameter_actual":                         "cdu",
+	"bdx_cdu_parameter_setpoint":                       "cdu",
+	"bdx_cdu_parameter_deviation":                      "cdu",
+	"bdx_liquid":                                       "liquid",
+	"bdx_liquid_rack":                                  "liquid",
+	"bdx_liquid_pump":                                  "liquid",
+	"bdx_liquid_manifold":                              "liquid",
+}
+
+// Value is a single reading from the latest collection
+type Value struct {
+	Source string            `json:"source"`
+	Target string            `json:"target"`
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// ValueFilter selects values; empty fields match everything
+type ValueFilter struct {
+	Source string
+	Target string
+	Metric string
+	Labels map[string]string
+}
+
+// Matches reports whether the value passes the filter
+func (f ValueFilter) Matches(v Value) bool {
+	if f.Source != "" && f.Source != v.Source {
+		return false
+	}
+	if f.Target != "" && f.Target != v.Target {
+		return false
+	}
+	if f.Metric != "" && f.Metric != v.Metric {
+		return false
+	}
+	for name, value := range f.Labels {
+		if v.Labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Values returns the latest collected readings that match the filter. The
+// target of a value is its name label.
+func (c *Collector) Values(f ValueFilter) ([]Value, error) {
+	mfs, err := c.Gatherer().Gather()
+	if err != nil {
+		return nil, err
+	}
+	cfg, _ := c.settings()
+
+	values := []Value{}
+	for _, mf := range mfs {
+		source, ok := MetricSources[mf.GetName()]
+		if !ok && cfg.ParameterMetrics.Named(mf.GetName()) {
+			source, ok = "cdu", true
+		}
+		if !ok {
+			continue
+		}
+		for _, m := range mf.Metric {
+			v := Value{
+				Source: source,
+				Metric: mf.GetName(),
+				Labels: make(map[string]string, len(m.Label)),
+				Value:  m.GetGauge().GetValue(),
+			}
+			for _, lp := range m.Label {
+				v.Labels[lp.GetName()] = lp.GetValue()
+			}
+			v.Target = v.Labels["name"]
+			if f.Matches(v) {
+				values = append(values, v)
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+var (
+	zoneTemperatureGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_zone_temperature",
+		Help: "Average, minimum and maximum temperature in Celsius of the TRH sensors of a zone",
+	}, []string{"zone", "stat"})
+
+	zoneTemperatureFahrenheitGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_zone_temperature_fahrenheit",
+		Help: "Average, minimum and maximum temperature in Fahrenheit of the TRH sensors of a zone",
+	}, []string{"zone", "stat"})
+
+	zoneHumidityGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_zone_humidity",
+		Help: "Average, minimum and maximum relative humidity percentage of the TRH sensors of a zone",
+	}, []string{"zone", "stat"})
+
+	zoneSensorsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_zone_sensors",
+		Help: "Number of TRH sensors of a zone with a reading in the last collection",
+	}, []string{"zone"})
+)
+
+// zoneStats accumulates the readings of the sensors of a zone
+type zoneStats struct {
+	temperature, humidity summary
+	sensors               int
+}
+
+// summary is the average, minimum and maximum of a set of readings
+type summary struct {
+	sum, min, max float64
+	n             int
+}
+
+// add adds a reading to the summary
+func (s *summary) add(v float64) {
+	if s.n == 0 {
+		s.min, s.max = v, v
+	}
+	s.sum += v
+	s.min = math.Min(s.min, v)
+	s.max = math.Max(s.max, v)
+	s.n++
+}
+
+// avg returns the average of the readings
+func (s summary) avg() float64 {
+	return s.sum / float64(s.n)
+}
+
+// set sets the avg, min and max series of a zone, converted by convert
+func (s summary) set(gauge *prometheus.GaugeVec, zone string, convert func(float64) float64) {
+	gauge.WithLabelValues(zone, "avg").Set(convert(s.avg()))
+	gauge.WithLabelValues(zone, "min").Set(convert(s.min))
+	gauge.WithLabelValues(zone, "max").Set(convert(s.max))
+}
+
+// zoneReadings collects the readings of a collection by zone
+type zoneReadings map[string]*zoneStats
+
+// add adds the readings of a sensor to its zone
+func (z zoneReadings) add(zone string, temperature, humidity float64) {
+	stats, ok := z[zone]
+	if !ok {
+		stats = &zoneStats{}
+		z[zone] = stats
+	}
+	stats.temperature.add(temperature)
+	stats.humidity.add(humidity)
+	stats.sensors++
+}
+
+// setZoneMetrics replaces the zone metrics with the readings of the last
+// collection
+func setZoneMetrics(cfg *config.Config, zones zoneReadings) {
+	zoneTemperatureGauge.Reset()
+	zoneTemperatureFahrenheitGauge.Reset()
+	zoneHumidityGauge.Reset()
+	zoneSensorsGauge.Reset()
+
+	celsius := func(v float64) float64 { return v }
+	for zone, stats := range zones {
+		if cfg.Temperature.Celsius() {
+			stats.temperature.set(zoneTemperatureGauge, zone, celsius)
+		}
+		if cfg.Temperature.Fahrenheit() {
+			stats.temperature.set(zoneTemperatureFahrenheitGauge, zone, Fahrenheit)
+		}
+		stats.humidity.set(zoneHumidityGauge, zone, celsius)
+		zoneSensorsGauge.WithLabelValues(zone).Set(float64(stats.sensors))
+	}
+}
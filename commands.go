@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/promrules"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/tunnel"
+)
+
+// scrapeOnce runs a single collection cycle and writes the resulting metrics
+// to stdout in the Prometheus text format
+func scrapeOnce(args []string) int {
+	fs := flag.NewFlagSet("scrape-once", flag.ExitOnError)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+
+	stopTunnel, err := tunnel.Start(context.Background(), cfg.Tunnel, cfg.HTTPTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start SSH tunnel: %v\n", err)
+		return 1
+	}
+	defer stopTunnel()
+
+	col := collector.NewCollector(cfg)
+	if err := col.Discover(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to discover CDU targets: %v\n", err)
+	}
+	col.Collect(context.Background())
+
+	families, err := col.Gatherer().Gather()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to gather metrics: %v\n", err)
+		return 1
+	}
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(os.Stdout, mf); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write metrics: %v\n", err)
+			return 1
+		}
+	}
+
+	if _, lastSuccess := col.GetHealthStatus(); !lastSuccess {
+		return 1
+	}
+	return 0
+}
+
+// validateConfig loads and validates the configuration, printing every
+// problem found, and returns the process exit code
+func validateConfig(args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	checkHosts := fs.Bool("check-hosts", false, "Also check that every configured host is reachable")
+	dialTimeout := fs.Duration("dial-timeout", 5*time.Second, "Timeout for each reachability check")
+
+	cfg, err := readConfig(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+
+	errs := cfg.Validate()
+	if *checkHosts {
+		errs = append(errs, cfg.CheckReachability(*dialTimeout)...)
+	}
+
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "Configuration is invalid (%d problems):\n", len(errs))
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "  - %v\n", err)
+		}
+		return 1
+	}
+
+	fmt.Println("Configuration is valid")
+	return 0
+}
+
+// login signs in to the portal and prints the session cookies in .env format
+func login(args []string) int {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	loginURL := fs.String("login-url", "", "Portal login page URL (LOGIN_URL)")
+	username := fs.String("username", "", "Portal username (BDX_USERNAME)")
+	password := fs.String("password", "", "Portal password (BDX_PASSWORD)")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	if *loginURL != "" {
+		cfg.LoginURL = *loginURL
+	}
+	if *username != "" {
+		cfg.Username = *username
+	}
+	if *password != "" {
+		cfg.Password = *password
+	}
+	if cfg.Username == "" || cfg.Password == "" {
+		fmt.Fprintln(os.Stderr, "Username and password are required (BDX_USERNAME/BDX_PASSWORD or -username/-password)")
+		return 1
+	}
+
+	stopTunnel, err := tunnel.Start(context.Background(), cfg.Tunnel, cfg.HTTPTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start SSH tunnel: %v\n", err)
+		return 1
+	}
+	defer stopTunnel()
+
+	browser, err := collector.BrowserOptions(cfg, cfg.LoginURL, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up the browser: %v\n", err)
+		return 1
+	}
+	sessMap, phpSessID, err := scraper.Login(cfg.LoginURL, browser, cfg.Username, cfg.Password, cfg.ScrapeTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Login failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("SESS_MAP=%s\n", sessMap)
+	fmt.Printf("PHPSESSID=%s\n", phpSessID)
+	return 0
+}
+
+// generateRules writes a Prometheus rule file alerting on the configured
+// threshold rules and on the metrics of the exporter
+func generateRules(args []string) int {
+	fs := flag.NewFlagSet("rules", flag.ExitOnError)
+	job := fs.String("job", "bdx-exporter", "Job name Prometheus scrapes the exporter with")
+	output := fs.String("output", "", "File to write the rules to instead of stdout")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+
+	data, err := promrules.Generate(cfg, *job)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate rules: %v\n", err)
+		return 1
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write rules: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+)
+
+// Aisles a TRH sensor can be classified into
+const (
+	AisleHot     = "hot"
+	AisleCold    = "cold"
+	AisleAmbient = "ambient"
+)
+
+// AisleRule classifies the TRH sensors whose names match Regex into Aisle
+type AisleRule struct {
+	Aisle string `yaml:"aisle"`
+	Regex string `yaml:"regex"`
+	re    *regexp.Regexp
+}
+
+// AisleConfig classifies the TRH sensors into hot aisle, cold aisle or
+// ambient, for the aisle label and the delta-T of each row
+type AisleConfig struct {
+	// Rules apply in order, the first matching one classifies a sensor
+	Rules []AisleRule
+	// RowLabel is the label of the sensors naming their row, such as one
+	// split from the sensor name
+	RowLabel string
+}
+
+// loadAisles loads the row label of the aisles from the environment
+func loadAisles() AisleConfig {
+	return AisleConfig{RowLabel: getEnv("AISLE_ROW_LABEL", "row")}
+}
+
+// applyAisleRules checks and compiles the aisle_rules of the configuration
+// file
+func applyAisleRules(rules []AisleRule) ([]AisleRule, error) {
+	for i := range rules {
+		r := &rules[i]
+		switch r.Aisle {
+		case AisleHot, AisleCold, AisleAmbient:
+		default:
+			return nil, fmt.Errorf("aisle_rules[%d]: aisle must be %q, %q or %q, got %q", i, AisleHot, AisleCold, AisleAmbient, r.Aisle)
+		}
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("aisle_rules[%d]: invalid regex: %w", i, err)
+		}
+		r.re = re
+	}
+	return rules, nil
+}
+
+// Enabled reports whether the sensors are classified into aisles
+func (a AisleConfig) Enabled() bool {
+	return len(a.Rules) > 0
+}
+
+// Aisle returns the aisle of a sensor, empty when no rule matches its name
+func (a AisleConfig) Aisle(sensor string) string {
+	for _, r := range a.Rules {
+		if r.re.MatchString(sensor) {
+			return r.Aisle
+		}
+	}
+	return ""
+}
+
+// validate checks the row label of the aisles
+func (a AisleConfig) validate() []error {
+	if a.Enabled() && (a.RowLabel == "" || a.RowLabel == "name" || !model.LabelName(a.RowLabel).IsValidLegacy()) {
+		return []error{fmt.Errorf("AISLE_ROW_LABEL: invalid label name %q", a.RowLabel)}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// AlarmHistoryConfig configures scraping the alarm history tab of the CDU
+// dashboards, which keeps the alarms raised and cleared between scrapes
+type AlarmHistoryConfig struct {
+	// Pages is the most pages of the history read per scrape, 0 disables
+	// scraping it
+	Pages int
+	// Interval is how often the history of a CDU is scraped
+	Interval time.Duration
+	// Timezone is the time zone of the times of the history
+	Timezone string
+}
+
+// loadAlarmHistory loads the alarm history settings from the environment
+func loadAlarmHistory(errs *[]error) AlarmHistoryConfig {
+	return AlarmHistoryConfig{
+		Pages:    envInt("ALARM_HISTORY_PAGES", "0", errs),
+		Interval: envModelDuration("ALARM_HISTORY_INTERVAL", "15m", errs),
+		Timezone: getEnv("ALARM_HISTORY_TIMEZONE", "Local"),
+	}
+}
+
+// Enabled reports whether the alarm history is scraped
+func (a AlarmHistoryConfig) Enabled() bool {
+	return a.Pages > 0
+}
+
+// Location returns the time zone of the times of the history
+func (a AlarmHistoryConfig) Location() *time.Location {
+	loc, err := time.LoadLocation(a.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// validate checks the alarm history settings
+func (a AlarmHistoryConfig) validate() []error {
+	var errs []error
+	if a.Pages < 0 {
+		errs = append(errs, fmt.Errorf("ALARM_HISTORY_PAGES: must not be negative, got %d", a.Pages))
+	}
+	if a.Enabled() && a.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("ALARM_HISTORY_INTERVAL: must be greater than zero, got %s", a.Interval))
+	}
+	if _, err := time.LoadLocation(a.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("ALARM_HISTORY_TIMEZONE: %w", err))
+	}
+	return errs
+}
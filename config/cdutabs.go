@@ -0,0 +1,49 @@
+package config
+
+import "fmt"
+
+// CDUTab is a tab of the CDU dashboards, such as the pumps or valves, whose
+// tables are only shown after clicking through it
+type CDUTab struct {
+	// Name is the type label of the readings of the tab
+	Name string `yaml:"name"`
+	// Clicks are the CSS selectors of the elements clicked in turn to open
+	// the tab, e.g. a[href="#pumps"]
+	Clicks []string `yaml:"clicks"`
+	// Table is the CSS selector of the tables of the tab, defaulting to the
+	// tables of the active tab pane
+	Table string `yaml:"table"`
+}
+
+// applyCDUTabs checks the tabs of the configuration file listed under field
+func applyCDUTabs(tabs []CDUTab, field string) ([]CDUTab, error) {
+	seen := make(map[string]bool)
+	for i, tab := range tabs {
+		switch {
+		case tab.Name == "":
+			return nil, fmt.Errorf("%s[%d]: name must be set", field, i)
+		case tab.Name == "alarm" || tab.Name == "parameter":
+			return nil, fmt.Errorf("%s[%d]: name %q is the type of the main tables", field, i, tab.Name)
+		case seen[tab.Name]:
+			return nil, fmt.Errorf("%s[%d]: duplicate name %q", field, i, tab.Name)
+		case len(tab.Clicks) == 0 && tab.Table == "":
+			return nil, fmt.Errorf("%s[%d]: either clicks or table must be set", field, i)
+		}
+		seen[tab.Name] = true
+		for j, click := range tab.Clicks {
+			if click == "" {
+				return nil, fmt.Errorf("%s[%d]: clicks[%d] must not be empty", field, i, j)
+			}
+		}
+	}
+	return tabs, nil
+}
+
+// TabsFor returns the tabs scraped on the dashboard of a target: its own,
+// or the ones of every CDU when it has none
+func (c *Config) TabsFor(t CDUTarget) []CDUTab {
+	if t.Tabs != nil {
+		return t.Tabs
+	}
+	return c.CDUTabs
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// CheckmkConfig configures the Checkmk local check output
+type CheckmkConfig struct {
+	// SpoolDir is the spool directory of the Checkmk agent the local checks
+	// are written to after every collection, empty disables it
+	SpoolDir string
+	// Levels are the thresholds of the readings, from checkmk_levels in the
+	// configuration file
+	Levels []CheckmkLevels
+}
+
+// CheckmkLevels sets the warning and critical levels of the readings selected
+// by metric and labels. Upper levels are [warn, crit] and alert at or above
+// them, lower levels are [warn, crit] and alert at or below them.
+type CheckmkLevels struct {
+	Metric string            `yaml:"metric"`
+	Labels map[string]string `yaml:"labels"`
+	Upper  []float64         `yaml:"upper"`
+	Lower  []float64         `yaml:"lower"`
+}
+
+// loadCheckmk loads the Checkmk settings from the environment
+func loadCheckmk() CheckmkConfig {
+	return CheckmkConfig{
+		SpoolDir: getEnv("CHECKMK_SPOOL_DIR", ""),
+	}
+}
+
+// validate checks the Checkmk settings
+func (c CheckmkConfig) validate() []error {
+	if c.SpoolDir == "" {
+		return nil
+	}
+
+	info, err := os.Stat(c.SpoolDir)
+	if err != nil {
+		return []error{fmt.Errorf("CHECKMK_SPOOL_DIR: %w", err)}
+	}
+	if !info.IsDir() {
+		return []error{fmt.Errorf("CHECKMK_SPOOL_DIR: %s is not a directory", c.SpoolDir)}
+	}
+	return nil
+}
+
+// applyCheckmkLevels checks the levels of the configuration file
+func applyCheckmkLevels(levels []CheckmkLevels) ([]CheckmkLevels, error) {
+	for i, l := range levels {
+		if l.Metric == "" {
+			return nil, fmt.Errorf("checkmk_levels[%d]: metric must be set", i)
+		}
+		if len(l.Upper) == 0 && len(l.Lower) == 0 {
+			return nil, fmt.Errorf("checkmk_levels[%d]: upper or lower must be set", i)
+		}
+		if len(l.Upper) != 0 && (len(l.Upper) != 2 || l.Upper[0] > l.Upper[1]) {
+			return nil, fmt.Errorf("checkmk_levels[%d]: upper must be [warn, crit] with warn <= crit", i)
+		}
+		if len(l.Lower) != 0 && (len(l.Lower) != 2 || l.Lower[0] < l.Lower[1]) {
+			return nil, fmt.Errorf("checkmk_levels[%d]: lower must be [warn, crit] with warn >= crit", i)
+		}
+	}
+	return levels, nil
+}
@@ -1,72 +1,272 @@
 package config
 
 import (
+	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/prometheus/common/model"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Port             string
-	ScrapeInterval   time.Duration
-	HTTPTimeout      time.Duration
-	ScrapeTimeout    time.Duration
-	TRHURL           string
-	LiquidCoolingURL string
-	CDUURLs          []string
-	SessMap          string
-	PHPSessID        string
-	Referer          string
+	Port                  string
+	ListenAddress         string
+	TelemetryPath         string
+	MetricsListenAddress  string
+	AdminListenAddress    string
+	EnablePprof           bool
+	WebConfigFile         string
+	AdminTokens           []string
+	AdminTokenFile        string
+	AllowedCIDRs          []netip.Prefix
+	MetricsAllowedCIDRs   []netip.Prefix
+	AdminAllowedCIDRs     []netip.Prefix
+	RateLimit             float64
+	RateLimitBurst        int
+	EventBufferSize       int
+	LiquidScrollPasses    int
+	AlarmRaiseCycles      int
+	AlarmClearCycles      int
+	AlarmHistory          AlarmHistoryConfig
+	AvailabilityRetention time.Duration
+	HistoryPath           string
+	HistoryRetention      time.Duration
+	ScrapeInterval        time.Duration
+	HTTPTimeout           time.Duration
+	ScrapeTimeout         time.Duration
+	HangTimeout           time.Duration
+	ShutdownGracePeriod   time.Duration
+	TRHTargets            []TRHTarget
+	SensorNames           SensorNameConfig
+	Temperature           TemperatureConfig
+	Zones                 ZoneConfig
+	Aisles                AisleConfig
+	LabelRules            []LabelRule
+	LiquidCoolingURL      string
+	CDUURLs               []string
+	CDUTargets            []CDUTarget
+	CDUAliases            []FileCDUTarget
+	CDUTabs               []CDUTab
+	ParameterMetrics      ParameterMetricConfig
+	DiscoveryURL          string
+	DiscoveryInterval     time.Duration
+	ConfigFile            string
+	RemoteConfigURL       string
+	RemoteConfigToken     string
+	Watch                 WatchConfig
+	Federation            FederationConfig
+	ConstantLabels        map[string]string
+	Maintenance           Maintenance
+	ThresholdRules        []ThresholdRule
+	Silences              []Silence
+	Logging               LoggingConfig
+	Election              ElectionConfig
+	Proxy                 ProxyConfig
+	Tunnel                TunnelConfig
+	TargetTLS             TargetTLSConfig
+	DNS                   DNSConfig
+	Transport             TransportConfig
+	Limits                LimitsConfig
+	PortalLimit           PortalLimitConfig
+	SilencesFile          string
+	Pushgateway           PushgatewayConfig
+	Graphite              GraphiteConfig
+	StatsD                StatsDConfig
+	Kafka                 KafkaConfig
+	MQTT                  MQTTConfig
+	CloudWatch            CloudWatchConfig
+	Datadog               DatadogConfig
+	GoogleCloudMonitoring GoogleCloudMonitoringConfig
+	Postgres              PostgresConfig
+	SNMP                  SNMPConfig
+	SNMPTrap              SNMPTrapConfig
+	Modbus                ModbusConfig
+	Checkmk               CheckmkConfig
+	Webhook               WebhookConfig
+	Alertmanager          AlertmanagerConfig
+	Slack                 ChatConfig
+	Teams                 ChatConfig
+	Email                 EmailConfig
+	PagerDuty             PagerDutyConfig
+	Opsgenie              OpsgenieConfig
+	Sentry                SentryConfig
+	SessMap               string
+	PHPSessID             string
+	Referer               string
+	UserAgent             string
+	RequestHeaders        map[string]string
+	LoginURL              string
+	Username              string
+	Password              string
+
+	// loadErrs are the settings Load couldn't parse, reported by Validate
+	loadErrs []error
 }
 
-// Load loads configuration from environment variables and .env file
-func Load() (*Config, error) {
+// Load loads configuration from environment variables and .env file. A
+// setting that doesn't parse doesn't stop the loading: its default is used
+// and the problem is reported by Validate along with the others, so that one
+// run lists every bad setting.
+func Load() *Config {
 	// Load .env file if it exists
 	_ = godotenv.Load()
 
+	var errs []error
 	port := getEnv("PORT", "8080")
-	scrapeIntervalStr := getEnv("SCRAPE_INTERVAL", "30s")
-	scrapeInterval, err := time.ParseDuration(scrapeIntervalStr)
-	if err != nil {
-		return nil, err
-	}
+	scrapeInterval := envDuration("SCRAPE_INTERVAL", "30s", &errs)
+	httpTimeout := envDuration("HTTP_TIMEOUT", "10s", &errs)
+	scrapeTimeout := envDuration("SCRAPE_TIMEOUT", "30s", &errs)
+	discoveryInterval := envDuration("DISCOVERY_INTERVAL", "10m", &errs)
+	hangTimeout := envDuration("COLLECTION_HANG_TIMEOUT", "15m", &errs)
+	shutdownGrace := envDuration("SHUTDOWN_GRACE_PERIOD", "30s", &errs)
 
-	httpTimeoutStr := getEnv("HTTP_TIMEOUT", "10s")
-	httpTimeout, err := time.ParseDuration(httpTimeoutStr)
-	if err != nil {
-		return nil, err
-	}
+	trhTargets := loadTRHTargets(&errs)
 
-	scrapeTimeoutStr := getEnv("SCRAPE_TIMEOUT", "30s")
-	scrapeTimeout, err := time.ParseDuration(scrapeTimeoutStr)
-	if err != nil {
-		return nil, err
+	cduURLsStr := getEnv("CDU_URLS", "https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38329,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38337,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38331,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38339,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38333,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38341,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38335,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38343")
+	cduURLs := splitList(cduURLsStr)
+
+	// Bearer tokens accepted by the admin endpoints, from the environment
+	// and/or a file with one token per line
+	adminTokens := splitList(getEnv("ADMIN_TOKENS", ""))
+	adminTokenFile := getEnv("ADMIN_TOKEN_FILE", "")
+	if adminTokenFile != "" {
+		data, err := os.ReadFile(adminTokenFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read ADMIN_TOKEN_FILE: %w", err))
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if token := strings.TrimSpace(line); token != "" && !strings.HasPrefix(token, "#") {
+				adminTokens = append(adminTokens, token)
+			}
+		}
 	}
 
-	cduURLsStr := getEnv("CDU_URLS", "https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38329,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38337,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38331,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38339,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38333,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38341,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38335,https://app.managed360view.com/360view/cdu_dashboard.php?cabinetid=38343")
-	var cduURLs []string
-	if cduURLsStr != "" {
-		cduURLs = strings.Split(cduURLsStr, ",")
-		for i := range cduURLs {
-			cduURLs[i] = strings.TrimSpace(cduURLs[i])
+	allowedCIDRs := parseCIDRs("ALLOWED_CIDRS", &errs)
+	metricsAllowedCIDRs := parseCIDRs("METRICS_ALLOWED_CIDRS", &errs)
+	adminAllowedCIDRs := parseCIDRs("ADMIN_ALLOWED_CIDRS", &errs)
+
+	enablePprof := envBool("ENABLE_PPROF", "false", &errs)
+	rateLimit := envFloat("RATE_LIMIT", "0", &errs)
+	rateLimitBurst := envInt("RATE_LIMIT_BURST", "10", &errs)
+	eventBufferSize := envInt("EVENT_BUFFER_SIZE", "1000", &errs)
+	liquidScrollPasses := envInt("LIQUID_SCROLL_PASSES", "20", &errs)
+	alarmRaiseCycles := envInt("ALARM_RAISE_CYCLES", "1", &errs)
+	alarmClearCycles := envInt("ALARM_CLEAR_CYCLES", "1", &errs)
+	availabilityRetention := envModelDuration("AVAILABILITY_RETENTION", "30d", &errs)
+	historyRetention := envModelDuration("HISTORY_RETENTION", "7d", &errs)
+
+	snmp := loadSNMP()
+
+	// The SSH tunnel serves as the proxy of the portal
+	proxy := loadProxy()
+	tunnel := loadTunnel(&errs)
+	if tunnel.Host != "" {
+		if proxy.URL != "" {
+			errs = append(errs, fmt.Errorf("PROXY_URL and SSH_TUNNEL_HOST are mutually exclusive"))
 		}
+		proxy.URL = tunnel.ProxyURL()
 	}
 
-	return &Config{
-		Port:             port,
-		ScrapeInterval:   scrapeInterval,
-		HTTPTimeout:      httpTimeout,
-		ScrapeTimeout:    scrapeTimeout,
-		TRHURL:           getEnv("TRH_URL", "https://app.managed360view.com/360view/trh_monitoring_dashboard.php"),
-		LiquidCoolingURL: getEnv("LIQUID_URL", "https://app.managed360view.com/360view/liquid_cooling_overview.php"),
-		CDUURLs:          cduURLs,
-		SessMap:          getEnv("SESS_MAP", "rcbqfqyrbtqtweyxzrsasyxfcfcssacawexwqaesxxdefbxvzyaydxrwyqxvvzrufbtdeauexytusqzewzddadqaadcrrabcftrftttbdyttusascfqzqsfcrqevytucbctrdtaxqwqyfuqcavzvfwzrswyszwwytyfswvqwazaxdedq"),
-		PHPSessID:        getEnv("PHPSESSID", "ghv6gfuhing3knheq9hbnvaqh5"),
-		Referer:          getEnv("REFERER", "https://app.managed360view.com/360view/trh_monitoring_dashboard.php"),
-	}, nil
+	// Deployment metadata added as constant labels to every metric
+	constantLabels := make(map[string]string)
+	for label, key := range map[string]string{"environment": "ENVIRONMENT", "region": "REGION", "team": "TEAM"} {
+		if value := getEnv(key, ""); value != "" {
+			constantLabels[label] = value
+		}
+	}
+	cfg := &Config{
+		Port:                  port,
+		ListenAddress:         getEnv("LISTEN_ADDRESS", ":"+port),
+		TelemetryPath:         getEnv("TELEMETRY_PATH", "/metrics"),
+		MetricsListenAddress:  getEnv("METRICS_LISTEN_ADDRESS", ""),
+		AdminListenAddress:    getEnv("ADMIN_LISTEN_ADDRESS", ""),
+		EnablePprof:           enablePprof,
+		WebConfigFile:         getEnv("WEB_CONFIG_FILE", ""),
+		AdminTokens:           adminTokens,
+		AdminTokenFile:        adminTokenFile,
+		AllowedCIDRs:          allowedCIDRs,
+		MetricsAllowedCIDRs:   metricsAllowedCIDRs,
+		AdminAllowedCIDRs:     adminAllowedCIDRs,
+		RateLimit:             rateLimit,
+		RateLimitBurst:        rateLimitBurst,
+		EventBufferSize:       eventBufferSize,
+		LiquidScrollPasses:    liquidScrollPasses,
+		AlarmRaiseCycles:      alarmRaiseCycles,
+		AlarmClearCycles:      alarmClearCycles,
+		AlarmHistory:          loadAlarmHistory(&errs),
+		AvailabilityRetention: availabilityRetention,
+		HistoryPath:           getEnv("HISTORY_PATH", ""),
+		HistoryRetention:      historyRetention,
+		SilencesFile:          getEnv("SILENCES_FILE", ""),
+		Logging:               loadLogging(&errs),
+		Election:              loadElection(&errs),
+		Proxy:                 proxy,
+		Tunnel:                tunnel,
+		TargetTLS:             loadTargetTLS(&errs),
+		DNS:                   loadDNS(&errs),
+		Transport:             loadTransport(&errs),
+		Limits:                loadLimits(&errs),
+		PortalLimit:           loadPortalLimit(&errs),
+		ScrapeInterval:        scrapeInterval,
+		HTTPTimeout:           httpTimeout,
+		ScrapeTimeout:         scrapeTimeout,
+		HangTimeout:           hangTimeout,
+		ShutdownGracePeriod:   shutdownGrace,
+		TRHTargets:            trhTargets,
+		SensorNames:           loadSensorNames(),
+		Temperature:           loadTemperature(),
+		Zones:                 loadZones(),
+		Aisles:                loadAisles(),
+		ParameterMetrics:      loadParameterMetrics(),
+		LiquidCoolingURL:      getEnv("LIQUID_URL", "https://app.managed360view.com/360view/liquid_cooling_overview.php"),
+		CDUURLs:               cduURLs,
+		CDUTargets:            newCDUTargets(cduURLs),
+		ConfigFile:            getEnv("CONFIG_FILE", ""),
+		RemoteConfigURL:       getEnv("REMOTE_CONFIG_URL", ""),
+		RemoteConfigToken:     getEnv("REMOTE_CONFIG_TOKEN", ""),
+		Watch:                 loadWatch(&errs),
+		Federation:            loadFederation(&errs),
+		ConstantLabels:        constantLabels,
+		Maintenance:           Maintenance{Mode: MaintenanceSuppress},
+		Pushgateway:           loadPushgateway(&errs),
+		Graphite:              loadGraphite(&errs),
+		StatsD:                loadStatsD(),
+		Kafka:                 loadKafka(),
+		MQTT:                  loadMQTT(&errs),
+		CloudWatch:            loadCloudWatch(),
+		Datadog:               loadDatadog(&errs),
+		GoogleCloudMonitoring: loadGoogleCloudMonitoring(&errs),
+		Postgres:              loadPostgres(&errs),
+		SNMP:                  snmp,
+		SNMPTrap:              loadSNMPTrap(snmp.BaseOID),
+		Modbus:                loadModbus(),
+		Checkmk:               loadCheckmk(),
+		Webhook:               loadWebhook(&errs),
+		Alertmanager:          loadAlertmanager(),
+		Slack:                 loadChat("SLACK", &errs),
+		Teams:                 loadChat("TEAMS", &errs),
+		Email:                 loadEmail(),
+		PagerDuty:             loadPagerDuty(),
+		Opsgenie:              loadOpsgenie(),
+		Sentry:                loadSentry(&errs),
+		DiscoveryURL:          getEnv("DISCOVERY_URL", ""),
+		DiscoveryInterval:     discoveryInterval,
+		SessMap:               getEnv("SESS_MAP", "rcbqfqyrbtqtweyxzrsasyxfcfcssacawexwqaesxxdefbxvzyaydxrwyqxvvzrufbtdeauexytusqzewzddadqaadcrrabcftrftttbdyttusascfqzqsfcrqevytucbctrdtaxqwqyfuqcavzvfwzrswyszwwytyfswvqwazaxdedq"),
+		PHPSessID:             getEnv("PHPSESSID", "ghv6gfuhing3knheq9hbnvaqh5"),
+		Referer:               getEnv("REFERER", "https://app.managed360view.com/360view/trh_monitoring_dashboard.php"),
+		UserAgent:             getEnv("USER_AGENT", ""),
+		LoginURL:              getEnv("LOGIN_URL", "https://app.managed360view.com/360view/login.php"),
+		Username:              getEnv("BDX_USERNAME", ""),
+		Password:              getEnv("BDX_PASSWORD", ""),
+	}
+	// Set once the loaders in the literal above have run
+	cfg.loadErrs = errs
+	return cfg
 }
 
 func getEnv(key, defaultValue string) string {
@@ -75,3 +275,85 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// splitList splits a comma separated list, trimming spaces and dropping
+// empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseCIDRs parses the comma separated list of CIDRs in the environment
+// variable key. Plain IP addresses are accepted as single-address prefixes.
+// Entries that don't parse are added to errs.
+func parseCIDRs(key string, errs *[]error) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, item := range splitList(getEnv(key, "")) {
+		if addr, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("invalid %s entry %q: %w", key, item, err))
+			continue
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+// parseEnv parses the environment variable key, or def when it is unset. A
+// value that doesn't parse is added to errs and def is used instead.
+func parseEnv[T any](key, def string, errs *[]error, parse func(string) (T, error)) T {
+	value := getEnv(key, def)
+	parsed, err := parse(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("invalid %s %q: %w", key, value, err))
+		parsed, _ = parse(def)
+	}
+	return parsed
+}
+
+// envDuration parses a duration setting, see parseEnv
+func envDuration(key, def string, errs *[]error) time.Duration {
+	return parseEnv(key, def, errs, time.ParseDuration)
+}
+
+// envModelDuration parses a duration setting which may be given in days or
+// weeks, see parseEnv
+func envModelDuration(key, def string, errs *[]error) time.Duration {
+	return parseEnv(key, def, errs, func(s string) (time.Duration, error) {
+		d, err := model.ParseDuration(s)
+		return time.Duration(d), err
+	})
+}
+
+// envInt parses an integer setting, see parseEnv
+func envInt(key, def string, errs *[]error) int {
+	return parseEnv(key, def, errs, strconv.Atoi)
+}
+
+// envInt64 parses a 64-bit integer setting, see parseEnv
+func envInt64(key, def string, errs *[]error) int64 {
+	return parseEnv(key, def, errs, func(s string) (int64, error) {
+		return strconv.ParseInt(s, 10, 64)
+	})
+}
+
+// envFloat parses a floating point setting, see parseEnv
+func envFloat(key, def string, errs *[]error) float64 {
+	return parseEnv(key, def, errs, func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
+	})
+}
+
+// envBool parses a boolean setting, see parseEnv
+func envBool(key, def string, errs *[]error) bool {
+	return parseEnv(key, def, errs, strconv.ParseBool)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// DNSConfig configures how the host names of the portal are resolved, for
+// networks where public DNS doesn't resolve them
+type DNSConfig struct {
+	// HostOverrides maps host names to the IP address they resolve to
+	HostOverrides map[string]string
+	// Server is the DNS server, as host:port, that resolves the other host
+	// names instead of the system resolver
+	Server string
+}
+
+// loadDNS loads the name resolution settings from the environment
+func loadDNS(errs *[]error) DNSConfig {
+	server := getEnv("DNS_SERVER", "")
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+	}
+	return DNSConfig{HostOverrides: parseLabels("HOST_OVERRIDES", errs), Server: server}
+}
+
+// Enabled reports whether the portal host names are resolved differently
+// from the system
+func (d DNSConfig) Enabled() bool {
+	return len(d.HostOverrides) > 0 || d.Server != ""
+}
+
+// Resolver returns the resolver of the other host names, nil for the
+// system one
+func (d DNSConfig) Resolver() *net.Resolver {
+	if d.Server == "" {
+		return nil
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, d.Server)
+		},
+	}
+}
+
+// Lookup returns the IP address of host, from the overrides or else from
+// the resolver. IP addresses are returned as they are.
+func (d DNSConfig) Lookup(ctx context.Context, host string) (string, error) {
+	if ip, ok := d.HostOverrides[host]; ok {
+		return ip, nil
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return host, nil
+	}
+	resolver := d.Resolver()
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	return addrs[0], nil
+}
+
+// validate checks the name resolution settings
+func (d DNSConfig) validate() []error {
+	var errs []error
+	for host, ip := range d.HostOverrides {
+		if _, err := netip.ParseAddr(ip); err != nil {
+			errs = append(errs, fmt.Errorf("HOST_OVERRIDES: %s must map to an IP address, got %q", host, ip))
+		}
+	}
+	if d.Server != "" {
+		if _, _, err := net.SplitHostPort(d.Server); err != nil {
+			errs = append(errs, fmt.Errorf("DNS_SERVER: %w", err))
+		}
+	}
+	return errs
+}
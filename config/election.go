@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ElectionConfig configures leader election between the replicas of an
+// active/standby pair
+type ElectionConfig struct {
+	// URL is the lease to compete for, kubernetes://namespace/name for a
+	// Kubernetes Lease or consul://host:port/key for a Consul lock. Empty
+	// disables leader election.
+	URL string
+	// Identity names this replica in the lease, the host name by default
+	Identity      string
+	LeaseDuration time.Duration
+	// Token authenticates to Consul
+	Token string
+}
+
+// loadElection loads the leader election settings from the environment
+func loadElection(errs *[]error) ElectionConfig {
+	hostname, _ := os.Hostname()
+	return ElectionConfig{
+		URL:           getEnv("LEADER_ELECTION_URL", ""),
+		Identity:      getEnv("LEADER_ELECTION_IDENTITY", hostname),
+		LeaseDuration: envModelDuration("LEADER_ELECTION_LEASE_DURATION", "15s", errs),
+		Token:         getEnv("LEADER_ELECTION_TOKEN", ""),
+	}
+}
+
+// validate checks the leader election settings
+func (e ElectionConfig) validate() []error {
+	if e.URL == "" {
+		return nil
+	}
+
+	var errs []error
+	u, err := url.Parse(e.URL)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("LEADER_ELECTION_URL: %w", err))
+	case u.Scheme == "kubernetes":
+		if name := strings.Trim(u.Path, "/"); name == "" || strings.Contains(name, "/") {
+			errs = append(errs, fmt.Errorf("LEADER_ELECTION_URL: must be kubernetes://namespace/name, got %q", e.URL))
+		}
+	case u.Scheme == "consul" || u.Scheme == "consul+https":
+		if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			errs = append(errs, fmt.Errorf("LEADER_ELECTION_URL: must be consul://host:port/key, got %q", e.URL))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("LEADER_ELECTION_URL: scheme must be kubernetes or consul, got %q", e.URL))
+	}
+	if e.Identity == "" {
+		errs = append(errs, fmt.Errorf("LEADER_ELECTION_IDENTITY: must be set when the host name is unknown"))
+	}
+	// Consul rejects session TTLs below 10s
+	if e.LeaseDuration < 10*time.Second {
+		errs = append(errs, fmt.Errorf("LEADER_ELECTION_LEASE_DURATION: must be at least 10s, got %s", e.LeaseDuration))
+	}
+	return errs
+}
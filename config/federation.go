@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// FederationConfig configures the federate command, which aggregates the
+// metrics of several site exporters
+type FederationConfig struct {
+	// Sites maps the site label to the base URL of its exporter, such as
+	// https://bdx-ams1:8080. The telemetry path is appended when the URL has
+	// no path.
+	Sites    map[string]string
+	Interval time.Duration
+	// Staleness is how long the series of a site are kept after its last
+	// successful scrape
+	Staleness time.Duration
+	// Username and Password authenticate to the site exporters
+	Username string
+	Password string
+}
+
+// loadFederation loads the federation settings from the environment
+func loadFederation(errs *[]error) FederationConfig {
+	return FederationConfig{
+		Sites:     parseLabels("FEDERATION_SITES", errs),
+		Interval:  envModelDuration("FEDERATION_INTERVAL", "30s", errs),
+		Staleness: envModelDuration("FEDERATION_STALENESS", "5m", errs),
+		Username:  getEnv("FEDERATION_USERNAME", ""),
+		Password:  getEnv("FEDERATION_PASSWORD", ""),
+	}
+}
+
+// validate checks the federation settings
+func (f FederationConfig) validate() []error {
+	if len(f.Sites) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for site, u := range f.Sites {
+		if site == "" || !model.LabelValue(site).IsValid() {
+			errs = append(errs, fmt.Errorf("FEDERATION_SITES: invalid site name %q", site))
+		}
+		if err := validateURL(u); err != nil {
+			errs = append(errs, fmt.Errorf("FEDERATION_SITES: site %s: %w", site, err))
+		}
+	}
+	if f.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("FEDERATION_INTERVAL: must be positive, got %s", f.Interval))
+	}
+	if f.Staleness < f.Interval {
+		errs = append(errs, fmt.Errorf("FEDERATION_STALENESS: must be at least FEDERATION_INTERVAL, got %s", f.Staleness))
+	}
+	return errs
+}
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"sort"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// CDUTarget describes a single CDU dashboard to scrape
+type CDUTarget struct {
+	URL       string
+	CabinetID string
+	// Name overrides the name read from the dashboard title when set
+	Name   string
+	Labels map[string]string
+	// Proxy overrides the proxy of the target, see ProxyConfig.For
+	Proxy string
+	// Tabs override the tabs of the dashboard scraped, see Config.TabsFor
+	Tabs []CDUTab
+}
+
+// File is the structure of the optional YAML configuration file
+type File struct {
+	ConstantLabels   map[string]string   `yaml:"constant_labels"`
+	TRHTargets       []FileTRHTarget     `yaml:"trh_targets"`
+	CDUTargets       []FileCDUTarget     `yaml:"cdu_targets"`
+	Maintenance      *Maintenance        `yaml:"maintenance"`
+	ModbusRegisters  []ModbusRegister    `yaml:"modbus_registers"`
+	CheckmkLevels    []CheckmkLevels     `yaml:"checkmk_levels"`
+	ThresholdRules   []ThresholdRule     `yaml:"threshold_rules"`
+	Alertmanager     *AlertmanagerFile   `yaml:"alertmanager"`
+	EmailRoutes      []EmailRoute        `yaml:"email_routes"`
+	SNMPVarbinds     []SNMPVarbind       `yaml:"snmp_trap_varbinds"`
+	Silences         []Silence           `yaml:"silences"`
+	RequestHeaders   map[string]string   `yaml:"request_headers"`
+	LabelRules       []LabelRule         `yaml:"label_rules"`
+	SensorZones      map[string][]string `yaml:"sensor_zones"`
+	AisleRules       []AisleRule         `yaml:"aisle_rules"`
+	CDUTabs          []CDUTab            `yaml:"cdu_tabs"`
+	ParameterMetrics []ParameterMetric   `yaml:"parameter_metrics"`
+}
+
+// AlertmanagerFile holds the label and annotation templates of the alerts
+// sent to Alertmanager
+type AlertmanagerFile struct {
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// FileCDUTarget maps a CDU dashboard, identified either by URL or by cabinet
+// ID, to a friendly name and extra labels
+type FileCDUTarget struct {
+	URL       string            `yaml:"url"`
+	CabinetID string            `yaml:"cabinet_id"`
+	Name      string            `yaml:"name"`
+	Labels    map[string]string `yaml:"labels"`
+	Proxy     string            `yaml:"proxy"`
+	Tabs      []CDUTab          `yaml:"tabs"`
+}
+
+// LoadFile reads the YAML configuration file at c.ConfigFile and merges it
+// into the configuration
+func (c *Config) LoadFile() error {
+	data, err := os.ReadFile(c.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	return c.ApplyFile(data, c.ConfigFile)
+}
+
+// ApplyFile parses YAML configuration file contents read from origin and
+// merges them into the configuration
+func (c *Config) ApplyFile(data []byte, origin string) error {
+	var f File
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", origin, err)
+	}
+
+	for name, value := range f.ConstantLabels {
+		c.ConstantLabels[name] = value
+	}
+
+	// TRH endpoints of the file replace those of the environment
+	if len(f.TRHTargets) > 0 {
+		trhTargets, err := applyTRHTargets(f.TRHTargets)
+		if err != nil {
+			return err
+		}
+		c.TRHTargets = trhTargets
+	}
+
+	for i, ft := range f.CDUTargets {
+		if ft.URL == "" && ft.CabinetID == "" {
+			return fmt.Errorf("cdu_targets[%d]: either url or cabinet_id must be set", i)
+		}
+		if ft.Proxy != "" && ft.Proxy != ProxyDirect {
+			if err := validateProxyURL(ft.Proxy); err != nil {
+				return fmt.Errorf("cdu_targets[%d]: %w", i, err)
+			}
+		}
+		if _, err := applyCDUTabs(ft.Tabs, fmt.Sprintf("cdu_targets[%d].tabs", i)); err != nil {
+			return err
+		}
+
+		matched := false
+		for j := range c.CDUTargets {
+			if ft.matches(c.CDUTargets[j]) {
+				c.CDUTargets[j].Name = ft.Name
+				c.CDUTargets[j].Labels = ft.Labels
+				c.CDUTargets[j].Proxy = ft.Proxy
+				c.CDUTargets[j].Tabs = ft.Tabs
+				matched = true
+			}
+		}
+
+		// Targets listed by URL in the file are scraped even if they are not
+		// part of CDU_URLS, while cabinet IDs may refer to targets that are
+		// only found later by discovery
+		if !matched && ft.URL != "" {
+			c.CDUURLs = append(c.CDUURLs, ft.URL)
+			c.CDUTargets = append(c.CDUTargets, CDUTarget{
+				URL:       ft.URL,
+				CabinetID: cabinetID(ft.URL),
+				Name:      ft.Name,
+				Labels:    ft.Labels,
+				Proxy:     ft.Proxy,
+				Tabs:      ft.Tabs,
+			})
+		}
+	}
+	c.CDUAliases = f.CDUTargets
+
+	registers, err := applyModbusRegisters(f.ModbusRegisters)
+	if err != nil {
+		return err
+	}
+	c.Modbus.Registers = registers
+
+	levels, err := applyCheckmkLevels(f.CheckmkLevels)
+	if err != nil {
+		return err
+	}
+	c.Checkmk.Levels = levels
+
+	rules, err := applyThresholdRules(f.ThresholdRules)
+	if err != nil {
+		return err
+	}
+	c.ThresholdRules = rules
+
+	routes, err := applyEmailRoutes(f.EmailRoutes)
+	if err != nil {
+		return err
+	}
+	c.Email.Routes = routes
+
+	// Variable bindings of the file replace the default ones
+	if len(f.SNMPVarbinds) > 0 {
+		varbinds, err := applySNMPVarbinds(f.SNMPVarbinds)
+		if err != nil {
+			return err
+		}
+		c.SNMPTrap.Varbinds = varbinds
+	}
+
+	labelRules, err := applyLabelRules(f.LabelRules)
+	if err != nil {
+		return err
+	}
+	c.LabelRules = labelRules
+
+	aisleRules, err := applyAisleRules(f.AisleRules)
+	if err != nil {
+		return err
+	}
+	c.Aisles.Rules = aisleRules
+
+	cduTabs, err := applyCDUTabs(f.CDUTabs, "cdu_tabs")
+	if err != nil {
+		return err
+	}
+	c.CDUTabs = cduTabs
+
+	parameterMetrics, err := applyParameterMetrics(f.ParameterMetrics)
+	if err != nil {
+		return err
+	}
+	c.ParameterMetrics.Metrics = parameterMetrics
+
+	if len(f.SensorZones) > 0 {
+		zones, err := applySensorZones(f.SensorZones)
+		if err != nil {
+			return err
+		}
+		c.Zones.Sensors = zones
+	}
+
+	headers, err := applyRequestHeaders(f.RequestHeaders)
+	if err != nil {
+		return err
+	}
+	c.RequestHeaders = headers
+
+	silences, err := applySilences(f.Silences)
+	if err != nil {
+		return err
+	}
+	c.Silences = silences
+
+	// Templates of the file override the default ones, an empty template
+	// drops a default label or annotation
+	if f.Alertmanager != nil {
+		maps.Copy(c.Alertmanager.Labels, f.Alertmanager.Labels)
+		maps.Copy(c.Alertmanager.Annotations, f.Alertmanager.Annotations)
+	}
+
+	if f.Maintenance != nil {
+		c.Maintenance = *f.Maintenance
+		if c.Maintenance.Mode == "" {
+			c.Maintenance.Mode = MaintenanceSuppress
+		}
+	}
+
+	return nil
+}
+
+// NewCDUTarget builds the target for a CDU dashboard URL, applying the
+// configured alias if there is one
+func (c *Config) NewCDUTarget(rawURL string) CDUTarget {
+	t := CDUTarget{URL: rawURL, CabinetID: cabinetID(rawURL)}
+	for _, ft := range c.CDUAliases {
+		if ft.matches(t) {
+			t.Name = ft.Name
+			t.Labels = ft.Labels
+			t.Proxy = ft.Proxy
+			t.Tabs = ft.Tabs
+		}
+	}
+	return t
+}
+
+// CDULabelNames returns the sorted union of the extra label names configured
+// on the CDU targets
+func (c *Config) CDULabelNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(labels map[string]string) {
+		for name := range labels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	for _, t := range c.CDUTargets {
+		add(t.Labels)
+	}
+	for _, ft := range c.CDUAliases {
+		add(ft.Labels)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matches reports whether the file entry applies to the target
+func (ft FileCDUTarget) matches(t CDUTarget) bool {
+	if ft.URL != "" {
+		return t.URL == ft.URL
+	}
+	return t.CabinetID == ft.CabinetID
+}
+
+// newCDUTargets builds the CDU targets for a list of dashboard URLs
+func newCDUTargets(urls []string) []CDUTarget {
+	targets := make([]CDUTarget, 0, len(urls))
+	for _, u := range urls {
+		targets = append(targets, CDUTarget{URL: u, CabinetID: cabinetID(u)})
+	}
+	return targets
+}
+
+// cabinetID returns the cabinetid query parameter of a CDU dashboard URL
+func cabinetID(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("cabinetid")
+}
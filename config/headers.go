@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// applyRequestHeaders checks the request_headers of the configuration file
+func applyRequestHeaders(headers map[string]string) (map[string]string, error) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	applied := make(map[string]string, len(headers))
+	for _, name := range names {
+		value := headers[name]
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("request_headers: invalid header name %q", name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("request_headers: invalid value of header %s", name)
+		}
+		applied[http.CanonicalHeaderKey(name)] = value
+	}
+	return applied, nil
+}
+
+// PortalHeaders returns the headers added to every request to the portal,
+// including the User-Agent of USER_AGENT which takes precedence over the
+// one of request_headers
+func (c *Config) PortalHeaders() map[string]string {
+	headers := make(map[string]string, len(c.RequestHeaders)+1)
+	for name, value := range c.RequestHeaders {
+		headers[name] = value
+	}
+	if c.UserAgent != "" {
+		headers["User-Agent"] = c.UserAgent
+	}
+	return headers
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Names label rules apply to
+const (
+	LabelRuleSensor = "sensor"
+	LabelRuleCDU    = "cdu"
+	LabelRuleRack   = "rack"
+)
+
+// LabelRule derives labels from the names of TRH sensors, CDUs or liquid
+// cooling racks: each named group of the regular expression that matches a
+// name becomes a label of its series
+type LabelRule struct {
+	Source string `yaml:"source"`
+	Regex  string `yaml:"regex"`
+	re     *regexp.Regexp
+}
+
+// applyLabelRules checks and compiles the label_rules of the configuration
+// file
+func applyLabelRules(rules []LabelRule) ([]LabelRule, error) {
+	for i := range rules {
+		r := &rules[i]
+		switch r.Source {
+		case LabelRuleSensor, LabelRuleCDU, LabelRuleRack:
+		default:
+			return nil, fmt.Errorf("label_rules[%d]: source must be %q, %q or %q, got %q", i, LabelRuleSensor, LabelRuleCDU, LabelRuleRack, r.Source)
+		}
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("label_rules[%d]: invalid regex: %w", i, err)
+		}
+		named := false
+		for _, name := range re.SubexpNames()[1:] {
+			if name == "" {
+				continue
+			}
+			named = true
+			if !labelNameRE.MatchString(name) {
+				return nil, fmt.Errorf("label_rules[%d]: %q is not a valid label name", i, name)
+			}
+			if reservedCDULabels[name] {
+				return nil, fmt.Errorf("label_rules[%d]: label %q is reserved", i, name)
+			}
+		}
+		if !named {
+			return nil, fmt.Errorf("label_rules[%d]: regex has no named groups, such as (?P<row>[0-9]+)", i)
+		}
+		r.re = re
+	}
+	return rules, nil
+}
+
+// RuleLabelNames returns the sorted names of the labels the rules derive
+// from the names of source
+func (c *Config) RuleLabelNames(source string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, r := range c.LabelRules {
+		if r.Source != source {
+			continue
+		}
+		for _, name := range r.re.SubexpNames()[1:] {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RuleLabels returns the labels the rules derive from a name of source.
+// Every matching rule applies, a later one overriding the labels of an
+// earlier one, and groups that don't take part in the match are left out.
+func (c *Config) RuleLabels(source, name string) map[string]string {
+	var labels map[string]string
+	for _, r := range c.LabelRules {
+		if r.Source != source {
+			continue
+		}
+		match := r.re.FindStringSubmatchIndex(name)
+		if match == nil {
+			continue
+		}
+		for i, group := range r.re.SubexpNames() {
+			if i == 0 || group == "" || match[2*i] < 0 {
+				continue
+			}
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			labels[group] = name[match[2*i]:match[2*i+1]]
+		}
+	}
+	return labels
+}
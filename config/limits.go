@@ -0,0 +1,47 @@
+package config
+
+import "fmt"
+
+// Actions on a portal response over the size limit
+const (
+	LimitActionAbort    = "abort"
+	LimitActionTruncate = "truncate"
+)
+
+// LimitsConfig bounds how much of a portal response is read into memory, so
+// a malformed or enormous response can't exhaust it
+type LimitsConfig struct {
+	// MaxResponseSize is the maximum size in bytes of the body of an HTTP
+	// response, such as the TRH data or the discovery page
+	MaxResponseSize int64
+	// MaxPageSize is the maximum size in characters of the HTML of a page
+	// rendered by the browser
+	MaxPageSize int64
+	// Action is LimitActionAbort to fail the scrape of a response over the
+	// limit, or LimitActionTruncate to parse its beginning
+	Action string
+}
+
+// loadLimits loads the response size limits from the environment
+func loadLimits(errs *[]error) LimitsConfig {
+	return LimitsConfig{
+		MaxResponseSize: envInt64("MAX_RESPONSE_SIZE_MB", "10", errs) << 20,
+		MaxPageSize:     envInt64("MAX_PAGE_SIZE_MB", "20", errs) << 20,
+		Action:          getEnv("RESPONSE_SIZE_LIMIT_ACTION", LimitActionAbort),
+	}
+}
+
+// validate checks the response size limits
+func (l LimitsConfig) validate() []error {
+	var errs []error
+	if l.MaxResponseSize <= 0 {
+		errs = append(errs, fmt.Errorf("MAX_RESPONSE_SIZE_MB: must be greater than zero"))
+	}
+	if l.MaxPageSize <= 0 {
+		errs = append(errs, fmt.Errorf("MAX_PAGE_SIZE_MB: must be greater than zero"))
+	}
+	if l.Action != LimitActionAbort && l.Action != LimitActionTruncate {
+		errs = append(errs, fmt.Errorf("RESPONSE_SIZE_LIMIT_ACTION: must be %s or %s, got %q", LimitActionAbort, LimitActionTruncate, l.Action))
+	}
+	return errs
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Log outputs
+const (
+	LogStderr   = "stderr"
+	LogFile     = "file"
+	LogSyslog   = "syslog"
+	LogJournald = "journald"
+	LogEventLog = "eventlog"
+)
+
+// Log modes of the collected values
+const (
+	LogModeAll     = "all"
+	LogModeChanges = "changes"
+)
+
+// SyslogFacilities are the facilities syslog messages may be sent with
+var SyslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// LoggingConfig configures where the exporter logs to
+type LoggingConfig struct {
+	Outputs []string
+	// File is the path of the log file. It is rotated when it grows past
+	// FileMaxSize bytes, and rotated files are removed once there are more
+	// than FileMaxBackups or they are older than FileMaxAge.
+	File           string
+	FileMaxSize    int64
+	FileMaxAge     time.Duration
+	FileMaxBackups int
+	// AuditFile is the path of the audit log of the admin API calls, which
+	// are logged with the other messages when empty. It is rotated like File.
+	AuditFile string
+	// SyslogAddress is the syslog server as udp://host:port or
+	// tcp://host:port, the local syslog daemon when empty
+	SyslogAddress  string
+	SyslogFacility string
+	// Tag identifies the exporter in syslog and journald, and is the source
+	// of the Windows event log entries
+	Tag string
+	// Mode is LogModeAll to log every collected value every cycle, or
+	// LogModeChanges to only log the values that moved by at least
+	// ChangeDelta since they were last logged, and the alarms raised or
+	// cleared
+	Mode        string
+	ChangeDelta float64
+}
+
+// loadLogging loads the log output settings from the environment
+func loadLogging(errs *[]error) LoggingConfig {
+	return LoggingConfig{
+		Outputs:        splitList(getEnv("LOG_OUTPUTS", LogStderr)),
+		File:           getEnv("LOG_FILE", ""),
+		FileMaxSize:    envInt64("LOG_FILE_MAX_SIZE_MB", "100", errs) << 20,
+		FileMaxAge:     envModelDuration("LOG_FILE_MAX_AGE", "30d", errs),
+		FileMaxBackups: envInt("LOG_FILE_MAX_BACKUPS", "5", errs),
+		AuditFile:      getEnv("AUDIT_LOG_FILE", ""),
+		SyslogAddress:  getEnv("SYSLOG_ADDRESS", ""),
+		SyslogFacility: getEnv("SYSLOG_FACILITY", "daemon"),
+		Tag:            getEnv("LOG_TAG", "bdx_exporter"),
+		Mode:           getEnv("LOG_MODE", LogModeAll),
+		ChangeDelta:    envFloat("LOG_CHANGE_DELTA", "0.5", errs),
+	}
+}
+
+// Enabled reports whether the output is one of the configured outputs
+func (l LoggingConfig) Enabled(output string) bool {
+	return slices.Contains(l.Outputs, output)
+}
+
+// validate checks the log output settings
+func (l LoggingConfig) validate() []error {
+	var errs []error
+	if len(l.Outputs) == 0 {
+		errs = append(errs, fmt.Errorf("LOG_OUTPUTS: at least one output must be set"))
+	}
+	for _, output := range l.Outputs {
+		if !slices.Contains([]string{LogStderr, LogFile, LogSyslog, LogJournald, LogEventLog}, output) {
+			errs = append(errs, fmt.Errorf("LOG_OUTPUTS: unknown output %q, must be %s, %s, %s, %s or %s", output, LogStderr, LogFile, LogSyslog, LogJournald, LogEventLog))
+		}
+	}
+
+	if l.Mode != LogModeAll && l.Mode != LogModeChanges {
+		errs = append(errs, fmt.Errorf("LOG_MODE: must be %s or %s, got %q", LogModeAll, LogModeChanges, l.Mode))
+	}
+	if l.ChangeDelta < 0 {
+		errs = append(errs, fmt.Errorf("LOG_CHANGE_DELTA: must not be negative, got %g", l.ChangeDelta))
+	}
+
+	if l.Enabled(LogFile) && l.File == "" {
+		errs = append(errs, fmt.Errorf("LOG_FILE: must be set for the file output"))
+	}
+	if l.AuditFile != "" && l.AuditFile == l.File {
+		errs = append(errs, fmt.Errorf("AUDIT_LOG_FILE: must differ from LOG_FILE"))
+	}
+	if l.Enabled(LogFile) || l.AuditFile != "" {
+		if l.FileMaxSize <= 0 {
+			errs = append(errs, fmt.Errorf("LOG_FILE_MAX_SIZE_MB: must be greater than zero"))
+		}
+		if l.FileMaxAge <= 0 {
+			errs = append(errs, fmt.Errorf("LOG_FILE_MAX_AGE: must be greater than zero, got %s", l.FileMaxAge))
+		}
+		if l.FileMaxBackups < 0 {
+			errs = append(errs, fmt.Errorf("LOG_FILE_MAX_BACKUPS: must not be negative, got %d", l.FileMaxBackups))
+		}
+	}
+
+	if l.Enabled(LogSyslog) {
+		if l.SyslogAddress != "" {
+			u, err := url.Parse(l.SyslogAddress)
+			if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("SYSLOG_ADDRESS: must be udp://host:port or tcp://host:port, got %q", l.SyslogAddress))
+			}
+		}
+		if !slices.Contains(SyslogFacilities, l.SyslogFacility) {
+			errs = append(errs, fmt.Errorf("SYSLOG_FACILITY: unknown facility %q", l.SyslogFacility))
+		}
+	}
+	return errs
+}
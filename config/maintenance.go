@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Maintenance modes
+const (
+	// MaintenanceSuppress drops the alarm series of targets in maintenance
+	MaintenanceSuppress = "suppress"
+	// MaintenanceTag keeps the alarm series and labels them in_maintenance="true"
+	MaintenanceTag = "tag"
+)
+
+// Maintenance configures the planned maintenance windows of CDU targets
+type Maintenance struct {
+	Mode    string              `yaml:"mode"`
+	Windows []MaintenanceWindow `yaml:"windows"`
+}
+
+// MaintenanceWindow is either a one-off window with a start and end time, or
+// a recurring window with a schedule
+type MaintenanceWindow struct {
+	Name string `yaml:"name"`
+	// Targets lists CDU names or cabinet IDs; an empty list matches every target
+	Targets  []string           `yaml:"targets"`
+	Start    time.Time          `yaml:"start"`
+	End      time.Time          `yaml:"end"`
+	Schedule *RecurringSchedule `yaml:"schedule"`
+}
+
+// RecurringSchedule is a window that repeats on the given weekdays, or every
+// day if none are given
+type RecurringSchedule struct {
+	Weekdays []string `yaml:"weekdays"`
+	// Start is the local time of day the window opens, as HH:MM
+	Start    string        `yaml:"start"`
+	Duration time.Duration `yaml:"duration"`
+	Timezone string        `yaml:"timezone"`
+}
+
+// Enabled reports whether any maintenance windows are configured
+func (m Maintenance) Enabled() bool {
+	return len(m.Windows) > 0
+}
+
+// Active returns the name of the first maintenance window that covers the
+// target at the given time, or an empty string if there is none
+func (m Maintenance) Active(name, cabinetID string, now time.Time) string {
+	for i, w := range m.Windows {
+		if !w.appliesTo(name, cabinetID) {
+			continue
+		}
+		if w.covers(now) {
+			if w.Name != "" {
+				return w.Name
+			}
+			return fmt.Sprintf("window_%d", i)
+		}
+	}
+	return ""
+}
+
+// Validate checks the maintenance configuration and returns every problem found
+func (m Maintenance) Validate() []error {
+	var errs []error
+
+	if m.Mode != MaintenanceSuppress && m.Mode != MaintenanceTag {
+		errs = append(errs, fmt.Errorf("maintenance.mode: must be %q or %q, got %q", MaintenanceSuppress, MaintenanceTag, m.Mode))
+	}
+
+	for i, w := range m.Windows {
+		if w.Schedule == nil {
+			if w.Start.IsZero() || w.End.IsZero() {
+				errs = append(errs, fmt.Errorf("maintenance.windows[%d]: either start and end or schedule must be set", i))
+			} else if !w.End.After(w.Start) {
+				errs = append(errs, fmt.Errorf("maintenance.windows[%d]: end must be after start", i))
+			}
+			continue
+		}
+
+		s := w.Schedule
+		if _, err := time.Parse("15:04", s.Start); err != nil {
+			errs = append(errs, fmt.Errorf("maintenance.windows[%d].schedule.start: %q is not a HH:MM time", i, s.Start))
+		}
+		if s.Duration <= 0 || s.Duration > 24*time.Hour {
+			errs = append(errs, fmt.Errorf("maintenance.windows[%d].schedule.duration: must be between 0 and 24h, got %s", i, s.Duration))
+		}
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("maintenance.windows[%d].schedule.timezone: %w", i, err))
+		}
+		for _, day := range s.Weekdays {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				errs = append(errs, fmt.Errorf("maintenance.windows[%d].schedule.weekdays: unknown weekday %q", i, day))
+			}
+		}
+	}
+
+	return errs
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// appliesTo reports whether the window covers the given target
+func (w MaintenanceWindow) appliesTo(name, cabinetID string) bool {
+	if len(w.Targets) == 0 {
+		return true
+	}
+	return slices.Contains(w.Targets, name) || (cabinetID != "" && slices.Contains(w.Targets, cabinetID))
+}
+
+// covers reports whether the window is open at the given time
+func (w MaintenanceWindow) covers(now time.Time) bool {
+	if w.Schedule == nil {
+		return !now.Before(w.Start) && now.Before(w.End)
+	}
+
+	s := w.Schedule
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+	start, err := time.Parse("15:04", s.Start)
+	if err != nil {
+		return false
+	}
+
+	// A window that started yesterday may still be open past midnight
+	local := now.In(loc)
+	for _, offset := range []int{0, -1} {
+		day := local.AddDate(0, 0, offset)
+		if !s.onWeekday(day.Weekday()) {
+			continue
+		}
+		open := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+		if !local.Before(open) && local.Before(open.Add(s.Duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// onWeekday reports whether the schedule runs on the given weekday
+func (s RecurringSchedule) onWeekday(day time.Weekday) bool {
+	if len(s.Weekdays) == 0 {
+		return true
+	}
+	for _, name := range s.Weekdays {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// Modbus register types
+const (
+	ModbusInt16   = "int16"
+	ModbusUint16  = "uint16"
+	ModbusInt32   = "int32"
+	ModbusUint32  = "uint32"
+	ModbusFloat32 = "float32"
+)
+
+// ModbusConfig configures the embedded Modbus TCP server exposing selected
+// readings as holding registers
+type ModbusConfig struct {
+	// ListenAddress is the TCP address of the server, empty disables it
+	ListenAddress string
+	// Registers is the register map, from modbus_registers in the
+	// configuration file
+	Registers []ModbusRegister
+}
+
+// ModbusRegister maps the reading selected by metric and labels to the
+// holding register at address, or to the two registers starting there for
+// 32-bit types
+type ModbusRegister struct {
+	Address int               `yaml:"address"`
+	Metric  string            `yaml:"metric"`
+	Labels  map[string]string `yaml:"labels"`
+	Type    string            `yaml:"type"`
+	// Scale multiplies the reading before it is stored, e.g. 10 to keep one
+	// decimal in an integer register
+	Scale float64 `yaml:"scale"`
+}
+
+// Size returns the number of registers used by the type
+func (r ModbusRegister) Size() int {
+	switch r.Type {
+	case ModbusInt32, ModbusUint32, ModbusFloat32:
+		return 2
+	}
+	return 1
+}
+
+// loadModbus loads the Modbus server settings from the environment
+func loadModbus() ModbusConfig {
+	return ModbusConfig{
+		ListenAddress: getEnv("MODBUS_LISTEN_ADDRESS", ""),
+	}
+}
+
+// validate checks the Modbus server settings
+func (m ModbusConfig) validate() []error {
+	if m.ListenAddress == "" {
+		return nil
+	}
+
+	var errs []error
+	if _, _, err := net.SplitHostPort(m.ListenAddress); err != nil {
+		errs = append(errs, fmt.Errorf("MODBUS_LISTEN_ADDRESS: %w", err))
+	}
+	if len(m.Registers) == 0 {
+		errs = append(errs, fmt.Errorf("MODBUS_LISTEN_ADDRESS: modbus_registers in the configuration file is empty"))
+	}
+	return errs
+}
+
+// applyModbusRegisters checks the register map of the configuration file and
+// fills in the defaults
+func applyModbusRegisters(registers []ModbusRegister) ([]ModbusRegister, error) {
+	used := make(map[int]int)
+	for i := range registers {
+		r := &registers[i]
+		if r.Metric == "" {
+			return nil, fmt.Errorf("modbus_registers[%d]: metric must be set", i)
+		}
+		if r.Type == "" {
+			r.Type = ModbusInt16
+		}
+		switch r.Type {
+		case ModbusInt16, ModbusUint16, ModbusInt32, ModbusUint32, ModbusFloat32:
+		default:
+			return nil, fmt.Errorf("modbus_registers[%d]: unknown type %q", i, r.Type)
+		}
+		if r.Scale == 0 {
+			r.Scale = 1
+		}
+		if r.Address < 0 || r.Address+r.Size() > 65536 {
+			return nil, fmt.Errorf("modbus_registers[%d]: address %d is out of range", i, r.Address)
+		}
+		for a := r.Address; a < r.Address+r.Size(); a++ {
+			if j, ok := used[a]; ok {
+				return nil, fmt.Errorf("modbus_registers[%d]: register %d is already used by modbus_registers[%d]", i, a, j)
+			}
+			used[a] = i
+		}
+	}
+	return registers, nil
+}
@@ -0,0 +1,402 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// WebhookConfig configures posting a JSON payload to webhooks for every
+// alarm that is raised or cleared and every threshold rule that fires or
+// resolves
+type WebhookConfig struct {
+	URLs []string
+	// TemplateFile is a text/template rendering the request body from a
+	// notification, empty sends the notification as JSON
+	TemplateFile string
+	// MaxRetries is the number of retries of a failed request
+	MaxRetries int
+}
+
+// loadWebhook loads the webhook settings from the environment
+func loadWebhook(errs *[]error) WebhookConfig {
+	return WebhookConfig{
+		URLs:         splitList(getEnv("WEBHOOK_URLS", "")),
+		TemplateFile: getEnv("WEBHOOK_TEMPLATE_FILE", ""),
+		MaxRetries:   envInt("WEBHOOK_MAX_RETRIES", "3", errs),
+	}
+}
+
+// validate checks the webhook settings
+func (w WebhookConfig) validate() []error {
+	if len(w.URLs) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, u := range w.URLs {
+		if err := validateURL(u); err != nil {
+			errs = append(errs, fmt.Errorf("WEBHOOK_URLS: %w", err))
+		}
+	}
+	if w.TemplateFile != "" {
+		if _, err := w.Template(); err != nil {
+			errs = append(errs, fmt.Errorf("WEBHOOK_TEMPLATE_FILE: %w", err))
+		}
+	}
+	if w.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("WEBHOOK_MAX_RETRIES: must not be negative"))
+	}
+	return errs
+}
+
+// Template parses the template file, or returns nil if there is none
+func (w WebhookConfig) Template() (*template.Template, error) {
+	if w.TemplateFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(w.TemplateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	return ParseTemplate(filepath.Base(w.TemplateFile), string(data))
+}
+
+// AlertmanagerConfig configures sending the alarms and threshold rules to
+// the v2 API of Alertmanager
+type AlertmanagerConfig struct {
+	// URLs are the Alertmanager instances, every alert is sent to each
+	URLs []string
+	// Labels and Annotations are text/templates rendered from a
+	// notification, from alertmanager in the configuration file
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// loadAlertmanager loads the Alertmanager settings from the environment
+func loadAlertmanager() AlertmanagerConfig {
+	return AlertmanagerConfig{
+		URLs: splitList(getEnv("ALERTMANAGER_URLS", "")),
+		Labels: map[string]string{
+			"alertname": "{{if .Rule}}{{.Rule}}{{else}}CDUAlarm{{end}}",
+			"severity":  "{{.Severity}}",
+			"source":    "{{.Source}}",
+			"name":      "{{.Target}}",
+			"item":      "{{.Item}}",
+			"status":    "{{.Status}}",
+		},
+		Annotations: map[string]string{
+			"summary": "{{.Summary}}",
+			"value":   "{{with .Value}}{{.}}{{end}}",
+		},
+	}
+}
+
+// validate checks the Alertmanager settings
+func (a AlertmanagerConfig) validate() []error {
+	if len(a.URLs) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, u := range a.URLs {
+		if err := validateURL(u); err != nil {
+			errs = append(errs, fmt.Errorf("ALERTMANAGER_URLS: %w", err))
+		}
+	}
+	for name, text := range a.Labels {
+		if _, err := ParseTemplate(name, text); err != nil {
+			errs = append(errs, fmt.Errorf("alertmanager.labels.%s: %w", name, err))
+		}
+	}
+	for name, text := range a.Annotations {
+		if _, err := ParseTemplate(name, text); err != nil {
+			errs = append(errs, fmt.Errorf("alertmanager.annotations.%s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// ParseTemplate parses a notification template
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+// templateFuncs are the functions available to notification templates
+var templateFuncs = template.FuncMap{
+	// json encodes a value, such as a string to be embedded in a JSON body
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// ChatConfig configures posting notifications to a chat service through
+// incoming webhooks
+type ChatConfig struct {
+	// prefix is the prefix of the environment variables, such as SLACK
+	prefix string
+	// WebhookURL receives every notification, unless CriticalWebhookURL is
+	// set and receives the critical ones; empty disables the notifier
+	WebhookURL         string
+	CriticalWebhookURL string
+	// TemplateFile is a text/template rendering the message text from a
+	// notification, empty uses a built-in message
+	TemplateFile string
+	// RateLimit is the number of messages per minute, further notifications
+	// are dropped
+	RateLimit int
+}
+
+// loadChat loads the settings of a chat service from the environment
+// variables starting with prefix
+func loadChat(prefix string, errs *[]error) ChatConfig {
+	return ChatConfig{
+		prefix:             prefix,
+		WebhookURL:         getEnv(prefix+"_WEBHOOK_URL", ""),
+		CriticalWebhookURL: getEnv(prefix+"_CRITICAL_WEBHOOK_URL", ""),
+		TemplateFile:       getEnv(prefix+"_TEMPLATE_FILE", ""),
+		RateLimit:          envInt(prefix+"_RATE_LIMIT", "20", errs),
+	}
+}
+
+// Enabled reports whether any webhook URL is set
+func (c ChatConfig) Enabled() bool {
+	return c.WebhookURL != "" || c.CriticalWebhookURL != ""
+}
+
+// URL returns the webhook URL of a severity, or an empty string if the
+// notifications of the severity are not sent
+func (c ChatConfig) URL(severity string) string {
+	if severity == SeverityCritical && c.CriticalWebhookURL != "" {
+		return c.CriticalWebhookURL
+	}
+	return c.WebhookURL
+}
+
+// validate checks the settings of a chat service
+func (c ChatConfig) validate() []error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	var errs []error
+	for _, u := range []struct{ name, url string }{
+		{"_WEBHOOK_URL", c.WebhookURL},
+		{"_CRITICAL_WEBHOOK_URL", c.CriticalWebhookURL},
+	} {
+		if u.url == "" {
+			continue
+		}
+		if err := validateURL(u.url); err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", c.prefix, u.name, err))
+		}
+	}
+	if c.TemplateFile != "" {
+		if _, err := c.Template(); err != nil {
+			errs = append(errs, fmt.Errorf("%s_TEMPLATE_FILE: %w", c.prefix, err))
+		}
+	}
+	if c.RateLimit < 1 {
+		errs = append(errs, fmt.Errorf("%s_RATE_LIMIT: must be at least 1", c.prefix))
+	}
+	return errs
+}
+
+// Template parses the template file, or returns nil if there is none
+func (c ChatConfig) Template() (*template.Template, error) {
+	if c.TemplateFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(c.TemplateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	return ParseTemplate(filepath.Base(c.TemplateFile), string(data))
+}
+
+// SMTP security modes
+const (
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+	SMTPNone     = "none"
+)
+
+// EmailConfig configures sending notifications by email
+type EmailConfig struct {
+	// Address is the host:port of the SMTP server, empty disables email
+	Address  string
+	Username string
+	Password string
+	// Security is starttls, tls for implicit TLS such as on port 465, or none
+	Security string
+	From     string
+	// To receives every notification, in addition to the recipients of the
+	// routes matching it
+	To []string
+	// SubjectTemplate and BodyTemplateFile are text/templates rendered from
+	// a notification, empty uses a built-in body
+	SubjectTemplate  string
+	BodyTemplateFile string
+	// Routes add recipients by severity and labels, from email_routes in
+	// the configuration file
+	Routes []EmailRoute
+}
+
+// EmailRoute sends the notifications with the severity, if set, and the
+// labels to more recipients
+type EmailRoute struct {
+	Severity string            `yaml:"severity"`
+	Labels   map[string]string `yaml:"labels"`
+	To       []string          `yaml:"to"`
+}
+
+// loadEmail loads the email settings from the environment
+func loadEmail() EmailConfig {
+	return EmailConfig{
+		Address:          getEnv("SMTP_ADDRESS", ""),
+		Username:         getEnv("SMTP_USERNAME", ""),
+		Password:         getEnv("SMTP_PASSWORD", ""),
+		Security:         getEnv("SMTP_SECURITY", SMTPStartTLS),
+		From:             getEnv("SMTP_FROM", ""),
+		To:               splitList(getEnv("SMTP_TO", "")),
+		SubjectTemplate:  getEnv("SMTP_SUBJECT_TEMPLATE", "[BDX {{if eq .State \"resolved\"}}RESOLVED{{else}}{{upper .Severity}}{{end}}] {{.Summary}}"),
+		BodyTemplateFile: getEnv("SMTP_BODY_TEMPLATE_FILE", ""),
+	}
+}
+
+// validate checks the email settings
+func (e EmailConfig) validate() []error {
+	if e.Address == "" {
+		return nil
+	}
+
+	var errs []error
+	if _, _, err := net.SplitHostPort(e.Address); err != nil {
+		errs = append(errs, fmt.Errorf("SMTP_ADDRESS: %w", err))
+	}
+	switch e.Security {
+	case SMTPStartTLS, SMTPTLS, SMTPNone:
+	default:
+		errs = append(errs, fmt.Errorf("SMTP_SECURITY: must be %q, %q or %q, got %q", SMTPStartTLS, SMTPTLS, SMTPNone, e.Security))
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		errs = append(errs, fmt.Errorf("SMTP_FROM: %w", err))
+	}
+	for _, to := range e.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			errs = append(errs, fmt.Errorf("SMTP_TO: %q: %w", to, err))
+		}
+	}
+	if len(e.To) == 0 && len(e.Routes) == 0 {
+		errs = append(errs, fmt.Errorf("SMTP_TO: must be set unless email_routes are configured"))
+	}
+	if _, err := ParseTemplate("subject", e.SubjectTemplate); err != nil {
+		errs = append(errs, fmt.Errorf("SMTP_SUBJECT_TEMPLATE: %w", err))
+	}
+	if e.BodyTemplateFile != "" {
+		if _, err := e.BodyTemplate(); err != nil {
+			errs = append(errs, fmt.Errorf("SMTP_BODY_TEMPLATE_FILE: %w", err))
+		}
+	}
+	return errs
+}
+
+// BodyTemplate parses the body template file, or returns nil if there is
+// none
+func (e EmailConfig) BodyTemplate() (*template.Template, error) {
+	if e.BodyTemplateFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(e.BodyTemplateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	return ParseTemplate(filepath.Base(e.BodyTemplateFile), string(data))
+}
+
+// applyEmailRoutes checks the email routes of the configuration file
+func applyEmailRoutes(routes []EmailRoute) ([]EmailRoute, error) {
+	for i, r := range routes {
+		if r.Severity != "" && r.Severity != SeverityWarning && r.Severity != SeverityCritical {
+			return nil, fmt.Errorf("email_routes[%d]: severity must be %q or %q, got %q", i, SeverityWarning, SeverityCritical, r.Severity)
+		}
+		if len(r.To) == 0 {
+			return nil, fmt.Errorf("email_routes[%d]: to must be set", i)
+		}
+		for _, to := range r.To {
+			if _, err := mail.ParseAddress(to); err != nil {
+				return nil, fmt.Errorf("email_routes[%d]: %q: %w", i, to, err)
+			}
+		}
+	}
+	return routes, nil
+}
+
+// PagerDutyConfig configures triggering and resolving PagerDuty incidents
+// for critical notifications through the Events API v2
+type PagerDutyConfig struct {
+	// RoutingKey is the integration key of the service, empty disables
+	// PagerDuty
+	RoutingKey string
+	URL        string
+}
+
+// loadPagerDuty loads the PagerDuty settings from the environment
+func loadPagerDuty() PagerDutyConfig {
+	return PagerDutyConfig{
+		RoutingKey: getEnv("PAGERDUTY_ROUTING_KEY", ""),
+		URL:        getEnv("PAGERDUTY_URL", "https://events.pagerduty.com/v2/enqueue"),
+	}
+}
+
+// validate checks the PagerDuty settings
+func (p PagerDutyConfig) validate() []error {
+	if p.RoutingKey == "" {
+		return nil
+	}
+	if err := validateURL(p.URL); err != nil {
+		return []error{fmt.Errorf("PAGERDUTY_URL: %w", err)}
+	}
+	return nil
+}
+
+// OpsgenieConfig configures creating and closing Opsgenie alerts for
+// critical notifications
+type OpsgenieConfig struct {
+	// APIKey is the key of an API integration, empty disables Opsgenie
+	APIKey string
+	// APIURL is https://api.eu.opsgenie.com for the EU instance
+	APIURL string
+	// Responders are the teams notified of the alerts
+	Responders []string
+	Tags       []string
+}
+
+// loadOpsgenie loads the Opsgenie settings from the environment
+func loadOpsgenie() OpsgenieConfig {
+	return OpsgenieConfig{
+		APIKey:     getEnv("OPSGENIE_API_KEY", ""),
+		APIURL:     getEnv("OPSGENIE_API_URL", "https://api.opsgenie.com"),
+		Responders: splitList(getEnv("OPSGENIE_RESPONDERS", "")),
+		Tags:       splitList(getEnv("OPSGENIE_TAGS", "")),
+	}
+}
+
+// validate checks the Opsgenie settings
+func (o OpsgenieConfig) validate() []error {
+	if o.APIKey == "" {
+		return nil
+	}
+	if err := validateURL(o.APIURL); err != nil {
+		return []error{fmt.Errorf("OPSGENIE_API_URL: %w", err)}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Modes of exporting the CDU parameters
+const (
+	// ParameterMetricsGeneric exports every parameter on bdx_cdu
+	ParameterMetricsGeneric = "generic"
+	// ParameterMetricsNamed exports the mapped parameters as their own
+	// metric only, and the others on bdx_cdu
+	ParameterMetricsNamed = "named"
+	// ParameterMetricsBoth exports the mapped parameters both ways
+	ParameterMetricsBoth = "both"
+)
+
+// metricNameRE matches a valid Prometheus metric name
+var metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// ReservedMetric reports whether a metric name is used by the exporter, when
+// it is set. The collector sets it, as only it knows the metrics it exports.
+var ReservedMetric func(name string) bool
+
+// ParameterMetric maps the CDU parameters whose item matches Item to a
+// metric of their own, such as bdx_cdu_tcs_supply_temperature_celsius
+type ParameterMetric struct {
+	// Item is a regular expression matching the whole item
+	Item string `yaml:"item"`
+	// Type selects the parameters of a dashboard tab by its name, or of the
+	// parameter table with "parameter". Empty matches both.
+	Type   string `yaml:"type"`
+	Metric string `yaml:"metric"`
+	Help   string `yaml:"help"`
+	// Scale multiplies the readings, e.g. 100 to export bar as kPa. Zero
+	// leaves them as they are.
+	Scale float64 `yaml:"scale"`
+	re    *regexp.Regexp
+}
+
+// ParameterMetricConfig configures exporting the CDU parameters as metrics
+// named after them rather than on bdx_cdu with an item label
+type ParameterMetricConfig struct {
+	// Mode is ParameterMetricsGeneric, ParameterMetricsNamed or
+	// ParameterMetricsBoth
+	Mode    string
+	Metrics []ParameterMetric
+}
+
+// loadParameterMetrics loads the parameter metric mode from the environment
+func loadParameterMetrics() ParameterMetricConfig {
+	return ParameterMetricConfig{Mode: getEnv("PARAMETER_METRICS", ParameterMetricsGeneric)}
+}
+
+// applyParameterMetrics checks and compiles the parameter_metrics of the
+// configuration file
+func applyParameterMetrics(metrics []ParameterMetric) ([]ParameterMetric, error) {
+	seen := make(map[string]bool)
+	for i := range metrics {
+		m := &metrics[i]
+		switch {
+		case !metricNameRE.MatchString(m.Metric):
+			return nil, fmt.Errorf("parameter_metrics[%d]: %q is not a valid metric name", i, m.Metric)
+		case m.Metric == "bdx_cdu", ReservedMetric != nil && ReservedMetric(m.Metric):
+			return nil, fmt.Errorf("parameter_metrics[%d]: metric %q is reserved", i, m.Metric)
+		case seen[m.Metric]:
+			return nil, fmt.Errorf("parameter_metrics[%d]: duplicate metric %q", i, m.Metric)
+		}
+		seen[m.Metric] = true
+		re, err := regexp.Compile("^(?:" + m.Item + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("parameter_metrics[%d]: invalid item: %w", i, err)
+		}
+		m.re = re
+		if m.Help == "" {
+			m.Help = "CDU parameter " + m.Item
+		}
+		if m.Scale == 0 {
+			m.Scale = 1
+		}
+	}
+	return metrics, nil
+}
+
+// Lookup returns the metric a CDU parameter of type typ is exported as,
+// the first one whose item and type match. It finds none in the generic
+// mode.
+func (p ParameterMetricConfig) Lookup(typ, item string) (ParameterMetric, bool) {
+	if p.Mode == ParameterMetricsGeneric {
+		return ParameterMetric{}, false
+	}
+	for _, m := range p.Metrics {
+		if (m.Type == "" || m.Type == typ) && m.re.MatchString(item) {
+			return m, true
+		}
+	}
+	return ParameterMetric{}, false
+}
+
+// Named reports whether name is a metric parameters are exported as
+func (p ParameterMetricConfig) Named(name string) bool {
+	if p.Mode == ParameterMetricsGeneric {
+		return false
+	}
+	for _, m := range p.Metrics {
+		if m.Metric == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the parameter metric mode
+func (p ParameterMetricConfig) validate() []error {
+	switch p.Mode {
+	case ParameterMetricsGeneric, ParameterMetricsNamed, ParameterMetricsBoth:
+		return nil
+	}
+	return []error{fmt.Errorf("PARAMETER_METRICS: must be %s, %s or %s, got %q", ParameterMetricsGeneric, ParameterMetricsNamed, ParameterMetricsBoth, p.Mode)}
+}
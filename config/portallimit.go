@@ -0,0 +1,34 @@
+package config
+
+import "fmt"
+
+// PortalLimitConfig caps the load the exporter puts on a portal host,
+// shared by the HTTP requests and browser scrapes of every source
+type PortalLimitConfig struct {
+	// MaxConcurrency is the maximum number of requests and page loads in
+	// progress per host, 0 for no limit
+	MaxConcurrency int
+	// RequestsPerMinute is the maximum number of requests and page loads
+	// started per host and minute, 0 for no limit
+	RequestsPerMinute int
+}
+
+// loadPortalLimit loads the portal load limits from the environment
+func loadPortalLimit(errs *[]error) PortalLimitConfig {
+	return PortalLimitConfig{
+		MaxConcurrency:    envInt("PORTAL_MAX_CONCURRENCY", "2", errs),
+		RequestsPerMinute: envInt("PORTAL_REQUESTS_PER_MINUTE", "0", errs),
+	}
+}
+
+// validate checks the portal load limits
+func (p PortalLimitConfig) validate() []error {
+	var errs []error
+	if p.MaxConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("PORTAL_MAX_CONCURRENCY: must not be negative, got %d", p.MaxConcurrency))
+	}
+	if p.RequestsPerMinute < 0 {
+		errs = append(errs, fmt.Errorf("PORTAL_REQUESTS_PER_MINUTE: must not be negative, got %d", p.RequestsPerMinute))
+	}
+	return errs
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyDirect is the proxy override of a target that is reached without a
+// proxy
+const ProxyDirect = "direct"
+
+// ProxyConfig configures the proxy through which the portal is reached, by
+// the HTTP client as well as the browser
+type ProxyConfig struct {
+	// URL is an http, https or socks5 proxy. When empty, HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY are honored.
+	URL string
+}
+
+// loadProxy loads the proxy settings from the environment
+func loadProxy() ProxyConfig {
+	return ProxyConfig{URL: getEnv("PROXY_URL", "")}
+}
+
+// validate checks the proxy settings
+func (p ProxyConfig) validate() []error {
+	if p.URL == "" {
+		return nil
+	}
+	if err := validateProxyURL(p.URL); err != nil {
+		return []error{fmt.Errorf("PROXY_URL: %w", err)}
+	}
+	return nil
+}
+
+// validateProxyURL checks that raw is an http, https or socks5 proxy URL
+func validateProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+	return nil
+}
+
+// Func returns the proxy function of the configured proxy, falling back to
+// the environment. NO_PROXY applies to both.
+func (p ProxyConfig) Func() func(*url.URL) (*url.URL, error) {
+	env := httpproxy.FromEnvironment()
+	if p.URL != "" {
+		env.HTTPProxy, env.HTTPSProxy = p.URL, p.URL
+	}
+	return env.ProxyFunc()
+}
+
+// For returns the proxy URL for requests to rawURL, or an empty string for a
+// direct connection. A target override, a proxy URL or ProxyDirect, takes
+// precedence over the configured proxy.
+func (p ProxyConfig) For(rawURL, override string) (string, error) {
+	switch override {
+	case "":
+	case ProxyDirect:
+		return "", nil
+	default:
+		return override, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	proxy, err := p.Func()(u)
+	if err != nil || proxy == nil {
+		return "", err
+	}
+	return proxy.String(), nil
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RemoteSource is a key/value store holding the contents of the YAML
+// configuration file
+type RemoteSource interface {
+	// Get returns the current value together with a version that changes
+	// whenever the value does
+	Get(ctx context.Context) ([]byte, uint64, error)
+	// Wait blocks until the version differs from the given one, or until an
+	// implementation specific timeout, and returns the current value
+	Wait(ctx context.Context, version uint64) ([]byte, uint64, error)
+}
+
+// NewRemoteSource creates a remote source from a URL of the form
+// consul://host:port/key/path or etcd://host:port/key/path. Use the
+// consul+https and etcd+https schemes for TLS endpoints.
+func NewRemoteSource(rawURL, token string) (RemoteSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote config URL %q: %w", rawURL, err)
+	}
+
+	kind, scheme, _ := strings.Cut(u.Scheme, "+")
+	if scheme == "" {
+		scheme = "http"
+	}
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("invalid remote config URL %q: unsupported transport %q", rawURL, scheme)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, fmt.Errorf("invalid remote config URL %q: host and key are required", rawURL)
+	}
+	base := scheme + "://" + u.Host
+
+	switch kind {
+	case "consul":
+		return &consulSource{
+			base:   base,
+			key:    key,
+			token:  token,
+			client: &http.Client{Timeout: consulWait + 30*time.Second},
+		}, nil
+	case "etcd":
+		return &etcdSource{
+			base:   base,
+			key:    key,
+			token:  token,
+			client: &http.Client{Timeout: 30 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid remote config URL %q: scheme must be consul or etcd", rawURL)
+	}
+}
+
+// LoadRemote fetches the configuration file contents from
+// c.RemoteConfigURL and merges them into the configuration
+func (c *Config) LoadRemote(ctx context.Context) error {
+	src, err := NewRemoteSource(c.RemoteConfigURL, c.RemoteConfigToken)
+	if err != nil {
+		return err
+	}
+	data, _, err := src.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load remote config: %w", err)
+	}
+	return c.ApplyFile(data, c.RemoteConfigURL)
+}
+
+// consulWait is how long a Consul blocking query waits for a change
+const consulWait = 5 * time.Minute
+
+// consulSource reads the configuration from Consul KV, using blocking
+// queries to wait for changes
+type consulSource struct {
+	base   string
+	key    string
+	token  string
+	client *http.Client
+}
+
+func (s *consulSource) Get(ctx context.Context) ([]byte, uint64, error) {
+	return s.query(ctx, 0)
+}
+
+func (s *consulSource) Wait(ctx context.Context, version uint64) ([]byte, uint64, error) {
+	return s.query(ctx, version)
+}
+
+func (s *consulSource) query(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	q := url.Values{}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", consulWait.String())
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", s.base+"/v1/kv/"+s.key+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if s.token != "" {
+		req.Header.Set("X-Consul-Token", s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query Consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, 0, fmt.Errorf("consul key %s does not exist", s.key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul request failed with status: %s", resp.Status)
+	}
+
+	var entries []struct {
+		Value       string
+		ModifyIndex uint64
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode Consul response: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, 0, fmt.Errorf("consul key %s does not exist", s.key)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(entries[0].Value)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode Consul value: %w", err)
+	}
+	return value, entries[0].ModifyIndex, nil
+}
+
+// etcdPollInterval is how often the etcd key is checked for changes
+const etcdPollInterval = 30 * time.Second
+
+// etcdSource reads the configuration from etcd through its v3 JSON gateway,
+// polling the key's revision to detect changes
+type etcdSource struct {
+	base   string
+	key    string
+	token  string
+	client *http.Client
+}
+
+func (s *etcdSource) Get(ctx context.Context) ([]byte, uint64, error) {
+	body, err := json.Marshal(map[string]string{
+		"key": base64.StdEncoding.EncodeToString([]byte(s.key)),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.base+"/v3/kv/range", bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query etcd: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, 0, fmt.Errorf("etcd request failed with status: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		KVs []struct {
+			Value       string `json:"value"`
+			ModRevision string `json:"mod_revision"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode etcd response: %w", err)
+	}
+	if len(result.KVs) == 0 {
+		return nil, 0, fmt.Errorf("etcd key %s does not exist", s.key)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(result.KVs[0].Value)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode etcd value: %w", err)
+	}
+	revision, err := strconv.ParseUint(result.KVs[0].ModRevision, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid etcd revision %q: %w", result.KVs[0].ModRevision, err)
+	}
+	return value, revision, nil
+}
+
+func (s *etcdSource) Wait(ctx context.Context, version uint64) ([]byte, uint64, error) {
+	ticker := time.NewTicker(etcdPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-ticker.C:
+			value, revision, err := s.Get(ctx)
+			if err != nil || revision != version {
+				return value, revision, err
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Threshold rule severities
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// ThresholdRule breaches while a reading selected by source, target, metric
+// and labels compares to value with the operator, and fires once it has
+// breached for the duration in for
+type ThresholdRule struct {
+	Name   string            `yaml:"name"`
+	Source string            `yaml:"source"`
+	Target string            `yaml:"target"`
+	Metric string            `yaml:"metric"`
+	Labels map[string]string `yaml:"labels"`
+	// Operator is one of >, >=, <, <=, == and !=
+	Operator string        `yaml:"operator"`
+	Value    float64       `yaml:"value"`
+	Severity string        `yaml:"severity"`
+	For      time.Duration `yaml:"for"`
+}
+
+// Breached reports whether the reading breaches the rule
+func (r ThresholdRule) Breached(v float64) bool {
+	switch r.Operator {
+	case ">":
+		return v > r.Value
+	case ">=":
+		return v >= r.Value
+	case "<":
+		return v < r.Value
+	case "<=":
+		return v <= r.Value
+	case "==":
+		return v == r.Value
+	case "!=":
+		return v != r.Value
+	}
+	return false
+}
+
+// applyThresholdRules checks the threshold rules of the configuration file
+// and fills in the defaults
+func applyThresholdRules(rules []ThresholdRule) ([]ThresholdRule, error) {
+	names := make(map[string]bool)
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("threshold_rules[%d]: name must be set", i)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("threshold_rules[%d]: duplicate rule name %q", i, r.Name)
+		}
+		names[r.Name] = true
+		if r.Metric == "" {
+			return nil, fmt.Errorf("threshold_rules[%d]: metric must be set", i)
+		}
+		switch r.Operator {
+		case ">", ">=", "<", "<=", "==", "!=":
+		default:
+			return nil, fmt.Errorf("threshold_rules[%d]: unknown operator %q", i, r.Operator)
+		}
+		if r.Severity == "" {
+			r.Severity = SeverityWarning
+		}
+		if r.Severity != SeverityWarning && r.Severity != SeverityCritical {
+			return nil, fmt.Errorf("threshold_rules[%d]: severity must be %q or %q, got %q", i, SeverityWarning, SeverityCritical, r.Severity)
+		}
+		if r.For < 0 {
+			return nil, fmt.Errorf("threshold_rules[%d]: for must not be negative", i)
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// skipField marks a part of a sensor name that is not turned into a label
+const skipField = "_"
+
+// Policies for sensors of the same name in a TRH response
+const (
+	DuplicateSuffix  = "suffix"
+	DuplicateFirst   = "first"
+	DuplicateAverage = "average"
+	DuplicateDrop    = "drop"
+)
+
+// SensorNameConfig splits the names of the TRH sensors into labels, for
+// names that encode their location such as CGK3A-1.04-ROW3-R12-TOP
+type SensorNameConfig struct {
+	Separator string
+	// Fields are the label names of the parts of a name in order, "_"
+	// skips a part. The last field takes the rest of the name.
+	Fields []string
+	// Duplicates is the policy for sensors of the same name in a response:
+	// DuplicateSuffix, DuplicateFirst, DuplicateAverage or DuplicateDrop
+	Duplicates string
+}
+
+// loadSensorNames loads the sensor name splitting from the environment
+func loadSensorNames() SensorNameConfig {
+	return SensorNameConfig{
+		Separator:  getEnv("SENSOR_NAME_SEPARATOR", "-"),
+		Fields:     splitList(getEnv("SENSOR_NAME_FIELDS", "")),
+		Duplicates: getEnv("SENSOR_DUPLICATE_POLICY", DuplicateFirst),
+	}
+}
+
+// LabelNames returns the names of the labels taken from the sensor names
+func (s SensorNameConfig) LabelNames() []string {
+	var names []string
+	for _, field := range s.Fields {
+		if field != skipField {
+			names = append(names, field)
+		}
+	}
+	return names
+}
+
+// Labels splits a sensor name into its labels. A name with fewer parts than
+// fields leaves the labels of the missing parts out.
+func (s SensorNameConfig) Labels(name string) map[string]string {
+	if len(s.Fields) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(s.Fields))
+	for i, part := range strings.SplitN(name, s.Separator, len(s.Fields)) {
+		if field := s.Fields[i]; field != skipField && part != "" {
+			labels[field] = part
+		}
+	}
+	return labels
+}
+
+// validate checks the sensor name splitting and duplicate policy
+func (s SensorNameConfig) validate() []error {
+	var errs []error
+	switch s.Duplicates {
+	case DuplicateSuffix, DuplicateFirst, DuplicateAverage, DuplicateDrop:
+	default:
+		errs = append(errs, fmt.Errorf("SENSOR_DUPLICATE_POLICY: must be %s, %s, %s or %s, got %q", DuplicateSuffix, DuplicateFirst, DuplicateAverage, DuplicateDrop, s.Duplicates))
+	}
+	if len(s.Fields) == 0 {
+		return errs
+	}
+	if s.Separator == "" {
+		errs = append(errs, fmt.Errorf("SENSOR_NAME_SEPARATOR: must not be empty"))
+	}
+	seen := make(map[string]bool)
+	for _, field := range s.LabelNames() {
+		switch {
+		case field == "name" || !model.LabelName(field).IsValidLegacy():
+			errs = append(errs, fmt.Errorf("SENSOR_NAME_FIELDS: invalid label name %q", field))
+		case seen[field]:
+			errs = append(errs, fmt.Errorf("SENSOR_NAME_FIELDS: duplicate label name %q", field))
+		}
+		seen[field] = true
+	}
+	return errs
+}
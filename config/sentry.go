@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+)
+
+// SentryConfig configures reporting panics, repeated scrape errors and
+// parser anomalies to Sentry or a compatible service such as GlitchTip
+type SentryConfig struct {
+	// DSN is the client key of the project, empty disables reporting
+	DSN         string
+	Environment string
+	// ScrapeErrorThreshold is the number of consecutive failed scrapes of a
+	// target after which the failure is reported
+	ScrapeErrorThreshold int
+}
+
+// loadSentry loads the Sentry settings from the environment. The
+// environment defaults to the ENVIRONMENT label.
+func loadSentry(errs *[]error) SentryConfig {
+	return SentryConfig{
+		DSN:                  getEnv("SENTRY_DSN", ""),
+		Environment:          getEnv("SENTRY_ENVIRONMENT", getEnv("ENVIRONMENT", "")),
+		ScrapeErrorThreshold: envInt("SENTRY_SCRAPE_ERROR_THRESHOLD", "3", errs),
+	}
+}
+
+// validate checks the Sentry settings
+func (s SentryConfig) validate() []error {
+	if s.DSN == "" {
+		return nil
+	}
+
+	var errs []error
+	u, err := url.Parse(s.DSN)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("SENTRY_DSN: %w", err))
+	case u.Scheme != "http" && u.Scheme != "https", u.Host == "":
+		errs = append(errs, fmt.Errorf("SENTRY_DSN: must be an http or https URL"))
+	case u.User.Username() == "":
+		errs = append(errs, fmt.Errorf("SENTRY_DSN: public key is missing"))
+	case path.Base(u.Path) == "/" || path.Base(u.Path) == ".":
+		errs = append(errs, fmt.Errorf("SENTRY_DSN: project ID is missing"))
+	}
+	if s.ScrapeErrorThreshold < 1 {
+		errs = append(errs, fmt.Errorf("SENTRY_SCRAPE_ERROR_THRESHOLD: must be at least 1, got %d", s.ScrapeErrorThreshold))
+	}
+	return errs
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Matcher types, as in Alertmanager
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+// Silence mutes the notifications whose labels match every matcher between
+// StartsAt and EndsAt. Matchers are written like in Alertmanager, e.g.
+// name="CDU_1.1", item=~"pump_.*" or compartment!="B".
+type Silence struct {
+	ID        string    `yaml:"id" json:"id"`
+	Matchers  []string  `yaml:"matchers" json:"matchers"`
+	StartsAt  time.Time `yaml:"starts_at" json:"starts_at"`
+	EndsAt    time.Time `yaml:"ends_at" json:"ends_at"`
+	CreatedBy string    `yaml:"created_by" json:"created_by,omitempty"`
+	Comment   string    `yaml:"comment" json:"comment,omitempty"`
+}
+
+// Matcher matches the value of a label
+type Matcher struct {
+	Name  string
+	Type  string
+	Value string
+	re    *regexp.Regexp
+}
+
+// matcherPattern splits a matcher into label name, type and value
+var matcherPattern = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*(.*?)\s*$`)
+
+// ParseMatcher parses a matcher such as item=~"pump_.*". The value may be
+// quoted.
+func ParseMatcher(s string) (Matcher, error) {
+	parts := matcherPattern.FindStringSubmatch(s)
+	if parts == nil {
+		return Matcher{}, fmt.Errorf("invalid matcher %q", s)
+	}
+	m := Matcher{Name: parts[1], Type: parts[2], Value: parts[3]}
+	if strings.HasPrefix(m.Value, `"`) {
+		value, err := strconv.Unquote(m.Value)
+		if err != nil {
+			return Matcher{}, fmt.Errorf("invalid matcher %q: %w", s, err)
+		}
+		m.Value = value
+	}
+	if m.Type == MatchRegexp || m.Type == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + m.Value + ")$")
+		if err != nil {
+			return Matcher{}, fmt.Errorf("invalid matcher %q: %w", s, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// Matches reports whether the labels match. A missing label has an empty
+// value.
+func (m Matcher) Matches(labels map[string]string) bool {
+	value := labels[m.Name]
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+// ParseMatchers parses the matchers of a silence
+func (s Silence) ParseMatchers() ([]Matcher, error) {
+	matchers := make([]Matcher, 0, len(s.Matchers))
+	for _, text := range s.Matchers {
+		m, err := ParseMatcher(text)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// Active reports whether the silence mutes notifications at the given time
+func (s Silence) Active(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// Validate checks the matchers and the time window of the silence
+func (s Silence) Validate() error {
+	if len(s.Matchers) == 0 {
+		return fmt.Errorf("at least one matcher must be set")
+	}
+	if _, err := s.ParseMatchers(); err != nil {
+		return err
+	}
+	if s.EndsAt.IsZero() {
+		return fmt.Errorf("ends_at must be set")
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// applySilences checks the silences of the configuration file and names the
+// ones without an ID after their position
+func applySilences(silences []Silence) ([]Silence, error) {
+	ids := make(map[string]bool)
+	for i := range silences {
+		s := &silences[i]
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("silences[%d]: %w", i, err)
+		}
+		if s.ID == "" {
+			s.ID = fmt.Sprintf("file-%d", i)
+		}
+		if ids[s.ID] {
+			return nil, fmt.Errorf("silences[%d]: duplicate id %q", i, s.ID)
+		}
+		ids[s.ID] = true
+	}
+	return silences, nil
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SNMP trap versions
+const (
+	SNMPv2c = "v2c"
+	SNMPv3  = "v3"
+)
+
+// SNMPv3 authentication protocols
+const (
+	SNMPAuthNone   = "none"
+	SNMPAuthMD5    = "md5"
+	SNMPAuthSHA    = "sha"
+	SNMPAuthSHA256 = "sha256"
+	SNMPAuthSHA512 = "sha512"
+)
+
+// SNMPv3 privacy protocols
+const (
+	SNMPPrivNone = "none"
+	SNMPPrivDES  = "des"
+	SNMPPrivAES  = "aes"
+)
+
+// SNMP trap variable binding types
+const (
+	SNMPString  = "string"
+	SNMPInteger = "integer"
+	SNMPGauge   = "gauge"
+)
+
+// SNMPConfig configures the embedded SNMP agent serving the readings and
+// the active alarms to network management systems
+type SNMPConfig struct {
+	// ListenAddress is the UDP address of the agent, empty disables it
+	ListenAddress string
+	Community     string
+	// BaseOID is the subtree of the value and alarm tables
+	BaseOID string
+}
+
+// loadSNMP loads the SNMP agent settings from the environment
+func loadSNMP() SNMPConfig {
+	return SNMPConfig{
+		ListenAddress: getEnv("SNMP_LISTEN_ADDRESS", ""),
+		Community:     getEnv("SNMP_COMMUNITY", "public"),
+		BaseOID:       getEnv("SNMP_BASE_OID", "1.3.6.1.4.1.8072.9999.9999.1"),
+	}
+}
+
+// validate checks the SNMP agent settings
+func (s SNMPConfig) validate() []error {
+	if s.ListenAddress == "" {
+		return nil
+	}
+
+	var errs []error
+	if _, _, err := net.SplitHostPort(s.ListenAddress); err != nil {
+		errs = append(errs, fmt.Errorf("SNMP_LISTEN_ADDRESS: %w", err))
+	}
+	if s.Community == "" {
+		errs = append(errs, fmt.Errorf("SNMP_COMMUNITY: must not be empty"))
+	}
+	if err := validateOID(s.BaseOID); err != nil {
+		errs = append(errs, fmt.Errorf("SNMP_BASE_OID: %w", err))
+	}
+	return errs
+}
+
+// SNMPTrapConfig configures sending traps to network management systems when
+// critical alarms appear or clear
+type SNMPTrapConfig struct {
+	// Targets are the receivers as host:port, empty disables traps
+	Targets   []string
+	Version   string
+	Community string
+	// FiringOID and ResolvedOID identify the traps sent when an alarm
+	// appears and when it clears
+	FiringOID   string
+	ResolvedOID string
+	// SNMPv3 user based security
+	Username     string
+	AuthProtocol string
+	AuthPassword string
+	PrivProtocol string
+	PrivPassword string
+	// EngineID is the hex encoded engine ID of the sender, derived from the
+	// hostname when empty
+	EngineID string
+	// Varbinds are the variable bindings sent after snmpTrapOID, from
+	// snmp_trap_varbinds in the configuration file
+	Varbinds []SNMPVarbind
+}
+
+// SNMPVarbind is a variable binding of a trap, its value rendered from the
+// notification by a Go template
+type SNMPVarbind struct {
+	OID   string `yaml:"oid"`
+	Type  string `yaml:"type"`
+	Value string `yaml:"value"`
+}
+
+// loadSNMPTrap loads the SNMP trap settings from the environment. The trap
+// and variable OIDs default to the subtree of the agent.
+func loadSNMPTrap(baseOID string) SNMPTrapConfig {
+	base := strings.TrimSuffix(baseOID, ".")
+	return SNMPTrapConfig{
+		Targets:      splitList(getEnv("SNMP_TRAP_TARGETS", "")),
+		Version:      getEnv("SNMP_TRAP_VERSION", SNMPv2c),
+		Community:    getEnv("SNMP_TRAP_COMMUNITY", "public"),
+		FiringOID:    getEnv("SNMP_TRAP_FIRING_OID", base+".0.1"),
+		ResolvedOID:  getEnv("SNMP_TRAP_RESOLVED_OID", base+".0.2"),
+		Username:     getEnv("SNMP_TRAP_USERNAME", ""),
+		AuthProtocol: strings.ToLower(getEnv("SNMP_TRAP_AUTH_PROTOCOL", SNMPAuthNone)),
+		AuthPassword: getEnv("SNMP_TRAP_AUTH_PASSWORD", ""),
+		PrivProtocol: strings.ToLower(getEnv("SNMP_TRAP_PRIV_PROTOCOL", SNMPPrivNone)),
+		PrivPassword: getEnv("SNMP_TRAP_PRIV_PASSWORD", ""),
+		EngineID:     getEnv("SNMP_TRAP_ENGINE_ID", ""),
+		Varbinds: []SNMPVarbind{
+			{OID: base + ".4.1.0", Type: SNMPString, Value: "{{.Target}}"},
+			{OID: base + ".4.2.0", Type: SNMPString, Value: "{{.Item}}"},
+			{OID: base + ".4.3.0", Type: SNMPString, Value: "{{.Status}}"},
+			{OID: base + ".4.4.0", Type: SNMPString, Value: "{{.Severity}}"},
+			{OID: base + ".4.5.0", Type: SNMPString, Value: "{{.Summary}}"},
+			{OID: base + ".4.6.0", Type: SNMPString, Value: "{{.Rule}}"},
+		},
+	}
+}
+
+// validate checks the SNMP trap settings
+func (t SNMPTrapConfig) validate() []error {
+	if len(t.Targets) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, target := range t.Targets {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			errs = append(errs, fmt.Errorf("SNMP_TRAP_TARGETS: %w", err))
+		}
+	}
+	if err := validateOID(t.FiringOID); err != nil {
+		errs = append(errs, fmt.Errorf("SNMP_TRAP_FIRING_OID: %w", err))
+	}
+	if err := validateOID(t.ResolvedOID); err != nil {
+		errs = append(errs, fmt.Errorf("SNMP_TRAP_RESOLVED_OID: %w", err))
+	}
+
+	switch t.Version {
+	case SNMPv2c:
+		if t.Community == "" {
+			errs = append(errs, fmt.Errorf("SNMP_TRAP_COMMUNITY: must not be empty"))
+		}
+	case SNMPv3:
+		errs = append(errs, t.validateV3()...)
+	default:
+		errs = append(errs, fmt.Errorf("SNMP_TRAP_VERSION: must be %q or %q, got %q", SNMPv2c, SNMPv3, t.Version))
+	}
+	return errs
+}
+
+// validateV3 checks the user based security settings
+func (t SNMPTrapConfig) validateV3() []error {
+	var errs []error
+	if t.Username == "" {
+		errs = append(errs, fmt.Errorf("SNMP_TRAP_USERNAME: must be set for SNMPv3"))
+	}
+	switch t.AuthProtocol {
+	case SNMPAuthNone:
+	case SNMPAuthMD5, SNMPAuthSHA, SNMPAuthSHA256, SNMPAuthSHA512:
+		// RFC 3414 requires passwords of at least 8 characters
+		if len(t.AuthPassword) < 8 {
+			errs = append(errs, fmt.Errorf("SNMP_TRAP_AUTH_PASSWORD: must be at least 8 characters"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("SNMP_TRAP_AUTH_PROTOCOL: must be none, md5, sha, sha256 or sha512, got %q", t.AuthProtocol))
+	}
+	switch t.PrivProtocol {
+	case SNMPPrivNone:
+	case SNMPPrivDES, SNMPPrivAES:
+		if t.AuthProtocol == SNMPAuthNone {
+			errs = append(errs, fmt.Errorf("SNMP_TRAP_PRIV_PROTOCOL: requires SNMP_TRAP_AUTH_PROTOCOL"))
+		}
+		if len(t.PrivPassword) < 8 {
+			errs = append(errs, fmt.Errorf("SNMP_TRAP_PRIV_PASSWORD: must be at least 8 characters"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("SNMP_TRAP_PRIV_PROTOCOL: must be none, des or aes, got %q", t.PrivProtocol))
+	}
+	if t.EngineID != "" {
+		if id, err := hex.DecodeString(t.EngineID); err != nil || len(id) < 5 || len(id) > 32 {
+			errs = append(errs, fmt.Errorf("SNMP_TRAP_ENGINE_ID: must be 5 to 32 hex encoded bytes"))
+		}
+	}
+	return errs
+}
+
+// applySNMPVarbinds checks the trap variable bindings of the configuration
+// file and fills in the defaults
+func applySNMPVarbinds(varbinds []SNMPVarbind) ([]SNMPVarbind, error) {
+	for i := range varbinds {
+		v := &varbinds[i]
+		if err := validateOID(v.OID); err != nil {
+			return nil, fmt.Errorf("snmp_trap_varbinds[%d]: %w", i, err)
+		}
+		if v.Type == "" {
+			v.Type = SNMPString
+		}
+		switch v.Type {
+		case SNMPString, SNMPInteger, SNMPGauge:
+		default:
+			return nil, fmt.Errorf("snmp_trap_varbinds[%d]: unknown type %q", i, v.Type)
+		}
+		if _, err := ParseTemplate(v.OID, v.Value); err != nil {
+			return nil, fmt.Errorf("snmp_trap_varbinds[%d]: %w", i, err)
+		}
+	}
+	return varbinds, nil
+}
+
+// validateOID checks a dotted object identifier such as 1.3.6.1.4.1
+func validateOID(oid string) error {
+	arcs := strings.Split(strings.TrimPrefix(oid, "."), ".")
+	if len(arcs) < 2 {
+		return fmt.Errorf("invalid OID %q: needs at least two arcs", oid)
+	}
+	for i, arc := range arcs {
+		n, err := strconv.ParseUint(arc, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid OID %q: %w", oid, err)
+		}
+		if (i == 0 && n > 2) || (i == 1 && arcs[0] != "2" && n > 39) {
+			return fmt.Errorf("invalid OID %q", oid)
+		}
+	}
+	return nil
+}
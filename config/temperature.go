@@ -0,0 +1,41 @@
+package config
+
+import "fmt"
+
+// Units temperatures are exported in
+const (
+	TemperatureCelsius    = "celsius"
+	TemperatureFahrenheit = "fahrenheit"
+	TemperatureBoth       = "both"
+)
+
+// TemperatureConfig selects the unit of the exported temperatures. The
+// portal reports Celsius; Fahrenheit readings are converted from it.
+type TemperatureConfig struct {
+	// Unit is TemperatureCelsius, TemperatureFahrenheit or TemperatureBoth
+	Unit string
+}
+
+// loadTemperature loads the temperature unit from the environment
+func loadTemperature() TemperatureConfig {
+	return TemperatureConfig{Unit: getEnv("TEMPERATURE_UNIT", TemperatureCelsius)}
+}
+
+// Celsius reports whether temperatures are exported in Celsius
+func (t TemperatureConfig) Celsius() bool {
+	return t.Unit != TemperatureFahrenheit
+}
+
+// Fahrenheit reports whether temperatures are exported in Fahrenheit
+func (t TemperatureConfig) Fahrenheit() bool {
+	return t.Unit == TemperatureFahrenheit || t.Unit == TemperatureBoth
+}
+
+// validate checks the temperature unit
+func (t TemperatureConfig) validate() []error {
+	switch t.Unit {
+	case TemperatureCelsius, TemperatureFahrenheit, TemperatureBoth:
+		return nil
+	}
+	return []error{fmt.Errorf("TEMPERATURE_UNIT: must be %s, %s or %s, got %q", TemperatureCelsius, TemperatureFahrenheit, TemperatureBoth, t.Unit)}
+}
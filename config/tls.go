@@ -0,0 +1,146 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// tlsVersions maps the TLS version names to their crypto/tls value
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// TargetTLSConfig configures TLS of the connections to the portal, by the
+// HTTP client as well as the browser
+type TargetTLSConfig struct {
+	// CAFile holds CA certificates trusted in addition to the system ones,
+	// such as the one of an SSL-inspecting proxy
+	CAFile string
+	// CertFile and KeyFile are the client certificate, only presented by
+	// the HTTP client. The browser doesn't support client certificates.
+	CertFile string
+	KeyFile  string
+	// MinVersion is TLS10 to TLS13
+	MinVersion         string
+	InsecureSkipVerify bool
+}
+
+// loadTargetTLS loads the TLS settings of the portal connections from the
+// environment
+func loadTargetTLS(errs *[]error) TargetTLSConfig {
+	return TargetTLSConfig{
+		CAFile:             getEnv("TARGET_TLS_CA_FILE", ""),
+		CertFile:           getEnv("TARGET_TLS_CERT_FILE", ""),
+		KeyFile:            getEnv("TARGET_TLS_KEY_FILE", ""),
+		MinVersion:         getEnv("TARGET_TLS_MIN_VERSION", "TLS12"),
+		InsecureSkipVerify: envBool("TARGET_TLS_INSECURE_SKIP_VERIFY", "false", errs),
+	}
+}
+
+// ClientConfig builds the crypto/tls configuration of the HTTP client
+func (t TargetTLSConfig) ClientConfig() (*tls.Config, error) {
+	version, ok := tlsVersions[t.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unknown TLS version %q, must be TLS10, TLS11, TLS12 or TLS13", t.MinVersion)
+	}
+	cfg := &tls.Config{
+		MinVersion:         version,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		certs, err := t.caCertificates()
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, cert := range certs {
+			pool.AddCert(cert)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// CASPKIHashes returns the base64 encoded SHA-256 hashes of the public keys
+// of the certificates in CAFile, the format of Chrome's
+// --ignore-certificate-errors-spki-list
+func (t TargetTLSConfig) CASPKIHashes() ([]string, error) {
+	if t.CAFile == "" {
+		return nil, nil
+	}
+	certs, err := t.caCertificates()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		hashes = append(hashes, SPKIHash(cert))
+	}
+	return hashes, nil
+}
+
+// SPKIHash returns the base64 encoded SHA-256 hash of the public key of cert
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// caCertificates parses the certificates in CAFile
+func (t TargetTLSConfig) caCertificates() ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(t.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in CA file %s: %w", t.CAFile, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in CA file %s", t.CAFile)
+	}
+	return certs, nil
+}
+
+// validate checks the TLS settings of the portal connections
+func (t TargetTLSConfig) validate() []error {
+	var errs []error
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("TARGET_TLS_CERT_FILE, TARGET_TLS_KEY_FILE: must be set together"))
+	}
+	if _, err := t.ClientConfig(); err != nil {
+		errs = append(errs, fmt.Errorf("TARGET_TLS: %w", err))
+	}
+	return errs
+}
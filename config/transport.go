@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// TransportConfig tunes the connections of the HTTP client of the portal,
+// which is shared by every collector so connections are reused between
+// requests and scrapes
+type TransportConfig struct {
+	// DialTimeout limits establishing a TCP connection
+	DialTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes, negative disables
+	// them
+	KeepAlive time.Duration
+	// IdleConnTimeout is how long an idle connection is kept for reuse
+	IdleConnTimeout time.Duration
+	// MaxIdleConns limits the idle connections across all hosts
+	MaxIdleConns int
+	// MaxIdleConnsPerHost limits the idle connections to a host
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits all connections to a host, 0 means no limit
+	MaxConnsPerHost int
+	// DisableKeepAlives opens a new connection for every request
+	DisableKeepAlives bool
+}
+
+// loadTransport loads the HTTP client transport settings from the
+// environment
+func loadTransport(errs *[]error) TransportConfig {
+	return TransportConfig{
+		DialTimeout:         envDuration("HTTP_DIAL_TIMEOUT", "30s", errs),
+		KeepAlive:           envDuration("HTTP_KEEPALIVE", "30s", errs),
+		IdleConnTimeout:     envDuration("HTTP_IDLE_CONN_TIMEOUT", "90s", errs),
+		MaxIdleConns:        envInt("HTTP_MAX_IDLE_CONNS", "100", errs),
+		MaxIdleConnsPerHost: envInt("HTTP_MAX_IDLE_CONNS_PER_HOST", "10", errs),
+		MaxConnsPerHost:     envInt("HTTP_MAX_CONNS_PER_HOST", "0", errs),
+		DisableKeepAlives:   envBool("HTTP_DISABLE_KEEPALIVES", "false", errs),
+	}
+}
+
+// validate checks the HTTP client transport settings
+func (t TransportConfig) validate() []error {
+	var errs []error
+	if t.DialTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("HTTP_DIAL_TIMEOUT: must be greater than zero, got %s", t.DialTimeout))
+	}
+	if t.IdleConnTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("HTTP_IDLE_CONN_TIMEOUT: must be greater than zero, got %s", t.IdleConnTimeout))
+	}
+	if t.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("HTTP_MAX_IDLE_CONNS: must not be negative, got %d", t.MaxIdleConns))
+	}
+	if t.MaxIdleConnsPerHost < 1 {
+		errs = append(errs, fmt.Errorf("HTTP_MAX_IDLE_CONNS_PER_HOST: must be at least 1, got %d", t.MaxIdleConnsPerHost))
+	}
+	if t.MaxConnsPerHost < 0 {
+		errs = append(errs, fmt.Errorf("HTTP_MAX_CONNS_PER_HOST: must not be negative, got %d", t.MaxConnsPerHost))
+	}
+	return errs
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/prometheus/common/model"
+)
+
+// defaultTRHURL is the TRH endpoint scraped when none is configured
+const defaultTRHURL = "https://app.managed360view.com/360view/trh_monitoring_dashboard.php"
+
+// TRHTarget describes a temperature and humidity endpoint. Rooms and floors
+// each have their own, told apart by the labels of the target.
+type TRHTarget struct {
+	URL string
+	// Labels are added to the readings of the sensors of the endpoint, such
+	// as room and floor
+	Labels map[string]string
+}
+
+// FileTRHTarget is a TRH endpoint listed in the configuration file
+type FileTRHTarget struct {
+	URL    string            `yaml:"url"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// loadTRHTargets loads the TRH endpoints from TRH_URLS, a list of room=URL
+// pairs that set the room label, or else the single TRH_URL
+func loadTRHTargets(errs *[]error) []TRHTarget {
+	rooms := parseLabels("TRH_URLS", errs)
+	if len(rooms) == 0 {
+		return []TRHTarget{{URL: getEnv("TRH_URL", defaultTRHURL)}}
+	}
+
+	names := make([]string, 0, len(rooms))
+	for name := range rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	targets := make([]TRHTarget, 0, len(names))
+	for _, name := range names {
+		targets = append(targets, TRHTarget{URL: rooms[name], Labels: map[string]string{"room": name}})
+	}
+	return targets
+}
+
+// applyTRHTargets checks the trh_targets of the configuration file
+func applyTRHTargets(fileTargets []FileTRHTarget) ([]TRHTarget, error) {
+	targets := make([]TRHTarget, 0, len(fileTargets))
+	for i, ft := range fileTargets {
+		if ft.URL == "" {
+			return nil, fmt.Errorf("trh_targets[%d]: url must be set", i)
+		}
+		targets = append(targets, TRHTarget{URL: ft.URL, Labels: ft.Labels})
+	}
+	return targets, nil
+}
+
+// TRHLabelNames returns the sorted union of the label names configured on
+// the TRH targets and those taken from the sensor names by splitting, label
+// rules and aisle rules
+func (c *Config) TRHLabelNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, t := range c.TRHTargets {
+		for name := range t.Labels {
+			add(name)
+		}
+	}
+	for _, name := range c.SensorNames.LabelNames() {
+		add(name)
+	}
+	for _, name := range c.RuleLabelNames(LabelRuleSensor) {
+		add(name)
+	}
+	if c.Aisles.Enabled() {
+		add("aisle")
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateTRHTargets checks the TRH endpoints. With several endpoints each
+// needs labels that tell its sensors apart from those of the others.
+func (c *Config) validateTRHTargets() []error {
+	var errs []error
+	if len(c.TRHTargets) == 0 {
+		errs = append(errs, fmt.Errorf("TRH_URLS: no TRH endpoints configured"))
+	}
+	seen := make(map[string]int)
+	for i, t := range c.TRHTargets {
+		if err := validateURL(t.URL); err != nil {
+			errs = append(errs, fmt.Errorf("TRH_URLS[%d]: %w", i, err))
+		}
+		if first, ok := seen[t.URL]; ok {
+			errs = append(errs, fmt.Errorf("TRH_URLS[%d]: duplicate of TRH_URLS[%d] (%s)", i, first, t.URL))
+			continue
+		}
+		seen[t.URL] = i
+		if len(c.TRHTargets) > 1 && len(t.Labels) == 0 {
+			errs = append(errs, fmt.Errorf("TRH_URLS[%d]: labels are required when there are several TRH endpoints (%s)", i, t.URL))
+		}
+		for name := range t.Labels {
+			if name == "name" || !model.LabelName(name).IsValidLegacy() {
+				errs = append(errs, fmt.Errorf("TRH_URLS[%d]: invalid label name %q", i, name))
+			}
+		}
+	}
+	return errs
+}
+
+// TRHURLs returns the URLs of the TRH endpoints
+func (c *Config) TRHURLs() []string {
+	urls := make([]string, 0, len(c.TRHTargets))
+	for _, t := range c.TRHTargets {
+		urls = append(urls, t.URL)
+	}
+	return urls
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// TunnelConfig configures the SSH tunnel through a jump host over which the
+// portal is reached when it lives on an isolated network
+type TunnelConfig struct {
+	// Host is the jump host as host:port. Empty disables the tunnel.
+	Host string
+	User string
+	// KeyFile is the private key to authenticate with, optionally protected
+	// by KeyPassphrase
+	KeyFile       string
+	KeyPassphrase string
+	// KnownHostsFile holds the host key of the jump host
+	KnownHostsFile string
+	KeepAlive      time.Duration
+	// ListenAddress is the local address of the SOCKS5 proxy that forwards
+	// connections over the tunnel
+	ListenAddress string
+}
+
+// loadTunnel loads the SSH tunnel settings from the environment
+func loadTunnel(errs *[]error) TunnelConfig {
+	host := getEnv("SSH_TUNNEL_HOST", "")
+	if host != "" {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "22")
+		}
+	}
+	return TunnelConfig{
+		Host:           host,
+		User:           getEnv("SSH_TUNNEL_USER", ""),
+		KeyFile:        getEnv("SSH_TUNNEL_KEY_FILE", ""),
+		KeyPassphrase:  getEnv("SSH_TUNNEL_KEY_PASSPHRASE", ""),
+		KnownHostsFile: getEnv("SSH_TUNNEL_KNOWN_HOSTS", ""),
+		KeepAlive:      envModelDuration("SSH_TUNNEL_KEEPALIVE", "30s", errs),
+		ListenAddress:  getEnv("SSH_TUNNEL_LISTEN_ADDRESS", "127.0.0.1:1080"),
+	}
+}
+
+// ProxyURL returns the URL of the local SOCKS5 proxy of the tunnel
+func (t TunnelConfig) ProxyURL() string {
+	return "socks5://" + t.ListenAddress
+}
+
+// validate checks the SSH tunnel settings
+func (t TunnelConfig) validate() []error {
+	if t.Host == "" {
+		return nil
+	}
+
+	var errs []error
+	if t.User == "" {
+		errs = append(errs, fmt.Errorf("SSH_TUNNEL_USER: must be set with SSH_TUNNEL_HOST"))
+	}
+	if t.KeyFile == "" {
+		errs = append(errs, fmt.Errorf("SSH_TUNNEL_KEY_FILE: must be set with SSH_TUNNEL_HOST"))
+	}
+	if t.KnownHostsFile == "" {
+		errs = append(errs, fmt.Errorf("SSH_TUNNEL_KNOWN_HOSTS: must be set with SSH_TUNNEL_HOST to verify the jump host"))
+	}
+	if t.KeepAlive <= 0 {
+		errs = append(errs, fmt.Errorf("SSH_TUNNEL_KEEPALIVE: must be positive, got %s", t.KeepAlive))
+	}
+	if _, _, err := net.SplitHostPort(t.ListenAddress); err != nil {
+		errs = append(errs, fmt.Errorf("SSH_TUNNEL_LISTEN_ADDRESS: %w", err))
+	}
+	return errs
+}
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/web"
+)
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedCDULabels are the label names already used by the CDU metrics
+var reservedCDULabels = map[string]bool{
+	"name":           true,
+	"cabinet_id":     true,
+	"type":           true,
+	"item":           true,
+	"status":         true,
+	"metrix_type":    true,
+	"in_maintenance": true,
+}
+
+// LoadErrors returns the settings Load couldn't parse
+func (c *Config) LoadErrors() []error {
+	return c.loadErrs
+}
+
+// Validate checks the configuration for problems that would prevent the
+// exporter from collecting data and returns every problem found, starting
+// with the settings Load couldn't parse
+func (c *Config) Validate() []error {
+	errs := append([]error(nil), c.loadErrs...)
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT: %q is not a valid port number", c.Port))
+	}
+	if _, _, err := net.SplitHostPort(c.ListenAddress); err != nil {
+		errs = append(errs, fmt.Errorf("web.listen-address: %q is not a valid address: %w", c.ListenAddress, err))
+	}
+	if c.MetricsListenAddress != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsListenAddress); err != nil {
+			errs = append(errs, fmt.Errorf("web.metrics-listen-address: %q is not a valid address: %w", c.MetricsListenAddress, err))
+		} else if c.MetricsListenAddress == c.ListenAddress {
+			errs = append(errs, fmt.Errorf("web.metrics-listen-address: %q is already used by web.listen-address", c.MetricsListenAddress))
+		}
+	}
+	if c.AdminListenAddress != "" {
+		if _, _, err := net.SplitHostPort(c.AdminListenAddress); err != nil {
+			errs = append(errs, fmt.Errorf("web.admin-listen-address: %q is not a valid address: %w", c.AdminListenAddress, err))
+		} else if c.AdminListenAddress == c.ListenAddress || c.AdminListenAddress == c.MetricsListenAddress {
+			errs = append(errs, fmt.Errorf("web.admin-listen-address: %q is already used by another listener", c.AdminListenAddress))
+		}
+	}
+	if !strings.HasPrefix(c.TelemetryPath, "/") {
+		errs = append(errs, fmt.Errorf("web.telemetry-path: %q must start with /", c.TelemetryPath))
+	}
+
+	// Load the web configuration the way the listeners do, so a bad file is
+	// reported here rather than stopping the server once it starts
+	if c.WebConfigFile != "" {
+		if webCfg, err := web.LoadConfig(c.WebConfigFile); err != nil {
+			errs = append(errs, fmt.Errorf("web.config.file: %w", err))
+		} else if webCfg.TLSEnabled() {
+			if _, err := webCfg.ServerTLSConfig(); err != nil {
+				errs = append(errs, fmt.Errorf("web.config.file: %w", err))
+			}
+		}
+	}
+
+	if c.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT: must not be negative, got %g", c.RateLimit))
+	}
+	if c.RateLimit > 0 && c.RateLimitBurst < 1 {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BURST: must be at least 1, got %d", c.RateLimitBurst))
+	}
+
+	if c.LiquidScrollPasses < 0 {
+		errs = append(errs, fmt.Errorf("LIQUID_SCROLL_PASSES: must not be negative, got %d", c.LiquidScrollPasses))
+	}
+
+	if c.EventBufferSize < 0 {
+		errs = append(errs, fmt.Errorf("EVENT_BUFFER_SIZE: must not be negative, got %d", c.EventBufferSize))
+	}
+	if c.AlarmRaiseCycles < 1 {
+		errs = append(errs, fmt.Errorf("ALARM_RAISE_CYCLES: must be at least 1, got %d", c.AlarmRaiseCycles))
+	}
+	if c.AlarmClearCycles < 1 {
+		errs = append(errs, fmt.Errorf("ALARM_CLEAR_CYCLES: must be at least 1, got %d", c.AlarmClearCycles))
+	}
+
+	if c.AvailabilityRetention < time.Hour {
+		errs = append(errs, fmt.Errorf("AVAILABILITY_RETENTION: must be at least 1h, got %s", c.AvailabilityRetention))
+	}
+	if c.HistoryPath != "" && c.HistoryRetention < time.Hour {
+		errs = append(errs, fmt.Errorf("HISTORY_RETENTION: must be at least 1h, got %s", c.HistoryRetention))
+	}
+
+	if c.ScrapeInterval <= 0 {
+		errs = append(errs, fmt.Errorf("SCRAPE_INTERVAL: must be greater than zero, got %s", c.ScrapeInterval))
+	}
+	if c.HTTPTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("HTTP_TIMEOUT: must be greater than zero, got %s", c.HTTPTimeout))
+	}
+	if c.ScrapeTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("SCRAPE_TIMEOUT: must be greater than zero, got %s", c.ScrapeTimeout))
+	}
+	if c.HangTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("COLLECTION_HANG_TIMEOUT: must be greater than zero, got %s", c.HangTimeout))
+	}
+	if c.ShutdownGracePeriod < 0 {
+		errs = append(errs, fmt.Errorf("SHUTDOWN_GRACE_PERIOD: must not be negative, got %s", c.ShutdownGracePeriod))
+	}
+
+	errs = append(errs, c.validateTRHTargets()...)
+	errs = append(errs, c.SensorNames.validate()...)
+	errs = append(errs, c.Temperature.validate()...)
+	errs = append(errs, c.Zones.validate()...)
+	errs = append(errs, c.Aisles.validate()...)
+	errs = append(errs, c.AlarmHistory.validate()...)
+	errs = append(errs, c.ParameterMetrics.validate()...)
+	if err := validateURL(c.LiquidCoolingURL); err != nil {
+		errs = append(errs, fmt.Errorf("LIQUID_URL: %w", err))
+	}
+	if c.Referer != "" {
+		if err := validateURL(c.Referer); err != nil {
+			errs = append(errs, fmt.Errorf("REFERER: %w", err))
+		}
+	}
+
+	if c.DiscoveryURL != "" {
+		if err := validateURL(c.DiscoveryURL); err != nil {
+			errs = append(errs, fmt.Errorf("DISCOVERY_URL: %w", err))
+		}
+	}
+	if c.DiscoveryInterval <= 0 {
+		errs = append(errs, fmt.Errorf("DISCOVERY_INTERVAL: must be greater than zero, got %s", c.DiscoveryInterval))
+	}
+	if c.RemoteConfigURL != "" {
+		if _, err := NewRemoteSource(c.RemoteConfigURL, c.RemoteConfigToken); err != nil {
+			errs = append(errs, fmt.Errorf("REMOTE_CONFIG_URL: %w", err))
+		}
+	}
+
+	if len(c.CDUURLs) == 0 && c.DiscoveryURL == "" {
+		errs = append(errs, fmt.Errorf("CDU_URLS: no CDU dashboard URLs configured and DISCOVERY_URL is not set"))
+	}
+	seen := make(map[string]int)
+	for i, u := range c.CDUURLs {
+		if err := validateURL(u); err != nil {
+			errs = append(errs, fmt.Errorf("CDU_URLS[%d]: %w", i, err))
+		}
+		if first, ok := seen[u]; ok {
+			errs = append(errs, fmt.Errorf("CDU_URLS[%d]: duplicate of CDU_URLS[%d] (%s)", i, first, u))
+			continue
+		}
+		seen[u] = i
+	}
+
+	for _, t := range c.CDUTargets {
+		for name := range t.Labels {
+			if !labelNameRE.MatchString(name) {
+				errs = append(errs, fmt.Errorf("cdu_targets: %q is not a valid label name (target %s)", name, t.URL))
+			}
+			if reservedCDULabels[name] {
+				errs = append(errs, fmt.Errorf("cdu_targets: label %q is reserved (target %s)", name, t.URL))
+			}
+		}
+	}
+
+	for name := range c.ConstantLabels {
+		if !labelNameRE.MatchString(name) {
+			errs = append(errs, fmt.Errorf("constant_labels: %q is not a valid label name", name))
+		}
+		if reservedCDULabels[name] {
+			errs = append(errs, fmt.Errorf("constant_labels: label %q is reserved", name))
+		}
+	}
+	for _, name := range c.CDULabelNames() {
+		if _, ok := c.ConstantLabels[name]; ok {
+			errs = append(errs, fmt.Errorf("constant_labels: label %q is also set on CDU targets", name))
+		}
+	}
+	for _, source := range []string{LabelRuleSensor, LabelRuleCDU, LabelRuleRack} {
+		for _, name := range c.RuleLabelNames(source) {
+			if _, ok := c.ConstantLabels[name]; ok {
+				errs = append(errs, fmt.Errorf("constant_labels: label %q is also derived by label_rules", name))
+			}
+		}
+	}
+
+	errs = append(errs, c.Maintenance.Validate()...)
+	errs = append(errs, c.Pushgateway.validate()...)
+	errs = append(errs, c.Graphite.validate()...)
+	errs = append(errs, c.StatsD.validate()...)
+	errs = append(errs, c.Kafka.validate()...)
+	errs = append(errs, c.MQTT.validate()...)
+	errs = append(errs, c.CloudWatch.validate()...)
+	errs = append(errs, c.Datadog.validate()...)
+	errs = append(errs, c.GoogleCloudMonitoring.validate()...)
+	errs = append(errs, c.Postgres.validate()...)
+	errs = append(errs, c.SNMP.validate()...)
+	errs = append(errs, c.SNMPTrap.validate()...)
+	errs = append(errs, c.Modbus.validate()...)
+	errs = append(errs, c.Checkmk.validate()...)
+	errs = append(errs, c.Webhook.validate()...)
+	errs = append(errs, c.Alertmanager.validate()...)
+	errs = append(errs, c.Slack.validate()...)
+	errs = append(errs, c.Teams.validate()...)
+	errs = append(errs, c.Email.validate()...)
+	errs = append(errs, c.PagerDuty.validate()...)
+	errs = append(errs, c.Opsgenie.validate()...)
+	errs = append(errs, c.Logging.validate()...)
+	errs = append(errs, c.Election.validate()...)
+	errs = append(errs, c.Watch.validate()...)
+	errs = append(errs, c.Federation.validate()...)
+	errs = append(errs, c.Proxy.validate()...)
+	errs = append(errs, c.Tunnel.validate()...)
+	errs = append(errs, c.TargetTLS.validate()...)
+	errs = append(errs, c.DNS.validate()...)
+	errs = append(errs, c.Transport.validate()...)
+	errs = append(errs, c.Limits.validate()...)
+	errs = append(errs, c.PortalLimit.validate()...)
+	errs = append(errs, c.Sentry.validate()...)
+
+	if c.SessMap == "" {
+		errs = append(errs, fmt.Errorf("SESS_MAP: session cookie is not set"))
+	}
+	if c.PHPSessID == "" {
+		errs = append(errs, fmt.Errorf("PHPSESSID: session cookie is not set"))
+	}
+
+	return errs
+}
+
+// CheckReachability dials every host referenced by the configured URLs and
+// returns an error for each one that cannot be reached within the timeout
+func (c *Config) CheckReachability(timeout time.Duration) []error {
+	var errs []error
+
+	urls := append(append(c.TRHURLs(), c.LiquidCoolingURL), c.CDUURLs...)
+	checked := make(map[string]bool)
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			// Already reported by Validate
+			continue
+		}
+
+		addr := u.Host
+		if u.Port() == "" {
+			port := "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+			addr = net.JoinHostPort(u.Hostname(), port)
+		}
+		if checked[addr] {
+			continue
+		}
+		checked[addr] = true
+
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: host unreachable: %w", addr, err))
+			continue
+		}
+		conn.Close()
+	}
+
+	return errs
+}
+
+// validateURL checks that raw is an absolute http or https URL
+func validateURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("URL is empty")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+	return nil
+}
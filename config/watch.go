@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// WatchConfig configures reloading the configuration when the files it is
+// read from change, such as a mounted ConfigMap or Secret
+type WatchConfig struct {
+	// Paths are the files and directories to watch. When empty and running
+	// in Kubernetes, the configuration, admin token and web configuration
+	// files are watched.
+	Paths []string
+	// Interval is how often the paths are checked, 0 disables watching
+	Interval time.Duration
+}
+
+// loadWatch loads the configuration watch settings from the environment
+func loadWatch(errs *[]error) WatchConfig {
+	return WatchConfig{
+		Paths:    splitList(getEnv("CONFIG_WATCH_PATHS", "")),
+		Interval: envModelDuration("CONFIG_WATCH_INTERVAL", "10s", errs),
+	}
+}
+
+// WatchedPaths returns the files and directories whose changes reload the
+// configuration
+func (c *Config) WatchedPaths() []string {
+	if c.Watch.Interval == 0 {
+		return nil
+	}
+	if len(c.Watch.Paths) > 0 {
+		return c.Watch.Paths
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return nil
+	}
+	var paths []string
+	for _, path := range []string{c.ConfigFile, c.AdminTokenFile, c.WebConfigFile} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// validate checks the configuration watch settings
+func (w WatchConfig) validate() []error {
+	if w.Interval < 0 {
+		return []error{fmt.Errorf("CONFIG_WATCH_INTERVAL: must not be negative, got %s", w.Interval)}
+	}
+	return nil
+}
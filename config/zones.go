@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/prometheus/common/model"
+)
+
+// ZoneConfig groups the TRH sensors into zones, such as rooms or cold
+// aisles, whose temperature and humidity are aggregated
+type ZoneConfig struct {
+	// Label is the label of the sensors naming their zone, such as room,
+	// whether set on the TRH target or derived from the sensor name
+	Label string
+	// Sensors maps sensor names to their zone, taking precedence over Label
+	Sensors map[string]string
+}
+
+// loadZones loads the zone label from the environment
+func loadZones() ZoneConfig {
+	return ZoneConfig{Label: getEnv("TRH_ZONE_LABEL", "")}
+}
+
+// applySensorZones turns the sensor_zones of the configuration file, lists
+// of sensors by zone, into the zone of each sensor
+func applySensorZones(zones map[string][]string) (map[string]string, error) {
+	sensors := make(map[string]string)
+	for zone, names := range zones {
+		for _, name := range names {
+			if other, ok := sensors[name]; ok && other != zone {
+				return nil, fmt.Errorf("sensor_zones: sensor %q is in both %q and %q", name, other, zone)
+			}
+			sensors[name] = zone
+		}
+	}
+	return sensors, nil
+}
+
+// Zone returns the zone of a sensor with labels, empty when it has none
+func (z ZoneConfig) Zone(sensor string, labels map[string]string) string {
+	if zone, ok := z.Sensors[sensor]; ok {
+		return zone
+	}
+	if z.Label != "" {
+		return labels[z.Label]
+	}
+	return ""
+}
+
+// validate checks the zone label
+func (z ZoneConfig) validate() []error {
+	if z.Label != "" && (z.Label == "name" || !model.LabelName(z.Label).IsValidLegacy()) {
+		return []error{fmt.Errorf("TRH_ZONE_LABEL: invalid label name %q", z.Label)}
+	}
+	return nil
+}
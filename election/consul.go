@@ -0,0 +1,150 @@
+package election
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// consulLock is a Consul KV lock held through a session whose TTL is the
+// lease duration
+type consulLock struct {
+	base     string
+	key      string
+	token    string
+	identity string
+	duration time.Duration
+	client   *http.Client
+
+	session string
+}
+
+// newConsulLock creates the lock of consul://host:port/key, or of
+// consul+https://host:port/key for a TLS endpoint
+func newConsulLock(u *url.URL, cfg config.ElectionConfig, timeout time.Duration) *consulLock {
+	scheme := "http"
+	if u.Scheme == "consul+https" {
+		scheme = "https"
+	}
+	return &consulLock{
+		base:     scheme + "://" + u.Host,
+		key:      strings.Trim(u.Path, "/"),
+		token:    cfg.Token,
+		identity: cfg.Identity,
+		duration: cfg.LeaseDuration,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *consulLock) tryAcquire(ctx context.Context) (string, error) {
+	if err := c.renewSession(ctx); err != nil {
+		return "", err
+	}
+
+	var acquired bool
+	if err := c.request(ctx, "PUT", "/v1/kv/"+c.key+"?acquire="+c.session, []byte(c.identity), &acquired); err != nil {
+		return "", err
+	}
+	if acquired {
+		return c.identity, nil
+	}
+
+	var entries []struct {
+		Value   string
+		Session string
+	}
+	if err := c.request(ctx, "GET", "/v1/kv/"+c.key, nil, &entries); err != nil {
+		return "", err
+	}
+	if len(entries) == 0 || entries[0].Session == "" {
+		// Released, but still in its lock delay
+		return "", fmt.Errorf("consul lock %s is not held but could not be acquired yet", c.key)
+	}
+	holder, err := base64.StdEncoding.DecodeString(entries[0].Value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode Consul value: %w", err)
+	}
+	return string(holder), nil
+}
+
+func (c *consulLock) release(ctx context.Context) error {
+	if c.session == "" {
+		return nil
+	}
+	if err := c.request(ctx, "PUT", "/v1/kv/"+c.key+"?release="+c.session, nil, nil); err != nil {
+		return err
+	}
+	err := c.request(ctx, "PUT", "/v1/session/destroy/"+c.session, nil, nil)
+	c.session = ""
+	return err
+}
+
+// renewSession renews the session holding the lock, or creates a new one if
+// it expired
+func (c *consulLock) renewSession(ctx context.Context) error {
+	if c.session != "" {
+		err := c.request(ctx, "PUT", "/v1/session/renew/"+c.session, nil, nil)
+		if !errors.Is(err, errSessionNotFound) {
+			return err
+		}
+		c.session = ""
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"Name":      "bdx_exporter " + c.identity,
+		"TTL":       c.duration.String(),
+		"LockDelay": "0s",
+		"Behavior":  "release",
+	})
+	if err != nil {
+		return err
+	}
+	var created struct{ ID string }
+	if err := c.request(ctx, "PUT", "/v1/session/create", body, &created); err != nil {
+		return err
+	}
+	c.session = created.ID
+	return nil
+}
+
+// errSessionNotFound is returned when renewing a session that expired
+var errSessionNotFound = errors.New("consul session not found")
+
+// request sends a request to the Consul HTTP API and decodes the response
+// into result unless it is nil
+func (c *consulLock) request(ctx context.Context, method, path string, body []byte, result any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/v1/session/renew/"):
+		return errSessionNotFound
+	case resp.StatusCode != http.StatusOK:
+		return statusError(resp)
+	case result == nil:
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode Consul response: %w", err)
+	}
+	return nil
+}
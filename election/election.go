@@ -0,0 +1,124 @@
+// Package election elects the replica of an active/standby pair that scrapes
+// the portal, through a Kubernetes Lease or a Consul lock
+package election
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// lock is a lease held by at most one replica at a time
+type lock interface {
+	// tryAcquire acquires the lease, or renews it if this replica holds it,
+	// and returns the identity of the holder
+	tryAcquire(ctx context.Context) (string, error)
+	// release gives up the lease if this replica holds it
+	release(ctx context.Context) error
+}
+
+// newLock creates the lock of the lease in cfg.URL
+func newLock(cfg config.ElectionConfig, timeout time.Duration) (lock, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid leader election URL %q: %w", cfg.URL, err)
+	}
+	switch u.Scheme {
+	case "kubernetes":
+		return newKubernetesLock(u, cfg, timeout)
+	case "consul", "consul+https":
+		return newConsulLock(u, cfg, timeout), nil
+	default:
+		return nil, fmt.Errorf("invalid leader election URL %q: scheme must be kubernetes or consul", cfg.URL)
+	}
+}
+
+// Start makes the collector a standby and competes for the lease in the
+// background. The collector scrapes while this replica holds the lease. The
+// returned function stops competing and releases the lease, so the standby
+// takes over without waiting for it to expire.
+func Start(ctx context.Context, cfg config.ElectionConfig, timeout time.Duration, col *collector.Collector) (func(), error) {
+	l, err := newLock(cfg, timeout)
+	if err != nil {
+		return nil, err
+	}
+	col.SetLeader(false)
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx, cfg, l, col)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		if !col.IsLeader() {
+			return
+		}
+		col.SetLeader(false)
+		releaseCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := l.release(releaseCtx); err != nil {
+			log.Printf("Failed to release leader lease: %v", err)
+			return
+		}
+		log.Printf("Released leader lease")
+	}, nil
+}
+
+// run tries to acquire or renew the lease three times per lease duration.
+// The leader steps down when it could not renew the lease for two thirds of
+// the lease duration, before another replica may take it over.
+func run(ctx context.Context, cfg config.ElectionConfig, l lock, col *collector.Collector) {
+	ticker := time.NewTicker(cfg.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	var renewed time.Time
+	lastHolder := ""
+	for {
+		holder, err := l.tryAcquire(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			log.Printf("Failed to acquire leader lease: %v", err)
+			if col.IsLeader() && time.Since(renewed) > cfg.LeaseDuration*2/3 {
+				log.Printf("Could not renew leader lease, stepping down")
+				col.SetLeader(false)
+			}
+		case holder == cfg.Identity:
+			renewed = time.Now()
+			if !col.IsLeader() {
+				log.Printf("Acquired leader lease as %s, starting collection", cfg.Identity)
+				col.SetLeader(true)
+				go func() {
+					if err := col.Discover(); err != nil {
+						log.Printf("Failed to discover CDU targets: %v", err)
+					}
+					col.Collect(ctx)
+				}()
+			}
+		default:
+			if col.IsLeader() {
+				log.Printf("Lost leader lease to %s, stopping collection", holder)
+				col.SetLeader(false)
+			} else if holder != lastHolder {
+				log.Printf("Standing by, leader is %s", holder)
+			}
+		}
+		lastHolder = holder
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package election
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// serviceAccountDir holds the credentials of the pod's service account
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// microTime is the time format of the Lease fields
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// kubernetesLock is a coordination.k8s.io/v1 Lease, accessed with the
+// in-cluster service account credentials
+type kubernetesLock struct {
+	url      string
+	name     string
+	identity string
+	duration time.Duration
+	client   *http.Client
+}
+
+// lease is the part of a Lease object the lock uses
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions"`
+}
+
+// errLeaseConflict is returned when another replica updated the Lease
+// between reading and writing it
+var errLeaseConflict = errors.New("lease was updated by another replica")
+
+// newKubernetesLock creates the lock of kubernetes://namespace/name. The
+// namespace defaults to the one of the pod.
+func newKubernetesLock(u *url.URL, cfg config.ElectionConfig, timeout time.Duration) (*kubernetesLock, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("leader election through a Kubernetes Lease requires running in a pod")
+	}
+	namespace := u.Host
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pod namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in cluster CA %s/ca.crt", serviceAccountDir)
+	}
+
+	name := strings.Trim(u.Path, "/")
+	return &kubernetesLock{
+		url:      "https://" + net.JoinHostPort(host, port) + "/apis/coordination.k8s.io/v1/namespaces/" + namespace + "/leases",
+		name:     name,
+		identity: cfg.Identity,
+		duration: cfg.LeaseDuration,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+func (k *kubernetesLock) tryAcquire(ctx context.Context) (string, error) {
+	now := time.Now()
+	l, err := k.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	if l == nil {
+		l = &lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: k.name},
+			Spec:       leaseSpec{HolderIdentity: k.identity, AcquireTime: now.Format(microTime)},
+		}
+		l.Spec.LeaseDurationSeconds = int(k.duration.Seconds())
+		l.Spec.RenewTime = now.Format(microTime)
+		if err := k.write(ctx, "POST", k.url, l); err != nil {
+			return "", err
+		}
+		return k.identity, nil
+	}
+
+	holder := l.Spec.HolderIdentity
+	if holder != k.identity && holder != "" && !l.expired(now) {
+		return holder, nil
+	}
+	if holder != k.identity {
+		l.Spec.HolderIdentity = k.identity
+		l.Spec.AcquireTime = now.Format(microTime)
+		l.Spec.LeaseTransitions++
+	}
+	l.Spec.LeaseDurationSeconds = int(k.duration.Seconds())
+	l.Spec.RenewTime = now.Format(microTime)
+	if err := k.write(ctx, "PUT", k.url+"/"+k.name, l); err != nil {
+		return "", err
+	}
+	return k.identity, nil
+}
+
+func (k *kubernetesLock) release(ctx context.Context) error {
+	l, err := k.get(ctx)
+	if err != nil || l == nil || l.Spec.HolderIdentity != k.identity {
+		return err
+	}
+	// Like client-go, leave an expired lease without holder behind
+	l.Spec.HolderIdentity = ""
+	l.Spec.LeaseDurationSeconds = 1
+	l.Spec.RenewTime = time.Now().Format(microTime)
+	return k.write(ctx, "PUT", k.url+"/"+k.name, l)
+}
+
+// expired reports whether the holder of the lease failed to renew it in time
+func (l *lease) expired(now time.Time) bool {
+	renewed, err := time.Parse(time.RFC3339Nano, l.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(l.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+// get returns the Lease, or nil if it does not exist
+func (k *kubernetesLock) get(ctx context.Context) (*lease, error) {
+	resp, err := k.do(ctx, "GET", k.url+"/"+k.name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+	var l lease
+	if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+		return nil, fmt.Errorf("failed to decode lease: %w", err)
+	}
+	return &l, nil
+}
+
+// write creates or updates the Lease. The update fails with
+// errLeaseConflict if the Lease changed since it was read.
+func (k *kubernetesLock) write(ctx context.Context, method, url string, l *lease) error {
+	body, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	resp, err := k.do(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return errLeaseConflict
+	case resp.StatusCode >= 300:
+		return statusError(resp)
+	}
+	return nil
+}
+
+// do sends a request to the API server with the service account token,
+// which is read on every request as it is rotated
+func (k *kubernetesLock) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the Kubernetes API: %w", err)
+	}
+	return resp, nil
+}
+
+// statusError describes an unexpected response
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("request failed with status: %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/version"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/federation"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/logging"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/web"
+)
+
+// federate runs a central instance that scrapes the site exporters in
+// FEDERATION_SITES and serves their metrics with a site label, instead of
+// scraping the portal itself
+func federate(args []string) int {
+	fs := flag.NewFlagSet("federate", flag.ExitOnError)
+	cfg, err := readConfig(fs, args)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	closeLog, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	defer closeLog()
+	gin.DefaultWriter, gin.DefaultErrorWriter = log.Writer(), log.Writer()
+
+	log.Printf("Starting %s %s in federation mode", programName, version.Info())
+	prometheus.MustRegister(versioncollector.NewCollector(programName))
+
+	if errs := cfg.Validate(); len(errs) > 0 {
+		log.Fatalf("Invalid config: %v", errors.Join(errs...))
+	}
+	if len(cfg.Federation.Sites) == 0 {
+		log.Fatalf("Invalid config: FEDERATION_SITES: must list the site exporters to federate")
+	}
+
+	fed, err := federation.New(cfg.Federation, cfg.TelemetryPath, cfg.HTTPTimeout)
+	if err != nil {
+		log.Fatalf("Failed to set up federation: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go fed.Run(ctx)
+
+	r := gin.New()
+	r.Use(recoverPanics(nil), requestID())
+
+	// Healthy while every site is scraped successfully
+	r.GET("/health", func(c *gin.Context) {
+		sites := fed.Sites()
+		status := "healthy"
+		for _, s := range sites {
+			if !s.Up {
+				status = "unhealthy"
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"status": status, "sites": sites})
+	})
+	r.GET("/livez", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok\n")
+	})
+	// Ready once every site was scraped, so the first scrape of the
+	// federation doesn't miss sites that are up
+	r.GET("/readyz", func(c *gin.Context) {
+		if pending := fed.Pending(); len(pending) > 0 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "pending": pending})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "pending": []string{}})
+	})
+
+	r.GET(cfg.TelemetryPath, gin.WrapH(promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer, promhttp.HandlerFor(fed.Gatherer(prometheus.DefaultGatherer), promhttp.HandlerOpts{}),
+	)))
+
+	server := &http.Server{Addr: cfg.ListenAddress, Handler: r}
+	go func() {
+		log.Printf("Starting server on %s", server.Addr)
+		if err := web.ListenAndServe(server, cfg.WebConfigFile); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-sigChan
+	log.Println("Received shutdown signal, shutting down gracefully...")
+	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server %s forced to shutdown: %v", server.Addr, err)
+	}
+
+	log.Println("Server exited")
+	return 0
+}
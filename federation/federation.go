@@ -0,0 +1,323 @@
+// Package federation scrapes the metrics of several site exporters and
+// serves them again with a site label, so one central instance aggregates
+// every site for the global NOC
+package federation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// siteLabel names the site a federated series comes from. A site label set
+// by the site exporter itself is kept as exported_site.
+const siteLabel = "site"
+
+// prefix is the metric name prefix of the series taken from the sites
+const prefix = "bdx_"
+
+// ownPrefix is the prefix of the metrics of the federation itself, which
+// are never taken from a site
+const ownPrefix = "bdx_federation_"
+
+var (
+	upGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_federation_up",
+		Help: "Whether the last scrape of a site exporter was successful",
+	}, []string{"site"})
+
+	lastSuccessGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_federation_last_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful scrape of a site exporter",
+	}, []string{"site"})
+
+	durationGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_federation_scrape_duration_seconds",
+		Help: "Duration of the last scrape of a site exporter",
+	}, []string{"site"})
+
+	seriesGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_federation_series",
+		Help: "Number of series taken from a site exporter by its last successful scrape",
+	}, []string{"site"})
+)
+
+// Site is the scrape state of a site exporter
+type Site struct {
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	Up          bool      `json:"up"`
+	Stale       bool      `json:"stale"`
+	LastScrape  time.Time `json:"last_scrape"`
+	LastSuccess time.Time `json:"last_success"`
+	Series      int       `json:"series"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// site is a site exporter together with its latest metrics
+type site struct {
+	Site
+	families []*dto.MetricFamily
+}
+
+// Federator scrapes the site exporters and gathers their metrics
+type Federator struct {
+	cfg    config.FederationConfig
+	client *http.Client
+
+	mu    sync.RWMutex
+	sites map[string]*site
+}
+
+// New creates a federator for the sites in the configuration. URLs without
+// a path are scraped under telemetryPath.
+func New(cfg config.FederationConfig, telemetryPath string, timeout time.Duration) (*Federator, error) {
+	f := &Federator{
+		cfg:    cfg,
+		client: &http.Client{Timeout: timeout},
+		sites:  make(map[string]*site),
+	}
+	for name, raw := range cfg.Sites {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL of site %s %q: %w", name, raw, err)
+		}
+		if strings.Trim(u.Path, "/") == "" {
+			u.Path = telemetryPath
+		}
+		f.sites[name] = &site{Site: Site{Name: name, URL: u.String()}}
+		upGauge.WithLabelValues(name).Set(0)
+		seriesGauge.WithLabelValues(name).Set(0)
+	}
+	return f, nil
+}
+
+// Run scrapes every site each interval until ctx is canceled
+func (f *Federator) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		f.scrapeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scrapeAll scrapes the sites concurrently, so a slow site doesn't delay
+// the others
+func (f *Federator) scrapeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, name := range f.siteNames() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.scrapeSite(ctx, name)
+		}()
+	}
+	wg.Wait()
+}
+
+// scrapeSite scrapes a site and records the outcome
+func (f *Federator) scrapeSite(ctx context.Context, name string) {
+	f.mu.RLock()
+	target := f.sites[name].URL
+	f.mu.RUnlock()
+
+	start := time.Now()
+	families, err := f.fetch(ctx, name, target)
+	durationGauge.WithLabelValues(name).Set(time.Since(start).Seconds())
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.sites[name]
+	s.LastScrape = start
+	s.Up = err == nil
+	if err != nil {
+		s.Error = err.Error()
+		upGauge.WithLabelValues(name).Set(0)
+		log.Printf("Failed to scrape site %s: %v", name, err)
+		return
+	}
+	s.Error = ""
+	s.LastSuccess = start
+	s.families = families
+	s.Series = 0
+	for _, mf := range families {
+		s.Series += len(mf.Metric)
+	}
+	upGauge.WithLabelValues(name).Set(1)
+	seriesGauge.WithLabelValues(name).Set(float64(s.Series))
+	lastSuccessGauge.WithLabelValues(name).Set(float64(start.Unix()))
+}
+
+// fetch scrapes the metrics of a site and adds the site label to them
+func (f *Federator) fetch(ctx context.Context, name, target string) ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	if f.cfg.Username != "" {
+		req.SetBasicAuth(f.cfg.Username, f.cfg.Password)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	parsed, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+
+	var families []*dto.MetricFamily
+	for metricName, mf := range parsed {
+		if !strings.HasPrefix(metricName, prefix) || strings.HasPrefix(metricName, ownPrefix) {
+			continue
+		}
+		for _, m := range mf.Metric {
+			m.Label = withSite(m.Label, name)
+		}
+		families = append(families, mf)
+	}
+	return families, nil
+}
+
+// withSite adds the site label to a label set, renaming a site label that
+// is already there to exported_site
+func withSite(labels []*dto.LabelPair, name string) []*dto.LabelPair {
+	for _, lp := range labels {
+		if lp.GetName() == siteLabel {
+			exported := "exported_" + siteLabel
+			lp.Name = &exported
+		}
+	}
+	labelName := siteLabel
+	labels = append(labels, &dto.LabelPair{Name: &labelName, Value: &name})
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+	return labels
+}
+
+// Gather returns the metrics of the sites that were scraped successfully
+// within the staleness period, merged by metric name. The help text of a
+// metric is taken from the first site that has it.
+func (f *Federator) Gather() ([]*dto.MetricFamily, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	merged := make(map[string]*dto.MetricFamily)
+	for _, name := range f.siteNamesLocked() {
+		s := f.sites[name]
+		if f.staleLocked(s) {
+			continue
+		}
+		for _, mf := range s.families {
+			m, ok := merged[mf.GetName()]
+			if !ok {
+				m = &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type}
+				merged[mf.GetName()] = m
+			}
+			if m.GetType() != mf.GetType() {
+				log.Printf("Skipping %s of site %s: type %s differs from %s of other sites", mf.GetName(), name, mf.GetType(), m.GetType())
+				continue
+			}
+			m.Metric = append(m.Metric, mf.Metric...)
+		}
+	}
+
+	families := make([]*dto.MetricFamily, 0, len(merged))
+	for _, mf := range merged {
+		families = append(families, mf)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].GetName() < families[j].GetName() })
+	return families, nil
+}
+
+// Gatherer combines the metrics of the sites with the local ones. The
+// local bdx_ metrics are dropped, as the federate command doesn't collect,
+// except for those of the federation and the build information.
+func (f *Federator) Gatherer(local prometheus.Gatherer) prometheus.Gatherer {
+	filtered := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := local.Gather()
+		kept := mfs[:0]
+		for _, mf := range mfs {
+			name := mf.GetName()
+			if !strings.HasPrefix(name, prefix) || strings.HasPrefix(name, ownPrefix) || strings.HasSuffix(name, "_build_info") {
+				kept = append(kept, mf)
+			}
+		}
+		return kept, err
+	})
+	return prometheus.Gatherers{filtered, f}
+}
+
+// Sites returns the scrape state of every site, ordered by name
+func (f *Federator) Sites() []Site {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	sites := make([]Site, 0, len(f.sites))
+	for _, name := range f.siteNamesLocked() {
+		s := f.sites[name].Site
+		s.Stale = f.staleLocked(f.sites[name])
+		sites = append(sites, s)
+	}
+	return sites
+}
+
+// Pending returns the sites that were not scraped yet, successfully or not
+func (f *Federator) Pending() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	pending := []string{}
+	for _, name := range f.siteNamesLocked() {
+		if f.sites[name].LastScrape.IsZero() {
+			pending = append(pending, name)
+		}
+	}
+	return pending
+}
+
+// staleLocked reports whether the series of a site are too old to serve
+func (f *Federator) staleLocked(s *site) bool {
+	return s.LastSuccess.IsZero() || time.Since(s.LastSuccess) > f.cfg.Staleness
+}
+
+// siteNames returns the names of the sites in order
+func (f *Federator) siteNames() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.siteNamesLocked()
+}
+
+func (f *Federator) siteNamesLocked() []string {
+	names := make([]string, 0, len(f.sites))
+	for name := range f.sites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
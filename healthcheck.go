@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/web"
+)
+
+// healthcheck queries the /health endpoint of the local exporter and exits
+// with 0 when it is healthy and 1 otherwise, for container health checks
+// in images without curl
+func healthcheck(args []string) int {
+	fs := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	healthURL := fs.String("url", "", "Health endpoint to query, by default /health on the admin or main listener of the local exporter")
+	maxAge := fs.Duration("max-age", 0, "Also fail when the last collection is older than this, e.g. 10m; 0 disables the check")
+	username := fs.String("username", "", "Basic authentication user, when the web configuration requires it")
+	password := fs.String("password", os.Getenv("HEALTHCHECK_PASSWORD"), "Basic authentication password (HEALTHCHECK_PASSWORD)")
+	insecure := fs.Bool("insecure-skip-verify", false, "Don't verify the TLS certificate of -url; the certificate of the local listener is never verified")
+	clientCert := fs.String("client-cert", os.Getenv("HEALTHCHECK_CLIENT_CERT"), "Client certificate presented when the web configuration requires one (HEALTHCHECK_CLIENT_CERT)")
+	clientKey := fs.String("client-key", os.Getenv("HEALTHCHECK_CLIENT_KEY"), "Key of -client-cert (HEALTHCHECK_CLIENT_KEY)")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+
+	target := *healthURL
+	if target == "" {
+		scheme := "http"
+		if cfg.WebConfigFile != "" {
+			webCfg, err := web.LoadConfig(cfg.WebConfigFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to load web config: %v\n", err)
+				return 1
+			}
+			if webCfg.TLSEnabled() {
+				scheme = "https"
+			}
+		}
+		addr := cfg.ListenAddress
+		if cfg.AdminListenAddress != "" {
+			addr = cfg.AdminListenAddress
+		}
+		target = scheme + "://" + localAddress(addr) + "/health"
+		// The certificate is issued for the public name of the exporter
+		*insecure = true
+	}
+
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid health URL: %v\n", err)
+		return 1
+	}
+	if *username != "" {
+		req.SetBasicAuth(*username, *password)
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: *insecure}
+	if *clientCert != "" || *clientKey != "" {
+		cert, err := tls.LoadX509KeyPair(*clientCert, *clientKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load client certificate: %v\n", err)
+			return 1
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	client := &http.Client{
+		Timeout:   cfg.HTTPTimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Printf("unhealthy: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Printf("unhealthy: %s returned %s\n", target, resp.Status)
+		return 1
+	}
+
+	var health struct {
+		Status      string `json:"status"`
+		LastCollect string `json:"last_collect"`
+		Leader      *bool  `json:"leader"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		fmt.Printf("unhealthy: failed to decode health status: %v\n", err)
+		return 1
+	}
+
+	// A standby doesn't collect, so only its HTTP server is checked
+	if health.Leader != nil && !*health.Leader {
+		fmt.Println("healthy: standby")
+		return 0
+	}
+	if health.Status != "healthy" {
+		fmt.Printf("unhealthy: last collection at %s failed\n", health.LastCollect)
+		return 1
+	}
+	if *maxAge > 0 {
+		lastCollect, err := time.Parse(time.RFC3339, health.LastCollect)
+		if err != nil || time.Since(lastCollect) > *maxAge {
+			fmt.Printf("unhealthy: last collection at %s is older than %s\n", health.LastCollect, *maxAge)
+			return 1
+		}
+	}
+	fmt.Printf("healthy: last collection at %s\n", health.LastCollect)
+	return 0
+}
+
+// localAddress returns the address to reach a listener from the same host,
+// replacing an empty or unspecified host by the loopback address
+func localAddress(listenAddr string) string {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return listenAddr
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
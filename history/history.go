@@ -0,0 +1,306 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+)
+
+// dayFormat names the daily history files, such as 2025-01-31.jsonl
+const dayFormat = "2006-01-02"
+
+// Store keeps a rolling history of the collected readings in a directory,
+// with one file of JSON lines per UTC day. Every file first defines a series
+// in a line of its own, and then refers to it by id in the sample lines.
+type Store struct {
+	dir       string
+	retention time.Duration
+
+	mu     sync.Mutex
+	day    string
+	file   *os.File
+	series map[string]int
+}
+
+// Series is the history of a single reading
+type Series struct {
+	Source string            `json:"source"`
+	Target string            `json:"target"`
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels"`
+	Points []Point           `json:"points"`
+}
+
+// Point is a value of a series at the time of a collection
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// record is a line of a history file, either a series definition or the
+// [id, value] samples of a collection
+type record struct {
+	Series *seriesRecord `json:"series,omitempty"`
+	Time   int64         `json:"t,omitempty"`
+	Values [][2]float64  `json:"v,omitempty"`
+}
+
+type seriesRecord struct {
+	ID     int               `json:"id"`
+	Source string            `json:"source"`
+	Target string            `json:"target"`
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels"`
+}
+
+// Open opens the history in dir, creating the directory if needed, and
+// removes the files older than the retention
+func Open(dir string, retention time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	s := &Store{dir: dir, retention: retention}
+	s.prune(time.Now())
+	return s, nil
+}
+
+// Start records the readings after every collection cycle of the collector,
+// until the context is canceled
+func Start(ctx context.Context, col *collector.Collector, s *Store) {
+	updates, unsubscribe := col.Subscribe()
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-updates:
+				values, err := col.Values(collector.ValueFilter{})
+				if err != nil {
+					log.Printf("Failed to read values for the history: %v", err)
+					continue
+				}
+				collected, _ := col.GetHealthStatus()
+				if err := s.Append(collected, values); err != nil {
+					log.Printf("Failed to write history: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Append records the values of a collection. Values that are not finite
+// numbers are skipped, JSON cannot represent them.
+func (s *Store) Append(t time.Time, values []collector.Value) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if day := t.UTC().Format(dayFormat); day != s.day {
+		if err := s.openDay(day); err != nil {
+			return err
+		}
+		s.prune(t)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	samples := record{Time: t.Unix()}
+	for _, v := range values {
+		if math.IsNaN(v.Value) || math.IsInf(v.Value, 0) {
+			continue
+		}
+		key := seriesKey(v.Source, v.Target, v.Metric, v.Labels)
+		id, ok := s.series[key]
+		if !ok {
+			id = len(s.series) + 1
+			s.series[key] = id
+			sr := &seriesRecord{ID: id, Source: v.Source, Target: v.Target, Metric: v.Metric, Labels: v.Labels}
+			if err := enc.Encode(record{Series: sr}); err != nil {
+				return err
+			}
+		}
+		samples.Values = append(samples.Values, [2]float64{float64(id), v.Value})
+	}
+	if err := enc.Encode(samples); err != nil {
+		return err
+	}
+
+	// A single write keeps lines whole for concurrent readers
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.file.Name(), err)
+	}
+	return nil
+}
+
+// openDay switches to the file of a day, loading the series it defines
+func (s *Store) openDay(day string) error {
+	if s.file != nil {
+		s.file.Close()
+		s.file, s.day = nil, ""
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.dir, day+".jsonl"), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	s.series = make(map[string]int)
+	complete, err := readFile(f, func(r record) {
+		if r.Series != nil {
+			s.series[seriesKey(r.Series.Source, r.Series.Target, r.Series.Metric, r.Series.Labels)] = r.Series.ID
+		}
+	})
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	// Terminate a line cut off by a crash so the next line is readable
+	if !complete {
+		if _, err := f.Write([]byte("\n")); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write %s: %w", f.Name(), err)
+		}
+	}
+
+	s.day, s.file = day, f
+	return nil
+}
+
+// Query returns the history of the series that match the filter and have
+// points between from and to, sorted by metric, target and labels
+func (s *Store) Query(f collector.ValueFilter, from, to time.Time) ([]Series, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if oldest := time.Now().Add(-s.retention); from.Before(oldest) {
+		from = oldest
+	}
+
+	// Series are merged by their key, as ids differ between files
+	byKey := make(map[string]*Series)
+	for day := from.UTC().Truncate(24 * time.Hour); !day.After(to); day = day.Add(24 * time.Hour) {
+		file, err := os.Open(filepath.Join(s.dir, day.Format(dayFormat)+".jsonl"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		matched := make(map[int]*Series)
+		_, err = readFile(file, func(r record) {
+			if sr := r.Series; sr != nil {
+				if !f.Matches(collector.Value{Source: sr.Source, Target: sr.Target, Metric: sr.Metric, Labels: sr.Labels}) {
+					return
+				}
+				key := seriesKey(sr.Source, sr.Target, sr.Metric, sr.Labels)
+				if byKey[key] == nil {
+					byKey[key] = &Series{Source: sr.Source, Target: sr.Target, Metric: sr.Metric, Labels: sr.Labels, Points: []Point{}}
+				}
+				matched[sr.ID] = byKey[key]
+				return
+			}
+
+			t := time.Unix(r.Time, 0)
+			if t.Before(from) || t.After(to) {
+				return
+			}
+			for _, sample := range r.Values {
+				if series, ok := matched[int(sample[0])]; ok {
+					series.Points = append(series.Points, Point{Time: t, Value: sample[1]})
+				}
+			}
+		})
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]string, 0, len(byKey))
+	for key, series := range byKey {
+		if len(series.Points) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	series := make([]Series, 0, len(keys))
+	for _, key := range keys {
+		series = append(series, *byKey[key])
+	}
+	return series, nil
+}
+
+// Close closes the file being written
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file, s.day = nil, ""
+	return err
+}
+
+// prune removes the files whose whole day is older than the retention
+func (s *Store) prune(now time.Time) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Printf("Failed to list history files: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		day, err := time.Parse(dayFormat, strings.TrimSuffix(entry.Name(), ".jsonl"))
+		if err != nil || !strings.HasSuffix(entry.Name(), ".jsonl") {
+			continue
+		}
+		if day.Add(24 * time.Hour).Before(now.Add(-s.retention)) {
+			if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+				log.Printf("Failed to remove history file: %v", err)
+			}
+		}
+	}
+}
+
+// readFile calls fn for every line of a history file and reports whether
+// the file ends with a complete line. Lines that cannot be parsed, such as
+// one cut off by a crash, are skipped.
+func readFile(f *os.File, fn func(record)) (bool, error) {
+	r := bufio.NewReader(f)
+	complete := true
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			complete = line[len(line)-1] == '\n'
+			var rec record
+			if json.Unmarshal(line, &rec) == nil {
+				fn(rec)
+			}
+		}
+		if err == io.EOF {
+			return complete, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// seriesKey identifies a series across files. JSON sorts the label names.
+func seriesKey(source, target, metric string, labels map[string]string) string {
+	data, _ := json.Marshal(labels)
+	return metric + "\x00" + target + "\x00" + source + "\x00" + string(data)
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package logging
+
+import (
+	"errors"
+	"io"
+)
+
+// openEventLog fails, as the event log only exists on Windows
+func openEventLog(source string) (io.WriteCloser, error) {
+	return nil, errors.New("the event log is only supported on Windows")
+}
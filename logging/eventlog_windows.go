@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventLogWriter writes every message to the Windows event log as an
+// information event
+type eventLogWriter struct {
+	log *eventlog.Log
+}
+
+// openEventLog opens the event log source registered when the service was
+// installed
+func openEventLog(source string) (io.WriteCloser, error) {
+	l, err := eventlog.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	return eventLogWriter{l}, nil
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	if err := w.log.Info(1, strings.TrimSuffix(string(p), "\n")); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w eventLogWriter) Close() error {
+	return w.log.Close()
+}
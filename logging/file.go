@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// backupFormat is the suffix of rotated log files, sorting by time
+const backupFormat = "20060102T150405.000"
+
+// rotatingFile is a log file that is renamed once it grows past its size
+// limit. Rotated files beyond the backup count or age limit are removed.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// openRotatingFile opens the log file at path with the rotation limits of
+// the file output
+func openRotatingFile(path string, cfg config.LoggingConfig) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    cfg.FileMaxSize,
+		maxAge:     cfg.FileMaxAge,
+		maxBackups: cfg.FileMaxBackups,
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	r.removeBackups()
+	return r, nil
+}
+
+// open opens the log file for appending
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		// The logger writes here, so report on stderr
+		if err := r.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to rotate log file %s: %v\n", r.path, err)
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the log file and opens a new one. If that fails the
+// messages keep going to the current file.
+func (r *rotatingFile) rotate() error {
+	if err := os.Rename(r.path, r.path+"."+time.Now().Format(backupFormat)); err != nil {
+		return err
+	}
+	old := r.f
+	if err := r.open(); err != nil {
+		return err
+	}
+	old.Close()
+	r.removeBackups()
+	return nil
+}
+
+// removeBackups removes the rotated files beyond the backup count and the
+// ones older than the age limit
+func (r *rotatingFile) removeBackups() {
+	dir, base := filepath.Split(r.path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	var backups []os.DirEntry
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), base+".")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(backupFormat, suffix); err == nil {
+			backups = append(backups, e)
+		}
+	}
+	// Oldest first
+	slices.SortFunc(backups, func(a, b os.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+
+	cutoff := time.Now().Add(-r.maxAge)
+	for i, e := range backups {
+		info, err := e.Info()
+		if i < len(backups)-r.maxBackups || (err == nil && info.ModTime().Before(cutoff)) {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// OpenAuditLog opens the audit log file, rotated like the log file
+func OpenAuditLog(cfg config.LoggingConfig) (io.WriteCloser, error) {
+	return openRotatingFile(cfg.AuditFile, cfg)
+}
@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+)
+
+// journaldSocket is the socket of the native journald protocol
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journald sends every message as a journal entry
+type journald struct {
+	conn net.Conn
+	tag  string
+}
+
+func dialJournald(tag string) (*journald, error) {
+	conn, err := net.Dial("unixgram", journaldSocket)
+	if err != nil {
+		return nil, err
+	}
+	return &journald{conn: conn, tag: tag}, nil
+}
+
+func (j *journald) Write(p []byte) (int, error) {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", strings.TrimSuffix(string(p), "\n"))
+	writeJournalField(&b, "PRIORITY", "6")
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", j.tag)
+	if _, err := j.conn.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (j *journald) Close() error {
+	return j.conn.Close()
+}
+
+// writeJournalField appends a field in the native journal format. Values
+// spanning several lines are prefixed with their length.
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
@@ -0,0 +1,99 @@
+// Package logging sends the log of the exporter to stderr, a rotating file,
+// syslog, journald or the Windows event log
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// Setup sends the standard logger to the configured outputs. The returned
+// function closes them.
+func Setup(cfg config.LoggingConfig) (func(), error) {
+	var writers fanout
+	var closers []io.Closer
+	closeAll := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+
+	for _, output := range cfg.Outputs {
+		switch output {
+		case config.LogStderr:
+			writers = append(writers, timestamped{os.Stderr})
+		case config.LogFile:
+			f, err := openRotatingFile(cfg.File, cfg)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("failed to open log file: %w", err)
+			}
+			writers = append(writers, timestamped{f})
+			closers = append(closers, f)
+		case config.LogSyslog:
+			w, err := dialSyslog(cfg)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+			}
+			writers = append(writers, w)
+			closers = append(closers, w)
+		case config.LogEventLog:
+			w, err := openEventLog(cfg.Tag)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("failed to open the event log: %w", err)
+			}
+			writers = append(writers, w)
+			closers = append(closers, w)
+		case config.LogJournald:
+			w, err := dialJournald(cfg.Tag)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("failed to connect to journald: %w", err)
+			}
+			writers = append(writers, w)
+			closers = append(closers, w)
+		default:
+			closeAll()
+			return nil, fmt.Errorf("unknown log output %q", output)
+		}
+	}
+
+	// syslog, journald and the event log timestamp the messages themselves
+	log.SetFlags(0)
+	log.SetOutput(writers)
+	return closeAll, nil
+}
+
+// fanout writes every message to all writers, so an unreachable syslog
+// server doesn't hold back the other outputs
+type fanout []io.Writer
+
+func (f fanout) Write(p []byte) (int, error) {
+	for _, w := range f {
+		w.Write(p)
+	}
+	return len(p), nil
+}
+
+// timestamped prefixes every message with the time, as the standard logger
+// does by default
+type timestamped struct {
+	w io.Writer
+}
+
+func (t timestamped) Write(p []byte) (int, error) {
+	line := make([]byte, 0, len(p)+20)
+	line = time.Now().AppendFormat(line, "2006/01/02 15:04:05 ")
+	line = append(line, p...)
+	if _, err := t.w.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,49 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"io"
+	"log/syslog"
+	"net/url"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// syslogFacilities maps the names of config.SyslogFacilities to their codes
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// dialSyslog connects to the configured syslog server, or to the local
+// syslog daemon
+func dialSyslog(cfg config.LoggingConfig) (io.WriteCloser, error) {
+	network, addr := "", ""
+	if cfg.SyslogAddress != "" {
+		u, err := url.Parse(cfg.SyslogAddress)
+		if err != nil {
+			return nil, err
+		}
+		network, addr = u.Scheme, u.Host
+	}
+	return syslog.Dial(network, addr, syslogFacilities[cfg.SyslogFacility]|syslog.LOG_INFO, cfg.Tag)
+}
@@ -0,0 +1,15 @@
+//go:build windows || plan9
+
+package logging
+
+import (
+	"errors"
+	"io"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// dialSyslog fails, as syslog is not supported on this platform
+func dialSyslog(cfg config.LoggingConfig) (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -2,101 +2,142 @@ package main
 
 import (
 	"context"
-	"log"
-	"net/http"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"strings"
 
-	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/prometheus/common/version"
 	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
 )
 
+const programName = "bdx_exporter"
+
+const usage = `Usage: bdx-exporter [command] [flags]
+
+Commands:
+  serve            Run the exporter (default)
+  federate         Aggregate the metrics of several site exporters
+  scrape-once      Run a single collection and print the metrics to stdout
+  validate-config  Validate the configuration and exit
+  check            Check a metric against thresholds as a Nagios plugin
+  checkmk          Run a single collection and print Checkmk local checks
+  rules            Print Prometheus alerting rules for the configured thresholds
+  login            Log in to the portal and print fresh session cookies
+  backfill         Write the sensor history of the portal to a remote_write endpoint
+  healthcheck      Query the health of the local exporter, exiting 0 or 1
+  service          Install, uninstall, start or stop the Windows service
+  version          Print version information
+
+Every setting can also be provided through environment variables or a .env
+file; flags take precedence over the environment.
+
+Run 'bdx-exporter <command> -h' for the flags of a command.
+`
+
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	if isWindowsService() {
+		os.Exit(runService())
 	}
 
-	// Create context for graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Handle shutdown signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// Create collector
-	col := collector.NewCollector(cfg)
-
-	// Initial collection
-	col.Collect()
-
-	// Start periodic collection
-	go func() {
-		ticker := time.NewTicker(cfg.ScrapeInterval)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				log.Println("Stopping periodic collection")
-				return
-			case <-ticker.C:
-				col.Collect()
-			}
-		}
-	}()
-
-	// Set up Gin router
-	r := gin.Default()
+	cmd := "serve"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
 
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		lastCollect, lastSuccess := col.GetHealthStatus()
-		status := "healthy"
-		if !lastSuccess {
-			status = "unhealthy"
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"status":        status,
-			"last_collect":  lastCollect.Format(time.RFC3339),
-			"last_success":  lastSuccess,
-		})
-	})
-
-	// Metrics endpoint
-	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
-
-	// Start server in a goroutine
-	server := &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: r,
+	switch cmd {
+	case "serve":
+		os.Exit(serve(args))
+	case "federate":
+		os.Exit(federate(args))
+	case "scrape-once":
+		os.Exit(scrapeOnce(args))
+	case "validate-config":
+		os.Exit(validateConfig(args))
+	case "check":
+		os.Exit(check(args))
+	case "checkmk":
+		os.Exit(checkmkLocal(args))
+	case "rules":
+		os.Exit(generateRules(args))
+	case "login":
+		os.Exit(login(args))
+	case "backfill":
+		os.Exit(backfill(args))
+	case "healthcheck":
+		os.Exit(healthcheck(args))
+	case "service":
+		os.Exit(serviceCommand(args))
+	case "version":
+		fmt.Println(version.Print(programName))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
 	}
+}
 
-	go func() {
-		log.Printf("Starting server on port %s", cfg.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
-		}
-	}()
+// loadConfig loads the configuration like readConfig and fails when a
+// setting doesn't parse, for the commands that don't validate it
+func loadConfig(fs *flag.FlagSet, args []string) (*config.Config, error) {
+	cfg, err := readConfig(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.Join(cfg.LoadErrors()...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readConfig loads the configuration from the environment, the configuration
+// file and the remote configuration, and then applies the command line flags
+// on top of it. The settings that don't parse are left to Validate, which
+// reports them with the other problems.
+func readConfig(fs *flag.FlagSet, args []string) (*config.Config, error) {
+	cfg := config.Load()
+
+	fs.StringVar(&cfg.ListenAddress, "web.listen-address", cfg.ListenAddress, "Address on which to expose metrics and web interface (LISTEN_ADDRESS)")
+	fs.StringVar(&cfg.MetricsListenAddress, "web.metrics-listen-address", cfg.MetricsListenAddress, "Separate address on which to expose only the metrics (METRICS_LISTEN_ADDRESS)")
+	fs.StringVar(&cfg.AdminListenAddress, "web.admin-listen-address", cfg.AdminListenAddress, "Separate address on which to expose the health and admin endpoints, e.g. 127.0.0.1:9401 (ADMIN_LISTEN_ADDRESS)")
+	fs.BoolVar(&cfg.EnablePprof, "web.enable-pprof", cfg.EnablePprof, "Serve the net/http/pprof profiling endpoints under /debug/pprof on the admin listener (ENABLE_PPROF)")
+	fs.StringVar(&cfg.TelemetryPath, "web.telemetry-path", cfg.TelemetryPath, "Path under which to expose metrics (TELEMETRY_PATH)")
+	fs.StringVar(&cfg.WebConfigFile, "web.config.file", cfg.WebConfigFile, "Path to the web configuration file enabling TLS and basic authentication (WEB_CONFIG_FILE)")
+	fs.DurationVar(&cfg.ScrapeInterval, "scrape.interval", cfg.ScrapeInterval, "Interval between collection cycles (SCRAPE_INTERVAL)")
+	fs.DurationVar(&cfg.ScrapeTimeout, "scrape.timeout", cfg.ScrapeTimeout, "Timeout for browser scraping operations (SCRAPE_TIMEOUT)")
+	fs.DurationVar(&cfg.HTTPTimeout, "http.timeout", cfg.HTTPTimeout, "Timeout for HTTP requests (HTTP_TIMEOUT)")
+	fs.StringVar(&cfg.ConfigFile, "config.file", cfg.ConfigFile, "Path to the optional YAML configuration file (CONFIG_FILE)")
+	fs.StringVar(&cfg.RemoteConfigURL, "config.remote-url", cfg.RemoteConfigURL, "Consul or etcd key holding the YAML configuration, e.g. consul://127.0.0.1:8500/bdx/config (REMOTE_CONFIG_URL)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
-	// Wait for shutdown signal
-	<-sigChan
-	log.Println("Received shutdown signal, shutting down gracefully...")
+	// The flags set on the command line are applied again after the file
+	// and remote configuration, so they take precedence over both
+	set := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = f.Value.String() })
 
-	// Cancel context to stop collection
-	cancel()
+	if cfg.ConfigFile != "" {
+		if err := cfg.LoadFile(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RemoteConfigURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPTimeout)
+		defer cancel()
+		if err := cfg.LoadRemote(ctx); err != nil {
+			return nil, err
+		}
+	}
 
-	// Shutdown server with timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer shutdownCancel()
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+	for name, value := range set {
+		if err := fs.Set(name, value); err != nil {
+			return nil, err
+		}
 	}
 
-	log.Println("Server exited")
-}
\ No newline at end of file
+	return cfg, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/sentry"
+)
+
+// requireAdminToken rejects requests that don't carry one of the configured
+// admin bearer tokens. Without configured tokens every request is rejected,
+// the admin endpoints are never open.
+func requireAdminToken(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokens := col.Config().AdminTokens
+		if len(tokens) == 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin endpoints are disabled, no admin tokens are configured"})
+			return
+		}
+
+		auth := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		if ok {
+			for _, t := range tokens {
+				if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+					c.Set(adminCallerKey, tokenFingerprint(t))
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.Header("WWW-Authenticate", `Bearer realm="bdx_exporter admin"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "a valid admin bearer token is required"})
+	}
+}
+
+// allowCIDRs rejects requests whose remote address is not in the allowlist
+// returned by list. An empty allowlist allows every address. The remote
+// address of the connection is used, X-Forwarded-For is not trusted.
+func allowCIDRs(col *collector.Collector, list func(*config.Config) []netip.Prefix) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prefixes := list(col.Config())
+		if len(prefixes) == 0 {
+			c.Next()
+			return
+		}
+
+		addr, err := netip.ParseAddr(c.RemoteIP())
+		if err == nil {
+			addr = addr.Unmap()
+			for _, prefix := range prefixes {
+				if prefix.Contains(addr) {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "client address is not allowed"})
+	}
+}
+
+// requestIDHeader carries the ID of a request from the client and back
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey is the key of the request ID in the gin context
+const requestIDKey = "request_id"
+
+// validRequestID matches the request IDs accepted from clients
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestID assigns every request an ID and returns it in X-Request-ID. The
+// ID sent by the client, e.g. by a reverse proxy, is kept if it is valid.
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = collector.NewID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// accessLog logs every request with its ID and the ID of the collection
+// cycle whose data was current when it completed
+func accessLog(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		path := c.Request.URL.Path
+		if c.Request.URL.RawQuery != "" {
+			path += "?" + c.Request.URL.RawQuery
+		}
+		log.Printf("[request %s] %s %s %s %d %dB %s cycle=%s",
+			c.GetString(requestIDKey), c.RemoteIP(), c.Request.Method, path,
+			c.Writer.Status(), max(c.Writer.Size(), 0), time.Since(start).Round(time.Microsecond), col.CycleID())
+	}
+}
+
+// recoverPanics responds with 500 to requests whose handler panicked, and
+// reports the panic when error reporting is enabled
+func recoverPanics(reporter *sentry.Client) gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, err any) {
+		reporter.CapturePanic(err, map[string]string{
+			"request_id": c.GetString(requestIDKey),
+			"method":     c.Request.Method,
+			"route":      c.FullPath(),
+		})
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	})
+}
@@ -0,0 +1,216 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// Function codes
+const (
+	readHoldingRegisters = 0x03
+	readInputRegisters   = 0x04
+)
+
+// Exception codes
+const (
+	illegalFunction    = 0x01
+	illegalDataAddress = 0x02
+	illegalDataValue   = 0x03
+)
+
+// maxReadQuantity is the most registers a single read may request
+const maxReadQuantity = 125
+
+// Server is a read-only Modbus TCP server. It answers read holding registers
+// and read input registers requests for any unit ID from the register map,
+// which is rebuilt after every collection cycle. Unmapped registers read as
+// 0, and registers whose reading is missing read as the lowest value of a
+// signed type, the highest value of an unsigned type or NaN.
+type Server struct {
+	cfg config.ModbusConfig
+	col *collector.Collector
+
+	mu        sync.RWMutex
+	registers map[uint16]uint16
+}
+
+// NewServer creates a Modbus TCP server serving the data of the collector
+func NewServer(cfg config.ModbusConfig, col *collector.Collector) *Server {
+	return &Server{cfg: cfg, col: col}
+}
+
+// ListenAndServe answers Modbus requests until the context is canceled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.ListenAddress)
+	if err != nil {
+		return err
+	}
+	log.Printf("Starting Modbus TCP server on %s", s.cfg.ListenAddress)
+
+	updates, unsubscribe := s.col.Subscribe()
+	s.update()
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				ln.Close()
+				return
+			case <-updates:
+				s.update()
+			}
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.serve(ctx, conn)
+	}
+}
+
+// update rebuilds the registers from the latest collection and the current
+// register map, which may change on reload
+func (s *Server) update() {
+	values, err := s.col.Values(collector.ValueFilter{})
+	if err != nil {
+		log.Printf("Failed to read values for Modbus: %v", err)
+		return
+	}
+
+	registers := make(map[uint16]uint16)
+	for _, r := range s.col.Config().Modbus.Registers {
+		value, found := math.NaN(), 0
+		filter := collector.ValueFilter{Metric: r.Metric, Labels: r.Labels}
+		for _, v := range values {
+			if filter.Matches(v) {
+				value = v.Value
+				found++
+			}
+		}
+		// A selector matching several readings is ambiguous
+		if found != 1 {
+			value = math.NaN()
+		}
+		for i, word := range encode(r, value) {
+			registers[uint16(r.Address+i)] = word
+		}
+	}
+
+	s.mu.Lock()
+	s.registers = registers
+	s.mu.Unlock()
+}
+
+// encode converts a reading to the register words of its type, high word
+// first
+func encode(r config.ModbusRegister, value float64) []uint16 {
+	scaled := math.Round(value * r.Scale)
+	missing := math.IsNaN(scaled)
+	switch r.Type {
+	case config.ModbusUint16:
+		if missing {
+			return []uint16{math.MaxUint16}
+		}
+		return []uint16{uint16(clamp(scaled, 0, math.MaxUint16))}
+	case config.ModbusInt32:
+		if missing {
+			scaled = math.MinInt32
+		}
+		n := uint32(int32(clamp(scaled, math.MinInt32, math.MaxInt32)))
+		return []uint16{uint16(n >> 16), uint16(n)}
+	case config.ModbusUint32:
+		if missing {
+			scaled = math.MaxUint32
+		}
+		n := uint32(clamp(scaled, 0, math.MaxUint32))
+		return []uint16{uint16(n >> 16), uint16(n)}
+	case config.ModbusFloat32:
+		n := math.Float32bits(float32(value * r.Scale))
+		return []uint16{uint16(n >> 16), uint16(n)}
+	default:
+		if missing {
+			scaled = math.MinInt16
+		}
+		return []uint16{uint16(int16(clamp(scaled, math.MinInt16, math.MaxInt16)))}
+	}
+}
+
+func clamp(f, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, f))
+}
+
+// serve answers the requests of a connection
+func (s *Server) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var header [7]byte
+	for {
+		// MBAP header: transaction ID, protocol ID, length, unit ID
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				log.Printf("Modbus connection from %s failed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		length := binary.BigEndian.Uint16(header[4:])
+		if binary.BigEndian.Uint16(header[2:]) != 0 || length < 2 || length > 254 {
+			log.Printf("Invalid Modbus frame from %s", conn.RemoteAddr())
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		resp := s.handle(pdu)
+		frame := append(header[:4:4], 0, 0, header[6])
+		binary.BigEndian.PutUint16(frame[4:], uint16(len(resp)+1))
+		if _, err := conn.Write(append(frame, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+// handle answers a request PDU
+func (s *Server) handle(pdu []byte) []byte {
+	function := pdu[0]
+	if function != readHoldingRegisters && function != readInputRegisters {
+		return []byte{function | 0x80, illegalFunction}
+	}
+	if len(pdu) != 5 {
+		return []byte{function | 0x80, illegalDataValue}
+	}
+	start := int(binary.BigEndian.Uint16(pdu[1:]))
+	quantity := int(binary.BigEndian.Uint16(pdu[3:]))
+	if quantity < 1 || quantity > maxReadQuantity {
+		return []byte{function | 0x80, illegalDataValue}
+	}
+	if start+quantity > 65536 {
+		return []byte{function | 0x80, illegalDataAddress}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	resp := []byte{function, byte(2 * quantity)}
+	for a := start; a < start+quantity; a++ {
+		resp = binary.BigEndian.AppendUint16(resp, s.registers[uint16(a)])
+	}
+	return resp
+}
@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// alertmanager sends the alarms and threshold rules as alerts to the v2 API
+// of Alertmanager. Transitions are sent right away, and the active alerts
+// are sent again after every cycle with an end time a few scrape intervals
+// ahead, so Alertmanager resolves them by itself if the exporter stops.
+type alertmanager struct {
+	cfg            config.AlertmanagerConfig
+	client         *http.Client
+	constantLabels map[string]string
+	labels         map[string]*template.Template
+	annotations    map[string]*template.Template
+	// timeout is how long an alert stays firing without being sent again
+	timeout time.Duration
+}
+
+// alert is an alert of the Alertmanager v2 API
+type alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    string            `json:"startsAt,omitempty"`
+	EndsAt      string            `json:"endsAt,omitempty"`
+}
+
+func newAlertmanager(cfg config.AlertmanagerConfig, constantLabels map[string]string, interval, timeout time.Duration) (*alertmanager, error) {
+	a := &alertmanager{
+		cfg:            cfg,
+		client:         &http.Client{Timeout: timeout},
+		constantLabels: constantLabels,
+		labels:         make(map[string]*template.Template),
+		annotations:    make(map[string]*template.Template),
+		timeout:        3 * interval,
+	}
+	for name, text := range cfg.Labels {
+		tmpl, err := config.ParseTemplate(name, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Alertmanager label template %s: %w", name, err)
+		}
+		a.labels[name] = tmpl
+	}
+	for name, text := range cfg.Annotations {
+		tmpl, err := config.ParseTemplate(name, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Alertmanager annotation template %s: %w", name, err)
+		}
+		a.annotations[name] = tmpl
+	}
+	return a, nil
+}
+
+// Name identifies the notifier
+func (a *alertmanager) Name() string {
+	return "alertmanager"
+}
+
+// Notify sends the alert of a transition
+func (a *alertmanager) Notify(ctx context.Context, n Notification) error {
+	al, err := a.alert(n)
+	if err != nil {
+		return err
+	}
+	return a.send(ctx, []alert{al})
+}
+
+// Refresh sends the active alerts again
+func (a *alertmanager) Refresh(ctx context.Context, active []Notification) error {
+	if len(active) == 0 {
+		return nil
+	}
+	alerts := make([]alert, 0, len(active))
+	for _, n := range active {
+		al, err := a.alert(n)
+		if err != nil {
+			return err
+		}
+		alerts = append(alerts, al)
+	}
+	return a.send(ctx, alerts)
+}
+
+// alert renders the alert of a notification. A firing alert ends a few
+// scrape intervals from now unless it is sent again, a resolved one ends at
+// the time it resolved.
+func (a *alertmanager) alert(n Notification) (alert, error) {
+	al := alert{Labels: make(map[string]string), Annotations: make(map[string]string)}
+	for name, value := range a.constantLabels {
+		al.Labels[name] = value
+	}
+	if err := render(a.labels, n, al.Labels); err != nil {
+		return al, fmt.Errorf("failed to render Alertmanager labels: %w", err)
+	}
+	if err := render(a.annotations, n, al.Annotations); err != nil {
+		return al, fmt.Errorf("failed to render Alertmanager annotations: %w", err)
+	}
+
+	if n.State == StateResolved {
+		al.EndsAt = n.Time.Format(time.RFC3339)
+		return al, nil
+	}
+	if !n.Time.IsZero() {
+		al.StartsAt = n.Time.Format(time.RFC3339)
+	}
+	al.EndsAt = time.Now().Add(a.timeout).Format(time.RFC3339)
+	return al, nil
+}
+
+// render executes the templates into dst, dropping empty values
+func render(templates map[string]*template.Template, n Notification, dst map[string]string) error {
+	for name, tmpl := range templates {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, n); err != nil {
+			return err
+		}
+		if value := strings.TrimSpace(buf.String()); value != "" {
+			dst[name] = value
+		} else {
+			delete(dst, name)
+		}
+	}
+	return nil
+}
+
+// send posts alerts to every Alertmanager
+func (a *alertmanager) send(ctx context.Context, alerts []alert) error {
+	data, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, u := range a.cfg.URLs {
+		if err := a.post(ctx, strings.TrimSuffix(u, "/")+"/api/v2/alerts", data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *alertmanager) post(ctx context.Context, u string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alerts to Alertmanager: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to send alerts to %s: %s: %s", req.URL.Redacted(), resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// defaultChatTemplate is the message text used without a template file
+var defaultChatTemplate = template.Must(config.ParseTemplate("chat",
+	`{{if eq .State "resolved"}}RESOLVED{{else}}{{upper .Severity}}{{end}}: {{.Summary}}`))
+
+// chat posts notifications as messages to the incoming webhooks of a chat
+// service such as Slack or Microsoft Teams
+type chat struct {
+	name     string
+	cfg      config.ChatConfig
+	client   *http.Client
+	template *template.Template
+	// payload builds the request body of a message
+	payload func(n Notification, text string) any
+
+	// mu guards the token bucket limiting the messages per minute
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newChat(name string, cfg config.ChatConfig, timeout time.Duration, payload func(Notification, string) any) (*chat, error) {
+	tmpl, err := cfg.Template()
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	if tmpl == nil {
+		tmpl = defaultChatTemplate
+	}
+	return &chat{
+		name:     name,
+		cfg:      cfg,
+		client:   &http.Client{Timeout: timeout},
+		template: tmpl,
+		payload:  payload,
+		tokens:   float64(cfg.RateLimit),
+	}, nil
+}
+
+// newSlack creates a notifier posting to Slack incoming webhooks
+func newSlack(cfg config.ChatConfig, timeout time.Duration) (*chat, error) {
+	return newChat("slack", cfg, timeout, func(n Notification, text string) any {
+		return map[string]any{
+			"text": text,
+			"attachments": []map[string]any{{
+				"color":     color(n),
+				"text":      text,
+				"mrkdwn_in": []string{"text"},
+			}},
+		}
+	})
+}
+
+// newTeams creates a notifier posting Adaptive Cards to Microsoft Teams
+// incoming webhooks, as created by the Workflows app
+func newTeams(cfg config.ChatConfig, timeout time.Duration) (*chat, error) {
+	return newChat("teams", cfg, timeout, func(n Notification, text string) any {
+		textColor := "Warning"
+		switch {
+		case n.State == StateResolved:
+			textColor = "Good"
+		case n.Severity == config.SeverityCritical:
+			textColor = "Attention"
+		}
+		return map[string]any{
+			"type": "message",
+			"attachments": []map[string]any{{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]any{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body": []map[string]any{{
+						"type":  "TextBlock",
+						"text":  text,
+						"wrap":  true,
+						"color": textColor,
+					}},
+				},
+			}},
+		}
+	})
+}
+
+// color is the Slack attachment color of a notification
+func color(n Notification) string {
+	switch {
+	case n.State == StateResolved:
+		return "good"
+	case n.Severity == config.SeverityCritical:
+		return "danger"
+	}
+	return "warning"
+}
+
+// Name identifies the notifier
+func (c *chat) Name() string {
+	return c.name
+}
+
+// Notify posts the notification to the webhook of its severity
+func (c *chat) Notify(ctx context.Context, n Notification) error {
+	u := c.cfg.URL(n.Severity)
+	if u == "" {
+		return nil
+	}
+	if !c.allow(time.Now()) {
+		return errRateLimited
+	}
+
+	var text bytes.Buffer
+	if err := c.template.Execute(&text, n); err != nil {
+		return fmt.Errorf("failed to render %s template: %w", c.name, err)
+	}
+	data, err := json.Marshal(c.payload(n, strings.TrimSpace(text.String())))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %w", c.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", c.name, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// allow takes a token from the bucket, which refills at RateLimit tokens per
+// minute up to RateLimit
+func (c *chat) allow(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	limit := float64(c.cfg.RateLimit)
+	if !c.last.IsZero() {
+		c.tokens = min(limit, c.tokens+now.Sub(c.last).Minutes()*limit)
+	}
+	c.last = now
+	if c.tokens < 1 {
+		return false
+	}
+	c.tokens--
+	return true
+}
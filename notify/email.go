@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// defaultEmailBody is the body used without a template file
+var defaultEmailBody = template.Must(config.ParseTemplate("body", `{{.Summary}}
+
+State:    {{.State}}
+Severity: {{.Severity}}
+Source:   {{.Source}}
+Target:   {{.Target}}
+{{with .Item}}Item:     {{.}}
+{{end}}{{with .Status}}Status:   {{.}}
+{{end}}{{with .Rule}}Rule:     {{.}}
+{{end}}{{with .Value}}Value:    {{.}}
+{{end}}{{if not .Time.IsZero}}Time:     {{.Time.Format "2006-01-02 15:04:05 MST"}}
+{{end}}{{with .Labels}}
+Labels:
+{{range $name, $value := .}}  {{$name}}: {{$value}}
+{{end}}{{end}}`))
+
+// email sends every notification as a plain text email
+type email struct {
+	cfg     config.EmailConfig
+	timeout time.Duration
+	subject *template.Template
+	body    *template.Template
+}
+
+func newEmail(cfg config.EmailConfig, timeout time.Duration) (*email, error) {
+	subject, err := config.ParseTemplate("subject", cfg.SubjectTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email subject template: %w", err)
+	}
+	body, err := cfg.BodyTemplate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid email body template: %w", err)
+	}
+	if body == nil {
+		body = defaultEmailBody
+	}
+	return &email{cfg: cfg, timeout: timeout, subject: subject, body: body}, nil
+}
+
+// Name identifies the notifier
+func (e *email) Name() string {
+	return "email"
+}
+
+// Notify emails the notification to SMTP_TO and the recipients of the
+// matching routes
+func (e *email) Notify(ctx context.Context, n Notification) error {
+	recipients := e.recipients(n)
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	var subject, body bytes.Buffer
+	if err := e.subject.Execute(&subject, n); err != nil {
+		return fmt.Errorf("failed to render email subject: %w", err)
+	}
+	if err := e.body.Execute(&body, n); err != nil {
+		return fmt.Errorf("failed to render email body: %w", err)
+	}
+	msg, err := e.message(recipients, strings.TrimSpace(subject.String()), body.String())
+	if err != nil {
+		return err
+	}
+	return e.send(ctx, recipients, msg)
+}
+
+// recipients returns the addresses a notification is sent to
+func (e *email) recipients(n Notification) []string {
+	recipients := slices.Clone(e.cfg.To)
+	for _, r := range e.cfg.Routes {
+		if r.Severity != "" && r.Severity != n.Severity {
+			continue
+		}
+		if !(collector.ValueFilter{Labels: r.Labels}).Matches(collector.Value{Labels: n.Labels}) {
+			continue
+		}
+		recipients = append(recipients, r.To...)
+	}
+	slices.Sort(recipients)
+	return slices.Compact(recipients)
+}
+
+// message builds the email with its headers
+func (e *email) message(recipients []string, subject, body string) ([]byte, error) {
+	from, err := mail.ParseAddress(e.cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+	id := rand.Text()
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", id, domain(from.Address))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&msg)
+	if _, err := w.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// send delivers the message over SMTP
+func (e *email) send(ctx context.Context, recipients []string, msg []byte) error {
+	host, _, _ := net.SplitHostPort(e.cfg.Address)
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if e.cfg.Security == config.SMTPTLS {
+		d := tls.Dialer{Config: &tls.Config{ServerName: host}}
+		conn, err = d.DialContext(ctx, "tcp", e.cfg.Address)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", e.cfg.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer c.Close()
+
+	if e.cfg.Security == config.SMTPStartTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if e.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(e.cfg.From)
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP server rejected sender: %w", err)
+	}
+	for _, to := range recipients {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+		if err := c.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("SMTP server rejected recipient %s: %w", addr.Address, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return c.Quit()
+}
+
+// domain returns the domain of an email address
+func domain(address string) string {
+	if _, d, ok := strings.Cut(address, "@"); ok {
+		return d
+	}
+	return "localhost"
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// incidentRetries is the number of retries of a failed request to an
+// incident management service
+const incidentRetries = 3
+
+// dedupKey identifies the incident of a notification, so the notification
+// resolving it closes the one that opened it
+func dedupKey(n Notification) string {
+	if n.Kind == KindRule {
+		return "bdx/" + n.Rule + "/" + n.Target + "/" + n.Item
+	}
+	return "bdx/" + n.Target + "/" + n.Item
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n-3], "") + "..."
+}
+
+// pagerDuty triggers and resolves PagerDuty incidents for critical
+// notifications
+type pagerDuty struct {
+	cfg    config.PagerDutyConfig
+	client *http.Client
+}
+
+func newPagerDuty(cfg config.PagerDutyConfig, timeout time.Duration) *pagerDuty {
+	return &pagerDuty{cfg: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+// Name identifies the notifier
+func (p *pagerDuty) Name() string {
+	return "pagerduty"
+}
+
+// Notify sends the notification as a PagerDuty event
+func (p *pagerDuty) Notify(ctx context.Context, n Notification) error {
+	if n.Severity != config.SeverityCritical {
+		return nil
+	}
+
+	event := map[string]any{
+		"routing_key":  p.cfg.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    dedupKey(n),
+	}
+	if n.State == StateResolved {
+		event["event_action"] = "resolve"
+	} else {
+		event["payload"] = map[string]any{
+			"summary":        truncate(n.Summary, 1024),
+			"source":         n.Target,
+			"severity":       "critical",
+			"timestamp":      n.Time.Format(time.RFC3339),
+			"component":      n.Item,
+			"group":          n.Source,
+			"class":          n.Kind,
+			"custom_details": n,
+		}
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, p.client, p.cfg.URL, nil, data, incidentRetries)
+}
+
+// opsgenie creates and closes Opsgenie alerts for critical notifications
+type opsgenie struct {
+	cfg    config.OpsgenieConfig
+	client *http.Client
+}
+
+func newOpsgenie(cfg config.OpsgenieConfig, timeout time.Duration) *opsgenie {
+	return &opsgenie{cfg: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+// Name identifies the notifier
+func (o *opsgenie) Name() string {
+	return "opsgenie"
+}
+
+// Notify creates the alert of a firing notification, or closes it when the
+// notification resolves
+func (o *opsgenie) Notify(ctx context.Context, n Notification) error {
+	if n.Severity != config.SeverityCritical {
+		return nil
+	}
+
+	base := strings.TrimSuffix(o.cfg.APIURL, "/") + "/v2/alerts"
+	header := http.Header{"Authorization": {"GenieKey " + o.cfg.APIKey}}
+	alias := truncate(dedupKey(n), 512)
+
+	if n.State == StateResolved {
+		data, err := json.Marshal(map[string]string{"source": "bdx-exporter", "note": n.Summary})
+		if err != nil {
+			return err
+		}
+		u := base + "/" + url.PathEscape(alias) + "/close?identifierType=alias"
+		return postJSON(ctx, o.client, u, header, data, incidentRetries)
+	}
+
+	details := map[string]string{
+		"kind":   n.Kind,
+		"source": n.Source,
+		"target": n.Target,
+		"item":   n.Item,
+	}
+	if n.Status != "" {
+		details["status"] = n.Status
+	}
+	if n.Rule != "" {
+		details["rule"] = n.Rule
+	}
+	for name, value := range n.Labels {
+		details["label_"+name] = value
+	}
+	alert := map[string]any{
+		"message":     truncate(n.Summary, 130),
+		"alias":       alias,
+		"description": n.Summary,
+		"priority":    "P1",
+		"source":      "bdx-exporter",
+		"entity":      n.Target,
+		"details":     details,
+		"tags":        o.cfg.Tags,
+	}
+	if len(o.cfg.Responders) > 0 {
+		responders := make([]map[string]string, len(o.cfg.Responders))
+		for i, team := range o.cfg.Responders {
+			responders[i] = map[string]string{"type": "team", "name": team}
+		}
+		alert["responders"] = responders
+	}
+
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, o.client, base, header, data, incidentRetries)
+}
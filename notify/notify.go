@@ -0,0 +1,291 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// Notification kinds
+const (
+	KindAlarm = "alarm"
+	KindRule  = "rule"
+)
+
+// Notification states
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+var (
+	notificationsSentCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bdx_notifications_sent_total",
+		Help: "Number of notifications delivered by a notifier",
+	}, []string{"notifier"})
+
+	notificationErrorsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bdx_notification_errors_total",
+		Help: "Number of notifications a notifier failed to deliver",
+	}, []string{"notifier"})
+
+	notificationsDroppedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bdx_notifications_dropped_total",
+		Help: "Number of notifications dropped by the rate limit of a notifier",
+	}, []string{"notifier"})
+)
+
+// errRateLimited is returned by notifiers dropping a notification to stay
+// within their rate limit
+var errRateLimited = errors.New("rate limit exceeded")
+
+// Notification is a CDU alarm that was raised or cleared, or a threshold
+// rule that fired or resolved for a reading
+type Notification struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	State    string    `json:"state"`
+	Severity string    `json:"severity"`
+	Source   string    `json:"source"`
+	Target   string    `json:"target"`
+	// Item is the alarm item, or the CDU item or liquid cooling type of the
+	// reading of a rule
+	Item string `json:"item"`
+	// Status is the status text of an alarm
+	Status string `json:"status,omitempty"`
+	// Rule, Metric and Value describe the reading of a rule
+	Rule   string   `json:"rule,omitempty"`
+	Metric string   `json:"metric,omitempty"`
+	Value  *float64 `json:"value,omitempty"`
+	// Labels are the labels of the reading of a rule and the labels of the
+	// CDU target from cdu_targets, such as its compartment
+	Labels  map[string]string `json:"labels,omitempty"`
+	Summary string            `json:"summary"`
+}
+
+// Notifier delivers notifications to an external system
+type Notifier interface {
+	// Name identifies the notifier in logs and metrics
+	Name() string
+	// Notify delivers a single notification
+	Notify(ctx context.Context, n Notification) error
+}
+
+// refresher is a notifier that also sends the active alarms and firing
+// threshold rules after every collection cycle
+type refresher interface {
+	Refresh(ctx context.Context, active []Notification) error
+}
+
+// New creates the notifiers enabled in the configuration
+func New(cfg *config.Config) ([]Notifier, error) {
+	var notifiers []Notifier
+	if len(cfg.Webhook.URLs) > 0 {
+		n, err := newWebhook(cfg.Webhook, cfg.HTTPTimeout)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if len(cfg.Alertmanager.URLs) > 0 {
+		n, err := newAlertmanager(cfg.Alertmanager, cfg.ConstantLabels, cfg.ScrapeInterval, cfg.HTTPTimeout)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if cfg.Email.Address != "" {
+		n, err := newEmail(cfg.Email, cfg.HTTPTimeout)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if cfg.PagerDuty.RoutingKey != "" {
+		notifiers = append(notifiers, newPagerDuty(cfg.PagerDuty, cfg.HTTPTimeout))
+	}
+	if cfg.Opsgenie.APIKey != "" {
+		notifiers = append(notifiers, newOpsgenie(cfg.Opsgenie, cfg.HTTPTimeout))
+	}
+	if cfg.Slack.Enabled() {
+		n, err := newSlack(cfg.Slack, cfg.HTTPTimeout)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if cfg.Teams.Enabled() {
+		n, err := newTeams(cfg.Teams, cfg.HTTPTimeout)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	return notifiers, nil
+}
+
+// Start sends the alarm and threshold rule transitions of every collection
+// cycle that are not muted by a silence to the notifiers, until the context
+// is canceled
+func Start(ctx context.Context, col *collector.Collector, notifiers []Notifier, silences *Silences) {
+	if len(notifiers) == 0 {
+		return
+	}
+
+	updates, unsubscribe := col.Subscribe()
+	go func() {
+		defer unsubscribe()
+		var lastAlarm, lastRule time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-updates:
+				var notifications []Notification
+				alarms := col.Events("", lastAlarm)
+				for _, e := range alarms {
+					notifications = append(notifications, fromAlarm(e))
+				}
+				if len(alarms) > 0 {
+					lastAlarm = alarms[len(alarms)-1].Time
+				}
+				rules := col.RuleEvents(lastRule)
+				for _, e := range rules {
+					notifications = append(notifications, fromRule(e))
+				}
+				if len(rules) > 0 {
+					lastRule = rules[len(rules)-1].Time
+				}
+
+				addTargetLabels(col, notifications)
+				notifications = silences.filter(notifications, time.Now())
+				for _, n := range notifications {
+					for _, notifier := range notifiers {
+						send(ctx, notifier, n)
+					}
+				}
+				refresh(ctx, col, notifiers, silences)
+			}
+		}
+	}()
+}
+
+// send delivers a notification to a single notifier and records the outcome
+func send(ctx context.Context, notifier Notifier, n Notification) {
+	err := notifier.Notify(ctx, n)
+	if errors.Is(err, errRateLimited) {
+		log.Printf("Dropped notification to %s: %s", notifier.Name(), n.Summary)
+		notificationsDroppedCounter.WithLabelValues(notifier.Name()).Inc()
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to send notification to %s: %v", notifier.Name(), err)
+		notificationErrorsCounter.WithLabelValues(notifier.Name()).Inc()
+		return
+	}
+	notificationsSentCounter.WithLabelValues(notifier.Name()).Inc()
+}
+
+// refresh sends the active alarms and firing threshold rules that are not
+// muted by a silence to the notifiers that keep them alive
+func refresh(ctx context.Context, col *collector.Collector, notifiers []Notifier, silences *Silences) {
+	var active []Notification
+	built := false
+	for _, notifier := range notifiers {
+		r, ok := notifier.(refresher)
+		if !ok {
+			continue
+		}
+		if !built {
+			for _, a := range col.ActiveAlarms() {
+				active = append(active, fromAlarm(collector.AlarmEvent{Target: a.Target, Item: a.Item, Status: a.Status, State: "raised"}))
+			}
+			for _, e := range col.FiringRules() {
+				active = append(active, fromRule(e))
+			}
+			addTargetLabels(col, active)
+			active = silences.unsilenced(active, time.Now())
+			built = true
+		}
+		if err := r.Refresh(ctx, active); err != nil {
+			log.Printf("Failed to refresh %s: %v", notifier.Name(), err)
+			notificationErrorsCounter.WithLabelValues(notifier.Name()).Inc()
+		}
+	}
+}
+
+// addTargetLabels adds the labels of their CDU target to the notifications.
+// Labels of a reading take precedence.
+func addTargetLabels(col *collector.Collector, notifications []Notification) {
+	targetLabels := make(map[string]map[string]string)
+	for _, t := range col.CDUTargets() {
+		if t.Name != "" && len(t.Labels) > 0 {
+			targetLabels[t.Name] = t.Labels
+		}
+	}
+	for i := range notifications {
+		n := &notifications[i]
+		labels := targetLabels[n.Target]
+		if len(labels) == 0 {
+			continue
+		}
+		merged := maps.Clone(labels)
+		maps.Copy(merged, n.Labels)
+		n.Labels = merged
+	}
+}
+
+// fromAlarm builds the notification of an alarm event. CDU alarms are
+// critical.
+func fromAlarm(e collector.AlarmEvent) Notification {
+	n := Notification{
+		Time:     e.Time,
+		Kind:     KindAlarm,
+		State:    StateFiring,
+		Severity: config.SeverityCritical,
+		Source:   "cdu",
+		Target:   e.Target,
+		Item:     e.Item,
+		Status:   e.Status,
+	}
+	if e.State == "cleared" {
+		n.State = StateResolved
+	}
+	n.Summary = fmt.Sprintf("CDU %s alarm %s: %s (%s)", e.Target, e.Item, e.Status, e.State)
+	return n
+}
+
+// fromRule builds the notification of a threshold rule event
+func fromRule(e collector.RuleEvent) Notification {
+	v := e.Value
+	n := Notification{
+		Time:     e.Time,
+		Kind:     KindRule,
+		State:    e.State,
+		Severity: e.Severity,
+		Source:   v.Source,
+		Target:   v.Target,
+		Item:     v.Labels["item"],
+		Rule:     e.Rule,
+		Metric:   v.Metric,
+		Labels:   v.Labels,
+		Value:    &v.Value,
+	}
+	if n.Item == "" {
+		n.Item = v.Labels["type"]
+	}
+	subject := v.Target
+	if n.Item != "" {
+		subject += " " + n.Item
+	}
+	n.Summary = fmt.Sprintf("Rule %s %s: %s %s = %g", e.Rule, e.State, v.Metric, subject, v.Value)
+	return n
+}
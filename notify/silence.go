@@ -0,0 +1,305 @@
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// Silence states
+const (
+	SilencePending = "pending"
+	SilenceActive  = "active"
+	SilenceExpired = "expired"
+)
+
+var notificationsSilencedCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "bdx_notifications_silenced_total",
+	Help: "Number of notifications muted by a silence",
+})
+
+var (
+	// ErrSilenceNotFound is returned when expiring an unknown silence
+	ErrSilenceNotFound = errors.New("silence not found")
+	// ErrFileSilence is returned when expiring a silence of the configuration
+	// file, which only ends by editing the file
+	ErrFileSilence = errors.New("silence is defined in the configuration file")
+)
+
+// SilenceStatus is a silence with its current state
+type SilenceStatus struct {
+	config.Silence
+	State string `json:"state"`
+	// Source is "file" for the silences of the configuration file and "api"
+	// for the ones created through the API
+	Source string `json:"source"`
+}
+
+// Silences mutes the notifications matching a silence, like Alertmanager
+// does. A firing notification muted by a silence is held back and sent when
+// the silence ends if it is still firing by then, and its resolution is only
+// sent if the firing notification was.
+type Silences struct {
+	col  *collector.Collector
+	path string
+
+	mu      sync.Mutex
+	created []config.Silence
+	// muted holds the firing notifications held back by a silence
+	muted map[string]Notification
+}
+
+// silence is a silence with parsed matchers
+type silence struct {
+	id       string
+	matchers []config.Matcher
+}
+
+// NewSilences creates the silence store. The silences created through the
+// API are kept in cfg.SilencesFile when it is set.
+func NewSilences(col *collector.Collector, cfg *config.Config) (*Silences, error) {
+	s := &Silences{col: col, path: cfg.SilencesFile, muted: make(map[string]Notification)}
+	if s.path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read silences file: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.created); err != nil {
+		return nil, fmt.Errorf("failed to parse silences file %s: %w", s.path, err)
+	}
+	return s, nil
+}
+
+// List returns the pending and active silences, ordered by end time
+func (s *Silences) List() []SilenceStatus {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []SilenceStatus
+	add := func(silences []config.Silence, source string) {
+		for _, sil := range silences {
+			if state := silenceState(sil, now); state != SilenceExpired {
+				list = append(list, SilenceStatus{Silence: sil, State: state, Source: source})
+			}
+		}
+	}
+	add(s.col.Config().Silences, "file")
+	add(s.created, "api")
+	slices.SortStableFunc(list, func(a, b SilenceStatus) int {
+		return a.EndsAt.Compare(b.EndsAt)
+	})
+	return list
+}
+
+// Add creates a silence starting now unless StartsAt is set, and returns it
+// with its assigned ID
+func (s *Silences) Add(sil config.Silence) (config.Silence, error) {
+	now := time.Now()
+	if sil.StartsAt.IsZero() {
+		sil.StartsAt = now
+	}
+	if err := sil.Validate(); err != nil {
+		return config.Silence{}, err
+	}
+	if !sil.EndsAt.After(now) {
+		return config.Silence{}, fmt.Errorf("ends_at must be in the future")
+	}
+	sil.ID = rand.Text()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.created = append(s.created, sil)
+	s.save(now)
+	log.Printf("Created silence %s until %s: %s", sil.ID, sil.EndsAt.Format(time.RFC3339), strings.Join(sil.Matchers, ", "))
+	return sil, nil
+}
+
+// Expire ends a silence created through the API
+func (s *Silences) Expire(id string) error {
+	for _, sil := range s.col.Config().Silences {
+		if sil.ID == id {
+			return ErrFileSilence
+		}
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.created, func(sil config.Silence) bool { return sil.ID == id })
+	if i < 0 || !s.created[i].EndsAt.After(now) {
+		return ErrSilenceNotFound
+	}
+	s.created[i].EndsAt = now
+	s.save(now)
+	log.Printf("Expired silence %s", id)
+	return nil
+}
+
+// save drops the expired silences and writes the others to the silences
+// file. The caller holds the lock.
+func (s *Silences) save(now time.Time) {
+	s.created = slices.DeleteFunc(s.created, func(sil config.Silence) bool {
+		return silenceState(sil, now) == SilenceExpired
+	})
+	if s.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.created, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode silences: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("Failed to write silences file: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("Failed to write silences file: %v", err)
+	}
+}
+
+// active returns the silences muting notifications at the given time. The
+// caller holds the lock.
+func (s *Silences) active(now time.Time) []silence {
+	var active []silence
+	for _, sil := range slices.Concat(s.col.Config().Silences, s.created) {
+		if !sil.Active(now) {
+			continue
+		}
+		matchers, err := sil.ParseMatchers()
+		if err != nil {
+			continue
+		}
+		active = append(active, silence{id: sil.ID, matchers: matchers})
+	}
+	return active
+}
+
+// filter returns the notifications to send out of a collection cycle. It
+// holds back the firing notifications muted by a silence, and the
+// resolutions of the ones held back, and releases the firing notifications
+// whose silences ended.
+func (s *Silences) filter(notifications []Notification, now time.Time) []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := s.active(now)
+	var out []Notification
+	for _, n := range notifications {
+		key := notificationKey(n)
+		if n.State == StateResolved {
+			if _, ok := s.muted[key]; ok {
+				delete(s.muted, key)
+				notificationsSilencedCounter.Inc()
+				continue
+			}
+			out = append(out, n)
+			continue
+		}
+		if id := matchSilence(active, n); id != "" {
+			log.Printf("Silenced notification by %s: %s", id, n.Summary)
+			s.muted[key] = n
+			notificationsSilencedCounter.Inc()
+			continue
+		}
+		out = append(out, n)
+	}
+
+	var released []string
+	for key, n := range s.muted {
+		if matchSilence(active, n) == "" {
+			released = append(released, key)
+		}
+	}
+	slices.Sort(released)
+	for _, key := range released {
+		out = append(out, s.muted[key])
+		delete(s.muted, key)
+	}
+	return out
+}
+
+// unsilenced drops the active alarms and firing rules muted by a silence
+func (s *Silences) unsilenced(notifications []Notification, now time.Time) []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := s.active(now)
+	if len(active) == 0 {
+		return notifications
+	}
+	return slices.DeleteFunc(notifications, func(n Notification) bool {
+		return matchSilence(active, n) != ""
+	})
+}
+
+// matchSilence returns the ID of the first silence muting the notification
+func matchSilence(silences []silence, n Notification) string {
+	if len(silences) == 0 {
+		return ""
+	}
+	labels := silenceLabels(n)
+	for _, sil := range silences {
+		if !slices.ContainsFunc(sil.matchers, func(m config.Matcher) bool { return !m.Matches(labels) }) {
+			return sil.id
+		}
+	}
+	return ""
+}
+
+// silenceLabels returns the labels silences match a notification on: the
+// labels of the notification and of its CDU target, plus name, item,
+// status, severity, source, rule and alertname as in Alertmanager
+func silenceLabels(n Notification) map[string]string {
+	labels := make(map[string]string, len(n.Labels)+7)
+	for name, value := range n.Labels {
+		labels[name] = value
+	}
+	labels["name"] = n.Target
+	labels["item"] = n.Item
+	labels["status"] = n.Status
+	labels["severity"] = n.Severity
+	labels["source"] = n.Source
+	labels["rule"] = n.Rule
+	labels["alertname"] = n.Rule
+	if n.Kind == KindAlarm {
+		labels["alertname"] = "CDUAlarm"
+	}
+	return labels
+}
+
+// notificationKey identifies the alarm or rule reading a notification is
+// about, so its resolution can be matched to the firing notification
+func notificationKey(n Notification) string {
+	return n.Kind + "/" + dedupKey(n) + "/" + n.Status
+}
+
+// silenceState returns whether a silence is pending, active or expired
+func silenceState(sil config.Silence, now time.Time) string {
+	switch {
+	case now.Before(sil.StartsAt):
+		return SilencePending
+	case now.Before(sil.EndsAt):
+		return SilenceActive
+	}
+	return SilenceExpired
+}
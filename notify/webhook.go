@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// webhookBackoff is the delay before the first retry, doubled for every
+// further retry
+const webhookBackoff = time.Second
+
+// webhook posts every notification to the configured URLs
+type webhook struct {
+	cfg      config.WebhookConfig
+	client   *http.Client
+	template *template.Template
+}
+
+func newWebhook(cfg config.WebhookConfig, timeout time.Duration) (*webhook, error) {
+	tmpl, err := cfg.Template()
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+	return &webhook{cfg: cfg, client: &http.Client{Timeout: timeout}, template: tmpl}, nil
+}
+
+// Name identifies the notifier
+func (w *webhook) Name() string {
+	return "webhook"
+}
+
+// Notify posts the notification to every URL, retrying failed requests
+func (w *webhook) Notify(ctx context.Context, n Notification) error {
+	var body bytes.Buffer
+	if w.template != nil {
+		if err := w.template.Execute(&body, n); err != nil {
+			return fmt.Errorf("failed to render webhook template: %w", err)
+		}
+	} else if err := json.NewEncoder(&body).Encode(n); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, u := range w.cfg.URLs {
+		if err := postJSON(ctx, w.client, u, nil, body.Bytes(), w.cfg.MaxRetries); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postJSON posts a JSON body to a URL, retrying network errors, rate
+// limiting and server errors up to retries times with exponential backoff
+func postJSON(ctx context.Context, client *http.Client, u string, header http.Header, body []byte, retries int) error {
+	backoff := webhookBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := tryPost(ctx, client, u, header, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// tryPost sends a single request and reports whether a failure is worth
+// retrying
+func tryPost(ctx context.Context, client *http.Client, u string, header http.Header, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("%s returned %s: %s", req.URL.Redacted(), resp.Status, strings.TrimSpace(string(data)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return false, nil
+}
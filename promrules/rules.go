@@ -0,0 +1,216 @@
+// Package promrules renders the threshold rules of the configuration, and
+// alerts on the metrics the exporter exposes, as a Prometheus rule file
+package promrules
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"go.yaml.in/yaml/v2"
+)
+
+// RuleFile is the structure of a Prometheus rule file
+type RuleFile struct {
+	Groups []Group `yaml:"groups"`
+}
+
+// Group is a group of rules evaluated together
+type Group struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is an alerting rule. Labels and annotations are ordered maps, so
+// the output is stable.
+type Rule struct {
+	Alert       string        `yaml:"alert"`
+	Expr        string        `yaml:"expr"`
+	For         string        `yaml:"for,omitempty"`
+	Labels      yaml.MapSlice `yaml:"labels,omitempty"`
+	Annotations yaml.MapSlice `yaml:"annotations,omitempty"`
+}
+
+// itemLabels maps the metrics holding readings to the label naming the item
+// of a reading, as the threshold_breach metric does
+var itemLabels = map[string]string{
+	"bdx_cdu":         "item",
+	"bdx_liquid":      "type",
+	"bdx_liquid_rack": "type",
+}
+
+// Generate returns the rule file for the configuration. job is the job
+// name Prometheus scrapes the exporter with.
+func Generate(cfg *config.Config, job string) ([]byte, error) {
+	f := RuleFile{Groups: []Group{
+		{Name: "bdx_exporter", Rules: exporterRules(cfg, job)},
+	}}
+	if len(cfg.ThresholdRules) > 0 {
+		f.Groups = append(f.Groups, Group{Name: "bdx_exporter_thresholds", Rules: thresholdRules(cfg.ThresholdRules)})
+	}
+	return yaml.Marshal(f)
+}
+
+// exporterRules alerts on the exporter itself and on the CDU alarms, named
+// like the alerts the exporter sends to Alertmanager
+func exporterRules(cfg *config.Config, job string) []Rule {
+	alarmSelector := `type="alarm"`
+	if cfg.Maintenance.Enabled() && cfg.Maintenance.Mode == config.MaintenanceTag {
+		alarmSelector += `,in_maintenance!="true"`
+	}
+
+	rules := []Rule{
+		{
+			Alert:  "BDXExporterDown",
+			Expr:   fmt.Sprintf("up{job=%s} == 0", strconv.Quote(job)),
+			For:    "5m",
+			Labels: severity(config.SeverityCritical),
+			Annotations: yaml.MapSlice{
+				{Key: "summary", Value: "BDX exporter {{ $labels.instance }} is down"},
+			},
+		},
+		{
+			Alert: "CDUAlarm",
+			Expr:  "bdx_cdu{" + alarmSelector + "} == 1",
+			Labels: yaml.MapSlice{
+				{Key: "severity", Value: config.SeverityCritical},
+				{Key: "source", Value: "cdu"},
+			},
+			Annotations: yaml.MapSlice{
+				{Key: "summary", Value: "CDU {{ $labels.name }} alarm {{ $labels.item }}: {{ $labels.status }}"},
+			},
+		},
+		{
+			// A leak has its own alert so it can be routed apart from the
+			// other alarms
+			Alert: "CDULeakDetected",
+			Expr:  "bdx_cdu_leak_detected == 1",
+			Labels: yaml.MapSlice{
+				{Key: "severity", Value: config.SeverityCritical},
+				{Key: "source", Value: "cdu"},
+			},
+			Annotations: yaml.MapSlice{
+				{Key: "summary", Value: "CDU {{ $labels.name }} detects a leak at {{ $labels.location }}"},
+			},
+		},
+		{
+			Alert:  "BDXCollectionPaused",
+			Expr:   "bdx_collection_paused == 1",
+			For:    "1h",
+			Labels: severity(config.SeverityWarning),
+			Annotations: yaml.MapSlice{
+				{Key: "summary", Value: "Collection of {{ $labels.source }} has been paused for more than an hour"},
+			},
+		},
+		{
+			Alert:  "BDXConfigReloadFailed",
+			Expr:   "bdx_config_last_reload_successful == 0",
+			Labels: severity(config.SeverityWarning),
+			Annotations: yaml.MapSlice{
+				{Key: "summary", Value: "BDX exporter {{ $labels.instance }} failed to reload its configuration"},
+			},
+		},
+		{
+			Alert:  "BDXSinkPublishFailing",
+			Expr:   "bdx_sink_last_publish_successful == 0",
+			For:    duration(3 * cfg.ScrapeInterval),
+			Labels: severity(config.SeverityWarning),
+			Annotations: yaml.MapSlice{
+				{Key: "summary", Value: "BDX exporter {{ $labels.instance }} fails to publish to {{ $labels.sink }}"},
+			},
+		},
+		{
+			Alert:  "BDXNotificationsFailing",
+			Expr:   "increase(bdx_notification_errors_total[15m]) > 0",
+			Labels: severity(config.SeverityWarning),
+			Annotations: yaml.MapSlice{
+				{Key: "summary", Value: "BDX exporter {{ $labels.instance }} fails to send notifications to {{ $labels.notifier }}"},
+			},
+		},
+	}
+
+	if cfg.DiscoveryURL != "" {
+		rules = append(rules, Rule{
+			Alert:  "BDXDiscoveryFailing",
+			Expr:   fmt.Sprintf("time() - bdx_last_discovery_success_timestamp_seconds > %g", (3 * cfg.DiscoveryInterval).Seconds()),
+			Labels: severity(config.SeverityWarning),
+			Annotations: yaml.MapSlice{
+				{Key: "summary", Value: "BDX exporter {{ $labels.instance }} has not discovered CDU targets for three intervals"},
+			},
+		})
+	}
+	return rules
+}
+
+// thresholdRules translates the threshold rules into alerting rules on the
+// metrics they select
+func thresholdRules(thresholds []config.ThresholdRule) []Rule {
+	rules := make([]Rule, 0, len(thresholds))
+	for _, t := range thresholds {
+		matchers := make(map[string]string, len(t.Labels)+1)
+		for name, value := range t.Labels {
+			matchers[name] = value
+		}
+		if t.Target != "" {
+			matchers["name"] = t.Target
+		}
+
+		names := make([]string, 0, len(matchers))
+		for name := range matchers {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		selectors := make([]string, len(names))
+		for i, name := range names {
+			selectors[i] = name + "=" + strconv.Quote(matchers[name])
+		}
+
+		expr := t.Metric
+		if len(selectors) > 0 {
+			expr += "{" + strings.Join(selectors, ",") + "}"
+		}
+		expr += fmt.Sprintf(" %s %g", t.Operator, t.Value)
+
+		source := t.Source
+		if source == "" {
+			source = collector.MetricSources[t.Metric]
+		}
+		labels := severity(t.Severity)
+		if source != "" {
+			labels = append(labels, yaml.MapItem{Key: "source", Value: source})
+		}
+
+		reading := "{{ $labels.name }}"
+		if label, ok := itemLabels[t.Metric]; ok {
+			reading += " {{ $labels." + label + " }}"
+		}
+		rules = append(rules, Rule{
+			Alert:  t.Name,
+			Expr:   expr,
+			For:    duration(t.For),
+			Labels: labels,
+			Annotations: yaml.MapSlice{
+				{Key: "summary", Value: fmt.Sprintf("Rule %s: %s = {{ $value }} (%s %g)", t.Name, reading, t.Operator, t.Value)},
+			},
+		})
+	}
+	return rules
+}
+
+func severity(s string) yaml.MapSlice {
+	return yaml.MapSlice{{Key: "severity", Value: s}}
+}
+
+// duration formats a duration as Prometheus does, or returns an empty
+// string for zero
+func duration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return model.Duration(d).String()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+)
+
+var rateLimitedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bdx_http_rate_limited_requests_total",
+	Help: "Number of HTTP requests rejected by the per-client rate limit",
+}, []string{"path"})
+
+// rateLimiter is a token bucket per client address
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limitRate rejects requests from clients that exceed RATE_LIMIT requests per
+// second, allowing bursts of RATE_LIMIT_BURST. A rate of 0 disables the limit.
+// The settings are read on every request so they follow config reloads.
+func limitRate(col *collector.Collector) gin.HandlerFunc {
+	rl := &rateLimiter{buckets: make(map[string]*bucket)}
+
+	return func(c *gin.Context) {
+		cfg := col.Config()
+		if cfg.RateLimit <= 0 {
+			c.Next()
+			return
+		}
+
+		wait := rl.take(c.RemoteIP(), cfg.RateLimit, float64(cfg.RateLimitBurst), time.Now())
+		if wait == 0 {
+			c.Next()
+			return
+		}
+
+		rateLimitedCounter.WithLabelValues(c.FullPath()).Inc()
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+	}
+}
+
+// take removes a token from the client's bucket. It returns 0 if a token was
+// available, otherwise how long until the next one is.
+func (rl *rateLimiter) take(client string, rate, burst float64, now time.Time) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// Forget clients whose bucket has been full for a while
+	if now.Sub(rl.lastSweep) > time.Minute {
+		for key, b := range rl.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*rate >= burst {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	b, ok := rl.buckets[client]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		rl.buckets[client] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
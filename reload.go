@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+var (
+	reloadSuccessGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bdx_config_last_reload_successful",
+		Help: "Whether the last configuration reload attempt was successful",
+	})
+
+	reloadTimestampGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bdx_config_last_reload_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful configuration reload",
+	})
+)
+
+// reloader re-reads the configuration from all of its sources and applies it
+// to the running exporter. SIGHUP, the /-/reload endpoint, remote config
+// changes and watched file changes all go through Reload.
+type reloader struct {
+	args    []string
+	col     *collector.Collector
+	onApply func(*config.Config)
+	// started is the configuration the exporter started with, which the
+	// settings only read at startup must keep
+	started *config.Config
+	mu      sync.Mutex
+}
+
+// newReloader creates a reloader for the serve command line args. onApply is
+// called with every configuration that was applied successfully.
+func newReloader(args []string, col *collector.Collector, onApply func(*config.Config)) *reloader {
+	reloadSuccessGauge.Set(1)
+	reloadTimestampGauge.SetToCurrentTime()
+	return &reloader{args: args, col: col, onApply: onApply, started: col.Config()}
+}
+
+// startupSettings are the settings the serve command only reads when it
+// starts: the listeners, logging, the tunnel, leader election, and the
+// sinks, notifiers and servers it builds once. Changing them takes a
+// restart.
+var startupSettings = []struct {
+	name  string
+	value func(cfg *config.Config) any
+}{
+	{"LISTEN_ADDRESS", func(cfg *config.Config) any { return cfg.ListenAddress }},
+	{"METRICS_LISTEN_ADDRESS", func(cfg *config.Config) any { return cfg.MetricsListenAddress }},
+	{"ADMIN_LISTEN_ADDRESS", func(cfg *config.Config) any { return cfg.AdminListenAddress }},
+	{"TELEMETRY_PATH", func(cfg *config.Config) any { return cfg.TelemetryPath }},
+	{"WEB_CONFIG_FILE", func(cfg *config.Config) any { return cfg.WebConfigFile }},
+	{"ENABLE_PPROF", func(cfg *config.Config) any { return cfg.EnablePprof }},
+	{"ADMIN_TOKENS", func(cfg *config.Config) any { return len(cfg.AdminTokens) > 0 }},
+	{"LOG_*", func(cfg *config.Config) any { return cfg.Logging }},
+	{"SSH_TUNNEL_*", func(cfg *config.Config) any { return cfg.Tunnel }},
+	{"LEADER_ELECTION_*", func(cfg *config.Config) any { return cfg.Election }},
+	{"SENTRY_DSN", func(cfg *config.Config) any { return [2]string{cfg.Sentry.DSN, cfg.Sentry.Environment} }},
+	{"CONFIG_WATCH_*", func(cfg *config.Config) any { return cfg.Watch }},
+	{"REMOTE_CONFIG_URL", func(cfg *config.Config) any { return [2]string{cfg.RemoteConfigURL, cfg.RemoteConfigToken} }},
+	{"HISTORY_*", func(cfg *config.Config) any { return [2]any{cfg.HistoryPath, cfg.HistoryRetention} }},
+	{"PUSHGATEWAY_*", func(cfg *config.Config) any { return cfg.Pushgateway }},
+	{"GRAPHITE_*", func(cfg *config.Config) any { return cfg.Graphite }},
+	{"STATSD_*", func(cfg *config.Config) any { return cfg.StatsD }},
+	{"KAFKA_*", func(cfg *config.Config) any { return cfg.Kafka }},
+	{"MQTT_*", func(cfg *config.Config) any { return cfg.MQTT }},
+	{"CLOUDWATCH_*", func(cfg *config.Config) any { return cfg.CloudWatch }},
+	{"DATADOG_*", func(cfg *config.Config) any { return cfg.Datadog }},
+	{"GCM_*", func(cfg *config.Config) any { return cfg.GoogleCloudMonitoring }},
+	{"POSTGRES_*", func(cfg *config.Config) any { return cfg.Postgres }},
+	{"CHECKMK_SPOOL_DIR", func(cfg *config.Config) any { return cfg.Checkmk.SpoolDir }},
+	{"WEBHOOK_*", func(cfg *config.Config) any { return cfg.Webhook }},
+	{"ALERTMANAGER_*", func(cfg *config.Config) any { return cfg.Alertmanager }},
+	{"SLACK_*", func(cfg *config.Config) any { return cfg.Slack }},
+	{"TEAMS_*", func(cfg *config.Config) any { return cfg.Teams }},
+	{"SMTP_*", func(cfg *config.Config) any { return cfg.Email }},
+	{"PAGERDUTY_*", func(cfg *config.Config) any { return cfg.PagerDuty }},
+	{"OPSGENIE_*", func(cfg *config.Config) any { return cfg.Opsgenie }},
+	{"SNMP_*", func(cfg *config.Config) any { return [2]any{cfg.SNMP, cfg.SNMPTrap} }},
+	{"MODBUS_LISTEN_ADDRESS", func(cfg *config.Config) any { return cfg.Modbus.ListenAddress }},
+}
+
+// changedStartupSettings returns the names of the startup settings that
+// differ between two configurations
+func changedStartupSettings(old, cfg *config.Config) []string {
+	var changed []string
+	for _, setting := range startupSettings {
+		if !reflect.DeepEqual(setting.value(old), setting.value(cfg)) {
+			changed = append(changed, setting.name)
+		}
+	}
+	return changed
+}
+
+// Reload loads and validates the configuration and applies it if it is valid
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := readConfig(flag.NewFlagSet("serve", flag.ContinueOnError), r.args)
+	if err == nil {
+		err = errors.Join(cfg.Validate()...)
+	}
+	if err == nil {
+		if changed := changedStartupSettings(r.started, cfg); len(changed) > 0 {
+			err = fmt.Errorf("settings that take a restart changed: %s", strings.Join(changed, ", "))
+		}
+	}
+	if err != nil {
+		reloadSuccessGauge.Set(0)
+		log.Printf("Failed to reload config: %v", err)
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+
+	r.col.ApplyConfig(cfg)
+	r.onApply(cfg)
+
+	reloadSuccessGauge.Set(1)
+	reloadTimestampGauge.SetToCurrentTime()
+	log.Println("Configuration reloaded")
+	return nil
+}
+
+// watchRemote reloads the configuration whenever the remote config key
+// changes, until the context is canceled
+func (r *reloader) watchRemote(ctx context.Context, cfg *config.Config) {
+	src, err := config.NewRemoteSource(cfg.RemoteConfigURL, cfg.RemoteConfigToken)
+	if err != nil {
+		log.Printf("Not watching remote config: %v", err)
+		return
+	}
+
+	var version uint64
+	for {
+		var next uint64
+		if version == 0 {
+			_, next, err = src.Get(ctx)
+		} else {
+			_, next, err = src.Wait(ctx, version)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to watch remote config: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Second):
+			}
+			continue
+		}
+
+		if version != 0 && next != version {
+			log.Printf("Remote config %s changed, reloading", cfg.RemoteConfigURL)
+			r.Reload()
+		}
+		version = next
+	}
+}
+
+// watchFiles reloads the configuration whenever one of the watched files
+// changes, until the context is canceled. The files are polled, which also
+// catches Kubernetes swapping the ..data symlink of a mounted ConfigMap or
+// Secret.
+func (r *reloader) watchFiles(ctx context.Context, paths []string, interval time.Duration) {
+	log.Printf("Watching %s for configuration changes", strings.Join(paths, ", "))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := fingerprint(paths)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if current := fingerprint(paths); current != last {
+			log.Printf("Configuration files changed, reloading")
+			last = current
+			r.Reload()
+		}
+	}
+}
+
+// fingerprint hashes the contents of the files, and of the files in the
+// directories, leaving out the hidden entries Kubernetes keeps its versions
+// in. Missing or unreadable files are part of the hash too.
+func fingerprint(paths []string) string {
+	h := sha256.New()
+	var add func(path string, dir bool)
+	add = func(path string, dir bool) {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			fmt.Fprintf(h, "%s: %v\n", path, err)
+		case info.IsDir() && dir:
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				fmt.Fprintf(h, "%s: %v\n", path, err)
+				return
+			}
+			for _, entry := range entries {
+				if !strings.HasPrefix(entry.Name(), "..") {
+					add(filepath.Join(path, entry.Name()), false)
+				}
+			}
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintf(h, "%s: %v\n", path, err)
+				return
+			}
+			fmt.Fprintf(h, "%s: %d\n", path, len(data))
+			h.Write(data)
+		}
+	}
+	for _, path := range paths {
+		add(path, true)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// rowClassRE matches the classes of the <tr> a row of the alarm table
+	// starts with. A row without attributes starts with its first cell,
+	// whose classes must not match.
+	rowClassRE = regexp.MustCompile(`(?i)^[^<>]*\bclass\s*=\s*["']([^"']*)["']`)
+	// titleRE matches the title attributes of the elements of a row, such as
+	// the one of the detail popup
+	titleRE = regexp.MustCompile(`(?i)\btitle\s*=\s*["']([^"']*)["']`)
+	// acknowledgedRE matches acknowledged as a whole word, with the negation
+	// before it if any. "Unacknowledged" doesn't match.
+	acknowledgedRE = regexp.MustCompile(`(?i)\b(not\s+)?acknowledged\b`)
+)
+
+// acknowledgedClasses are the classes of an acknowledged alarm row
+var acknowledgedClasses = []string{"acknowledged", "ack", "acked", "alarm-ack", "alarm-acknowledged"}
+
+// alarmAcknowledged reports whether a row of the alarm table of a CDU
+// dashboard is acknowledged. The acknowledgment column follows the status;
+// without one, an acknowledged row is told apart by a class of its <tr>,
+// such as <tr class="alarm-ack">, or by a title of one of its elements, such
+// as the detail popup, saying acknowledged as a whole word and not "not
+// acknowledged". The text of the cells is not used, as it may quote the
+// status, such as "Unacknowledged alarm".
+func alarmAcknowledged(row string, cells []string) bool {
+	for _, cell := range cells[min(3, len(cells)):] {
+		text := strings.ToLower(extractText(cell))
+		switch {
+		case strings.HasPrefix(text, "unack"), strings.HasPrefix(text, "not ack"), text == "no":
+			return false
+		case strings.HasPrefix(text, "ack"), text == "yes":
+			return true
+		}
+	}
+
+	if m := rowClassRE.FindStringSubmatch(row); m != nil {
+		for _, class := range strings.Fields(strings.ToLower(m[1])) {
+			for _, ack := range acknowledgedClasses {
+				if class == ack {
+					return true
+				}
+			}
+		}
+	}
+	for _, title := range titleRE.FindAllStringSubmatch(row, -1) {
+		for _, m := range acknowledgedRE.FindAllStringSubmatch(title[1], -1) {
+			if m[1] == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
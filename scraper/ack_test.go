@@ -0,0 +1,40 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlarmAcknowledged(t *testing.T) {
+	tests := []struct {
+		name string
+		row  string
+		want bool
+	}{
+		{"column acknowledged", `<tr><td class="td-detail">Leak</td><td>Alarm</td><td>Acknowledged</td></tr>`, true},
+		{"column yes", `<tr><td class="td-detail">Leak</td><td>Alarm</td><td>Yes</td></tr>`, true},
+		{"column unacknowledged", `<tr class="alarm-ack"><td class="td-detail">Leak</td><td>Alarm</td><td>Unacknowledged</td></tr>`, false},
+		{"column not acknowledged", `<tr><td class="td-detail">Leak</td><td>Alarm</td><td>Not acknowledged</td></tr>`, false},
+		{"column no", `<tr><td class="td-detail">Leak</td><td>Alarm</td><td>No</td></tr>`, false},
+		{"class", `<tr class="row alarm-ack"><td class="td-detail">Leak</td><td>Alarm</td></tr>`, true},
+		{"class acked", `<tr class="Acked"><td class="td-detail">Leak</td><td>Alarm</td></tr>`, true},
+		{"class unacked", `<tr class="alarm-unacked"><td class="td-detail">Leak</td><td>Alarm</td></tr>`, false},
+		{"class on a cell", `<tr><td class="td-detail ack">Leak</td><td>Alarm</td></tr>`, false},
+		{"title", `<tr><td class="td-detail"><a title="Acknowledged by operator">Leak</a></td><td>Alarm</td></tr>`, true},
+		{"title unacknowledged", `<tr><td class="td-detail"><a title="Unacknowledged">Leak</a></td><td>Alarm</td></tr>`, false},
+		{"title not acknowledged", `<tr><td class="td-detail"><a title="Alarm not acknowledged">Leak</a></td><td>Alarm</td></tr>`, false},
+		{"title both", `<tr><td class="td-detail"><a title="Not acknowledged, then acknowledged by operator">Leak</a></td><td>Alarm</td></tr>`, true},
+		{"cell text only", `<tr><td class="td-detail">Acknowledged leak</td><td>Alarm</td></tr>`, false},
+		{"plain", `<tr><td class="td-detail">Leak</td><td>Alarm</td></tr>`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Split the row the way the alarm table is
+			row := tableRowRE.Split(tt.row, -1)[1]
+			cells := strings.Split(row, "<td")
+			if got := alarmAcknowledged(row, cells); got != tt.want {
+				t.Errorf("alarmAcknowledged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
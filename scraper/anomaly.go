@@ -0,0 +1,64 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+)
+
+// Anomaly is a page the parser found no data in, e.g. after a change of the
+// markup of the portal or when the session expired
+type Anomaly struct {
+	URL string
+	// Page is "cdu" or "liquid"
+	Page   string
+	Reason string
+	// MarkupHash is a hash of the markup of the page with the text between
+	// the tags removed, so pages of the same layout share it whatever their
+	// readings are
+	MarkupHash string
+	Size       int
+}
+
+// ReportAnomaly is called with every anomaly found, when it is set
+var ReportAnomaly func(Anomaly)
+
+// pageText matches the text between tags
+var pageText = regexp.MustCompile(`>[^<]+<`)
+
+// reportAnomaly passes an anomaly of a page to ReportAnomaly
+func reportAnomaly(url, page, reason, html string) {
+	if ReportAnomaly == nil {
+		return
+	}
+	sum := sha256.Sum256([]byte(pageText.ReplaceAllString(html, "><")))
+	ReportAnomaly(Anomaly{
+		URL:        url,
+		Page:       page,
+		Reason:     reason,
+		MarkupHash: hex.EncodeToString(sum[:8]),
+		Size:       len(html),
+	})
+}
+
+// checkCDUPage reports a CDU page without a name or parameters
+func checkCDUPage(url, html, name string, params []CDUParameter) {
+	switch {
+	case name == "":
+		reportAnomaly(url, "cdu", "CDU name not found", html)
+	case len(params) == 0:
+		reportAnomaly(url, "cdu", "no parameters found", html)
+	}
+}
+
+// checkLiquidPage reports a liquid cooling page without CDUs or racks, or
+// with rack rows not aligned with their rack columns
+func checkLiquidPage(url, html string, cdus []LiquidCDU, racks []LiquidRack, stats PageStats) {
+	switch {
+	case len(cdus) == 0 && len(racks) == 0:
+		reportAnomaly(url, "liquid", "no CDUs or racks found", html)
+	case stats.MisalignedRows > 0:
+		reportAnomaly(url, "liquid", fmt.Sprintf("%d rack rows not aligned with the rack columns", stats.MisalignedRows), html)
+	}
+}
@@ -0,0 +1,125 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// browserContexts counts the browser contexts of the scrapes in progress
+var browserContexts atomic.Int64
+
+// BrowserContexts returns the number of browser contexts currently open
+func BrowserContexts() int64 {
+	return browserContexts.Load()
+}
+
+// trackBrowser counts a browser context until the returned function is
+// called
+func trackBrowser() func() {
+	browserContexts.Add(1)
+	return func() { browserContexts.Add(-1) }
+}
+
+// scrapeCtx is the parent context of every browser, canceled to abort the
+// scrapes in progress on shutdown
+var scrapeCtx, abortScrapes = context.WithCancel(context.Background())
+
+// AbortScrapes cancels the scrapes in progress, which closes their browsers,
+// and makes later scrapes fail right away
+func AbortScrapes() {
+	abortScrapes()
+}
+
+// Browser configures how the headless browser connects to the portal and
+// reads its pages
+type Browser struct {
+	// Proxy is an http, https or socks5 URL, empty to connect directly.
+	// Chrome can't authenticate to the proxy, so credentials in the URL are
+	// dropped.
+	Proxy string
+	// TrustedSPKIs are the base64 encoded SHA-256 hashes of the public keys
+	// whose certificates are accepted when they are part of the chain the
+	// server sends
+	TrustedSPKIs []string
+	// MinTLSVersion is tls1, tls1.1, tls1.2 or tls1.3, empty for the Chrome
+	// default
+	MinTLSVersion      string
+	InsecureSkipVerify bool
+	// HostResolverRules map host names to addresses, such as
+	// "MAP portal.example.com 10.0.0.5"
+	HostResolverRules []string
+	// UserAgent replaces the one of headless Chrome when set
+	UserAgent string
+	// Headers are sent with every request of the page
+	Headers map[string]string
+	// LoginURL is the login page of the portal, a page redirected there
+	// fails with ErrSessionExpired
+	LoginURL string
+	// MaxPageSize limits the characters of HTML read from a page, 0 for no
+	// limit. A larger page fails with ErrPageTooLarge unless TruncatePage is
+	// set.
+	MaxPageSize  int64
+	TruncatePage bool
+	// ScrollPasses is the most times the liquid cooling overview is
+	// scrolled through to render its lazily loaded rows, 0 to not scroll
+	ScrollPasses int
+}
+
+// allocatorOptions returns the options of the headless browser
+func allocatorOptions(browser Browser) []chromedp.ExecAllocatorOption {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", true),
+		chromedp.Flag("disable-gpu", true),
+		chromedp.Flag("no-sandbox", true),
+	)
+
+	if browser.Proxy == "" {
+		opts = append(opts, chromedp.Flag("no-proxy-server", true))
+	} else {
+		proxy := browser.Proxy
+		if u, err := url.Parse(proxy); err == nil {
+			u.User = nil
+			proxy = u.String()
+		}
+		opts = append(opts, chromedp.ProxyServer(proxy))
+	}
+
+	if browser.InsecureSkipVerify {
+		opts = append(opts, chromedp.Flag("ignore-certificate-errors", true))
+	} else if len(browser.TrustedSPKIs) > 0 {
+		opts = append(opts, chromedp.Flag("ignore-certificate-errors-spki-list", strings.Join(browser.TrustedSPKIs, ",")))
+	}
+	if browser.UserAgent != "" {
+		opts = append(opts, chromedp.UserAgent(browser.UserAgent))
+	}
+	if len(browser.HostResolverRules) > 0 {
+		opts = append(opts, chromedp.Flag("host-resolver-rules", strings.Join(browser.HostResolverRules, ", ")))
+	}
+	if browser.MinTLSVersion != "" {
+		opts = append(opts, chromedp.Flag("ssl-version-min", browser.MinTLSVersion))
+	}
+	return opts
+}
+
+// setHeaders makes the page send the extra headers of browser with every
+// request
+func setHeaders(browser Browser) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if len(browser.Headers) == 0 {
+			return nil
+		}
+		headers := make(network.Headers, len(browser.Headers))
+		for name, value := range browser.Headers {
+			headers[name] = value
+		}
+		if err := network.Enable().Do(ctx); err != nil {
+			return err
+		}
+		return network.SetExtraHTTPHeaders(headers).Do(ctx)
+	})
+}
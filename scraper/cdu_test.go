@@ -0,0 +1,96 @@
+package scraper
+
+import (
+	"reflect"
+	"testing"
+)
+
+// cduPage is a CDU dashboard as the portal renders it, with a setpoint
+// column in the parameter table
+const cduPage = `<div class="card"><h5 class="card-title mb-0">CDU-01</h5></div>
+<h6>ALARM</h6>
+<table><thead><tr><th>Item</th><th>Status</th></tr></thead><tbody>
+	<tr><td class="td-detail">Leak Detection Tray</td><td>Alarm</td></tr>
+	<tr><td class="td-detail">Leak Detection Floor</td><td>Normal</td></tr>
+	<tr><td class="td-detail">Pump-1 Fault</td><td>Normal</td></tr>
+</tbody></table>
+<h6>PARAMETER</h6>
+<table><thead><tr><th>Item</th><th>Value</th><th>Unit</th><th>Setpoint</th></tr></thead><tbody>
+	<tr><td class="td-detail">Supply Temp</td><td>18.5</td><td>°C</td><td>18</td></tr>
+	<tr><td class="td-detail">Pump 1 Speed</td><td>45</td><td>%</td><td>-</td></tr>
+	<tr><td class="td-detail">Filter Diff Press</td><td>35</td><td>kPa</td><td></td></tr>
+	<tr><td class="td-detail">Conductivity</td><td>n/a</td><td>µS/cm</td><td></td></tr>
+</tbody></table>`
+
+func TestParseCDUHTML(t *testing.T) {
+	var stats PageStats
+	name, alarms, params := parseCDUHTML(cduPage, &stats)
+	if name != "CDU_01" {
+		t.Errorf("name = %q, want CDU_01", name)
+	}
+
+	wantAlarms := []CDUAlarm{
+		{Item: "Leak_Detection_Tray", Status: "alarm"},
+		{Item: "Leak_Detection_Floor", Status: "normal"},
+		{Item: "Pump_1_Fault", Status: "normal"},
+	}
+	if !reflect.DeepEqual(alarms, wantAlarms) {
+		t.Errorf("alarms = %+v, want %+v", alarms, wantAlarms)
+	}
+
+	setpoint := 18.0
+	wantParams := []CDUParameter{
+		{Item: "Supply_Temp", Value: 18.5, Unit: "°C", Setpoint: &setpoint},
+		{Item: "Pump_1_Speed", Value: 45, Unit: "%"},
+		{Item: "Filter_Diff_Press", Value: 35, Unit: "kPa"},
+	}
+	if !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("params = %+v, want %+v", params, wantParams)
+	}
+	if stats.Rows != 6 || stats.SkippedRows != 1 {
+		t.Errorf("rows = %d, skipped = %d, want 6 and 1", stats.Rows, stats.SkippedRows)
+	}
+}
+
+func TestParseTabTables(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want []CDUParameter
+	}{
+		{
+			name: "item value unit",
+			html: `<table><tr><th>Item</th><th>Value</th><th>Unit</th></tr>
+				<tr><td>Valve Position</td><td>62.5</td><td>%</td></tr>
+				<tr><td>Conductivity</td><td>2.1 µS/cm</td><td></td></tr>
+				<tr><td>Leak Sensor Floor</td><td>No</td><td></td></tr>
+			</table>`,
+			want: []CDUParameter{
+				{Item: "Valve_Position", Value: 62.5, Unit: "%", Tab: "Pumps"},
+				{Item: "Conductivity", Value: 2.1, Unit: "µS/cm", Tab: "Pumps"},
+				{Item: "Leak_Sensor_Floor", Value: 0, Unit: "", Tab: "Pumps"},
+			},
+		},
+		{
+			name: "grid",
+			html: `<table><tr><th>Pump</th><th>Speed (rpm)</th><th>State</th></tr>
+				<tr><td>Pump 1</td><td>2900</td><td>Running</td></tr>
+				<tr><td>Pump 2</td><td>45 %</td><td>Stopped</td></tr>
+			</table>`,
+			want: []CDUParameter{
+				{Item: "Pump_1_Speed", Value: 2900, Unit: "rpm", Tab: "Pumps"},
+				{Item: "Pump_1_State", Value: 1, Unit: "", Tab: "Pumps"},
+				{Item: "Pump_2_Speed", Value: 45, Unit: "%", Tab: "Pumps"},
+				{Item: "Pump_2_State", Value: 0, Unit: "", Tab: "Pumps"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseTabTables(tt.html, "Pumps", &PageStats{})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTabTables() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// cduLinkSelector matches the CDU dashboard links of a page
+const cduLinkSelector = `a[href*="cdu_dashboard.php"]`
+
+// ScrapeCDULinks renders a portal page in the browser, like the liquid
+// cooling overview is scraped, and returns the CDU dashboard links of the
+// rendered page, so links added by its scripts are found too. It waits for
+// the first link, then as long as the other pages wait for their tables to
+// be filled, and scrolls through the page to render its lazily loaded rows.
+func ScrapeCDULinks(url string, browser Browser, sessMap, phpSessID string, timeout time.Duration) ([]string, PageStats, error) {
+	ctx, cancel := context.WithTimeout(scrapeCtx, timeout)
+	defer cancel()
+
+	defer trackBrowser()()
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, allocatorOptions(browser)...)
+	defer cancelAlloc()
+
+	taskCtx, cancelTask := chromedp.NewContext(allocCtx)
+	defer cancelTask()
+
+	cookies := []*network.CookieParam{
+		{Name: "sess_map", Value: sessMap, Domain: "app.managed360view.com", Path: "/"},
+		{Name: "PHPSESSID", Value: phpSessID, Domain: "app.managed360view.com", Path: "/"},
+	}
+	if err := chromedp.Run(taskCtx, setHeaders(browser), network.SetCookies(cookies)); err != nil {
+		return nil, PageStats{}, fmt.Errorf("failed to set cookies: %w", err)
+	}
+
+	var pageHTML string
+	var truncated bool
+	err := chromedp.Run(taskCtx,
+		chromedp.Navigate(url),
+		// Links of a collapsed menu are hidden, so wait for them to exist
+		// rather than to be visible
+		chromedp.WaitReady(cduLinkSelector+`, `+loginFormSelector, chromedp.ByQuery),
+		checkLogin(browser),
+		chromedp.Sleep(2*time.Second),
+		scrollToLoad(browser),
+		readHTML(browser, &pageHTML, &truncated),
+	)
+	if err != nil {
+		return nil, PageStats{}, fmt.Errorf("failed to scrape: %w", err)
+	}
+
+	stats := newPageStats(pageHTML)
+	stats.Truncated = truncated
+	return ParseCDULinks(pageHTML, url), stats, nil
+}
+
+var cduLinkRegex = regexp.MustCompile(`href=["']([^"']*cdu_dashboard\.php\?[^"']*cabinetid=\d+[^"']*)["']`)
+
+// ParseCDULinks extracts the CDU dashboard links from a portal page, resolved
+// against baseURL and deduplicated, in the order they appear
+func ParseCDULinks(pageHTML, baseURL string) []string {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	seen := make(map[string]bool)
+	for _, match := range cduLinkRegex.FindAllStringSubmatch(pageHTML, -1) {
+		ref, err := url.Parse(html.UnescapeString(match[1]))
+		if err != nil {
+			continue
+		}
+		link := base.ResolveReference(ref).String()
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
@@ -0,0 +1,209 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// AlarmHistoryEntry is a row of the alarm history tab of a CDU dashboard
+type AlarmHistoryEntry struct {
+	Time   time.Time
+	Item   string
+	Status string
+	// State is raised or cleared, empty when the history doesn't tell
+	State string
+}
+
+// AlarmHistoryOptions selects how much of the alarm history is read
+type AlarmHistoryOptions struct {
+	// MaxPages limits the pages of the history clicked through
+	MaxPages int
+	// Since stops at the first page with an entry before it, as the history
+	// lists the newest entries first. Entries before it are left out.
+	Since time.Time
+	// Location is the time zone of the times of the history
+	Location *time.Location
+}
+
+// JavaScript run in the page to find the history tab, its table and the
+// next page link, which the dashboard renders in different ways
+const (
+	historyTabJS = `(() => {
+		const tab = [...document.querySelectorAll('a, button, li')].find(e =>
+			/history/i.test(e.getAttribute('href') || '') ||
+			/history/i.test(e.getAttribute('data-target') || e.getAttribute('data-bs-target') || '') ||
+			/^\s*(alarm\s+)?history\s*$/i.test(e.textContent));
+		if (!tab) return false;
+		tab.click();
+		return true;
+	})()`
+	historyTableJS = `(() => {
+		const pane = document.querySelector('[id*="history" i].active, [id*="history" i].show, .tab-pane.active[id*="history" i]') ||
+			document.querySelector('[id*="history" i]');
+		const table = pane && pane.querySelector('table');
+		return table ? table.outerHTML : "";
+	})()`
+	historyNextJS = `(() => {
+		const pane = document.querySelector('[id*="history" i]') || document;
+		const next = [...pane.querySelectorAll('.pagination a, .pagination button, .paginate_button, a[rel="next"]')].find(e =>
+			/^\s*(next|›|»|>)\s*$/i.test(e.textContent) || e.classList.contains('next') || e.getAttribute('rel') === 'next');
+		if (!next || next.classList.contains('disabled') || next.closest('.disabled') || next.getAttribute('aria-disabled') === 'true') return false;
+		next.click();
+		return true;
+	})()`
+)
+
+// historyTimeFormats are the formats of the times of the alarm history
+var historyTimeFormats = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02-01-2006 15:04:05",
+	time.RFC3339,
+}
+
+// ScrapeCDUAlarmHistory reads the alarm history tab of a CDU dashboard,
+// clicking through its pages, and returns its entries newest first
+func ScrapeCDUAlarmHistory(url string, browser Browser, sessMap, phpSessID string, timeout time.Duration, opts AlarmHistoryOptions) ([]AlarmHistoryEntry, error) {
+	ctx, cancel := context.WithTimeout(scrapeCtx, timeout)
+	defer cancel()
+
+	defer trackBrowser()()
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, allocatorOptions(browser)...)
+	defer cancelAlloc()
+
+	taskCtx, cancelTask := chromedp.NewContext(allocCtx)
+	defer cancelTask()
+
+	cookies := []*network.CookieParam{
+		{Name: "sess_map", Value: sessMap, Domain: "app.managed360view.com", Path: "/"},
+		{Name: "PHPSESSID", Value: phpSessID, Domain: "app.managed360view.com", Path: "/"},
+	}
+	if err := chromedp.Run(taskCtx, setHeaders(browser), network.SetCookies(cookies)); err != nil {
+		return nil, fmt.Errorf("failed to set cookies: %w", err)
+	}
+
+	var found bool
+	err := chromedp.Run(taskCtx,
+		chromedp.Navigate(url),
+		waitForTables(browser),
+		chromedp.Evaluate(historyTabJS, &found),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the alarm history: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("no alarm history tab on the dashboard")
+	}
+
+	loc := opts.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	var entries []AlarmHistoryEntry
+	previous := ""
+	for page := 0; page < opts.MaxPages; page++ {
+		table, err := waitForHistoryTable(taskCtx, previous)
+		if err != nil {
+			return entries, fmt.Errorf("failed to read page %d of the alarm history: %w", page+1, err)
+		}
+		previous = table
+
+		older := false
+		for _, entry := range parseAlarmHistory(table, loc) {
+			if entry.Time.Before(opts.Since) {
+				older = true
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		if older {
+			break
+		}
+
+		var next bool
+		if err := chromedp.Run(taskCtx, chromedp.Evaluate(historyNextJS, &next)); err != nil {
+			return entries, fmt.Errorf("failed to open page %d of the alarm history: %w", page+2, err)
+		}
+		if !next {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// waitForHistoryTable waits until the history tab shows a table other than
+// previous, the table of the page before, and returns its HTML
+func waitForHistoryTable(ctx context.Context, previous string) (string, error) {
+	for {
+		var table string
+		if err := chromedp.Run(ctx, chromedp.Evaluate(historyTableJS, &table)); err != nil {
+			return "", err
+		}
+		if table != "" && table != previous {
+			return table, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// parseAlarmHistory parses the rows of a page of the alarm history: the
+// time, the item, the status and optionally whether it was raised or
+// cleared. Rows that can't be parsed are skipped.
+func parseAlarmHistory(table string, loc *time.Location) []AlarmHistoryEntry {
+	var entries []AlarmHistoryEntry
+	for _, row := range tableRowRE.Split(table, -1) {
+		cells := strings.Split(row, "<td")
+		if len(cells) < 4 {
+			continue
+		}
+		t, ok := parseHistoryTime(extractText(cells[1]), loc)
+		if !ok {
+			continue
+		}
+		entry := AlarmHistoryEntry{
+			Time:   t,
+			Item:   normalizeItem(extractText(cells[2])),
+			Status: strings.ToLower(extractText(cells[3])),
+		}
+		if len(cells) > 4 {
+			entry.State = historyState(extractText(cells[4]))
+		}
+		if entry.Item != "" && entry.Status != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// parseHistoryTime parses a time of the alarm history in loc
+func parseHistoryTime(s string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range historyTimeFormats {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// historyState maps the event column of the alarm history to raised or
+// cleared
+func historyState(s string) string {
+	switch s = strings.ToLower(s); {
+	case strings.Contains(s, "clear"), strings.Contains(s, "recover"), strings.Contains(s, "normal"), s == "off":
+		return "cleared"
+	case strings.Contains(s, "raise"), strings.Contains(s, "active"), strings.Contains(s, "occur"), s == "on":
+		return "raised"
+	}
+	return ""
+}
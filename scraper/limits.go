@@ -0,0 +1,37 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ErrPageTooLarge is returned when the HTML of a page exceeds the
+// MaxPageSize of the browser and truncating is not allowed
+var ErrPageTooLarge = errors.New("page too large")
+
+// readHTML reads the HTML of the page into html. The size is checked in the
+// browser first, so an oversized page is never transferred whole: it fails
+// with ErrPageTooLarge, or only its first MaxPageSize characters are read
+// when TruncatePage is set, which sets truncated.
+func readHTML(browser Browser, html *string, truncated *bool) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if browser.MaxPageSize <= 0 {
+			return chromedp.OuterHTML("html", html).Do(ctx)
+		}
+		var length int64
+		if err := chromedp.Evaluate(`document.documentElement.outerHTML.length`, &length).Do(ctx); err != nil {
+			return err
+		}
+		if length <= browser.MaxPageSize {
+			return chromedp.OuterHTML("html", html).Do(ctx)
+		}
+		if !browser.TruncatePage {
+			return fmt.Errorf("%w: %d characters, the limit is %d", ErrPageTooLarge, length, browser.MaxPageSize)
+		}
+		*truncated = true
+		return chromedp.Evaluate(fmt.Sprintf(`document.documentElement.outerHTML.substring(0, %d)`, browser.MaxPageSize), html).Do(ctx)
+	})
+}
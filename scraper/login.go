@@ -0,0 +1,64 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Login signs in to the portal with the given credentials and returns the
+// resulting sess_map and PHPSESSID cookie values
+func Login(loginURL string, browser Browser, username, password string, timeout time.Duration) (string, string, error) {
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(scrapeCtx, timeout)
+	defer cancel()
+
+	// Create chromedp context
+	opts := allocatorOptions(browser)
+
+	defer trackBrowser()()
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
+	defer cancelAlloc()
+
+	taskCtx, cancelTask := chromedp.NewContext(allocCtx)
+	defer cancelTask()
+
+	var cookies []*network.Cookie
+
+	// Fill in and submit the login form, then read back the session cookies
+	err := chromedp.Run(taskCtx,
+		setHeaders(browser),
+		chromedp.Navigate(loginURL),
+		chromedp.WaitVisible(`input[type="password"]`, chromedp.ByQuery),
+		chromedp.SendKeys(`input[name="username"]`, username, chromedp.ByQuery),
+		chromedp.SendKeys(`input[type="password"]`, password, chromedp.ByQuery),
+		chromedp.Submit(`input[type="password"]`, chromedp.ByQuery),
+		chromedp.Sleep(2*time.Second), // Wait for the post-login redirect
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			cookies, err = network.GetCookies().WithURLs([]string{loginURL}).Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to log in: %v", err)
+	}
+
+	var sessMap, phpSessID string
+	for _, cookie := range cookies {
+		switch cookie.Name {
+		case "sess_map":
+			sessMap = cookie.Value
+		case "PHPSESSID":
+			phpSessID = cookie.Value
+		}
+	}
+	if sessMap == "" || phpSessID == "" {
+		return "", "", fmt.Errorf("login did not return session cookies, check the credentials")
+	}
+
+	return sessMap, phpSessID, nil
+}
@@ -0,0 +1,118 @@
+package scraper
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gridCell is a cell of a table laid out on the columns it covers
+type gridCell struct {
+	text string
+	// cols is the number of columns the cell covers
+	cols int
+	// carried is set on the rows below the one a cell with a rowspan is in
+	carried bool
+}
+
+var (
+	// tableRowRE matches the start of a row of a table, with or without
+	// attributes, but not the <thead> or <tbody> around them
+	tableRowRE = regexp.MustCompile(`<tr[\s>]`)
+	colspanRE  = regexp.MustCompile(`(?i)^[^>]*\bcolspan\s*=\s*["']?(\d+)`)
+	rowspanRE  = regexp.MustCompile(`(?i)^[^>]*\browspan\s*=\s*["']?(\d+)`)
+)
+
+// layoutRows lays the cells of rows out on the columns of the table the way
+// a browser does, following their colspan and rowspan, and returns the cell
+// covering each column of every row
+func layoutRows(rows []string) [][]*gridCell {
+	type spanned struct {
+		cell *gridCell
+		rows int
+	}
+	pending := map[int]*spanned{}
+	// carry places the cell spanning down from the row above on col, if any
+	carry := func(grid []*gridCell, col int) ([]*gridCell, bool) {
+		s := pending[col]
+		if s == nil {
+			return grid, false
+		}
+		if s.rows--; s.rows == 0 {
+			delete(pending, col)
+		}
+		return append(grid, &gridCell{text: s.cell.text, cols: s.cell.cols, carried: true}), true
+	}
+
+	var grids [][]*gridCell
+	for _, row := range rows {
+		var grid []*gridCell
+		for _, cellHTML := range tabCellRE.Split(row, -1)[1:] {
+			for carried := true; carried; {
+				grid, carried = carry(grid, len(grid))
+			}
+			cell := &gridCell{text: extractText(cellHTML), cols: spanAttr(colspanRE, cellHTML)}
+			rowspan := spanAttr(rowspanRE, cellHTML)
+			for i := 0; i < cell.cols; i++ {
+				if rowspan > 1 {
+					pending[len(grid)] = &spanned{cell: cell, rows: rowspan - 1}
+				}
+				grid = append(grid, cell)
+			}
+		}
+		// Cells spanning down past the last cell of the row
+		var cols []int
+		for col := range pending {
+			if col >= len(grid) {
+				cols = append(cols, col)
+			}
+		}
+		sort.Ints(cols)
+		for _, col := range cols {
+			for len(grid) < col {
+				grid = append(grid, nil)
+			}
+			grid, _ = carry(grid, col)
+		}
+		grids = append(grids, grid)
+	}
+	return grids
+}
+
+// spanAttr returns the colspan or rowspan of a cell matched by re, 1 when it
+// has none
+func spanAttr(re *regexp.Regexp, cellHTML string) int {
+	m := re.FindStringSubmatch(cellHTML)
+	if m == nil {
+		return 1
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n < 1 {
+		return 1
+	}
+	// Browsers cap colspan at 1000
+	return min(n, 1000)
+}
+
+// rackColumns returns the number of columns of the header of a rack table
+// and the rack of each column, from the last header row naming racks. A
+// rack header covering several columns names none of them, as its values
+// can't be told apart.
+func rackColumns(headerHTML string) (int, map[int]string) {
+	width := 0
+	racks := map[int]string{}
+	for _, grid := range layoutRows(tableRowRE.Split(headerHTML, -1)[1:]) {
+		width = max(width, len(grid))
+		row := map[int]string{}
+		for col, cell := range grid {
+			if cell != nil && cell.cols == 1 && strings.Contains(cell.text, "RACK ") {
+				row[col] = strings.TrimSpace(strings.ReplaceAll(cell.text, "RACK ", ""))
+			}
+		}
+		if len(row) > 0 {
+			racks = row
+		}
+	}
+	return width, racks
+}
@@ -0,0 +1,133 @@
+package scraper
+
+import "testing"
+
+func TestParseRackTableAlignment(t *testing.T) {
+	tests := []struct {
+		name       string
+		table      string
+		flow       map[string]float64
+		delta      map[string]float64
+		rows       int
+		misaligned int
+	}{
+		{
+			name: "aligned",
+			table: `<table><thead><tr><th>Item</th><th>RACK 01</th><th>RACK 02</th></tr></thead><tbody>
+				<tr><td>TCS Flow</td><td>1 l/min</td><td>2 l/min</td></tr>
+				<tr><td>TCS Delta Temp</td><td>5 C</td><td>6 C</td></tr>
+			</tbody></table>`,
+			flow:  map[string]float64{"01": 1, "02": 2},
+			delta: map[string]float64{"01": 5, "02": 6},
+			rows:  2,
+		},
+		{
+			name: "colspan",
+			table: `<table><thead><tr><th>Item</th><th>RACK 01</th><th>RACK 02</th><th>RACK 03</th></tr></thead><tbody>
+				<tr><td>TCS Flow</td><td colspan="2">1 l/min</td><td>3 l/min</td></tr>
+				<tr><td>TCS Delta Temp</td><td>4 C</td><td>5 C</td><td>6 C</td></tr>
+			</tbody></table>`,
+			flow:       map[string]float64{"03": 3},
+			delta:      map[string]float64{"01": 4, "02": 5, "03": 6},
+			rows:       1,
+			misaligned: 1,
+		},
+		{
+			name: "missing cell",
+			table: `<table><thead><tr><th>Item</th><th>RACK 01</th><th>RACK 02</th></tr></thead><tbody>
+				<tr><td>TCS Flow</td><td>1 l/min</td><td>2 l/min</td></tr>
+				<tr><td>TCS Delta Temp</td><td>5 C</td></tr>
+			</tbody></table>`,
+			flow:       map[string]float64{"01": 1, "02": 2},
+			delta:      map[string]float64{},
+			rows:       1,
+			misaligned: 1,
+		},
+		{
+			name: "header without label cell",
+			table: `<table><thead><tr><th>RACK 01</th><th>RACK 02</th></tr></thead><tbody>
+				<tr><td>TCS Flow</td><td>1 l/min</td><td>2 l/min</td></tr>
+				<tr><td>TCS Delta Temp</td><td>5 C</td></tr>
+			</tbody></table>`,
+			flow:       map[string]float64{"01": 1, "02": 2},
+			delta:      map[string]float64{},
+			rows:       1,
+			misaligned: 1,
+		},
+		{
+			name: "rowspan",
+			table: `<table><thead><tr><th rowspan="2">Item</th><th colspan="2">COMPARTMENT A</th></tr><tr><th>RACK 01</th><th>RACK 02</th></tr></thead><tbody>
+				<tr><td>TCS Flow</td><td rowspan="2">1 l/min</td><td>2 l/min</td></tr>
+				<tr><td>TCS Delta Temp</td><td>6 C</td></tr>
+			</tbody></table>`,
+			flow:       map[string]float64{"01": 1, "02": 2},
+			delta:      map[string]float64{"02": 6},
+			rows:       1,
+			misaligned: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats PageStats
+			racks := parseRackTable(tt.table, "A", &stats)
+			flow := map[string]float64{}
+			delta := map[string]float64{}
+			for _, rack := range racks {
+				if rack.TCSFlow != 0 {
+					flow[rack.RackNumber] = rack.TCSFlow
+				}
+				if rack.TCSDeltaTemp != 0 {
+					delta[rack.RackNumber] = rack.TCSDeltaTemp
+				}
+			}
+			if !equalReadings(flow, tt.flow) {
+				t.Errorf("tcs_flow = %v, want %v", flow, tt.flow)
+			}
+			if !equalReadings(delta, tt.delta) {
+				t.Errorf("tcs_delta_temp = %v, want %v", delta, tt.delta)
+			}
+			if stats.Rows != tt.rows || stats.MisalignedRows != tt.misaligned {
+				t.Errorf("rows = %d, misaligned = %d, want %d and %d", stats.Rows, stats.MisalignedRows, tt.rows, tt.misaligned)
+			}
+		})
+	}
+}
+
+func equalReadings(got, want map[string]float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for rack, v := range want {
+		if got[rack] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseRackTableValveRows(t *testing.T) {
+	table := `<table><thead><tr><th>Item</th><th>RACK 01</th></tr></thead><tbody>
+		<tr><td>Valve Position (%)</td><td>45%</td></tr>
+		<tr><td>Valve Power (kW)</td><td>3.5 kW</td></tr>
+		<tr><td>Return Temperature (°C)</td><td>30.1 °C</td></tr>
+	</tbody></table>`
+	racks := parseRackTable(table, "A", &PageStats{})
+	if len(racks) != 1 {
+		t.Fatalf("got %d racks, want 1", len(racks))
+	}
+	rack := racks[0]
+	for name, got := range map[string]*float64{"valve_position": rack.ValvePosition, "valve_power": rack.ValvePower, "tcs_temp_return": rack.TCSTempReturn} {
+		if got == nil {
+			t.Errorf("%s not parsed", name)
+		}
+	}
+	if rack.ValvePosition != nil && *rack.ValvePosition != 45 {
+		t.Errorf("valve_position = %v, want 45", *rack.ValvePosition)
+	}
+	if rack.ValvePower != nil && *rack.ValvePower != 3.5 {
+		t.Errorf("valve_power = %v, want 3.5", *rack.ValvePower)
+	}
+	if rack.TCSTempReturn != nil && *rack.TCSTempReturn != 30.1 {
+		t.Errorf("tcs_temp_return = %v, want 30.1", *rack.TCSTempReturn)
+	}
+}
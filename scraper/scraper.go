@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +17,8 @@ import (
 type CDUAlarm struct {
 	Item   string
 	Status string
+	// Acknowledged is set when someone acknowledged the alarm on the portal
+	Acknowledged bool
 }
 
 // CDUParameter represents a parameter entry
@@ -23,6 +26,11 @@ type CDUParameter struct {
 	Item  string
 	Value float64
 	Unit  string
+	// Tab is the name of the tab of the dashboard the parameter was read
+	// from, empty for the parameter table
+	Tab string
+	// Setpoint is the setpoint listed next to the value, if any
+	Setpoint *float64
 }
 
 // LiquidCDU represents CDU liquid cooling data
@@ -44,21 +52,23 @@ type LiquidRack struct {
 	TCSFlow            float64
 	TCSDeltaTemp       float64
 	TCSTempSupply      float64
+	// Rows of the energy valve detail, nil when the table has none
+	ValvePosition *float64
+	ValvePower    *float64
+	TCSTempReturn *float64
 }
 
-// ScrapeCDU scrapes CDU data from the dashboard
-func ScrapeCDU(url, sessMap, phpSessID string, timeout time.Duration) (string, []CDUAlarm, []CDUParameter, error) {
+// ScrapeCDU scrapes CDU data from the dashboard, then opens the tabs in
+// turn and adds the readings of their tables to the parameters
+func ScrapeCDU(url string, browser Browser, sessMap, phpSessID string, timeout time.Duration, tabs []CDUTab) (string, []CDUAlarm, []CDUParameter, PageStats, error) {
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(scrapeCtx, timeout)
 	defer cancel()
 
 	// Create chromedp context
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
-		chromedp.Flag("disable-gpu", true),
-		chromedp.Flag("no-sandbox", true),
-	)
+	opts := allocatorOptions(browser)
 
+	defer trackBrowser()()
 	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
 	defer cancelAlloc()
 
@@ -81,30 +91,35 @@ func ScrapeCDU(url, sessMap, phpSessID string, timeout time.Duration) (string, [
 		},
 	}
 
-	if err := chromedp.Run(taskCtx, network.SetCookies(cookies)); err != nil {
-		return "", nil, nil, fmt.Errorf("failed to set cookies: %v", err)
+	if err := chromedp.Run(taskCtx, setHeaders(browser), network.SetCookies(cookies)); err != nil {
+		return "", nil, nil, PageStats{}, fmt.Errorf("failed to set cookies: %v", err)
 	}
 
 	var pageHTML string
+	var truncated bool
 
 	// Run tasks
 	err := chromedp.Run(taskCtx,
 		chromedp.Navigate(url),
-		chromedp.WaitVisible(`table`, chromedp.ByQuery), // Wait for tables to load
+		waitForTables(browser), // Wait for tables to load, or the login page
 		chromedp.Sleep(2*time.Second), // Additional wait
-		chromedp.OuterHTML("html", &pageHTML),
+		readHTML(browser, &pageHTML, &truncated),
 	)
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to scrape: %v", err)
+		return "", nil, nil, PageStats{}, fmt.Errorf("failed to scrape: %w", err)
 	}
 
-	name, alarms, params := parseCDUHTML(pageHTML)
+	stats := newPageStats(pageHTML)
+	stats.Truncated = truncated
+	name, alarms, params := parseCDUHTML(pageHTML, &stats)
+	checkCDUPage(url, pageHTML, name, params)
+	params = append(params, scrapeTabs(taskCtx, url, tabs, &stats)...)
 
-	return name, alarms, params, nil
+	return name, alarms, params, stats, nil
 }
 
 // parseCDUHTML parses the full HTML and extracts name, alarms and parameters
-func parseCDUHTML(html string) (string, []CDUAlarm, []CDUParameter) {
+func parseCDUHTML(html string, stats *PageStats) (string, []CDUAlarm, []CDUParameter) {
 	var name string
 	var alarms []CDUAlarm
 	var params []CDUParameter
@@ -120,9 +135,6 @@ func parseCDUHTML(html string) (string, []CDUAlarm, []CDUParameter) {
 			name = strings.ReplaceAll(name, "-", "_")
 		}
 	}
-	if name == "" {
-		name = "CDU_1.1" // fallback
-	}
 
 	// Find the alarm table: look for the table after "ALARM" header
 	alarmTableStart := strings.Index(html, "ALARM")
@@ -145,8 +157,9 @@ func parseCDUHTML(html string) (string, []CDUAlarm, []CDUParameter) {
 
 	alarmTbody := html[alarmTbodyStart:alarmTbodyEnd]
 
-	// Parse alarm rows
-	alarmRows := strings.Split(alarmTbody, "<tr>")
+	// Parse alarm rows, keeping their attributes as an acknowledged row may
+	// only differ by its classes
+	alarmRows := tableRowRE.Split(alarmTbody, -1)
 	for _, row := range alarmRows {
 		if strings.Contains(row, "<td") && strings.Contains(row, "td-detail") {
 			cells := strings.Split(row, "<td")
@@ -154,9 +167,12 @@ func parseCDUHTML(html string) (string, []CDUAlarm, []CDUParameter) {
 				item := normalizeItem(extractText(cells[1]))
 				status := strings.ToLower(extractText(cells[2]))
 				if item != "" && status != "" {
-					alarms = append(alarms, CDUAlarm{Item: item, Status: status})
+					alarms = append(alarms, CDUAlarm{Item: item, Status: status, Acknowledged: alarmAcknowledged(row, cells)})
+					stats.row(true)
+					continue
 				}
 			}
+			stats.row(false)
 		}
 	}
 
@@ -180,6 +196,7 @@ func parseCDUHTML(html string) (string, []CDUAlarm, []CDUParameter) {
 	paramTbodyEnd += paramTbodyStart
 
 	paramTbody := html[paramTbodyStart:paramTbodyEnd]
+	setpoint := setpointColumn(html[paramTableStart:paramTbodyStart])
 
 	// Parse parameter rows
 	paramRows := strings.Split(paramTbody, "<tr>")
@@ -193,29 +210,30 @@ func parseCDUHTML(html string) (string, []CDUAlarm, []CDUParameter) {
 				if item != "" && valueStr != "" {
 					value, err := strconv.ParseFloat(valueStr, 64)
 					if err == nil {
-						params = append(params, CDUParameter{Item: item, Value: value, Unit: unit})
+						params = append(params, CDUParameter{Item: item, Value: value, Unit: unit, Setpoint: parseSetpoint(cells, setpoint)})
+						stats.row(true)
+						continue
 					}
 				}
 			}
+			stats.row(false)
 		}
 	}
 
 	return name, alarms, params
 }
 
-// ScrapeLiquidCooling scrapes liquid cooling data from the overview page
-func ScrapeLiquidCooling(url, sessMap, phpSessID string, timeout time.Duration) ([]LiquidCDU, []LiquidRack, error) {
+// ScrapeLiquidCooling scrapes liquid cooling data from the overview page:
+// the CDU and rack tables, and the secondary loop pump and manifold sections
+func ScrapeLiquidCooling(url string, browser Browser, sessMap, phpSessID string, timeout time.Duration) ([]LiquidCDU, []LiquidRack, []LiquidReading, PageStats, error) {
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(scrapeCtx, timeout)
 	defer cancel()
 
 	// Create chromedp context
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
-		chromedp.Flag("disable-gpu", true),
-		chromedp.Flag("no-sandbox", true),
-	)
+	opts := allocatorOptions(browser)
 
+	defer trackBrowser()()
 	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
 	defer cancelAlloc()
 
@@ -238,30 +256,36 @@ func ScrapeLiquidCooling(url, sessMap, phpSessID string, timeout time.Duration)
 		},
 	}
 
-	if err := chromedp.Run(taskCtx, network.SetCookies(cookies)); err != nil {
-		return nil, nil, fmt.Errorf("failed to set cookies: %v", err)
+	if err := chromedp.Run(taskCtx, setHeaders(browser), network.SetCookies(cookies)); err != nil {
+		return nil, nil, nil, PageStats{}, fmt.Errorf("failed to set cookies: %v", err)
 	}
 
 	var pageHTML string
+	var truncated bool
 
 	// Run tasks
 	err := chromedp.Run(taskCtx,
 		chromedp.Navigate(url),
-		chromedp.WaitVisible(`table`, chromedp.ByQuery), // Wait for tables to load
+		waitForTables(browser), // Wait for tables to load, or the login page
 		chromedp.Sleep(2*time.Second), // Additional wait
-		chromedp.OuterHTML("html", &pageHTML),
+		scrollToLoad(browser), // Render the rows of long tables
+		readHTML(browser, &pageHTML, &truncated),
 	)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to scrape: %v", err)
+		return nil, nil, nil, PageStats{}, fmt.Errorf("failed to scrape: %w", err)
 	}
 
-	cdus, racks := parseLiquidHTML(pageHTML)
+	stats := newPageStats(pageHTML)
+	stats.Truncated = truncated
+	cdus, racks := parseLiquidHTML(pageHTML, &stats)
+	checkLiquidPage(url, pageHTML, cdus, racks, stats)
+	sections := parseLiquidSections(pageHTML, &stats)
 
-	return cdus, racks, nil
+	return cdus, racks, sections, stats, nil
 }
 
 // parseLiquidHTML parses the liquid cooling HTML and extracts CDU and rack data
-func parseLiquidHTML(html string) ([]LiquidCDU, []LiquidRack) {
+func parseLiquidHTML(html string, stats *PageStats) ([]LiquidCDU, []LiquidRack) {
 	var cdus []LiquidCDU
 	var racks []LiquidRack
 
@@ -298,7 +322,7 @@ func parseLiquidHTML(html string) ([]LiquidCDU, []LiquidRack) {
 
 		tableHTML := html[tableStart:tableEnd]
 
-		cdu := parseCDUTable(tableHTML, cduName)
+		cdu := parseCDUTable(tableHTML, cduName, stats)
 		if cdu.Name != "" {
 			cdus = append(cdus, cdu)
 		}
@@ -337,7 +361,7 @@ func parseLiquidHTML(html string) ([]LiquidCDU, []LiquidRack) {
 
 		tableHTML := html[tableStart:tableEnd]
 
-		rackData := parseRackTable(tableHTML, compartment)
+		rackData := parseRackTable(tableHTML, compartment, stats)
 		racks = append(racks, rackData...)
 	}
 
@@ -345,7 +369,7 @@ func parseLiquidHTML(html string) ([]LiquidCDU, []LiquidRack) {
 }
 
 // parseCDUTable parses a single CDU table
-func parseCDUTable(tableHTML, cduName string) LiquidCDU {
+func parseCDUTable(tableHTML, cduName string, stats *PageStats) LiquidCDU {
 	var cdu LiquidCDU
 	cdu.Name = cduName
 
@@ -363,6 +387,7 @@ func parseCDUTable(tableHTML, cduName string) LiquidCDU {
 		}
 
 		// Extract label-value pairs
+		parsed, skipped := 0, 0
 		for i := 1; i < len(cells); i += 2 {
 			if i+1 >= len(cells) {
 				break
@@ -380,8 +405,10 @@ func parseCDUTable(tableHTML, cduName string) LiquidCDU {
 
 			value, err := strconv.ParseFloat(strings.Fields(valueStr)[0], 64)
 			if err != nil {
+				skipped++
 				continue
 			}
+			parsed++
 
 			switch strings.ToLower(strings.ReplaceAll(label, " ", "_")) {
 			case "cdu_cooling":
@@ -400,13 +427,20 @@ func parseCDUTable(tableHTML, cduName string) LiquidCDU {
 				cdu.TCSTempRet = value
 			}
 		}
+		if parsed > 0 || skipped > 0 {
+			stats.row(skipped == 0)
+		}
 	}
 
 	return cdu
 }
 
+// rackLabelUnitRE matches the unit in parentheses ending a row label of a
+// rack table
+var rackLabelUnitRE = regexp.MustCompile(`\s*\([^)]*\)$`)
+
 // parseRackTable parses a single rack table
-func parseRackTable(tableHTML, compartment string) []LiquidRack {
+func parseRackTable(tableHTML, compartment string, stats *PageStats) []LiquidRack {
 	var racks []LiquidRack
 
 	// Find the header row to get rack numbers
@@ -421,14 +455,20 @@ func parseRackTable(tableHTML, compartment string) []LiquidRack {
 	headerEnd += headerStart
 	headerHTML := tableHTML[headerStart:headerEnd]
 
-	// Extract rack numbers from header
-	var rackNumbers []string
-	thMatches := regexp.MustCompile(`<th[^>]*>([^<]+)</th>`).FindAllStringSubmatch(headerHTML, -1)
-	for _, match := range thMatches {
-		if len(match) > 1 && strings.Contains(match[1], "RACK ") {
-			rackNum := strings.TrimSpace(strings.ReplaceAll(match[1], "RACK ", ""))
-			rackNumbers = append(rackNumbers, rackNum)
-		}
+	// Extract rack numbers from header, by the column they head
+	width, rackNumbers := rackColumns(headerHTML)
+	columns := make([]int, 0, len(rackNumbers))
+	for col := range rackNumbers {
+		columns = append(columns, col)
+	}
+	sort.Ints(columns)
+	if len(columns) == 0 {
+		return racks
+	}
+	// The label column of the rows may have no header cell
+	labelOffset := 0
+	if len(columns) > 0 && columns[0] == 0 {
+		labelOffset = 1
 	}
 
 	// Find tbody
@@ -444,18 +484,15 @@ func parseRackTable(tableHTML, compartment string) []LiquidRack {
 	tbodyHTML := tableHTML[tbodyStart:tbodyEnd]
 
 	// Parse rows
-	rows := strings.Split(tbodyHTML, "<tr")
-	for _, row := range rows {
-		if !strings.Contains(row, "<td") {
-			continue
-		}
-
-		cells := strings.Split(row, "<td")
-		if len(cells) < 2 {
+	rows := tableRowRE.Split(tbodyHTML, -1)[1:]
+	for r, grid := range layoutRows(rows) {
+		if !strings.Contains(rows[r], "<td") || len(grid) == 0 || grid[0] == nil {
 			continue
 		}
 
-		label := extractText(cells[1])
+		// Drop the units of the labels of the valve detail rows, such as
+		// "Valve Position (%)" or "Valve Power (kW)"
+		label := rackLabelUnitRE.ReplaceAllString(grid[0].text, "")
 		label = strings.ToLower(strings.ReplaceAll(label, " ", "_"))
 
 		// Skip if not a data row
@@ -463,22 +500,47 @@ func parseRackTable(tableHTML, compartment string) []LiquidRack {
 			continue
 		}
 
+		// A row of another width than the header, after a colspan or a
+		// missing cell, can't be told which rack each value belongs to
+		offset := len(grid) - width
+		if offset != labelOffset {
+			stats.MisalignedRows++
+			continue
+		}
+
 		// Extract values for each rack
-		for i, rackNum := range rackNumbers {
-			if i+2 >= len(cells) {
+		parsed, skipped, misaligned := 0, 0, false
+		for _, col := range columns {
+			rackNum := rackNumbers[col]
+			cell := grid[col+offset]
+			if cell == nil {
+				continue
+			}
+			valueStr := cell.text
+			// A value spanning several racks or rows belongs to none of them
+			if cell.cols > 1 || cell.carried {
+				if strings.TrimSpace(valueStr) != "" {
+					misaligned = true
+				}
 				continue
 			}
-			valueStr := extractText(cells[i+2])
 
 			// Normalize units
 			valueStr = strings.ReplaceAll(valueStr, "I/min", "l/min")
 			valueStr = strings.ReplaceAll(valueStr, "°C", "C")
 			valueStr = strings.ReplaceAll(valueStr, "kW", "kW")
+			valueStr = strings.ReplaceAll(valueStr, "%", " %")
+			// Racks without an energy valve leave its rows empty
+			if strings.TrimSpace(valueStr) == "" {
+				continue
+			}
 
 			value, err := strconv.ParseFloat(strings.Fields(valueStr)[0], 64)
 			if err != nil {
+				skipped++
 				continue
 			}
+			parsed++
 
 			// Find or create rack
 			var rack *LiquidRack
@@ -502,8 +564,19 @@ func parseRackTable(tableHTML, compartment string) []LiquidRack {
 				rack.TCSDeltaTemp = value
 			case "tcs_temp_supply":
 				rack.TCSTempSupply = value
+			case "valve_position", "valve_pos", "energy_valve_position":
+				rack.ValvePosition = &value
+			case "valve_power", "energy_valve_power":
+				rack.ValvePower = &value
+			case "tcs_temp_return", "tcs_temp_ret", "return_temp", "return_temperature":
+				rack.TCSTempReturn = &value
 			}
 		}
+		if misaligned {
+			stats.MisalignedRows++
+		} else if parsed > 0 || skipped > 0 {
+			stats.row(skipped == 0)
+		}
 	}
 
 	return racks
@@ -0,0 +1,54 @@
+package scraper
+
+import (
+	"context"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// scrollJS scrolls the page and every scrollable container to the bottom,
+// brings the last row of every table into view and returns the number of
+// rows rendered
+const scrollJS = `(() => {
+	window.scrollTo(0, document.documentElement.scrollHeight);
+	for (const e of document.querySelectorAll('*')) {
+		if (e.scrollHeight > e.clientHeight + 1 && /(auto|scroll)/.test(getComputedStyle(e).overflowY)) {
+			e.scrollTop = e.scrollHeight;
+		}
+	}
+	for (const row of document.querySelectorAll('table tr:last-child')) {
+		row.scrollIntoView({block: 'end'});
+	}
+	return document.querySelectorAll('tr').length;
+})()`
+
+// scrollWait is how long the page may take to render the rows scrolled
+// into view
+const scrollWait = 500 * time.Millisecond
+
+// scrollToLoad scrolls through the page until it renders no more table
+// rows, at most browser.ScrollPasses times, as long tables only render the
+// rows scrolled into view
+func scrollToLoad(browser Browser) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		previous := -1
+		for i := 0; i < browser.ScrollPasses; i++ {
+			var rows int
+			if err := chromedp.Evaluate(scrollJS, &rows).Do(ctx); err != nil {
+				return err
+			}
+			if rows == previous {
+				break
+			}
+			previous = rows
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(scrollWait):
+			}
+		}
+		var ignored any
+		return chromedp.Evaluate(`window.scrollTo(0, 0)`, &ignored).Do(ctx)
+	})
+}
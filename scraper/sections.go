@@ -0,0 +1,124 @@
+package scraper
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kinds of the sections of the liquid cooling overview beyond the CDU and
+// rack tables
+const (
+	SectionPump     = "pump"
+	SectionManifold = "manifold"
+)
+
+// LiquidReading is a reading of a secondary loop pump or manifold of the
+// liquid cooling overview
+type LiquidReading struct {
+	// Kind is SectionPump or SectionManifold
+	Kind string
+	// Section is the title of the section, e.g. SECONDARY_LOOP_PUMPS_A
+	Section string
+	// Name is the pump or manifold, the column of a table with a column per
+	// pump or the section of a table with a single one
+	Name  string
+	Item  string
+	Value float64
+	Unit  string
+}
+
+// liquidSectionRE matches the title of a secondary loop pump, pump skid or
+// manifold section, such as "SECONDARY LOOP PUMPS A" or "MANIFOLD 2 STATUS"
+var liquidSectionRE = regexp.MustCompile(`>\s*((?i:(?:secondary\s+loop\s+)?pumps?(?:\s+skid)?|(?:secondary\s+(?:loop\s+)?)?manifolds?))((?:\s+[A-Z0-9][\w.\-]*)?)(?i:\s+status)?\s*<`)
+
+// parseLiquidSections parses the tables following the secondary loop pump,
+// pump skid and manifold titles of the liquid cooling overview
+func parseLiquidSections(html string, stats *PageStats) []LiquidReading {
+	var readings []LiquidReading
+	for _, loc := range liquidSectionRE.FindAllStringSubmatchIndex(html, -1) {
+		// Column headers such as PUMP 1 are not titles
+		if strings.LastIndex(html[:loc[0]], "<table") > strings.LastIndex(html[:loc[0]], "</table>") {
+			continue
+		}
+		title := html[loc[2]:loc[3]] + html[loc[4]:loc[5]]
+		kind := SectionManifold
+		if strings.Contains(strings.ToLower(title), "pump") {
+			kind = SectionPump
+		}
+		section := strings.TrimSuffix(strings.ToUpper(normalizeItem(title)), "_STATUS")
+
+		tableStart := strings.Index(html[loc[1]:], "<table")
+		if tableStart == -1 {
+			continue
+		}
+		tableStart += loc[1]
+		// The table must belong to this title rather than a later one
+		if next := liquidSectionRE.FindStringIndex(html[loc[1]:tableStart]); next != nil {
+			continue
+		}
+		tableEnd := strings.Index(html[tableStart:], "</table>")
+		if tableEnd == -1 {
+			continue
+		}
+		readings = append(readings, parseSectionTable(html[tableStart:tableStart+tableEnd], kind, section, stats)...)
+	}
+	return readings
+}
+
+// parseSectionTable parses the table of a section. A table with a column
+// per pump or manifold, named in its header, gives readings per column;
+// any other table holds label and value pairs of the section itself.
+func parseSectionTable(table, kind, section string, stats *PageStats) []LiquidReading {
+	var readings []LiquidReading
+	var headers []string
+	for _, row := range tableRowRE.Split(table, -1)[1:] {
+		var cells []string
+		for _, cell := range tabCellRE.Split(row, -1)[1:] {
+			cells = append(cells, extractText(cell))
+		}
+		if !strings.Contains(row, "<td") {
+			if headers == nil && len(cells) > 0 {
+				headers = cells
+			}
+			continue
+		}
+		if len(cells) < 2 {
+			continue
+		}
+
+		before, skipped := len(readings), 0
+		add := func(name, label, value string) {
+			if label == "" || value == "" {
+				return
+			}
+			v, unit, ok := parseTabValue(normalizeLiquidUnit(value))
+			if !ok {
+				skipped++
+				return
+			}
+			item := strings.ToLower(normalizeItem(label))
+			readings = append(readings, LiquidReading{Kind: kind, Section: section, Name: name, Item: item, Value: v, Unit: unit})
+		}
+		if len(headers) > 2 {
+			for i := 1; i < len(cells) && i < len(headers); i++ {
+				add(normalizeItem(headers[i]), cells[0], cells[i])
+			}
+		} else {
+			for i := 0; i+1 < len(cells); i += 2 {
+				add(section, cells[i], cells[i+1])
+			}
+		}
+		if len(readings) > before || skipped > 0 {
+			stats.row(skipped == 0)
+		}
+	}
+	return readings
+}
+
+// normalizeLiquidUnit spells the units of a value of the liquid cooling
+// overview like its CDU and rack tables
+func normalizeLiquidUnit(value string) string {
+	value = strings.ReplaceAll(value, "I/min", "l/min")
+	value = strings.ReplaceAll(value, "°C", "C")
+	return strings.ReplaceAll(value, "%", "percentage")
+}
@@ -0,0 +1,64 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ErrSessionExpired is returned when the portal shows its login page
+// instead of the requested one, as the session cookies are no longer valid
+var ErrSessionExpired = errors.New("session expired, the portal shows the login page")
+
+// loginFormSelector matches the password field of the login form
+const loginFormSelector = `input[type="password"]`
+
+// waitForTables waits until the page shows a table. When the portal shows
+// its login page instead it fails right away with ErrSessionExpired,
+// rather than waiting for a table until the timeout.
+func waitForTables(browser Browser) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := chromedp.WaitVisible(`table, `+loginFormSelector, chromedp.ByQuery).Do(ctx); err != nil {
+			return err
+		}
+		return checkLogin(browser).Do(ctx)
+	})
+}
+
+// checkLogin fails with ErrSessionExpired when the portal shows its login
+// page
+func checkLogin(browser Browser) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var location string
+		if err := chromedp.Location(&location).Do(ctx); err != nil {
+			return err
+		}
+		var loginForm bool
+		if err := chromedp.Evaluate(`document.querySelector('`+loginFormSelector+`') !== null`, &loginForm).Do(ctx); err != nil {
+			return err
+		}
+		if loginForm || isLoginURL(location, browser.LoginURL) {
+			return fmt.Errorf("%w: %s", ErrSessionExpired, location)
+		}
+		return nil
+	})
+}
+
+// isLoginURL reports whether location is the login page at loginURL
+func isLoginURL(location, loginURL string) bool {
+	if loginURL == "" {
+		return false
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+	login, err := url.Parse(loginURL)
+	if err != nil {
+		return false
+	}
+	return u.Host == login.Host && u.Path == login.Path
+}
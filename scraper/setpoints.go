@@ -0,0 +1,34 @@
+package scraper
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// setpointHeaderRE matches the header of a setpoint column
+var setpointHeaderRE = regexp.MustCompile(`(?i)^(set\s*-?\s*point|sp|target)$`)
+
+// setpointColumn returns the index of the setpoint column of a table among
+// its cells split on <td, from the header cells before its body, or -1 when
+// it has none
+func setpointColumn(head string) int {
+	for i, cell := range tabCellRE.Split(head, -1)[1:] {
+		if setpointHeaderRE.MatchString(extractText(cell)) {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// parseSetpoint parses the setpoint cell of a row, nil when the table has
+// no setpoint column or the cell holds no number
+func parseSetpoint(cells []string, column int) *float64 {
+	if column < 0 || column >= len(cells) {
+		return nil
+	}
+	value, err := strconv.ParseFloat(extractText(cells[column]), 64)
+	if err != nil {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,38 @@
+package scraper
+
+import "strings"
+
+// PageStats describes how much of a page the parser understood, so a change
+// of the dashboard shows up as a drop in parsed rows
+type PageStats struct {
+	// Size is the size of the HTML in bytes
+	Size int
+	// Tables is the number of tables on the page
+	Tables int
+	// Rows is the number of data rows parsed
+	Rows int
+	// SkippedRows is the number of data rows dropped because a cell could
+	// not be parsed
+	SkippedRows int
+	// MisalignedRows is the number of rack rows whose cells could not be
+	// aligned with the rack columns of their header, e.g. after a colspan or
+	// a missing cell
+	MisalignedRows int
+	// Truncated is set when the page exceeded the size limit and only its
+	// beginning was parsed
+	Truncated bool
+}
+
+// newPageStats returns the statistics of a page before parsing its rows
+func newPageStats(html string) PageStats {
+	return PageStats{Size: len(html), Tables: strings.Count(html, "<table")}
+}
+
+// row counts a data row as parsed or skipped
+func (s *PageStats) row(parsed bool) {
+	if parsed {
+		s.Rows++
+	} else {
+		s.SkippedRows++
+	}
+}
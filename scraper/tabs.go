@@ -0,0 +1,213 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// CDUTab is a tab of the CDU dashboard, such as the pumps or valves, that is
+// only shown after clicking through it
+type CDUTab struct {
+	Name string
+	// Clicks are the CSS selectors of the elements clicked in turn to open
+	// the tab
+	Clicks []string
+	// Table is the CSS selector of the tables of the tab
+	Table string
+}
+
+// DefaultTabTable is the CSS selector of the tables of a tab when it has
+// none
+const DefaultTabTable = ".tab-pane.active table"
+
+// tabWait is how long an element to click or the tables of a tab may take
+// to show up
+const tabWait = 5 * time.Second
+
+var (
+	// tabCellRE matches the start of a data or header cell, but not the
+	// <thead> around them
+	tabCellRE = regexp.MustCompile(`<t[dh]\b`)
+	// tabValueRE matches a reading followed by its unit, e.g. "45.2 %"
+	tabValueRE = regexp.MustCompile(`^([-+]?\d+(?:\.\d+)?)\s*(.*)$`)
+	// tabHeaderUnitRE matches a column header followed by its unit, e.g.
+	// "Speed (rpm)"
+	tabHeaderUnitRE = regexp.MustCompile(`^(.*?)\s*\(([^)]*)\)$`)
+)
+
+// scrapeTabs opens the tabs of the dashboard loaded in ctx in turn and
+// parses their tables. A tab that can't be opened or has no readings is
+// reported as an anomaly and skipped, as the main tables were read already.
+func scrapeTabs(ctx context.Context, url string, tabs []CDUTab, stats *PageStats) []CDUParameter {
+	var params []CDUParameter
+	previous := ""
+	for _, tab := range tabs {
+		html, err := openTab(ctx, tab, previous)
+		if err != nil {
+			reportAnomaly(url, "cdu", fmt.Sprintf("tab %s: %v", tab.Name, err), html)
+			continue
+		}
+		previous = html
+		stats.Size += len(html)
+		stats.Tables += strings.Count(html, "<table")
+		found := parseTabTables(html, tab.Name, stats)
+		if len(found) == 0 {
+			reportAnomaly(url, "cdu", fmt.Sprintf("tab %s: no readings found", tab.Name), html)
+		}
+		params = append(params, found...)
+	}
+	return params
+}
+
+// openTab clicks through the elements of a tab and returns the HTML of its
+// tables once they differ from previous, the tables of the tab before
+func openTab(ctx context.Context, tab CDUTab, previous string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, tabWait*time.Duration(len(tab.Clicks)+1))
+	defer cancel()
+
+	for _, selector := range tab.Clicks {
+		if err := clickWhenReady(ctx, selector); err != nil {
+			return "", err
+		}
+	}
+
+	table := tab.Table
+	if table == "" {
+		table = DefaultTabTable
+	}
+	js := fmt.Sprintf(`[...document.querySelectorAll(%s)].map(t => t.outerHTML).join("")`, jsString(table))
+	deadline := time.Now().Add(tabWait)
+	for {
+		var html string
+		if err := chromedp.Run(ctx, chromedp.Evaluate(js, &html)); err != nil {
+			return "", err
+		}
+		// A tab that looks like the one before may just not have changed,
+		// so it is used once the wait is over
+		if html != "" && (html != previous || time.Now().After(deadline)) {
+			return html, nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("no element matches %q", table)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// clickWhenReady clicks the first element matching selector, waiting for it
+// to show up as an earlier click may have to render it first
+func clickWhenReady(ctx context.Context, selector string) error {
+	js := fmt.Sprintf(`(() => {
+		const e = document.querySelector(%s);
+		if (!e) return false;
+		e.click();
+		return true;
+	})()`, jsString(selector))
+	deadline := time.Now().Add(tabWait)
+	for {
+		var clicked bool
+		if err := chromedp.Run(ctx, chromedp.Evaluate(js, &clicked)); err != nil {
+			return err
+		}
+		if clicked {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no element matches %q", selector)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// jsString quotes s as a JavaScript string literal
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// parseTabTables parses the tables of a tab. A table of item, value and
+// unit columns, like the parameter table, gives a reading per row; any other
+// table gives a reading per cell named after its row and column, such as
+// pump_1_speed. Readings may carry their unit, e.g. "45.2 %", and states
+// such as running or closed are read as 1 or 0.
+func parseTabTables(html, tab string, stats *PageStats) []CDUParameter {
+	var params []CDUParameter
+	for _, table := range strings.Split(html, "<table")[1:] {
+		var headers []string
+		for _, row := range tableRowRE.Split(table, -1)[1:] {
+			var cells []string
+			for _, cell := range tabCellRE.Split(row, -1)[1:] {
+				cells = append(cells, extractText(cell))
+			}
+			// The first row of header cells names the columns
+			if !strings.Contains(row, "<td") {
+				if headers == nil && len(cells) > 0 {
+					headers = cells
+				}
+				continue
+			}
+			itemValueUnit := len(headers) == 0 || len(headers) == 3 && strings.EqualFold(headers[2], "unit")
+
+			name := normalizeItem(cells[0])
+			if name == "" || len(cells) < 2 {
+				stats.row(false)
+				continue
+			}
+
+			before := len(params)
+			if itemValueUnit {
+				if value, unit, ok := parseTabValue(cells[1]); ok {
+					if len(cells) > 2 && cells[2] != "" {
+						unit = cells[2]
+					}
+					params = append(params, CDUParameter{Item: name, Value: value, Unit: unit, Tab: tab})
+				}
+			} else {
+				for i, cell := range cells[1:] {
+					if i+1 >= len(headers) {
+						break
+					}
+					if value, unit, ok := parseTabValue(cell); ok {
+						header := headers[i+1]
+						if m := tabHeaderUnitRE.FindStringSubmatch(header); m != nil {
+							header = m[1]
+							if unit == "" {
+								unit = m[2]
+							}
+						}
+						item := normalizeItem(name + "_" + header)
+						params = append(params, CDUParameter{Item: item, Value: value, Unit: unit, Tab: tab})
+					}
+				}
+			}
+			stats.row(len(params) > before)
+		}
+	}
+	return params
+}
+
+// parseTabValue parses a reading of a tab and its unit
+func parseTabValue(s string) (float64, string, bool) {
+	switch strings.ToLower(s) {
+	case "on", "open", "opened", "running", "run", "yes", "true", "enabled":
+		return 1, "", true
+	case "off", "closed", "close", "stopped", "stop", "no", "false", "disabled":
+		return 0, "", true
+	}
+	m := tabValueRE.FindStringSubmatch(s)
+	if m == nil {
+		return 0, "", false
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return value, strings.TrimSpace(m[2]), true
+}
@@ -0,0 +1,295 @@
+// Package sentry reports panics, repeated scrape errors and parser anomalies
+// to Sentry or a compatible service such as GlitchTip
+package sentry
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/version"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+const (
+	// queueSize is the number of events waiting to be sent, further events
+	// are dropped
+	queueSize = 100
+	// reportInterval is the minimum time between two reports of the same
+	// issue
+	reportInterval = time.Hour
+)
+
+// Event levels
+const (
+	LevelFatal   = "fatal"
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// Client sends events to the envelope endpoint of a project. A nil Client
+// reports nothing, so callers don't need to check whether reporting is
+// enabled.
+type Client struct {
+	endpoint    string
+	auth        string
+	environment string
+	serverName  string
+	http        *http.Client
+	queue       chan []byte
+	pending     sync.WaitGroup
+
+	mu       sync.Mutex
+	reported map[string]time.Time
+}
+
+// event is a Sentry event
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Exception   *exceptions       `json:"exception,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]any    `json:"extra,omitempty"`
+	Fingerprint []string          `json:"fingerprint,omitempty"`
+}
+
+type exceptions struct {
+	Values []exception `json:"values"`
+}
+
+type exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *stacktrace `json:"stacktrace,omitempty"`
+}
+
+type stacktrace struct {
+	Frames []frame `json:"frames"`
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// New creates a client for the DSN of the configuration
+func New(cfg config.SentryConfig, timeout time.Duration) (*Client, error) {
+	u, err := url.Parse(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SENTRY_DSN: %w", err)
+	}
+	project := path.Base(u.Path)
+	prefix := strings.TrimSuffix(path.Dir(u.Path), "/")
+	hostname, _ := os.Hostname()
+
+	c := &Client{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, prefix, project),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=bdx_exporter/%s, sentry_key=%s", version.Version, u.User.Username()),
+		environment: cfg.Environment,
+		serverName:  hostname,
+		http:        &http.Client{Timeout: timeout},
+		queue:       make(chan []byte, queueSize),
+		reported:    make(map[string]time.Time),
+	}
+	go c.run()
+	return c, nil
+}
+
+// CapturePanic reports a recovered panic with the stack of the goroutine
+// that panicked. It is meant to be called from the deferred function that
+// recovered it.
+func (c *Client) CapturePanic(v any, tags map[string]string) {
+	if c == nil {
+		return
+	}
+	e := c.newEvent(LevelFatal, tags)
+	e.Exception = &exceptions{Values: []exception{{
+		Type:       "panic",
+		Value:      fmt.Sprint(v),
+		Stacktrace: &stacktrace{Frames: panicFrames()},
+	}}}
+	c.send(e)
+}
+
+// Recover reports a panic of the calling goroutine and panics again, so the
+// exporter still crashes. Use it as defer c.Recover().
+func (c *Client) Recover() {
+	if c == nil {
+		return
+	}
+	if v := recover(); v != nil {
+		c.CapturePanic(v, nil)
+		c.Flush(5 * time.Second)
+		panic(v)
+	}
+}
+
+// Flush waits up to timeout for the queued events to be sent
+func (c *Client) Flush(timeout time.Duration) {
+	if c == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		c.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// capture reports a message, unless an issue with the same key was
+// reported within the report interval
+func (c *Client) capture(key, level, message string, tags map[string]string, extra map[string]any, fingerprint []string) {
+	now := time.Now()
+	c.mu.Lock()
+	if last, ok := c.reported[key]; ok && now.Sub(last) < reportInterval {
+		c.mu.Unlock()
+		return
+	}
+	c.reported[key] = now
+	c.mu.Unlock()
+
+	e := c.newEvent(level, tags)
+	e.Message = message
+	e.Extra = extra
+	e.Fingerprint = fingerprint
+	c.send(e)
+}
+
+func (c *Client) newEvent(level string, tags map[string]string) *event {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &event{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   time.Now().UTC(),
+		Platform:    "go",
+		Level:       level,
+		Logger:      "bdx_exporter",
+		ServerName:  c.serverName,
+		Release:     "bdx_exporter@" + version.Version,
+		Environment: c.environment,
+		Tags:        tags,
+	}
+}
+
+// send queues the envelope of an event
+func (c *Client) send(e *event) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Failed to encode Sentry event: %v", err)
+		return
+	}
+	header, _ := json.Marshal(map[string]any{"event_id": e.EventID, "sent_at": time.Now().UTC()})
+	item, _ := json.Marshal(map[string]any{"type": "event", "length": len(payload)})
+
+	var b bytes.Buffer
+	for _, line := range [][]byte{header, item, payload} {
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+
+	c.pending.Add(1)
+	select {
+	case c.queue <- b.Bytes():
+	default:
+		c.pending.Done()
+		log.Printf("Dropped Sentry event %s, the queue is full", e.EventID)
+	}
+}
+
+// run sends the queued envelopes
+func (c *Client) run() {
+	for envelope := range c.queue {
+		if err := c.post(envelope); err != nil {
+			log.Printf("Failed to send event to Sentry: %v", err)
+		}
+		c.pending.Done()
+	}
+}
+
+func (c *Client) post(envelope []byte) error {
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(envelope))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", c.auth)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// panicFrames returns the stack of the panicking goroutine, oldest call
+// first, without the frames of the recovery
+func panicFrames() []frame {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(1, pcs)])
+
+	var stack []frame
+	for {
+		f, more := frames.Next()
+		if f.Function == "runtime.gopanic" {
+			// Drop the deferred calls recovering the panic
+			stack = stack[:0]
+		} else {
+			stack = append(stack, newFrame(f))
+		}
+		if !more {
+			break
+		}
+	}
+	slices.Reverse(stack)
+	return stack
+}
+
+func newFrame(f runtime.Frame) frame {
+	module, function := "", f.Function
+	if i := strings.LastIndex(f.Function, "/"); i >= 0 {
+		if j := strings.Index(f.Function[i:], "."); j >= 0 {
+			module, function = f.Function[:i+j], f.Function[i+j+1:]
+		}
+	} else if j := strings.Index(f.Function, "."); j >= 0 {
+		module, function = f.Function[:j], f.Function[j+1:]
+	}
+	return frame{
+		Function: function,
+		Module:   module,
+		Filename: path.Base(f.File),
+		AbsPath:  f.File,
+		Lineno:   f.Line,
+		InApp:    strings.HasPrefix(module, "github.com/reski-rukmantiyo/bdx-parser-prometheus") || module == "main",
+	}
+}
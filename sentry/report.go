@@ -0,0 +1,77 @@
+package sentry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/scraper"
+)
+
+// Start reports the scrape targets failing SENTRY_SCRAPE_ERROR_THRESHOLD
+// times in a row and the pages the parser found no data in, until the
+// context is canceled
+func Start(ctx context.Context, col *collector.Collector, c *Client) {
+	scraper.ReportAnomaly = func(a scraper.Anomaly) {
+		c.capture("anomaly/"+a.URL+"/"+a.Reason+"/"+a.MarkupHash, LevelWarning,
+			fmt.Sprintf("Parser anomaly on %s page: %s", a.Page, a.Reason),
+			map[string]string{
+				"page":        a.Page,
+				"url":         a.URL,
+				"markup_hash": a.MarkupHash,
+				"cycle_id":    col.CycleID(),
+			},
+			map[string]any{"size": a.Size},
+			[]string{"parser-anomaly", a.Page, a.Reason, a.MarkupHash})
+	}
+
+	updates, unsubscribe := col.Subscribe()
+	go func() {
+		defer unsubscribe()
+		seen := make(map[string]time.Time)
+		failures := make(map[string]int)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-updates:
+				threshold := col.Config().Sentry.ScrapeErrorThreshold
+				for _, t := range col.Targets() {
+					// Count every scrape once
+					if t.LastScrape == nil || t.LastScrape.Equal(seen[t.URL]) {
+						continue
+					}
+					seen[t.URL] = *t.LastScrape
+
+					if t.LastError == "" {
+						delete(failures, t.URL)
+						continue
+					}
+					failures[t.URL]++
+					if failures[t.URL] == threshold {
+						c.reportScrapeError(col, t, threshold)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// reportScrapeError reports a target that failed threshold times in a row
+func (c *Client) reportScrapeError(col *collector.Collector, t collector.TargetStatus, threshold int) {
+	target := t.Name
+	if target == "" {
+		target = t.URL
+	}
+	c.capture("scrape/"+t.URL, LevelError,
+		fmt.Sprintf("Scraping %s %s failed %d times in a row: %s", t.Source, target, threshold, t.LastError),
+		map[string]string{
+			"source":   t.Source,
+			"target":   target,
+			"url":      t.URL,
+			"cycle_id": col.CycleID(),
+		},
+		map[string]any{"last_error": t.LastError, "failures": threshold},
+		[]string{"scrape-error", t.Source, target})
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/version"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/election"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/history"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/logging"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/modbus"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/notify"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/sentry"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/sink"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/snmp"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/systemd"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/tunnel"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/web"
+)
+
+// serviceStop is closed when the Windows service manager stops the service
+var serviceStop = make(chan struct{})
+
+// serve runs the exporter until a shutdown signal is received
+func serve(args []string) int {
+	// Load configuration
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	cfg, err := readConfig(fs, args)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Send the log, including the one of gin, to the configured outputs
+	closeLog, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	defer closeLog()
+	gin.DefaultWriter, gin.DefaultErrorWriter = log.Writer(), log.Writer()
+
+	log.Printf("Starting %s %s", programName, version.Info())
+	prometheus.MustRegister(versioncollector.NewCollector(programName))
+
+	if errs := cfg.Validate(); len(errs) > 0 {
+		log.Fatalf("Invalid config: %v", errors.Join(errs...))
+	}
+
+	// Record the admin API calls to their own file if one is configured
+	auditor := &auditLog{}
+	if cfg.Logging.AuditFile != "" {
+		f, err := logging.OpenAuditLog(cfg.Logging)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer f.Close()
+		auditor.w = f
+	}
+
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handle shutdown signals and /-/quit
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	quitChan := make(chan struct{}, 1)
+
+	// Reach an isolated portal through the SSH tunnel
+	stopTunnel, err := tunnel.Start(ctx, cfg.Tunnel, cfg.HTTPTimeout)
+	if err != nil {
+		log.Fatalf("Failed to start SSH tunnel: %v", err)
+	}
+	defer stopTunnel()
+
+	// Create collector
+	col := collector.NewCollector(cfg)
+
+	// Report panics, repeated scrape errors and parser anomalies
+	var reporter *sentry.Client
+	if cfg.Sentry.DSN != "" {
+		reporter, err = sentry.New(cfg.Sentry, cfg.HTTPTimeout)
+		if err != nil {
+			log.Fatalf("Failed to set up Sentry: %v", err)
+		}
+		sentry.Start(ctx, col, reporter)
+	}
+	defer reporter.Recover()
+
+	// Publish to the configured sinks after every collection
+	sinks, err := sink.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up sinks: %v", err)
+	}
+	sink.Start(ctx, col, sinks)
+
+	// Notify about alarm and threshold rule transitions
+	notifiers, err := notify.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up notifiers: %v", err)
+	}
+	if len(cfg.SNMPTrap.Targets) > 0 {
+		trap, err := snmp.NewTrapSender(cfg.SNMPTrap)
+		if err != nil {
+			log.Fatalf("Failed to set up SNMP traps: %v", err)
+		}
+		notifiers = append(notifiers, trap)
+	}
+	silences, err := notify.NewSilences(col, cfg)
+	if err != nil {
+		log.Fatalf("Failed to load silences: %v", err)
+	}
+	notify.Start(ctx, col, notifiers, silences)
+
+	// Keep a local history of the readings for /api/v1/history
+	var hist *history.Store
+	if cfg.HistoryPath != "" {
+		hist, err = history.Open(cfg.HistoryPath, cfg.HistoryRetention)
+		if err != nil {
+			log.Fatalf("Failed to open history: %v", err)
+		}
+		defer hist.Close()
+		history.Start(ctx, col, hist)
+	}
+
+	// Serve the readings to network management systems over SNMP
+	if cfg.SNMP.ListenAddress != "" {
+		agent, err := snmp.NewAgent(cfg.SNMP, col)
+		if err != nil {
+			log.Fatalf("Failed to set up SNMP agent: %v", err)
+		}
+		go func() {
+			if err := agent.ListenAndServe(ctx); err != nil {
+				log.Fatalf("Failed to start SNMP agent: %v", err)
+			}
+		}()
+	}
+
+	// Serve selected readings to the BMS as Modbus registers
+	if cfg.Modbus.ListenAddress != "" {
+		server := modbus.NewServer(cfg.Modbus, col)
+		go func() {
+			if err := server.ListenAndServe(ctx); err != nil {
+				log.Fatalf("Failed to start Modbus server: %v", err)
+			}
+		}()
+	}
+
+	// With leader election, only the leader scrapes the portal, starting
+	// once it acquires the lease. The lease is released on shutdown so the
+	// standby takes over right away.
+	if cfg.Election.URL != "" {
+		stopElection, err := election.Start(ctx, cfg.Election, cfg.HTTPTimeout, col)
+		if err != nil {
+			log.Fatalf("Failed to start leader election: %v", err)
+		}
+		defer stopElection()
+	} else {
+		// Discover targets before the first collection so it covers them
+		if err := col.Discover(); err != nil {
+			log.Printf("Failed to discover CDU targets: %v", err)
+		}
+
+		// Initial collection
+		col.Collect(ctx)
+	}
+
+	collectTicker := time.NewTicker(cfg.ScrapeInterval)
+	discoveryTicker := time.NewTicker(cfg.DiscoveryInterval)
+
+	// Reload the configuration on SIGHUP, /-/reload and remote changes
+	reload := newReloader(args, col, func(cfg *config.Config) {
+		collectTicker.Reset(cfg.ScrapeInterval)
+		discoveryTicker.Reset(cfg.DiscoveryInterval)
+	})
+
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupChan:
+				log.Println("Received SIGHUP, reloading configuration")
+				reload.Reload()
+			}
+		}
+	}()
+
+	if cfg.RemoteConfigURL != "" {
+		go reload.watchRemote(ctx, cfg)
+	}
+	if paths := cfg.WatchedPaths(); len(paths) > 0 {
+		go reload.watchFiles(ctx, paths, cfg.Watch.Interval)
+	}
+
+	// Start periodic discovery
+	go func() {
+		defer reporter.Recover()
+		defer discoveryTicker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-discoveryTicker.C:
+				if err := col.Discover(); err != nil {
+					log.Printf("Failed to discover CDU targets: %v", err)
+				}
+			}
+		}
+	}()
+
+	// Start periodic collection
+	go func() {
+		defer reporter.Recover()
+		defer collectTicker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("Stopping periodic collection")
+				return
+			case <-collectTicker.C:
+				col.Collect(ctx)
+			}
+		}
+	}()
+
+	// Set up Gin routers. Metrics and operational endpoints move to their
+	// own listeners if those are configured.
+	r := newRouter(col, reporter)
+	servers := []*http.Server{{Addr: cfg.ListenAddress, Handler: r}}
+	metrics, ops := r, r
+	if cfg.MetricsListenAddress != "" {
+		metrics = newRouter(col, reporter)
+		servers = append(servers, &http.Server{Addr: cfg.MetricsListenAddress, Handler: metrics})
+	}
+	if cfg.AdminListenAddress != "" {
+		ops = newRouter(col, reporter)
+		servers = append(servers, &http.Server{Addr: cfg.AdminListenAddress, Handler: ops})
+	}
+
+	// Health check endpoint
+	ops.GET("/health", func(c *gin.Context) {
+		lastCollect, lastSuccess := col.GetHealthStatus()
+		status := "healthy"
+		if !lastSuccess {
+			status = "unhealthy"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status":       status,
+			"last_collect": lastCollect.Format(time.RFC3339),
+			"last_success": lastSuccess,
+			"labels":       col.ConstantLabels(),
+			"paused":       col.PausedSources(),
+			"leader":       col.IsLeader(),
+			// Set when the portal rejects the session, so it is refreshed
+			// rather than the portal investigated
+			"session_expired": col.SessionExpired(),
+		})
+	})
+
+	// Liveness, and readiness once every enabled source was collected
+	ops.GET("/livez", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok\n")
+	})
+	ops.GET("/readyz", func(c *gin.Context) {
+		if pending := col.PendingSources(); len(pending) > 0 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "pending": pending})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "pending": []string{}})
+	})
+
+	// Scrape target status
+	ops.GET("/targets", targetsHandler(col))
+
+	// JSON API
+	r.GET("/api/v1/values", valuesHandler(col))
+	r.GET("/api/v1/stream", streamHandler(col))
+	r.GET("/api/v1/events", eventsHandler(col))
+	r.GET("/api/v1/availability", availabilityHandler(col))
+	r.GET("/api/v1/sensors", sensorsHandler(col))
+	r.GET("/api/v1/silences", silencesHandler(silences))
+	if hist != nil {
+		r.GET("/api/v1/history", historyHandler(hist))
+	}
+	r.GET("/api/v1/openapi.yaml", openAPIHandler)
+
+	// Admin endpoints, protected by the admin bearer tokens. Without tokens
+	// they are not served at all rather than open to anyone.
+	if len(cfg.AdminTokens) == 0 {
+		log.Println("No admin tokens configured, admin endpoints are disabled")
+	} else {
+		admin := ops.Group("/",
+			audit(auditor),
+			allowCIDRs(col, func(cfg *config.Config) []netip.Prefix { return cfg.AdminAllowedCIDRs }),
+			requireAdminToken(col),
+		)
+
+		// Reload endpoint
+		admin.POST("/-/reload", func(c *gin.Context) {
+			if err := reload.Reload(); err != nil {
+				c.String(http.StatusInternalServerError, "%v\n", err)
+				return
+			}
+			c.String(http.StatusOK, "Configuration reloaded\n")
+		})
+
+		// Quit endpoint, shuts down like SIGTERM
+		admin.POST("/-/quit", func(c *gin.Context) {
+			select {
+			case quitChan <- struct{}{}:
+			default:
+			}
+			c.String(http.StatusOK, "Shutting down\n")
+		})
+
+		// Manual collection trigger
+		admin.POST("/admin/collect", collectHandler(col))
+
+		// Pause and resume periodic collection
+		admin.POST("/admin/pause", pauseHandler(col, true))
+		admin.POST("/admin/resume", pauseHandler(col, false))
+
+		// Create and expire silences
+		admin.POST("/api/v1/silences", createSilenceHandler(silences))
+		admin.DELETE("/api/v1/silences/:id", expireSilenceHandler(silences))
+
+		// Debug endpoints
+		admin.GET("/debug/parsed", parsedHandler(col))
+		if cfg.EnablePprof {
+			admin.GET("/debug/pprof/*profile", pprofHandler)
+			admin.POST("/debug/pprof/*profile", pprofHandler)
+		}
+	}
+
+	// Prometheus HTTP service discovery of the portal pages
+	metrics.GET("/sd", allowCIDRs(col, func(cfg *config.Config) []netip.Prefix { return cfg.MetricsAllowedCIDRs }), sdHandler(col))
+
+	// Metrics endpoint
+	metrics.GET(cfg.TelemetryPath, allowCIDRs(col, func(cfg *config.Config) []netip.Prefix { return cfg.MetricsAllowedCIDRs }), gin.WrapH(promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer, promhttp.HandlerFor(col.Gatherer(), promhttp.HandlerOpts{}),
+	)))
+
+	// Start servers in goroutines
+	for _, server := range servers {
+		// Requests are canceled on shutdown so long-lived streams end
+		server.BaseContext = func(net.Listener) context.Context { return ctx }
+		go func() {
+			log.Printf("Starting server on %s", server.Addr)
+			if err := web.ListenAndServe(server, cfg.WebConfigFile); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start server: %v", err)
+			}
+		}()
+	}
+
+	// Tell systemd the exporter is up and keep its watchdog fed
+	stopNotify := systemd.Start(ctx, col)
+
+	// Wait for shutdown signal
+	select {
+	case <-sigChan:
+		log.Println("Received shutdown signal, shutting down gracefully...")
+	case <-quitChan:
+		log.Println("Received quit request, shutting down gracefully...")
+	case <-serviceStop:
+		log.Println("Received stop request from the service manager, shutting down gracefully...")
+	}
+	stopNotify()
+
+	// Cancel context to stop collection, then let the running cycle finish
+	// while the metrics are still served
+	cancel()
+	col.Shutdown(col.Config().ShutdownGracePeriod)
+
+	// Shutdown server with timeout
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server %s forced to shutdown: %v", server.Addr, err)
+		}
+	}
+
+	log.Println("Server exited")
+	return 0
+}
+
+// newRouter creates a Gin router with the middleware shared by every listener
+func newRouter(col *collector.Collector, reporter *sentry.Client) *gin.Engine {
+	r := gin.New()
+	r.Use(recoverPanics(reporter), requestID(), accessLog(col))
+	r.Use(allowCIDRs(col, func(cfg *config.Config) []netip.Prefix { return cfg.AllowedCIDRs }))
+	r.Use(limitRate(col))
+	return r
+}
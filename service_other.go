@@ -0,0 +1,25 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// isWindowsService is false outside Windows
+func isWindowsService() bool {
+	return false
+}
+
+// runService is only used on Windows
+func runService() int {
+	return 1
+}
+
+// serviceCommand fails, as services are managed by systemd or the container
+// runtime outside Windows
+func serviceCommand(args []string) int {
+	fmt.Fprintln(os.Stderr, "The service command is only supported on Windows, see the systemd unit in the README")
+	return 1
+}
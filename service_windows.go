@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// isWindowsService reports whether the exporter was started by the Windows
+// service manager
+func isWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// runService runs the serve command under the Windows service manager
+func runService() int {
+	// Services start in the system directory, keep .env and relative paths
+	// next to the executable
+	if exe, err := os.Executable(); err == nil {
+		os.Chdir(filepath.Dir(exe))
+	}
+	if err := svc.Run(programName, &service{}); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// service is the handler of the Windows service manager requests
+type service struct{}
+
+func (service) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	// A service has no console, log to the event log source registered by
+	// the install command unless configured otherwise
+	if os.Getenv("LOG_OUTPUTS") == "" {
+		os.Setenv("LOG_OUTPUTS", "eventlog")
+	}
+	if os.Getenv("LOG_TAG") == "" && len(args) > 0 {
+		os.Setenv("LOG_TAG", args[0])
+	}
+
+	// The arguments given on install follow the executable, the ones of
+	// the start request follow the service name
+	serveArgs := os.Args[1:]
+	if len(serveArgs) > 0 && serveArgs[0] == "serve" {
+		serveArgs = serveArgs[1:]
+	}
+	if len(args) > 1 {
+		serveArgs = append(serveArgs, args[1:]...)
+	}
+	done := make(chan int, 1)
+	go func() { done <- serve(serveArgs) }()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case code := <-done:
+			return false, uint32(code)
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				// Shutdown waits for the running collection cycle
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32(time.Minute.Milliseconds())}
+				close(serviceStop)
+				return false, uint32(<-done)
+			}
+		}
+	}
+}
+
+// serviceCommand installs, removes, starts or stops the Windows service
+func serviceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: bdx-exporter service install|uninstall|start|stop [flags] [-- serve flags]")
+		return 2
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("service "+action, flag.ExitOnError)
+	name := fs.String("name", programName, "Name of the Windows service and of its event log source")
+	displayName := fs.String("display-name", "BDX Exporter", "Display name of the service, for install")
+	fs.Parse(args[1:])
+
+	m, err := mgr.Connect()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to the service manager: %v\n", err)
+		return 1
+	}
+	defer m.Disconnect()
+
+	switch action {
+	case "install":
+		err = installService(m, *name, *displayName, fs.Args())
+	case "uninstall":
+		err = uninstallService(m, *name)
+	case "start":
+		err = controlService(m, *name, true)
+	case "stop":
+		err = controlService(m, *name, false)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown service action %q, must be install, uninstall, start or stop\n", action)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to %s service %s: %v\n", action, *name, err)
+		return 1
+	}
+	fmt.Printf("Service %s: %s done\n", *name, action)
+	return 0
+}
+
+// installService registers the exporter as an automatically started service
+// that is restarted when it fails, with the serve flags in serveArgs
+func installService(m *mgr.Mgr, name, displayName string, serveArgs []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service already exists")
+	}
+
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: displayName,
+		Description: "Prometheus exporter for the BDX 360view portal",
+		StartType:   mgr.StartAutomatic,
+	}, append([]string{"serve"}, serveArgs...)...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	restart := mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: 10 * time.Second}
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{restart, restart, restart}, uint32((24 * time.Hour).Seconds())); err != nil {
+		return fmt.Errorf("failed to set recovery actions: %w", err)
+	}
+	if err := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf("failed to register event log source: %w", err)
+	}
+	return nil
+}
+
+// uninstallService removes the service and its event log source
+func uninstallService(m *mgr.Mgr, name string) error {
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	if err := eventlog.Remove(name); err != nil {
+		return fmt.Errorf("failed to remove event log source: %w", err)
+	}
+	return nil
+}
+
+// controlService starts the service, or stops it and waits until it stopped
+func controlService(m *mgr.Mgr, name string, start bool) error {
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if start {
+		return s.Start()
+	}
+
+	st, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(2 * time.Minute)
+	for st.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service did not stop within 2 minutes")
+		}
+		time.Sleep(500 * time.Millisecond)
+		if st, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/checkmk"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// checkmkSpool writes the local checks to the spool directory of a Checkmk
+// agent, which adds them to its output
+type checkmkSpool struct {
+	cfg config.CheckmkConfig
+	// file is named after its maximum age, so the agent drops the local
+	// checks once the exporter stops refreshing them
+	file string
+}
+
+func newCheckmkSpool(cfg config.CheckmkConfig, interval time.Duration) *checkmkSpool {
+	maxAge := int(math.Ceil(3 * interval.Seconds()))
+	return &checkmkSpool{cfg: cfg, file: fmt.Sprintf("%d_bdx_exporter", maxAge)}
+}
+
+// Name identifies the sink
+func (s *checkmkSpool) Name() string {
+	return "checkmk"
+}
+
+// Publish replaces the spool file with the local checks of the latest
+// collection
+func (s *checkmkSpool) Publish(ctx context.Context, col *collector.Collector) error {
+	values, err := col.Values(collector.ValueFilter{})
+	if err != nil {
+		return err
+	}
+	lines := checkmk.LocalChecks(col.Config().Checkmk.Levels, values, col.Targets())
+	data := "<<<local>>>\n" + strings.Join(lines, "\n") + "\n"
+
+	// The agent skips hidden files, so it never reads a partial file
+	tmp := filepath.Join(s.cfg.SpoolDir, "."+s.file)
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.cfg.SpoolDir, s.file)); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	return nil
+}
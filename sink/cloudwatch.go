@@ -0,0 +1,112 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// CloudWatch PutMetricData limits
+const (
+	cloudWatchBatchSize     = 1000
+	cloudWatchMaxDimensions = 30
+)
+
+// cloudWatch publishes the readings as CloudWatch custom metrics through the
+// PutMetricData query API
+type cloudWatch struct {
+	cfg    config.CloudWatchConfig
+	client *http.Client
+}
+
+// newCloudWatch creates a CloudWatch sink
+func newCloudWatch(cfg config.CloudWatchConfig, timeout time.Duration) *cloudWatch {
+	return &cloudWatch{cfg: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+// Name identifies the sink
+func (c *cloudWatch) Name() string {
+	return "cloudwatch"
+}
+
+// Publish sends the latest readings, in batches within the API limits
+func (c *cloudWatch) Publish(ctx context.Context, col *collector.Collector) error {
+	values, err := col.Values(collector.ValueFilter{})
+	if err != nil {
+		return err
+	}
+	collected, _ := col.GetHealthStatus()
+
+	for start := 0; start < len(values); start += cloudWatchBatchSize {
+		batch := values[start:min(start+cloudWatchBatchSize, len(values))]
+		if err := c.put(ctx, batch, collected); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put sends one PutMetricData request
+func (c *cloudWatch) put(ctx context.Context, values []collector.Value, timestamp time.Time) error {
+	form := url.Values{}
+	form.Set("Action", "PutMetricData")
+	form.Set("Version", "2010-08-01")
+	form.Set("Namespace", c.cfg.Namespace)
+	for i, v := range values {
+		member := "MetricData.member." + strconv.Itoa(i+1) + "."
+		form.Set(member+"MetricName", v.Metric)
+		form.Set(member+"Value", strconv.FormatFloat(v.Value, 'g', -1, 64))
+		form.Set(member+"Timestamp", timestamp.UTC().Format(time.RFC3339))
+		for j, name := range c.dimensions(v) {
+			dimension := member + "Dimensions.member." + strconv.Itoa(j+1) + "."
+			form.Set(dimension+"Name", name)
+			form.Set(dimension+"Value", v.Labels[name])
+		}
+	}
+	body := []byte(form.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.Endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signV4(req, body, awsCredentials{c.cfg.AccessKeyID, c.cfg.SecretAccessKey, c.cfg.SessionToken}, c.cfg.Region, "monitoring", time.Now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to put metric data: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to put metric data: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// dimensions returns the labels of a value used as dimensions: the
+// configured ones, or every label, skipping empty values
+func (c *cloudWatch) dimensions(v collector.Value) []string {
+	var names []string
+	for name, value := range v.Labels {
+		if value == "" || (len(c.cfg.Dimensions) > 0 && !slices.Contains(c.cfg.Dimensions, name)) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > cloudWatchMaxDimensions {
+		names = names[:cloudWatchMaxDimensions]
+	}
+	return names
+}
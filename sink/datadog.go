@@ -0,0 +1,142 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// datadogBatchSize keeps the submissions well below the 5 MB payload limit
+// of the series API
+const datadogBatchSize = 500
+
+// datadogGauge is the gauge type of the v2 series API
+const datadogGauge = 3
+
+// datadog submits the readings to the Datadog metrics API
+type datadog struct {
+	cfg    config.DatadogConfig
+	url    string
+	client *http.Client
+	// lastSubmit and retryAt throttle the submissions to DATADOG_INTERVAL
+	// and to the rate limit reported by Datadog
+	lastSubmit time.Time
+	retryAt    time.Time
+}
+
+type datadogSeries struct {
+	Metric string         `json:"metric"`
+	Type   int            `json:"type"`
+	Points []datadogPoint `json:"points"`
+	Tags   []string       `json:"tags,omitempty"`
+}
+
+type datadogPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+// newDatadog creates a Datadog sink
+func newDatadog(cfg config.DatadogConfig, timeout time.Duration) *datadog {
+	return &datadog{
+		cfg:    cfg,
+		url:    "https://api." + cfg.Site + "/api/v2/series",
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name identifies the sink
+func (d *datadog) Name() string {
+	return "datadog"
+}
+
+// Publish submits the latest readings as gauges, unless the last submission
+// is more recent than the configured interval
+func (d *datadog) Publish(ctx context.Context, col *collector.Collector) error {
+	now := time.Now()
+	if now.Before(d.retryAt) {
+		return fmt.Errorf("rate limited by Datadog until %s", d.retryAt.Format(time.RFC3339))
+	}
+	if now.Sub(d.lastSubmit) < d.cfg.Interval {
+		return nil
+	}
+
+	values, err := col.Values(collector.ValueFilter{})
+	if err != nil {
+		return err
+	}
+	collected, _ := col.GetHealthStatus()
+
+	series := make([]datadogSeries, len(values))
+	for i, v := range values {
+		series[i] = datadogSeries{
+			Metric: v.Metric,
+			Type:   datadogGauge,
+			Points: []datadogPoint{{Timestamp: collected.Unix(), Value: v.Value}},
+			Tags:   d.tags(v),
+		}
+	}
+	for start := 0; start < len(series); start += datadogBatchSize {
+		if err := d.submit(ctx, series[start:min(start+datadogBatchSize, len(series))]); err != nil {
+			return err
+		}
+	}
+	d.lastSubmit = now
+	return nil
+}
+
+// submit sends one batch of series
+func (d *datadog) submit(ctx context.Context, series []datadogSeries) error {
+	data, err := json.Marshal(map[string]any{"series": series})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", d.cfg.APIKey)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to submit series: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// Datadog reports the seconds until the rate limit window resets
+		reset, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Reset"))
+		if err != nil || reset <= 0 {
+			reset = 60
+		}
+		d.retryAt = time.Now().Add(time.Duration(reset) * time.Second)
+	}
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to submit series: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// tags converts the non-empty labels and the source of a value to Datadog
+// tags, followed by the configured tags
+func (d *datadog) tags(v collector.Value) []string {
+	tags := []string{"source:" + v.Source}
+	for name, value := range v.Labels {
+		if value != "" {
+			tags = append(tags, name+":"+value)
+		}
+	}
+	sort.Strings(tags)
+	return append(tags, d.cfg.Tags...)
+}
@@ -0,0 +1,145 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// gcmBatchSize is the maximum number of time series per request
+const gcmBatchSize = 200
+
+// googleCloudMonitoring writes the readings as Google Cloud Monitoring
+// custom metrics
+type googleCloudMonitoring struct {
+	cfg      config.GoogleCloudMonitoringConfig
+	url      string
+	resource gcmResource
+	tokens   *gcpTokenSource
+	client   *http.Client
+}
+
+type gcmTimeSeries struct {
+	Metric   gcmMetric   `json:"metric"`
+	Resource gcmResource `json:"resource"`
+	Points   []gcmPoint  `json:"points"`
+}
+
+type gcmMetric struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type gcmResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels"`
+}
+
+type gcmPoint struct {
+	Interval struct {
+		EndTime string `json:"endTime"`
+	} `json:"interval"`
+	Value struct {
+		DoubleValue float64 `json:"doubleValue"`
+	} `json:"value"`
+}
+
+// newGoogleCloudMonitoring creates a Google Cloud Monitoring sink
+func newGoogleCloudMonitoring(cfg config.GoogleCloudMonitoringConfig, timeout time.Duration) (*googleCloudMonitoring, error) {
+	client := &http.Client{Timeout: timeout}
+	tokens, err := newGCPTokenSource(cfg.CredentialsFile, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every monitored resource type has a project_id label
+	labels := map[string]string{"project_id": cfg.ProjectID}
+	maps.Copy(labels, cfg.ResourceLabels)
+
+	return &googleCloudMonitoring{
+		cfg:      cfg,
+		url:      "https://monitoring.googleapis.com/v3/projects/" + url.PathEscape(cfg.ProjectID) + "/timeSeries",
+		resource: gcmResource{Type: cfg.ResourceType, Labels: labels},
+		tokens:   tokens,
+		client:   client,
+	}, nil
+}
+
+// Name identifies the sink
+func (g *googleCloudMonitoring) Name() string {
+	return "google_cloud_monitoring"
+}
+
+// Publish writes the latest readings as gauge points
+func (g *googleCloudMonitoring) Publish(ctx context.Context, col *collector.Collector) error {
+	values, err := col.Values(collector.ValueFilter{})
+	if err != nil {
+		return err
+	}
+	collected, _ := col.GetHealthStatus()
+
+	series := make([]gcmTimeSeries, len(values))
+	for i, v := range values {
+		labels := make(map[string]string, len(v.Labels))
+		for name, value := range v.Labels {
+			if value != "" {
+				labels[strings.ToLower(name)] = value
+			}
+		}
+		point := gcmPoint{}
+		point.Interval.EndTime = collected.UTC().Format(time.RFC3339)
+		point.Value.DoubleValue = v.Value
+		series[i] = gcmTimeSeries{
+			Metric:   gcmMetric{Type: g.cfg.MetricPrefix + "/" + v.Metric, Labels: labels},
+			Resource: g.resource,
+			Points:   []gcmPoint{point},
+		}
+	}
+
+	for start := 0; start < len(series); start += gcmBatchSize {
+		if err := g.write(ctx, series[start:min(start+gcmBatchSize, len(series))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write sends one timeSeries.create request
+func (g *googleCloudMonitoring) write(ctx context.Context, series []gcmTimeSeries) error {
+	token, err := g.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(map[string]any{"timeSeries": series})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write time series: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to write time series: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
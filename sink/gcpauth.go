@@ -0,0 +1,166 @@
+package sink
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gcpMonitoringScope is the OAuth scope needed to write time series
+const gcpMonitoringScope = "https://www.googleapis.com/auth/monitoring.write"
+
+// gcpMetadataTokenURL returns the token of the default service account of a
+// GCE instance or GKE workload
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gcpServiceAccount is the part of a service account key file used to get
+// access tokens
+type gcpServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// gcpTokenSource gets OAuth access tokens from a service account key, or
+// from the metadata server without one, and caches them until shortly
+// before they expire
+type gcpTokenSource struct {
+	account *gcpServiceAccount
+	client  *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// newGCPTokenSource creates a token source from a service account key file,
+// or for the metadata server if path is empty
+func newGCPTokenSource(path string, client *http.Client) (*gcpTokenSource, error) {
+	ts := &gcpTokenSource{client: client}
+	if path == "" {
+		return ts, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key: %w", err)
+	}
+	account := &gcpServiceAccount{TokenURI: "https://oauth2.googleapis.com/token"}
+	if err := json.Unmarshal(data, account); err != nil {
+		return nil, fmt.Errorf("failed to parse service account key %s: %w", path, err)
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("no private key found in service account key %s", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key in service account key %s: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key in service account key %s is not an RSA key", path)
+	}
+	account.key = rsaKey
+	ts.account = account
+	return ts, nil
+}
+
+// Token returns a valid access token
+func (ts *gcpTokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != "" && time.Until(ts.expiry) > time.Minute {
+		return ts.token, nil
+	}
+
+	var req *http.Request
+	if ts.account == nil {
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataTokenURL, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to create request: %w", err)
+		}
+		r.Header.Set("Metadata-Flavor", "Google")
+		req = r
+	} else {
+		assertion, err := ts.account.assertion(time.Now())
+		if err != nil {
+			return "", err
+		}
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.account.TokenURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", fmt.Errorf("failed to create request: %w", err)
+		}
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = r
+	}
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("failed to get access token: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode access token: %w", err)
+	}
+	ts.token = token.AccessToken
+	ts.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return ts.token, nil
+}
+
+// assertion creates the signed JWT exchanged for an access token
+func (a *gcpServiceAccount) assertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   a.ClientEmail,
+		"scope": gcpMonitoringScope,
+		"aud":   a.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
@@ -0,0 +1,123 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+var graphiteInvalid = regexp.MustCompile(`[^a-zA-Z0-9_\-]`)
+
+// graphite sends the readings to Graphite over the plaintext or pickle
+// protocol
+type graphite struct {
+	cfg config.GraphiteConfig
+}
+
+// Name identifies the sink
+func (g *graphite) Name() string {
+	return "graphite"
+}
+
+// Publish sends the latest readings of the collector
+func (g *graphite) Publish(ctx context.Context, col *collector.Collector) error {
+	values, err := col.Values(collector.ValueFilter{})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	var buf bytes.Buffer
+	if g.cfg.Protocol == config.GraphitePickle {
+		writePickle(&buf, g.paths(values), values, now)
+	} else {
+		for i, path := range g.paths(values) {
+			fmt.Fprintf(&buf, "%s %g %d\n", path, values[i].Value, now)
+		}
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", g.cfg.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Graphite: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to send to Graphite: %w", err)
+	}
+	return nil
+}
+
+// paths returns the metric path of every value
+func (g *graphite) paths(values []collector.Value) []string {
+	paths := make([]string, len(values))
+	for i, v := range values {
+		paths[i] = graphitePath(g.cfg.Templates[v.Metric], v)
+	}
+	return paths
+}
+
+// graphitePath renders the template of a value. Without a template the path
+// is the metric name followed by the label values, ordered by label name.
+func graphitePath(template string, v collector.Value) string {
+	if template == "" {
+		names := make([]string, 0, len(v.Labels))
+		for name := range v.Labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		parts := []string{v.Metric}
+		for _, name := range names {
+			if v.Labels[name] != "" {
+				parts = append(parts, graphiteInvalid.ReplaceAllString(v.Labels[name], "_"))
+			}
+		}
+		return strings.Join(parts, ".")
+	}
+
+	return renderTemplate(template, func(name string) string {
+		if name == "metric" {
+			return v.Metric
+		}
+		return v.Labels[name]
+	}, graphiteInvalid)
+}
+
+// writePickle encodes the values as a Graphite pickle message: a length
+// prefixed pickle (protocol 2) of a list of (path, (timestamp, value)) tuples
+func writePickle(w *bytes.Buffer, paths []string, values []collector.Value, timestamp int64) {
+	var p bytes.Buffer
+	p.Write([]byte{0x80, 0x02}) // PROTO 2
+	p.WriteByte(']')            // EMPTY_LIST
+	p.WriteByte('(')            // MARK
+	for i, path := range paths {
+		p.WriteByte('X') // BINUNICODE
+		binary.Write(&p, binary.LittleEndian, uint32(len(path)))
+		p.WriteString(path)
+		p.WriteByte('J') // BININT
+		binary.Write(&p, binary.LittleEndian, int32(timestamp))
+		p.WriteByte('G') // BINFLOAT
+		binary.Write(&p, binary.BigEndian, math.Float64bits(values[i].Value))
+		p.WriteByte(0x86) // TUPLE2 (timestamp, value)
+		p.WriteByte(0x86) // TUPLE2 (path, datapoint)
+	}
+	p.WriteByte('e') // APPENDS
+	p.WriteByte('.') // STOP
+
+	binary.Write(w, binary.BigEndian, uint32(p.Len()))
+	w.Write(p.Bytes())
+}
@@ -0,0 +1,148 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// Avro schemas of the messages, registered by the REST Proxy
+const (
+	valueSchema = `{"type":"record","name":"Reading","namespace":"bdx","fields":[` +
+		`{"name":"time","type":"string"},{"name":"source","type":"string"},` +
+		`{"name":"target","type":"string"},{"name":"metric","type":"string"},` +
+		`{"name":"labels","type":{"type":"map","values":"string"}},{"name":"value","type":"double"}]}`
+	eventSchema = `{"type":"record","name":"AlarmEvent","namespace":"bdx","fields":[` +
+		`{"name":"time","type":"string"},{"name":"target","type":"string"},` +
+		`{"name":"item","type":"string"},{"name":"status","type":"string"},{"name":"state","type":"string"}]}`
+)
+
+// reading is a value message
+type reading struct {
+	Time   string            `json:"time"`
+	Source string            `json:"source"`
+	Target string            `json:"target"`
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// alarmEvent is an alarm event message
+type alarmEvent struct {
+	Time   string `json:"time"`
+	Target string `json:"target"`
+	Item   string `json:"item"`
+	Status string `json:"status"`
+	State  string `json:"state"`
+}
+
+// kafkaRecord is a record of a REST Proxy produce request
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// kafka produces readings and alarm events to Kafka topics through the
+// Confluent REST Proxy (v2 API), keyed by target
+type kafka struct {
+	cfg       config.KafkaConfig
+	client    *http.Client
+	lastEvent time.Time
+}
+
+// newKafka creates a Kafka sink
+func newKafka(cfg config.KafkaConfig, timeout time.Duration) *kafka {
+	// Only alarm events after startup are published
+	return &kafka{cfg: cfg, client: &http.Client{Timeout: timeout}, lastEvent: time.Now()}
+}
+
+// Name identifies the sink
+func (k *kafka) Name() string {
+	return "kafka"
+}
+
+// Publish produces the latest readings and the alarm events since the last
+// publish
+func (k *kafka) Publish(ctx context.Context, col *collector.Collector) error {
+	if k.cfg.ValuesTopic != "" {
+		values, err := col.Values(collector.ValueFilter{})
+		if err != nil {
+			return err
+		}
+		collected, _ := col.GetHealthStatus()
+		records := make([]kafkaRecord, len(values))
+		for i, v := range values {
+			records[i] = kafkaRecord{Key: v.Target, Value: reading{
+				Time: collected.Format(time.RFC3339), Source: v.Source, Target: v.Target,
+				Metric: v.Metric, Labels: v.Labels, Value: v.Value,
+			}}
+		}
+		if err := k.produce(ctx, k.cfg.ValuesTopic, valueSchema, records); err != nil {
+			return err
+		}
+	}
+
+	if k.cfg.EventsTopic != "" {
+		events := col.Events("", k.lastEvent)
+		records := make([]kafkaRecord, len(events))
+		for i, e := range events {
+			records[i] = kafkaRecord{Key: e.Target, Value: alarmEvent{
+				Time: e.Time.Format(time.RFC3339), Target: e.Target, Item: e.Item, Status: e.Status, State: e.State,
+			}}
+		}
+		if err := k.produce(ctx, k.cfg.EventsTopic, eventSchema, records); err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			k.lastEvent = events[len(events)-1].Time
+		}
+	}
+	return nil
+}
+
+// produce sends records to a topic
+func (k *kafka) produce(ctx context.Context, topic, schema string, records []kafkaRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	body := map[string]any{"records": records}
+	contentType := "application/vnd.kafka.json.v2+json"
+	if k.cfg.Format == config.KafkaAvro {
+		body["key_schema"] = `"string"`
+		body["value_schema"] = schema
+		contentType = "application/vnd.kafka.avro.v2+json"
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	u := strings.TrimSuffix(k.cfg.RESTURL, "/") + "/topics/" + url.PathEscape(topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to produce to %s: %w", topic, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to produce to %s: %s: %s", topic, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
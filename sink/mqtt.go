@@ -0,0 +1,308 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// mqttInvalid matches the characters that can't appear in a topic level
+var mqttInvalid = regexp.MustCompile(`[/+#\x00]`)
+
+// mqttMessage is a message to publish
+type mqttMessage struct {
+	topic   string
+	payload []byte
+}
+
+// mqtt publishes readings and alarm events to an MQTT broker. It speaks the
+// subset of MQTT 3.1.1 needed to publish at QoS 0 or 1, connecting once per
+// collection cycle.
+type mqtt struct {
+	cfg       config.MQTTConfig
+	lastEvent time.Time
+}
+
+// newMQTT creates an MQTT sink
+func newMQTT(cfg config.MQTTConfig) *mqtt {
+	// Only alarm events after startup are published
+	return &mqtt{cfg: cfg, lastEvent: time.Now()}
+}
+
+// Name identifies the sink
+func (m *mqtt) Name() string {
+	return "mqtt"
+}
+
+// Publish publishes the latest readings and the alarm events since the last
+// publish
+func (m *mqtt) Publish(ctx context.Context, col *collector.Collector) error {
+	var messages []mqttMessage
+
+	if m.cfg.ValuesTopic != "" {
+		values, err := col.Values(collector.ValueFilter{})
+		if err != nil {
+			return err
+		}
+		collected, _ := col.GetHealthStatus()
+		for _, v := range values {
+			payload, err := json.Marshal(reading{
+				Time: collected.Format(time.RFC3339), Source: v.Source, Target: v.Target,
+				Metric: v.Metric, Labels: v.Labels, Value: v.Value,
+			})
+			if err != nil {
+				return err
+			}
+			topic := renderTemplate(m.cfg.ValuesTopic, func(name string) string {
+				switch name {
+				case "source":
+					return v.Source
+				case "target":
+					return v.Target
+				case "metric":
+					return v.Metric
+				}
+				return v.Labels[name]
+			}, mqttInvalid)
+			messages = append(messages, mqttMessage{topic, payload})
+		}
+	}
+
+	var events []collector.AlarmEvent
+	if m.cfg.EventsTopic != "" {
+		events = col.Events("", m.lastEvent)
+		for _, e := range events {
+			event := alarmEvent{Time: e.Time.Format(time.RFC3339), Target: e.Target, Item: e.Item, Status: e.Status, State: e.State}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			fields := map[string]string{"target": e.Target, "item": e.Item, "status": e.Status, "state": e.State}
+			topic := renderTemplate(m.cfg.EventsTopic, func(name string) string { return fields[name] }, mqttInvalid)
+			messages = append(messages, mqttMessage{topic, payload})
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+	if err := m.send(ctx, messages); err != nil {
+		return err
+	}
+	if len(events) > 0 {
+		m.lastEvent = events[len(events)-1].Time
+	}
+	return nil
+}
+
+// send connects to the broker, publishes the messages and disconnects
+func (m *mqtt) send(ctx context.Context, messages []mqttMessage) error {
+	u, err := url.Parse(m.cfg.URL)
+	if err != nil {
+		return fmt.Errorf("invalid MQTT_URL: %w", err)
+	}
+
+	var d net.Dialer
+	var conn net.Conn
+	if u.Scheme == "ssl" || u.Scheme == "mqtts" {
+		td := tls.Dialer{NetDialer: &d, Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = td.DialContext(ctx, "tcp", u.Host)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", u.Host)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(conn)
+
+	// CONNECT with a clean session, then wait for CONNACK
+	var connect bytes.Buffer
+	flags := byte(0x02)
+	writeMQTTString(&connect, "MQTT")
+	connect.WriteByte(4) // protocol level 3.1.1
+	if u.User != nil {
+		flags |= 0x80
+		if _, ok := u.User.Password(); ok {
+			flags |= 0x40
+		}
+	}
+	connect.WriteByte(flags)
+	binary.Write(&connect, binary.BigEndian, uint16(60)) // keep alive
+	writeMQTTString(&connect, m.cfg.ClientID)
+	if u.User != nil {
+		writeMQTTString(&connect, u.User.Username())
+		if password, ok := u.User.Password(); ok {
+			writeMQTTString(&connect, password)
+		}
+	}
+	if err := writeMQTTPacket(conn, 0x10, connect.Bytes()); err != nil {
+		return fmt.Errorf("failed to send MQTT CONNECT: %w", err)
+	}
+	packetType, body, err := readMQTTPacket(r)
+	if err != nil {
+		return fmt.Errorf("failed to read MQTT CONNACK: %w", err)
+	}
+	if packetType != 0x20 || len(body) != 2 {
+		return fmt.Errorf("unexpected MQTT packet 0x%x instead of CONNACK", packetType)
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("MQTT broker refused the connection with return code %d", body[1])
+	}
+
+	// PUBLISH every message, then wait for the PUBACKs at QoS 1. The batch
+	// only counts as delivered when every packet identifier is acknowledged.
+	header := byte(0x30) | byte(m.cfg.QoS)<<1
+	if m.cfg.Retain {
+		header |= 0x01
+	}
+	session := newMQTTSession(r)
+	for _, msg := range messages {
+		var publish bytes.Buffer
+		writeMQTTString(&publish, msg.topic)
+		if m.cfg.QoS > 0 {
+			// Every identifier is in flight, wait for one to be released
+			if len(session.inflight) == mqttMaxInflight {
+				if err := session.awaitAck(); err != nil {
+					return err
+				}
+			}
+			binary.Write(&publish, binary.BigEndian, session.nextID(msg.topic))
+		}
+		publish.Write(msg.payload)
+		if err := writeMQTTPacket(conn, header, publish.Bytes()); err != nil {
+			return fmt.Errorf("failed to publish to %s: %w", msg.topic, err)
+		}
+	}
+	for len(session.inflight) > 0 {
+		if err := session.awaitAck(); err != nil {
+			return err
+		}
+	}
+
+	return writeMQTTPacket(conn, 0xE0, nil)
+}
+
+// mqttMaxInflight is the number of QoS 1 packet identifiers, 1 to 65535,
+// that can be awaiting a PUBACK at the same time
+const mqttMaxInflight = 65535
+
+// mqttSession tracks the QoS 1 packet identifiers of one connection that
+// haven't been acknowledged yet
+type mqttSession struct {
+	r        *bufio.Reader
+	lastID   uint16
+	inflight map[uint16]string // packet identifier to topic
+}
+
+// newMQTTSession creates the session of a connection reading from r
+func newMQTTSession(r *bufio.Reader) *mqttSession {
+	return &mqttSession{r: r, inflight: make(map[uint16]string)}
+}
+
+// nextID allocates the next packet identifier that isn't in flight for a
+// message to topic. The caller makes sure one is free.
+func (s *mqttSession) nextID(topic string) uint16 {
+	for {
+		s.lastID++
+		if s.lastID == 0 {
+			s.lastID = 1
+		}
+		if _, ok := s.inflight[s.lastID]; !ok {
+			s.inflight[s.lastID] = topic
+			return s.lastID
+		}
+	}
+}
+
+// awaitAck reads packets until a PUBACK arrives and releases its packet
+// identifier. A PUBACK for an identifier that isn't in flight is an error.
+func (s *mqttSession) awaitAck() error {
+	for {
+		packetType, body, err := readMQTTPacket(s.r)
+		if err != nil {
+			return fmt.Errorf("failed to read MQTT PUBACK: %w", err)
+		}
+		if packetType != 0x40 {
+			continue
+		}
+		if len(body) != 2 {
+			return fmt.Errorf("malformed MQTT PUBACK")
+		}
+		id := binary.BigEndian.Uint16(body)
+		if _, ok := s.inflight[id]; !ok {
+			return fmt.Errorf("MQTT PUBACK for packet identifier %d that isn't in flight", id)
+		}
+		delete(s.inflight, id)
+		return nil
+	}
+}
+
+// writeMQTTString writes a length prefixed UTF-8 string
+func writeMQTTString(w *bytes.Buffer, s string) {
+	binary.Write(w, binary.BigEndian, uint16(len(s)))
+	w.WriteString(s)
+}
+
+// writeMQTTPacket writes a packet with its fixed header
+func writeMQTTPacket(w io.Writer, header byte, body []byte) error {
+	packet := []byte{header}
+	// Remaining length, 7 bits per byte
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(packet, body...))
+	return err
+}
+
+// readMQTTPacket reads a packet and returns its type and body
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, multiplier := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+		if multiplier > 128*128*128 {
+			return 0, nil, fmt.Errorf("malformed remaining length")
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header & 0xf0, body, nil
+}
@@ -0,0 +1,132 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// mqttBroker accepts one connection, reads the CONNECT and n PUBLISH
+// packets and answers them with PUBACKs for the identifiers acks returns
+func mqttBroker(t *testing.T, ln net.Listener, n int, acks func(ids []uint16) []uint16) {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	if typ, _, err := readMQTTPacket(r); err != nil || typ != 0x10 {
+		t.Errorf("expected CONNECT, got 0x%x: %v", typ, err)
+		return
+	}
+	if err := writeMQTTPacket(conn, 0x20, []byte{0, 0}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var ids []uint16
+	for range n {
+		typ, body, err := readMQTTPacket(r)
+		if err != nil || typ != 0x30 {
+			t.Errorf("expected PUBLISH, got 0x%x: %v", typ, err)
+			return
+		}
+		topicLen := int(binary.BigEndian.Uint16(body))
+		ids = append(ids, binary.BigEndian.Uint16(body[2+topicLen:]))
+	}
+	for _, id := range acks(ids) {
+		if err := writeMQTTPacket(conn, 0x40, binary.BigEndian.AppendUint16(nil, id)); err != nil {
+			return
+		}
+	}
+	// Wait briefly for the DISCONNECT, then hang up on a client still
+	// waiting for an acknowledgement
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	readMQTTPacket(r)
+}
+
+func TestMQTTSendAcknowledgements(t *testing.T) {
+	tests := []struct {
+		name    string
+		acks    func(ids []uint16) []uint16
+		wantErr bool
+	}{
+		{
+			name: "in order",
+			acks: func(ids []uint16) []uint16 { return ids },
+		},
+		{
+			name: "out of order",
+			acks: func(ids []uint16) []uint16 {
+				reversed := slices.Clone(ids)
+				slices.Reverse(reversed)
+				return reversed
+			},
+		},
+		{
+			name:    "one missing",
+			acks:    func(ids []uint16) []uint16 { return ids[1:] },
+			wantErr: true,
+		},
+		{
+			name:    "duplicate instead of the last",
+			acks:    func(ids []uint16) []uint16 { return append(slices.Clone(ids[:len(ids)-1]), ids[0]) },
+			wantErr: true,
+		},
+		{
+			name:    "unknown identifier",
+			acks:    func(ids []uint16) []uint16 { return append([]uint16{999}, ids...) },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+
+			messages := []mqttMessage{{"a", []byte("1")}, {"b", []byte("2")}, {"c", []byte("3")}}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				mqttBroker(t, ln, len(messages), tt.acks)
+			}()
+
+			m := newMQTT(config.MQTTConfig{URL: "tcp://" + ln.Addr().String(), ClientID: "test", QoS: 1})
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err = m.send(ctx, messages)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			<-done
+		})
+	}
+}
+
+func TestMQTTSessionNextID(t *testing.T) {
+	s := newMQTTSession(nil)
+	s.lastID = 65534
+	s.inflight[65535] = "a"
+	s.inflight[1] = "b"
+
+	// 65535 and 1 are in flight and 0 is not a valid identifier
+	if id := s.nextID("c"); id != 2 {
+		t.Errorf("nextID() = %d, want 2", id)
+	}
+	if _, ok := s.inflight[2]; !ok {
+		t.Error("nextID() did not mark 2 as in flight")
+	}
+}
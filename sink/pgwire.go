@@ -0,0 +1,396 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pgConn is a minimal PostgreSQL frontend/backend protocol (v3) client. It
+// only runs simple queries and ignores their results, which is all the
+// Postgres sink needs.
+type pgConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// pgError is an ErrorResponse of the server
+type pgError struct {
+	Severity string
+	Code     string
+	Message  string
+}
+
+func (e *pgError) Error() string {
+	return fmt.Sprintf("%s: %s (SQLSTATE %s)", e.Severity, e.Message, e.Code)
+}
+
+// pgConnect connects and authenticates to the server of a postgres:// URL
+func pgConnect(ctx context.Context, dsn string) (*pgConn, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid POSTGRES_DSN")
+	}
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "5432"
+	}
+	user := u.User.Username()
+	password, _ := u.User.Password()
+	database := strings.TrimPrefix(u.Path, "/")
+	if database == "" {
+		database = user
+	}
+	sslmode := u.Query().Get("sslmode")
+	if sslmode == "" {
+		sslmode = "prefer"
+	}
+	var tlsConfig *tls.Config
+	if sslmode != "disable" {
+		if tlsConfig, err = pgTLSConfig(host, sslmode, u.Query().Get("sslrootcert")); err != nil {
+			return nil, err
+		}
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c := &pgConn{conn: conn, r: bufio.NewReader(conn)}
+	if tlsConfig != nil {
+		if err := c.startTLS(tlsConfig, sslmode); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err := c.startup(user, password, database); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	c.conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// pgTLSConfig returns the TLS configuration of an sslmode with the libpq
+// semantics: require encrypts without checking the server certificate,
+// verify-ca checks that a trusted CA signed it and verify-full also checks
+// that it names host. rootCert is a PEM file of the trusted CAs, the system
+// ones when empty.
+func pgTLSConfig(host, sslmode, rootCert string) (*tls.Config, error) {
+	var roots *x509.CertPool
+	if rootCert != "" {
+		pem, err := os.ReadFile(rootCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sslrootcert: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in sslrootcert %s", rootCert)
+		}
+	}
+
+	switch sslmode {
+	case "prefer", "require":
+		return &tls.Config{ServerName: host, InsecureSkipVerify: true}, nil
+	case "verify-ca":
+		// The hostname check of crypto/tls can't be turned off alone, so the
+		// chain is verified here instead
+		return &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				if len(cs.PeerCertificates) == 0 {
+					return errors.New("server sent no certificate")
+				}
+				opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+				for _, cert := range cs.PeerCertificates[1:] {
+					opts.Intermediates.AddCert(cert)
+				}
+				_, err := cs.PeerCertificates[0].Verify(opts)
+				return err
+			},
+		}, nil
+	case "verify-full":
+		return &tls.Config{ServerName: host, RootCAs: roots}, nil
+	}
+	return nil, fmt.Errorf("unsupported sslmode %q", sslmode)
+}
+
+// startTLS upgrades the connection with an SSLRequest
+func (c *pgConn) startTLS(config *tls.Config, sslmode string) error {
+	var req [8]byte
+	binary.BigEndian.PutUint32(req[0:], 8)
+	binary.BigEndian.PutUint32(req[4:], 80877103)
+	if _, err := c.conn.Write(req[:]); err != nil {
+		return err
+	}
+	answer, err := c.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if answer != 'S' {
+		if sslmode == "prefer" {
+			return nil
+		}
+		return fmt.Errorf("server does not support TLS")
+	}
+
+	tlsConn := tls.Client(c.conn, config)
+	c.conn = tlsConn
+	c.r = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// startup sends the startup message and answers the authentication
+// requests until the server is ready for queries
+func (c *pgConn) startup(user, password, database string) error {
+	var params []byte
+	for _, p := range []string{"user", user, "database", database, "application_name", "bdx_exporter"} {
+		params = append(params, p...)
+		params = append(params, 0)
+	}
+	params = append(params, 0)
+	msg := binary.BigEndian.AppendUint32(nil, uint32(8+len(params)))
+	msg = binary.BigEndian.AppendUint32(msg, 196608)
+	if _, err := c.conn.Write(append(msg, params...)); err != nil {
+		return err
+	}
+
+	var scram *scramClient
+	for {
+		typ, body, err := c.receive()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case 'R':
+			if len(body) < 4 {
+				return fmt.Errorf("malformed authentication request")
+			}
+			switch code := binary.BigEndian.Uint32(body); code {
+			case 0: // AuthenticationOk
+			case 3: // AuthenticationCleartextPassword
+				err = c.send('p', append([]byte(password), 0))
+			case 5: // AuthenticationMD5Password
+				if len(body) < 8 {
+					return fmt.Errorf("malformed MD5 authentication request")
+				}
+				inner := md5.Sum([]byte(password + user))
+				outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), body[4:8]...))
+				err = c.send('p', append([]byte("md5"+hex.EncodeToString(outer[:])), 0))
+			case 10: // AuthenticationSASL
+				if !strings.Contains(string(body[4:]), "SCRAM-SHA-256\x00") {
+					return fmt.Errorf("server requires an unsupported SASL mechanism")
+				}
+				scram = newSCRAMClient(password)
+				first := scram.clientFirst()
+				data := append([]byte("SCRAM-SHA-256\x00"), binary.BigEndian.AppendUint32(nil, uint32(len(first)))...)
+				err = c.send('p', append(data, first...))
+			case 11: // AuthenticationSASLContinue
+				if scram == nil {
+					return fmt.Errorf("unexpected SASL continue")
+				}
+				var final string
+				if final, err = scram.clientFinal(string(body[4:])); err == nil {
+					err = c.send('p', []byte(final))
+				}
+			case 12: // AuthenticationSASLFinal
+				if scram == nil {
+					return fmt.Errorf("unexpected SASL final")
+				}
+				err = scram.verifyServer(string(body[4:]))
+			default:
+				return fmt.Errorf("unsupported authentication method %d", code)
+			}
+			if err != nil {
+				return err
+			}
+		case 'E':
+			return parsePGError(body)
+		case 'Z':
+			return nil
+		}
+	}
+}
+
+// exec runs a simple query and waits until the server is ready again
+func (c *pgConn) exec(ctx context.Context, query string) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+		defer c.conn.SetDeadline(time.Time{})
+	}
+	if err := c.send('Q', append([]byte(query), 0)); err != nil {
+		return err
+	}
+
+	var queryErr error
+	for {
+		typ, body, err := c.receive()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case 'E':
+			queryErr = parsePGError(body)
+		case 'Z':
+			return queryErr
+		}
+	}
+}
+
+// close terminates the session
+func (c *pgConn) close() {
+	c.send('X', nil)
+	c.conn.Close()
+}
+
+// send writes a message
+func (c *pgConn) send(typ byte, body []byte) error {
+	msg := binary.BigEndian.AppendUint32([]byte{typ}, uint32(4+len(body)))
+	_, err := c.conn.Write(append(msg, body...))
+	return err
+}
+
+// receive reads a message
+func (c *pgConn) receive() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size < 4 || size > 1<<24 {
+		return 0, nil, fmt.Errorf("invalid message length %d", size)
+	}
+	body := make([]byte, size-4)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], body, nil
+}
+
+// parsePGError decodes the fields of an ErrorResponse
+func parsePGError(body []byte) error {
+	e := &pgError{}
+	for len(body) > 1 {
+		field := body[0]
+		value, rest, _ := strings.Cut(string(body[1:]), "\x00")
+		body = []byte(rest)
+		switch field {
+		case 'S':
+			e.Severity = value
+		case 'C':
+			e.Code = value
+		case 'M':
+			e.Message = value
+		}
+	}
+	return e
+}
+
+// pgQuoteIdent quotes an identifier such as a table name
+func pgQuoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// pgQuoteLiteral quotes a string constant, assuming
+// standard_conforming_strings, the default since PostgreSQL 9.1
+func pgQuoteLiteral(s string) string {
+	s = strings.ReplaceAll(s, "\x00", "")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// scramClient implements SCRAM-SHA-256 authentication (RFC 7677) as used by
+// PostgreSQL, which ignores the user name of the SCRAM messages
+type scramClient struct {
+	password    string
+	nonce       string
+	firstBare   string
+	authMessage string
+	saltedKey   []byte
+}
+
+func newSCRAMClient(password string) *scramClient {
+	return &scramClient{password: password, nonce: rand.Text()}
+}
+
+// clientFirst returns the client-first-message
+func (s *scramClient) clientFirst() string {
+	s.firstBare = "n=,r=" + s.nonce
+	return "n,," + s.firstBare
+}
+
+// clientFinal returns the client-final-message answering the
+// server-first-message
+func (s *scramClient) clientFinal(serverFirst string) (string, error) {
+	var nonce, salt string
+	var iterations int
+	for _, attr := range strings.Split(serverFirst, ",") {
+		key, value, _ := strings.Cut(attr, "=")
+		switch key {
+		case "r":
+			nonce = value
+		case "s":
+			salt = value
+		case "i":
+			iterations, _ = strconv.Atoi(value)
+		}
+	}
+	if !strings.HasPrefix(nonce, s.nonce) || salt == "" || iterations < 1 {
+		return "", fmt.Errorf("invalid SCRAM server-first-message")
+	}
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return "", fmt.Errorf("invalid SCRAM salt: %w", err)
+	}
+
+	s.saltedKey, err = pbkdf2.Key(sha256.New, s.password, saltBytes, iterations, sha256.Size)
+	if err != nil {
+		return "", err
+	}
+	withoutProof := "c=biws,r=" + nonce
+	s.authMessage = s.firstBare + "," + serverFirst + "," + withoutProof
+
+	clientKey := hmacSHA256(s.saltedKey, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	proof := hmacSHA256(storedKey[:], s.authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verifyServer checks the server signature of the server-final-message
+func (s *scramClient) verifyServer(serverFinal string) error {
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(serverFinal, "v="))
+	if err != nil || !strings.HasPrefix(serverFinal, "v=") {
+		return fmt.Errorf("invalid SCRAM server-final-message")
+	}
+	serverKey := hmacSHA256(s.saltedKey, "Server Key")
+	if !hmac.Equal(signature, hmacSHA256(serverKey, s.authMessage)) {
+		return errors.New("SCRAM server signature mismatch")
+	}
+	return nil
+}
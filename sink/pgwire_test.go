@@ -0,0 +1,278 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pgMessage is a message of a recorded exchange. The startup message has no
+// type, typ is 0 for it.
+type pgMessage struct {
+	typ  byte
+	body string
+}
+
+// pgStep is a message the client is expected to send and the answers of
+// the server to it
+type pgStep struct {
+	want    pgMessage
+	replies []pgMessage
+}
+
+// pgInt32 encodes n as the big-endian integer of the protocol
+func pgInt32(n uint32) string {
+	return string(binary.BigEndian.AppendUint32(nil, n))
+}
+
+// playServer plays the server side of a recorded exchange on conn
+func playServer(conn net.Conn, steps []pgStep) error {
+	r := bufio.NewReader(conn)
+	for i, step := range steps {
+		var typ byte
+		if step.want.typ != 0 {
+			var err error
+			if typ, err = r.ReadByte(); err != nil {
+				return fmt.Errorf("step %d: %w", i, err)
+			}
+		}
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+		body := make([]byte, binary.BigEndian.Uint32(size[:])-4)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+		if got := (pgMessage{typ, string(body)}); got != step.want {
+			return fmt.Errorf("step %d: got %q, want %q", i, got, step.want)
+		}
+		for _, reply := range step.replies {
+			msg := binary.BigEndian.AppendUint32([]byte{reply.typ}, uint32(4+len(reply.body)))
+			if _, err := conn.Write(append(msg, reply.body...)); err != nil {
+				return fmt.Errorf("step %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func TestPGConnMD5AndQueries(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	ready := pgMessage{'Z', "I"}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- playServer(server, []pgStep{
+			{
+				want:    pgMessage{0, pgInt32(196608) + "user\x00bdx\x00database\x00facility\x00application_name\x00bdx_exporter\x00\x00"},
+				replies: []pgMessage{{'R', pgInt32(5) + "\x01\x02\x03\x04"}},
+			},
+			{
+				want:    pgMessage{'p', "md5d336486a7cebb90fa7481be817935946\x00"},
+				replies: []pgMessage{{'R', pgInt32(0)}, {'S', "server_version\x0016.2\x00"}, {'K', pgInt32(1) + pgInt32(2)}, ready},
+			},
+			{
+				want:    pgMessage{'Q', "INSERT INTO t VALUES (1)\x00"},
+				replies: []pgMessage{{'C', "INSERT 0 1\x00"}, ready},
+			},
+			{
+				want:    pgMessage{'Q', "INSRT\x00"},
+				replies: []pgMessage{{'E', "SERROR\x00C42601\x00Msyntax error at or near \"INSRT\"\x00\x00"}, ready},
+			},
+		})
+	}()
+
+	c := &pgConn{conn: client, r: bufio.NewReader(client)}
+	if err := c.startup("bdx", "secret", "facility"); err != nil {
+		t.Fatalf("startup: %v", err)
+	}
+	ctx := context.Background()
+	if err := c.exec(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	err := c.exec(ctx, "INSRT")
+	var pgErr *pgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "42601" || pgErr.Severity != "ERROR" {
+		t.Fatalf("exec error = %v, want SQLSTATE 42601", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPGConnAuthenticationError(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go playServer(server, []pgStep{
+		{
+			want:    pgMessage{0, pgInt32(196608) + "user\x00bdx\x00database\x00bdx\x00application_name\x00bdx_exporter\x00\x00"},
+			replies: []pgMessage{{'R', pgInt32(3)}},
+		},
+		{
+			want:    pgMessage{'p', "wrong\x00"},
+			replies: []pgMessage{{'E', "SFATAL\x00C28P01\x00Mpassword authentication failed for user \"bdx\"\x00\x00"}},
+		},
+	})
+
+	c := &pgConn{conn: client, r: bufio.NewReader(client)}
+	err := c.startup("bdx", "wrong", "bdx")
+	var pgErr *pgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+		t.Fatalf("startup error = %v, want SQLSTATE 28P01", err)
+	}
+}
+
+// TestSCRAMClient checks the exchange of RFC 7677, section 3. PostgreSQL
+// leaves the user name out of the SCRAM messages, the RFC has it.
+func TestSCRAMClient(t *testing.T) {
+	s := &scramClient{password: "pencil", nonce: "rOprNGfwEbeRWgbNEkqO"}
+	if got, want := s.clientFirst(), "n,,n=,r=rOprNGfwEbeRWgbNEkqO"; got != want {
+		t.Errorf("clientFirst() = %q, want %q", got, want)
+	}
+	s.firstBare = "n=user,r=rOprNGfwEbeRWgbNEkqO"
+
+	final, err := s.clientFinal("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="; final != want {
+		t.Errorf("clientFinal() = %q, want %q", final, want)
+	}
+	if err := s.verifyServer("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="); err != nil {
+		t.Errorf("verifyServer() = %v", err)
+	}
+	if err := s.verifyServer("v=AAAATRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="); err == nil {
+		t.Error("verifyServer() accepted a wrong signature")
+	}
+
+	if _, err := s.clientFinal("r=someoneelse,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"); err == nil {
+		t.Error("clientFinal() accepted a nonce not extending the client one")
+	}
+}
+
+// testCA creates a CA and a server certificate it signs for db.internal
+func testCA(t *testing.T) (caPEM []byte, server tls.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "db.internal"},
+		DNSNames:     []string{"db.internal"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestPGTLSConfig(t *testing.T) {
+	caPEM, cert := testCA(t)
+	dir := t.TempDir()
+	trusted := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(trusted, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	otherPEM, _ := testCA(t)
+	untrusted := filepath.Join(dir, "other.pem")
+	if err := os.WriteFile(untrusted, otherPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host, sslmode, rootCert string
+		ok                      bool
+	}{
+		{"10.0.0.5", "require", untrusted, true},
+		{"10.0.0.5", "verify-ca", trusted, true},
+		{"10.0.0.5", "verify-ca", untrusted, false},
+		{"db.internal", "verify-full", trusted, true},
+		{"10.0.0.5", "verify-full", trusted, false},
+		{"db.internal", "verify-full", untrusted, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sslmode+"/"+tt.host+"/"+filepath.Base(tt.rootCert), func(t *testing.T) {
+			config, err := pgTLSConfig(tt.host, tt.sslmode, tt.rootCert)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A listener rather than net.Pipe, whose writes block until read,
+			// as both ends write at once when the handshake fails
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+			}()
+			client, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			client.SetDeadline(time.Now().Add(10 * time.Second))
+			err = tls.Client(client, config).Handshake()
+			if (err == nil) != tt.ok {
+				t.Errorf("handshake error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+
+	if _, err := pgTLSConfig("db.internal", "verify-everything", ""); err == nil || !strings.Contains(err.Error(), "sslmode") {
+		t.Errorf("unknown sslmode error = %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// postgres inserts the readings of every cycle into a PostgreSQL table,
+// which is turned into a hypertable when TimescaleDB is installed
+type postgres struct {
+	cfg   config.PostgresConfig
+	table string
+	conn  *pgConn
+}
+
+// newPostgres creates a PostgreSQL sink. It connects on the first publish.
+func newPostgres(cfg config.PostgresConfig) *postgres {
+	return &postgres{
+		cfg:   cfg,
+		table: pgQuoteIdent(cfg.Schema) + "." + pgQuoteIdent(cfg.Table),
+	}
+}
+
+// Name identifies the sink
+func (p *postgres) Name() string {
+	return "postgres"
+}
+
+// Publish inserts the latest readings in one transaction. The connection is
+// kept between cycles and reopened after an error.
+func (p *postgres) Publish(ctx context.Context, col *collector.Collector) error {
+	values, err := col.Values(collector.ValueFilter{})
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	collected, _ := col.GetHealthStatus()
+
+	if p.conn == nil {
+		conn, err := pgConnect(ctx, p.cfg.DSN)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		if err := p.createTable(ctx, conn); err != nil {
+			conn.close()
+			return fmt.Errorf("failed to create table %s: %w", p.table, err)
+		}
+		p.conn = conn
+	}
+
+	if err := p.insert(ctx, values, collected); err != nil {
+		p.conn.close()
+		p.conn = nil
+		return err
+	}
+	return nil
+}
+
+// createTable creates the table if it does not exist yet
+func (p *postgres) createTable(ctx context.Context, conn *pgConn) error {
+	err := conn.exec(ctx, `CREATE TABLE IF NOT EXISTS `+p.table+` (
+	time timestamptz NOT NULL,
+	source text NOT NULL,
+	target text NOT NULL,
+	metric text NOT NULL,
+	labels jsonb NOT NULL,
+	value double precision NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `+pgQuoteIdent(p.cfg.Table+"_metric_time_idx")+` ON `+p.table+` (metric, time DESC);
+DO $bdx$ BEGIN
+	IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+		PERFORM create_hypertable(`+pgQuoteLiteral(p.table)+`, 'time', if_not_exists => TRUE, migrate_data => TRUE);
+	END IF;
+END $bdx$`)
+	return err
+}
+
+// insert writes the values in batches of BatchSize rows
+func (p *postgres) insert(ctx context.Context, values []collector.Value, timestamp time.Time) error {
+	if err := p.conn.exec(ctx, "BEGIN"); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	ts := pgQuoteLiteral(timestamp.UTC().Format(time.RFC3339Nano))
+	for start := 0; start < len(values); start += p.cfg.BatchSize {
+		var query strings.Builder
+		query.WriteString("INSERT INTO " + p.table + " (time, source, target, metric, labels, value) VALUES ")
+		for i, v := range values[start:min(start+p.cfg.BatchSize, len(values))] {
+			labels, err := json.Marshal(v.Labels)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			fmt.Fprintf(&query, "(%s, %s, %s, %s, %s::jsonb, %s)",
+				ts, pgQuoteLiteral(v.Source), pgQuoteLiteral(v.Target), pgQuoteLiteral(v.Metric),
+				pgQuoteLiteral(string(labels)), pgFloat(v.Value))
+		}
+		if err := p.conn.exec(ctx, query.String()); err != nil {
+			return fmt.Errorf("failed to insert into %s: %w", p.table, err)
+		}
+	}
+
+	if err := p.conn.exec(ctx, "COMMIT"); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// pgFloat formats a float constant, including NaN and infinities
+func pgFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "'NaN'::float8"
+	case math.IsInf(f, 1):
+		return "'Infinity'::float8"
+	case math.IsInf(f, -1):
+		return "'-Infinity'::float8"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,58 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// pushgateway pushes the metrics to a Prometheus Pushgateway, replacing the
+// metrics of its grouping key every time
+type pushgateway struct {
+	cfg    config.PushgatewayConfig
+	url    string
+	user   *url.Userinfo
+	client *http.Client
+}
+
+// newPushgateway creates a Pushgateway sink. Credentials in the URL are used
+// for basic authentication.
+func newPushgateway(cfg config.PushgatewayConfig, timeout time.Duration) (*pushgateway, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PUSHGATEWAY_URL: %w", err)
+	}
+	user := u.User
+	u.User = nil
+
+	return &pushgateway{
+		cfg:    cfg,
+		url:    u.String(),
+		user:   user,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name identifies the sink
+func (p *pushgateway) Name() string {
+	return "pushgateway"
+}
+
+// Publish pushes every metric of the collector
+func (p *pushgateway) Publish(ctx context.Context, col *collector.Collector) error {
+	pusher := push.New(p.url, p.cfg.Job).Gatherer(col.Gatherer()).Client(p.client)
+	for name, value := range p.cfg.GroupingKey {
+		pusher = pusher.Grouping(name, value)
+	}
+	if p.user != nil {
+		password, _ := p.user.Password()
+		pusher = pusher.BasicAuth(p.user.Username(), password)
+	}
+	return pusher.PushContext(ctx)
+}
@@ -0,0 +1,149 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// TimeSeries is a series of timestamped samples sent with remote_write
+type TimeSeries struct {
+	// Labels include the metric name as __name__
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// Sample is a value of a series at a point in time
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// RemoteWriter sends samples to a Prometheus remote_write endpoint, such as
+// Prometheus with --web.enable-remote-write-receiver, Mimir or
+// VictoriaMetrics. It speaks version 1 of the protocol: a snappy compressed
+// protobuf WriteRequest.
+type RemoteWriter struct {
+	url    string
+	user   *url.Userinfo
+	client *http.Client
+}
+
+// NewRemoteWriter creates a remote_write client. Credentials in the URL are
+// used for basic authentication.
+func NewRemoteWriter(rawURL string, timeout time.Duration) (*RemoteWriter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote_write URL: %w", err)
+	}
+	user := u.User
+	u.User = nil
+
+	return &RemoteWriter{
+		url:    u.String(),
+		user:   user,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Write sends the series in a single request. The samples of each series
+// must be in time order.
+func (w *RemoteWriter) Write(ctx context.Context, series []TimeSeries) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", w.url, bytes.NewReader(snappyEncode(encodeWriteRequest(series))))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if w.user != nil {
+		password, _ := w.user.Password()
+		req.SetBasicAuth(w.user.Username(), password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write samples: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to write samples: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// encodeWriteRequest encodes the series as a prometheus.WriteRequest
+// protobuf message:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []TimeSeries) []byte {
+	var req []byte
+	for _, s := range series {
+		names := make([]string, 0, len(s.Labels))
+		for name := range s.Labels {
+			names = append(names, name)
+		}
+		// Remote write receivers expect the labels sorted by name
+		sort.Strings(names)
+
+		var ts []byte
+		for _, name := range names {
+			var label []byte
+			label = appendBytesField(label, 1, []byte(name))
+			label = appendBytesField(label, 2, []byte(s.Labels[name]))
+			ts = appendBytesField(ts, 1, label)
+		}
+		for _, sample := range s.Samples {
+			var b []byte
+			b = binary.AppendUvarint(b, 1<<3|1)
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(sample.Value))
+			b = binary.AppendUvarint(b, 2<<3)
+			b = binary.AppendUvarint(b, uint64(sample.Time.UnixMilli()))
+			ts = appendBytesField(ts, 2, b)
+		}
+		req = appendBytesField(req, 1, ts)
+	}
+	return req
+}
+
+// appendBytesField appends a length-delimited protobuf field
+func appendBytesField(b []byte, field int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// snappyEncode encodes data in the snappy block format. It only emits
+// literals, which every decoder accepts; the requests are small enough
+// that compressing them is not worth a dependency.
+func snappyEncode(data []byte) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(data)))
+	for len(data) > 0 {
+		n := min(len(data), 1<<16)
+		// A literal of up to 60 bytes has its length in the tag, a longer one
+		// in the 1 or 2 bytes after it
+		switch {
+		case n <= 60:
+			b = append(b, byte(n-1)<<2)
+		case n <= 1<<8:
+			b = append(b, 60<<2, byte(n-1))
+		default:
+			b = append(b, 61<<2, byte(n-1), byte((n-1)>>8))
+		}
+		b = append(b, data[:n]...)
+		data = data[n:]
+	}
+	return b
+}
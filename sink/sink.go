@@ -0,0 +1,119 @@
+package sink
+
+import (
+	"context"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+var (
+	publishSuccessGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_sink_last_publish_successful",
+		Help: "Whether the last publish to a sink was successful",
+	}, []string{"sink"})
+
+	publishTimestampGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bdx_sink_last_publish_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful publish to a sink",
+	}, []string{"sink"})
+
+	publishErrorsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bdx_sink_publish_errors_total",
+		Help: "Number of failed publishes to a sink",
+	}, []string{"sink"})
+)
+
+// Sink publishes the collected data to an external system after every
+// collection cycle
+type Sink interface {
+	// Name identifies the sink in logs and metrics
+	Name() string
+	// Publish sends the latest data of the collector
+	Publish(ctx context.Context, col *collector.Collector) error
+}
+
+// New creates the sinks enabled in the configuration
+func New(cfg *config.Config) ([]Sink, error) {
+	var sinks []Sink
+	if cfg.Pushgateway.URL != "" {
+		s, err := newPushgateway(cfg.Pushgateway, cfg.HTTPTimeout)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.Graphite.Address != "" {
+		sinks = append(sinks, &graphite{cfg: cfg.Graphite})
+	}
+	if cfg.StatsD.Address != "" {
+		sinks = append(sinks, &statsd{cfg: cfg.StatsD})
+	}
+	if cfg.Kafka.RESTURL != "" {
+		sinks = append(sinks, newKafka(cfg.Kafka, cfg.HTTPTimeout))
+	}
+	if cfg.MQTT.URL != "" {
+		sinks = append(sinks, newMQTT(cfg.MQTT))
+	}
+	if cfg.CloudWatch.Namespace != "" {
+		sinks = append(sinks, newCloudWatch(cfg.CloudWatch, cfg.HTTPTimeout))
+	}
+	if cfg.Datadog.APIKey != "" {
+		sinks = append(sinks, newDatadog(cfg.Datadog, cfg.HTTPTimeout))
+	}
+	if cfg.GoogleCloudMonitoring.ProjectID != "" {
+		s, err := newGoogleCloudMonitoring(cfg.GoogleCloudMonitoring, cfg.HTTPTimeout)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.Postgres.DSN != "" {
+		sinks = append(sinks, newPostgres(cfg.Postgres))
+	}
+	if cfg.Checkmk.SpoolDir != "" {
+		sinks = append(sinks, newCheckmkSpool(cfg.Checkmk, cfg.ScrapeInterval))
+	}
+	return sinks, nil
+}
+
+// Start publishes to the sinks after every collection cycle of the
+// collector, until the context is canceled
+func Start(ctx context.Context, col *collector.Collector, sinks []Sink) {
+	if len(sinks) == 0 {
+		return
+	}
+
+	updates, unsubscribe := col.Subscribe()
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-updates:
+				for _, s := range sinks {
+					publish(ctx, col, s)
+				}
+			}
+		}
+	}()
+}
+
+// publish publishes to a single sink and records the outcome
+func publish(ctx context.Context, col *collector.Collector, s Sink) {
+	ctx, cancel := context.WithTimeout(ctx, col.Config().HTTPTimeout)
+	defer cancel()
+
+	if err := s.Publish(ctx, col); err != nil {
+		log.Printf("Failed to publish to %s: %v", s.Name(), err)
+		publishSuccessGauge.WithLabelValues(s.Name()).Set(0)
+		publishErrorsCounter.WithLabelValues(s.Name()).Inc()
+		return
+	}
+	publishSuccessGauge.WithLabelValues(s.Name()).Set(1)
+	publishTimestampGauge.WithLabelValues(s.Name()).SetToCurrentTime()
+}
@@ -0,0 +1,79 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// statsdMaxPacket keeps datagrams below the usual Ethernet MTU
+const statsdMaxPacket = 1432
+
+// statsd sends the readings as StatsD or DogStatsD gauges over UDP
+type statsd struct {
+	cfg config.StatsDConfig
+}
+
+// Name identifies the sink
+func (s *statsd) Name() string {
+	return "statsd"
+}
+
+// Publish sends the latest readings of the collector
+func (s *statsd) Publish(ctx context.Context, col *collector.Collector) error {
+	values, err := col.Values(collector.ValueFilter{})
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", s.cfg.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to StatsD: %w", err)
+	}
+	defer conn.Close()
+
+	// Batch the lines into as few datagrams as possible
+	var packet bytes.Buffer
+	for _, v := range values {
+		line := s.line(v)
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return fmt.Errorf("failed to send to StatsD: %w", err)
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		if _, err := conn.Write(packet.Bytes()); err != nil {
+			return fmt.Errorf("failed to send to StatsD: %w", err)
+		}
+	}
+	return nil
+}
+
+// line formats a value as a gauge
+func (s *statsd) line(v collector.Value) string {
+	if s.cfg.Flavor == config.StatsDPlain {
+		return fmt.Sprintf("%s%s:%g|g", s.cfg.Prefix, graphitePath("", v), v.Value)
+	}
+
+	tags := make([]string, 0, len(v.Labels))
+	for name, value := range v.Labels {
+		if value != "" {
+			tags = append(tags, name+":"+strings.NewReplacer(",", "_", "|", "_").Replace(value))
+		}
+	}
+	sort.Strings(tags)
+	return fmt.Sprintf("%s%s:%g|g|#%s", s.cfg.Prefix, v.Metric, v.Value, strings.Join(tags, ","))
+}
@@ -0,0 +1,20 @@
+package sink
+
+import (
+	"regexp"
+)
+
+var templateLabelRE = regexp.MustCompile(`<([a-zA-Z_][a-zA-Z0-9_]*)>`)
+
+// renderTemplate replaces every <name> in the template with lookup(name).
+// Characters of the values matched by invalid are replaced by _, and empty
+// values become none.
+func renderTemplate(template string, lookup func(string) string, invalid *regexp.Regexp) string {
+	return templateLabelRE.ReplaceAllStringFunc(template, func(m string) string {
+		value := lookup(m[1 : len(m)-1])
+		if value == "" {
+			return "none"
+		}
+		return invalid.ReplaceAllString(value, "_")
+	})
+}
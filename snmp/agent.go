@@ -0,0 +1,447 @@
+package snmp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/version"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+)
+
+// PDU types
+const (
+	pduGet      = 0xa0
+	pduGetNext  = 0xa1
+	pduResponse = 0xa2
+	pduSet      = 0xa3
+	pduGetBulk  = 0xa5
+)
+
+// Error statuses
+const (
+	errTooBig      = 1
+	errNoSuchName  = 2
+	errNotWritable = 17
+)
+
+// SNMP versions on the wire
+const (
+	versionV1  = 0
+	versionV2c = 1
+)
+
+// maxResponseSize keeps responses within a single UDP datagram
+const maxResponseSize = 65000
+
+// System group of MIB-II, which network management systems read to identify
+// the agent
+var (
+	sysDescr    = oid{1, 3, 6, 1, 2, 1, 1, 1, 0}
+	sysObjectID = oid{1, 3, 6, 1, 2, 1, 1, 2, 0}
+	sysUpTime   = oid{1, 3, 6, 1, 2, 1, 1, 3, 0}
+	sysName     = oid{1, 3, 6, 1, 2, 1, 1, 5, 0}
+)
+
+// variable is an object instance with its BER encoded value
+type variable struct {
+	oid   oid
+	value []byte
+}
+
+// Agent is a read-only SNMPv1/v2c agent. Under the base OID it serves
+//
+//	base.1.1.<column>.<index>  value table: index, source, target, metric,
+//	                           labels, value as text, value * 100
+//	base.2.1.<column>.<index>  active alarm table: index, target, item, status
+//	base.3.1.0                 whether the last collection succeeded (1 or 2)
+//	base.3.2.0                 time of the last collection (RFC 3339)
+//
+// The index of a reading or an alarm stays the same while the exporter runs.
+type Agent struct {
+	cfg   config.SNMPConfig
+	base  oid
+	col   *collector.Collector
+	start time.Time
+
+	mu         sync.RWMutex
+	variables  []variable
+	valueIndex map[string]uint32
+	alarmIndex map[collector.Alarm]uint32
+}
+
+// NewAgent creates an SNMP agent serving the data of the collector
+func NewAgent(cfg config.SNMPConfig, col *collector.Collector) (*Agent, error) {
+	base, err := parseOID(cfg.BaseOID)
+	if err != nil {
+		return nil, err
+	}
+	return &Agent{
+		cfg:        cfg,
+		base:       base,
+		col:        col,
+		start:      time.Now(),
+		valueIndex: make(map[string]uint32),
+		alarmIndex: make(map[collector.Alarm]uint32),
+	}, nil
+}
+
+// ListenAndServe answers SNMP requests until the context is canceled. The
+// served data is refreshed after every collection cycle.
+func (a *Agent) ListenAndServe(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", a.cfg.ListenAddress)
+	if err != nil {
+		return err
+	}
+	log.Printf("Starting SNMP agent on %s", a.cfg.ListenAddress)
+
+	updates, unsubscribe := a.col.Subscribe()
+	a.update()
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-updates:
+				a.update()
+			}
+		}
+	}()
+
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		resp, err := a.handle(buf[:n])
+		if err != nil {
+			log.Printf("Invalid SNMP request from %s: %v", addr, err)
+			continue
+		}
+		if resp != nil {
+			conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+// update rebuilds the served variables from the latest collection
+func (a *Agent) update() {
+	values, err := a.col.Values(collector.ValueFilter{})
+	if err != nil {
+		log.Printf("Failed to read values for SNMP: %v", err)
+		return
+	}
+	alarms := a.col.ActiveAlarms()
+	lastCollect, lastSuccess := a.col.GetHealthStatus()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	hostname, _ := os.Hostname()
+	vars := []variable{
+		{sysDescr, appendTLV(nil, tagOctetString, []byte("bdx_exporter "+version.Version))},
+		{sysObjectID, appendTLV(nil, tagOID, encodeOID(a.base))},
+		{sysUpTime, nil},
+		{sysName, appendTLV(nil, tagOctetString, []byte(hostname))},
+	}
+
+	valueEntry := a.base.child(1, 1)
+	for _, v := range values {
+		key := valueKey(v)
+		index, ok := a.valueIndex[key]
+		if !ok {
+			index = uint32(len(a.valueIndex) + 1)
+			a.valueIndex[key] = index
+		}
+		vars = append(vars,
+			variable{valueEntry.child(1, index), integer(int64(index))},
+			variable{valueEntry.child(2, index), octetString(v.Source)},
+			variable{valueEntry.child(3, index), octetString(v.Target)},
+			variable{valueEntry.child(4, index), octetString(v.Metric)},
+			variable{valueEntry.child(5, index), octetString(formatLabels(v.Labels))},
+			variable{valueEntry.child(6, index), octetString(strconv.FormatFloat(v.Value, 'f', -1, 64))},
+			variable{valueEntry.child(7, index), integer(hundredths(v.Value))},
+		)
+	}
+
+	alarmEntry := a.base.child(2, 1)
+	for _, alarm := range alarms {
+		index, ok := a.alarmIndex[alarm]
+		if !ok {
+			index = uint32(len(a.alarmIndex) + 1)
+			a.alarmIndex[alarm] = index
+		}
+		vars = append(vars,
+			variable{alarmEntry.child(1, index), integer(int64(index))},
+			variable{alarmEntry.child(2, index), octetString(alarm.Target)},
+			variable{alarmEntry.child(3, index), octetString(alarm.Item)},
+			variable{alarmEntry.child(4, index), octetString(alarm.Status)},
+		)
+	}
+
+	success := int64(2)
+	if lastSuccess {
+		success = 1
+	}
+	vars = append(vars,
+		variable{a.base.child(3, 1, 0), integer(success)},
+		variable{a.base.child(3, 2, 0), octetString(lastCollect.Format(time.RFC3339))},
+	)
+
+	sort.Slice(vars, func(i, j int) bool { return vars[i].oid.compare(vars[j].oid) < 0 })
+	a.variables = vars
+}
+
+// handle answers a request, or returns nil to drop it
+func (a *Agent) handle(packet []byte) ([]byte, error) {
+	msg, _, err := expect(tagSequence, packet)
+	if err != nil {
+		return nil, err
+	}
+	value, msg, err := expect(tagInteger, msg)
+	if err != nil {
+		return nil, err
+	}
+	snmpVersion, err := decodeInt(value)
+	if err != nil {
+		return nil, err
+	}
+	if snmpVersion != versionV1 && snmpVersion != versionV2c {
+		return nil, fmt.Errorf("unsupported SNMP version %d", snmpVersion+1)
+	}
+	community, msg, err := expect(tagOctetString, msg)
+	if err != nil {
+		return nil, err
+	}
+	if string(community) != a.cfg.Community {
+		return nil, nil
+	}
+	pduType, pdu, _, err := readTLV(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields [3]int64
+	for i := range fields {
+		if value, pdu, err = expect(tagInteger, pdu); err != nil {
+			return nil, err
+		}
+		if fields[i], err = decodeInt(value); err != nil {
+			return nil, err
+		}
+	}
+	requestID := fields[0]
+	list, _, err := expect(tagSequence, pdu)
+	if err != nil {
+		return nil, err
+	}
+	var oids []oid
+	for len(list) > 0 {
+		var vb []byte
+		if vb, list, err = expect(tagSequence, list); err != nil {
+			return nil, err
+		}
+		if value, _, err = expect(tagOID, vb); err != nil {
+			return nil, err
+		}
+		o, err := decodeOID(value)
+		if err != nil {
+			return nil, err
+		}
+		oids = append(oids, o)
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	r := response{version: snmpVersion, community: community, requestID: requestID}
+	switch pduType {
+	case pduGet:
+		for i, o := range oids {
+			v, ok := a.get(o)
+			if !ok {
+				if snmpVersion == versionV1 {
+					return r.failed(errNoSuchName, i+1, oids), nil
+				}
+				v = variable{o, []byte{tagNoSuchObject, 0}}
+			}
+			r.add(v)
+		}
+	case pduGetNext:
+		for i, o := range oids {
+			v, ok := a.next(o)
+			if !ok {
+				if snmpVersion == versionV1 {
+					return r.failed(errNoSuchName, i+1, oids), nil
+				}
+				v = variable{o, []byte{tagEndOfMIBView, 0}}
+			}
+			r.add(v)
+		}
+	case pduGetBulk:
+		if snmpVersion == versionV1 {
+			return nil, fmt.Errorf("GetBulk is not supported by SNMPv1")
+		}
+		nonRepeaters := int(min(max(fields[1], 0), int64(len(oids))))
+		maxRepetitions := int(max(fields[2], 0))
+		for _, o := range oids[:nonRepeaters] {
+			v, ok := a.next(o)
+			if !ok {
+				v = variable{o, []byte{tagEndOfMIBView, 0}}
+			}
+			r.add(v)
+		}
+		last := oids[nonRepeaters:]
+		for rep := 0; rep < maxRepetitions && len(last) > 0 && r.size < maxResponseSize/2; rep++ {
+			more := false
+			for i, o := range last {
+				v, ok := a.next(o)
+				if !ok {
+					v = variable{o, []byte{tagEndOfMIBView, 0}}
+				} else {
+					more = true
+				}
+				r.add(v)
+				last[i] = v.oid
+			}
+			if !more {
+				break
+			}
+		}
+	case pduSet:
+		if snmpVersion == versionV1 {
+			return r.failed(errNoSuchName, 1, oids), nil
+		}
+		return r.failed(errNotWritable, 1, oids), nil
+	default:
+		return nil, fmt.Errorf("unsupported PDU type 0x%02x", pduType)
+	}
+
+	if r.size > maxResponseSize {
+		return r.failed(errTooBig, 0, nil), nil
+	}
+	return r.encode(), nil
+}
+
+// get returns the variable with the exact OID
+func (a *Agent) get(o oid) (variable, bool) {
+	i := sort.Search(len(a.variables), func(i int) bool { return a.variables[i].oid.compare(o) >= 0 })
+	if i < len(a.variables) && a.variables[i].oid.compare(o) == 0 {
+		return a.resolve(a.variables[i]), true
+	}
+	return variable{}, false
+}
+
+// next returns the first variable after the OID
+func (a *Agent) next(o oid) (variable, bool) {
+	i := sort.Search(len(a.variables), func(i int) bool { return a.variables[i].oid.compare(o) > 0 })
+	if i < len(a.variables) {
+		return a.resolve(a.variables[i]), true
+	}
+	return variable{}, false
+}
+
+// resolve fills in the values that change between requests
+func (a *Agent) resolve(v variable) variable {
+	if v.oid.compare(sysUpTime) == 0 {
+		ticks := uint64(time.Since(a.start)/(10*time.Millisecond)) & 0xffffffff
+		v.value = appendTLV(nil, tagTimeTicks, encodeUint(ticks))
+	}
+	return v
+}
+
+// response builds a Response PDU
+type response struct {
+	version   int64
+	community []byte
+	requestID int64
+	bindings  []byte
+	size      int
+}
+
+// add appends a variable binding
+func (r *response) add(v variable) {
+	vb := appendTLV(nil, tagOID, encodeOID(v.oid))
+	r.bindings = appendTLV(r.bindings, tagSequence, append(vb, v.value...))
+	r.size = len(r.bindings)
+}
+
+// failed returns an error response echoing the requested OIDs
+func (r *response) failed(status, index int, oids []oid) []byte {
+	r.bindings = nil
+	for _, o := range oids {
+		r.add(variable{o, []byte{tagNull, 0}})
+	}
+	return r.encodeStatus(status, index)
+}
+
+func (r *response) encode() []byte {
+	return r.encodeStatus(0, 0)
+}
+
+func (r *response) encodeStatus(status, index int) []byte {
+	pdu := appendTLV(nil, tagInteger, encodeInt(r.requestID))
+	pdu = appendTLV(pdu, tagInteger, encodeInt(int64(status)))
+	pdu = appendTLV(pdu, tagInteger, encodeInt(int64(index)))
+	pdu = appendTLV(pdu, tagSequence, r.bindings)
+
+	msg := appendTLV(nil, tagInteger, encodeInt(r.version))
+	msg = appendTLV(msg, tagOctetString, r.community)
+	msg = appendTLV(msg, pduResponse, pdu)
+	return appendTLV(nil, tagSequence, msg)
+}
+
+func integer(n int64) []byte {
+	return appendTLV(nil, tagInteger, encodeInt(n))
+}
+
+func octetString(s string) []byte {
+	return appendTLV(nil, tagOctetString, []byte(s))
+}
+
+// hundredths converts a reading to an Integer32 in hundredths of its unit,
+// for systems that cannot parse the text value
+func hundredths(f float64) int64 {
+	n := math.Round(f * 100)
+	switch {
+	case math.IsNaN(n):
+		return 0
+	case n > math.MaxInt32:
+		return math.MaxInt32
+	case n < math.MinInt32:
+		return math.MinInt32
+	}
+	return int64(n)
+}
+
+// formatLabels formats labels as name=value pairs sorted by name
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// valueKey identifies a reading across collections
+func valueKey(v collector.Value) string {
+	labels, _ := json.Marshal(v.Labels)
+	return v.Source + "\x00" + v.Metric + "\x00" + string(labels)
+}
@@ -0,0 +1,183 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BER tags used by SNMP
+const (
+	tagInteger      = 0x02
+	tagOctetString  = 0x04
+	tagNull         = 0x05
+	tagOID          = 0x06
+	tagSequence     = 0x30
+	tagCounter32    = 0x41
+	tagGauge32      = 0x42
+	tagTimeTicks    = 0x43
+	tagNoSuchObject = 0x80
+	tagEndOfMIBView = 0x82
+)
+
+var errTruncated = errors.New("truncated BER value")
+
+// oid is an object identifier
+type oid []uint32
+
+// parseOID parses a dotted object identifier such as 1.3.6.1.4.1
+func parseOID(s string) (oid, error) {
+	var o oid
+	for _, arc := range strings.Split(strings.TrimPrefix(s, "."), ".") {
+		n, err := strconv.ParseUint(arc, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q: %w", s, err)
+		}
+		o = append(o, uint32(n))
+	}
+	if len(o) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	return o, nil
+}
+
+// child returns the OID with arcs appended
+func (o oid) child(arcs ...uint32) oid {
+	return append(append(oid{}, o...), arcs...)
+}
+
+// compare orders OIDs lexicographically, as SNMP walks them
+func (o oid) compare(other oid) int {
+	for i := 0; i < len(o) && i < len(other); i++ {
+		if o[i] != other[i] {
+			if o[i] < other[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(o) - len(other)
+}
+
+func (o oid) String() string {
+	arcs := make([]string, len(o))
+	for i, arc := range o {
+		arcs[i] = strconv.FormatUint(uint64(arc), 10)
+	}
+	return strings.Join(arcs, ".")
+}
+
+// readTLV splits the first tag-length-value of data from the rest
+func readTLV(data []byte) (tag byte, value, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, errTruncated
+	}
+	tag, length, data := data[0], int(data[1]), data[2:]
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 3 || len(data) < n {
+			return 0, nil, nil, fmt.Errorf("unsupported BER length")
+		}
+		length = 0
+		for _, b := range data[:n] {
+			length = length<<8 | int(b)
+		}
+		data = data[n:]
+	}
+	if len(data) < length {
+		return 0, nil, nil, errTruncated
+	}
+	return tag, data[:length], data[length:], nil
+}
+
+// expect reads a TLV with the given tag
+func expect(tag byte, data []byte) (value, rest []byte, err error) {
+	t, value, rest, err := readTLV(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t != tag {
+		return nil, nil, fmt.Errorf("expected BER tag 0x%02x, got 0x%02x", tag, t)
+	}
+	return value, rest, nil
+}
+
+// decodeInt decodes a two's complement integer
+func decodeInt(value []byte) (int64, error) {
+	if len(value) == 0 || len(value) > 8 {
+		return 0, fmt.Errorf("invalid BER integer")
+	}
+	n := int64(int8(value[0]))
+	for _, b := range value[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+// decodeOID decodes an object identifier
+func decodeOID(value []byte) (oid, error) {
+	if len(value) == 0 {
+		return nil, fmt.Errorf("invalid BER OID")
+	}
+	o := oid{uint32(value[0]) / 40, uint32(value[0]) % 40}
+	var arc uint32
+	for i, b := range value[1:] {
+		arc = arc<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			o = append(o, arc)
+			arc = 0
+		} else if i == len(value)-2 {
+			return nil, fmt.Errorf("invalid BER OID")
+		}
+	}
+	return o, nil
+}
+
+// appendTLV appends a tag, the length of value and value
+func appendTLV(b []byte, tag byte, value []byte) []byte {
+	b = append(b, tag)
+	switch n := len(value); {
+	case n < 0x80:
+		b = append(b, byte(n))
+	case n <= 0xff:
+		b = append(b, 0x81, byte(n))
+	default:
+		b = append(b, 0x82, byte(n>>8), byte(n))
+	}
+	return append(b, value...)
+}
+
+// encodeInt encodes a signed integer in as few bytes as possible
+func encodeInt(n int64) []byte {
+	b := []byte{byte(n)}
+	for n > 0x7f || n < -0x80 {
+		n >>= 8
+		b = append([]byte{byte(n)}, b...)
+	}
+	return b
+}
+
+// encodeUint encodes an unsigned integer such as a Counter32 or TimeTicks
+func encodeUint(n uint64) []byte {
+	b := []byte{byte(n)}
+	for n > 0x7f {
+		n >>= 8
+		b = append([]byte{byte(n)}, b...)
+	}
+	return b
+}
+
+// encodeOID encodes an object identifier
+func encodeOID(o oid) []byte {
+	b := []byte{byte(o[0]*40 + o[1])}
+	for _, arc := range o[2:] {
+		var group []byte
+		group = append(group, byte(arc&0x7f))
+		for arc >>= 7; arc > 0; arc >>= 7 {
+			group = append([]byte{byte(arc&0x7f) | 0x80}, group...)
+		}
+		b = append(b, group...)
+	}
+	return b
+}
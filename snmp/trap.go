@@ -0,0 +1,405 @@
+package snmp
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/notify"
+)
+
+const (
+	pduTrapV2 = 0xa7
+	versionV3 = 3
+	// securityUSM is the user based security model of SNMPv3
+	securityUSM = 3
+	maxTrapSize = 65507
+	// netSNMPEngine is the enterprise number of the generated engine IDs
+	netSNMPEngine = 8072
+)
+
+// SNMPv3 message flags
+const (
+	flagAuth = 0x01
+	flagPriv = 0x02
+)
+
+// snmpTrapOID is the variable holding the OID of a trap
+var snmpTrapOID = oid{1, 3, 6, 1, 6, 3, 1, 1, 4, 1, 0}
+
+// engineEpoch is subtracted from the start time to get the engine boots
+var engineEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// trapVarbind is a configured variable binding with its parsed OID and
+// value template
+type trapVarbind struct {
+	oid      oid
+	typ      string
+	template *template.Template
+}
+
+// TrapSender sends SNMPv2c or SNMPv3 traps when critical alarms and
+// threshold rules fire or resolve. It is a notifier, so traps are counted
+// with the other notifications.
+type TrapSender struct {
+	cfg      config.SNMPTrapConfig
+	firing   oid
+	resolved oid
+	varbinds []trapVarbind
+	start    time.Time
+
+	// SNMPv3 user based security, with the keys localized to the engine ID
+	engineID []byte
+	boots    int64
+	authHash func() hash.Hash
+	authLen  int
+	authKey  []byte
+	privKey  []byte
+
+	mu        sync.Mutex
+	requestID int32
+	salt      uint64
+}
+
+// NewTrapSender creates a trap sender
+func NewTrapSender(cfg config.SNMPTrapConfig) (*TrapSender, error) {
+	t := &TrapSender{cfg: cfg, start: time.Now()}
+	var err error
+	if t.firing, err = parseOID(cfg.FiringOID); err != nil {
+		return nil, err
+	}
+	if t.resolved, err = parseOID(cfg.ResolvedOID); err != nil {
+		return nil, err
+	}
+	for _, v := range cfg.Varbinds {
+		o, err := parseOID(v.OID)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := config.ParseTemplate(v.OID, v.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template of trap variable %s: %w", v.OID, err)
+		}
+		t.varbinds = append(t.varbinds, trapVarbind{oid: o, typ: v.Type, template: tmpl})
+	}
+
+	var seed [12]byte
+	rand.Read(seed[:])
+	t.requestID = int32(binary.BigEndian.Uint32(seed[:4]) & 0x7fffffff)
+	t.salt = binary.BigEndian.Uint64(seed[4:])
+
+	if cfg.Version == config.SNMPv3 {
+		if err := t.setupUSM(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// setupUSM derives the engine ID and the localized keys of the user
+func (t *TrapSender) setupUSM() error {
+	if t.cfg.EngineID != "" {
+		id, err := hex.DecodeString(t.cfg.EngineID)
+		if err != nil {
+			return fmt.Errorf("invalid engine ID: %w", err)
+		}
+		t.engineID = id
+	} else {
+		// RFC 3411 text format under the net-snmp enterprise number
+		hostname, _ := os.Hostname()
+		text := "bdx-" + hostname
+		if len(text) > 27 {
+			text = text[:27]
+		}
+		t.engineID = binary.BigEndian.AppendUint32(nil, 0x80000000|netSNMPEngine)
+		t.engineID = append(append(t.engineID, 4), text...)
+	}
+	// The boots only need to increase with every restart, which the start
+	// time does without persisting a counter
+	t.boots = int64(t.start.Sub(engineEpoch) / time.Second)
+	log.Printf("Sending SNMPv3 traps as engine ID %x", t.engineID)
+
+	switch t.cfg.AuthProtocol {
+	case config.SNMPAuthNone:
+		return nil
+	case config.SNMPAuthMD5:
+		t.authHash, t.authLen = md5.New, 12
+	case config.SNMPAuthSHA:
+		t.authHash, t.authLen = sha1.New, 12
+	case config.SNMPAuthSHA256:
+		t.authHash, t.authLen = sha256.New, 24
+	case config.SNMPAuthSHA512:
+		t.authHash, t.authLen = sha512.New, 48
+	default:
+		return fmt.Errorf("unknown SNMPv3 authentication protocol %q", t.cfg.AuthProtocol)
+	}
+	t.authKey = localizeKey(t.authHash, t.cfg.AuthPassword, t.engineID)
+
+	switch t.cfg.PrivProtocol {
+	case config.SNMPPrivNone:
+	case config.SNMPPrivDES, config.SNMPPrivAES:
+		t.privKey = localizeKey(t.authHash, t.cfg.PrivPassword, t.engineID)
+		if len(t.privKey) < 16 {
+			return fmt.Errorf("%s keys are too short for %s", t.cfg.AuthProtocol, t.cfg.PrivProtocol)
+		}
+	default:
+		return fmt.Errorf("unknown SNMPv3 privacy protocol %q", t.cfg.PrivProtocol)
+	}
+	return nil
+}
+
+// localizeKey derives the key of a password for an engine ID, as described
+// in RFC 3414 appendix A.2
+func localizeKey(h func() hash.Hash, password string, engineID []byte) []byte {
+	const expanded = 1 << 20
+	d := h()
+	buf := bytes.Repeat([]byte(password), 64/len(password)+2)
+	for written := 0; written < expanded; written += 64 {
+		offset := written % len(password)
+		d.Write(buf[offset : offset+64])
+	}
+	ku := d.Sum(nil)
+
+	d = h()
+	d.Write(ku)
+	d.Write(engineID)
+	d.Write(ku)
+	return d.Sum(nil)
+}
+
+// Name identifies the notifier
+func (t *TrapSender) Name() string {
+	return "snmptrap"
+}
+
+// Notify sends the trap of a critical notification to every target
+func (t *TrapSender) Notify(ctx context.Context, n notify.Notification) error {
+	if n.Severity != config.SeverityCritical {
+		return nil
+	}
+
+	trapOID := t.firing
+	if n.State == notify.StateResolved {
+		trapOID = t.resolved
+	}
+	ticks := uint64(time.Since(t.start)/(10*time.Millisecond)) & 0xffffffff
+	r := response{}
+	r.add(variable{sysUpTime, appendTLV(nil, tagTimeTicks, encodeUint(ticks))})
+	r.add(variable{snmpTrapOID, appendTLV(nil, tagOID, encodeOID(trapOID))})
+	for _, v := range t.varbinds {
+		value, err := v.render(n)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			r.add(variable{v.oid, value})
+		}
+	}
+
+	var errs []error
+	for _, target := range t.cfg.Targets {
+		packet, err := t.encode(r.bindings)
+		if err != nil {
+			return err
+		}
+		if err := send(ctx, target, packet); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// render encodes the value of a variable binding, or returns nil to leave
+// out a numeric variable rendering empty
+func (v trapVarbind) render(n notify.Notification) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := v.template.Execute(&buf, n); err != nil {
+		return nil, fmt.Errorf("failed to render trap variable %s: %w", v.oid, err)
+	}
+	text := strings.TrimSpace(buf.String())
+	if v.typ == config.SNMPString {
+		return octetString(text), nil
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value of trap variable %s: %w", v.oid, err)
+	}
+	f = math.Round(f)
+	if v.typ == config.SNMPGauge {
+		return appendTLV(nil, tagGauge32, encodeUint(uint64(min(max(f, 0), math.MaxUint32)))), nil
+	}
+	return integer(int64(min(max(f, math.MinInt32), math.MaxInt32))), nil
+}
+
+// encode wraps the variable bindings into a trap message
+func (t *TrapSender) encode(bindings []byte) ([]byte, error) {
+	t.mu.Lock()
+	t.requestID = (t.requestID + 1) & 0x7fffffff
+	requestID := t.requestID
+	t.salt++
+	salt := t.salt
+	t.mu.Unlock()
+
+	pdu := appendTLV(nil, tagInteger, encodeInt(int64(requestID)))
+	pdu = appendTLV(pdu, tagInteger, encodeInt(0))
+	pdu = appendTLV(pdu, tagInteger, encodeInt(0))
+	pdu = appendTLV(pdu, tagSequence, bindings)
+	pdu = appendTLV(nil, pduTrapV2, pdu)
+
+	if t.cfg.Version != config.SNMPv3 {
+		msg := appendTLV(nil, tagInteger, encodeInt(versionV2c))
+		msg = appendTLV(msg, tagOctetString, []byte(t.cfg.Community))
+		msg = append(msg, pdu...)
+		return appendTLV(nil, tagSequence, msg), nil
+	}
+	return t.encodeV3(requestID, salt, pdu)
+}
+
+// encodeV3 builds an SNMPv3 message with user based security (RFC 3412 and
+// RFC 3414), encrypting and authenticating it as configured
+func (t *TrapSender) encodeV3(msgID int32, salt uint64, pdu []byte) ([]byte, error) {
+	engineTime := int64(time.Since(t.start) / time.Second)
+
+	scoped := appendTLV(nil, tagOctetString, t.engineID)
+	scoped = appendTLV(scoped, tagOctetString, nil)
+	scoped = appendTLV(nil, tagSequence, append(scoped, pdu...))
+
+	var flags byte
+	var privParams []byte
+	data := scoped
+	if t.privKey != nil {
+		flags |= flagPriv
+		var encrypted []byte
+		var err error
+		if t.cfg.PrivProtocol == config.SNMPPrivDES {
+			encrypted, privParams, err = t.encryptDES(salt, scoped)
+		} else {
+			encrypted, privParams, err = t.encryptAES(engineTime, salt, scoped)
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = appendTLV(nil, tagOctetString, encrypted)
+	}
+	if t.authKey != nil {
+		flags |= flagAuth
+	}
+
+	global := appendTLV(nil, tagInteger, encodeInt(int64(msgID)))
+	global = appendTLV(global, tagInteger, encodeInt(maxTrapSize))
+	global = appendTLV(global, tagOctetString, []byte{flags})
+	global = appendTLV(global, tagInteger, encodeInt(securityUSM))
+
+	// The authentication parameters are zeros while the digest is computed,
+	// their offset is tracked to fill it in afterwards
+	usm := appendTLV(nil, tagOctetString, t.engineID)
+	usm = appendTLV(usm, tagInteger, encodeInt(t.boots))
+	usm = appendTLV(usm, tagInteger, encodeInt(engineTime))
+	usm = appendTLV(usm, tagOctetString, []byte(t.cfg.Username))
+	authParams := make([]byte, t.authLen)
+	usm = appendTLV(usm, tagOctetString, authParams)
+	authEnd := len(usm)
+	usm = appendTLV(usm, tagOctetString, privParams)
+	usmSeq := appendTLV(nil, tagSequence, usm)
+	secParams := appendTLV(nil, tagOctetString, usmSeq)
+
+	msg := appendTLV(nil, tagInteger, encodeInt(versionV3))
+	msg = appendTLV(msg, tagSequence, global)
+	secEnd := len(msg) + len(secParams)
+	msg = append(msg, secParams...)
+	msg = append(msg, data...)
+	whole := appendTLV(nil, tagSequence, msg)
+
+	if t.authKey != nil {
+		// The USM sequence ends where the security parameters end
+		offset := len(whole) - len(msg) + secEnd - (len(usm) - authEnd) - t.authLen
+		mac := hmac.New(t.authHash, t.authKey)
+		mac.Write(whole)
+		copy(whole[offset:offset+t.authLen], mac.Sum(nil))
+	}
+	return whole, nil
+}
+
+// encryptDES encrypts a scoped PDU with CBC-DES (RFC 3414 section 8)
+func (t *TrapSender) encryptDES(salt uint64, plaintext []byte) ([]byte, []byte, error) {
+	block, err := des.NewCipher(t.privKey[:8])
+	if err != nil {
+		return nil, nil, err
+	}
+	params := binary.BigEndian.AppendUint32(nil, uint32(t.boots))
+	params = binary.BigEndian.AppendUint32(params, uint32(salt))
+	iv := make([]byte, des.BlockSize)
+	for i := range iv {
+		iv[i] = t.privKey[8+i] ^ params[i]
+	}
+
+	padded := append(bytes.Clone(plaintext), make([]byte, (des.BlockSize-len(plaintext)%des.BlockSize)%des.BlockSize)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+	return padded, params, nil
+}
+
+// encryptAES encrypts a scoped PDU with CFB128-AES-128 (RFC 3826)
+func (t *TrapSender) encryptAES(engineTime int64, salt uint64, plaintext []byte) ([]byte, []byte, error) {
+	block, err := aes.NewCipher(t.privKey[:16])
+	if err != nil {
+		return nil, nil, err
+	}
+	params := binary.BigEndian.AppendUint64(nil, salt)
+	iv := binary.BigEndian.AppendUint32(nil, uint32(t.boots))
+	iv = binary.BigEndian.AppendUint32(iv, uint32(engineTime))
+	iv = append(iv, params...)
+
+	// CFB with a full block of feedback
+	out := make([]byte, len(plaintext))
+	stream := make([]byte, aes.BlockSize)
+	for i := 0; i < len(plaintext); i += aes.BlockSize {
+		block.Encrypt(stream, iv)
+		end := min(i+aes.BlockSize, len(plaintext))
+		for j := i; j < end; j++ {
+			out[j] = plaintext[j] ^ stream[j-i]
+		}
+		iv = out[i:end]
+	}
+	return out, params, nil
+}
+
+// send sends a packet to a target over UDP
+func send(ctx context.Context, target string, packet []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", target)
+	if err != nil {
+		return fmt.Errorf("failed to send trap to %s: %w", target, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(packet); err != nil {
+		return fmt.Errorf("failed to send trap to %s: %w", target, err)
+	}
+	return nil
+}
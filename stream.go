@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+)
+
+// streamHandler pushes updates over Server-Sent Events after every
+// collection cycle. By default each update is a values event with the
+// readings matching the filter, starting with the current ones. With
+// alarms=true only alarm events for raised and cleared alarms are sent.
+func streamHandler(col *collector.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseValueFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		alarmsOnly := c.Query("alarms") == "true"
+
+		updates, unsubscribe := col.Subscribe()
+		defer unsubscribe()
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+
+		// Alarm events are sent from the moment the client connected
+		since := time.Now()
+		wait := alarmsOnly
+		c.Stream(func(w io.Writer) bool {
+			if wait {
+				select {
+				case <-c.Request.Context().Done():
+					return false
+				case <-updates:
+				}
+			}
+			wait = true
+
+			if alarmsOnly {
+				for _, e := range col.Events(filter.Target, since) {
+					c.SSEvent("alarm", e)
+					since = e.Time
+				}
+				return true
+			}
+
+			values, err := col.Values(filter)
+			if err != nil {
+				c.SSEvent("error", gin.H{"error": err.Error()})
+				return true
+			}
+			c.SSEvent("values", values)
+			return true
+		})
+	}
+}
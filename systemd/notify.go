@@ -0,0 +1,97 @@
+// Package systemd reports the state of the exporter to systemd for services
+// of Type=notify, and keeps its watchdog fed while collection makes progress
+package systemd
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/collector"
+)
+
+// Notify sends a state such as READY=1 to the service manager. It does
+// nothing when the exporter is not run by systemd with NotifyAccess.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Abstract sockets are given with a leading @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// watchdogInterval returns the watchdog timeout of the service, 0 when the
+// watchdog is disabled or meant for another process
+func watchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Start reports the exporter as ready and, when the service has WatchdogSec
+// set, notifies the watchdog twice per timeout until the context is
+// canceled. The watchdog is no longer notified while a collection cycle runs
+// for longer than COLLECTION_HANG_TIMEOUT, so systemd restarts the exporter
+// when the collection hangs. The returned function reports that the exporter
+// is stopping.
+func Start(ctx context.Context, col *collector.Collector) func() {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return func() {}
+	}
+	if err := Notify("READY=1"); err != nil {
+		log.Printf("Failed to notify systemd: %v", err)
+	}
+
+	if interval := watchdogInterval(); interval > 0 {
+		log.Printf("Notifying the systemd watchdog every %s", interval/2)
+		go func() {
+			ticker := time.NewTicker(interval / 2)
+			defer ticker.Stop()
+			hung := false
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+
+				busy := col.BusySince()
+				if !busy.IsZero() && time.Since(busy) > col.Config().HangTimeout {
+					if !hung {
+						log.Printf("Collection cycle %s has been running since %s, no longer notifying the systemd watchdog", col.CycleID(), busy.Format(time.RFC3339))
+						hung = true
+					}
+					continue
+				}
+				hung = false
+				if err := Notify("WATCHDOG=1"); err != nil {
+					log.Printf("Failed to notify the systemd watchdog: %v", err)
+				}
+			}
+		}()
+	}
+
+	return func() {
+		if err := Notify("STOPPING=1"); err != nil {
+			log.Printf("Failed to notify systemd: %v", err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package tunnel
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SOCKS5 protocol constants, see RFC 1928
+const (
+	socksVersion      = 5
+	socksNoAuth       = 0
+	socksNoAcceptable = 0xff
+	socksConnect      = 1
+	socksIPv4         = 1
+	socksDomain       = 3
+	socksIPv6         = 4
+	socksSucceeded    = 0
+	socksFailure      = 1
+	socksNotSupported = 7
+)
+
+// handshakeTimeout limits the time a client takes to send its request
+const handshakeTimeout = 10 * time.Second
+
+// serveSOCKS accepts SOCKS5 CONNECT requests on listener until it is
+// closed, and connects them with dial. Host names are passed on to dial
+// unresolved, so they are resolved on the far side of the tunnel.
+func serveSOCKS(listener net.Listener, dial func(addr string) (net.Conn, error)) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("SSH tunnel proxy stopped accepting connections: %v", err)
+			}
+			return
+		}
+		go func() {
+			if err := handleSOCKS(conn, dial); err != nil {
+				log.Printf("SSH tunnel proxy: %v", err)
+			}
+		}()
+	}
+}
+
+// handleSOCKS negotiates a SOCKS5 connection and relays it
+func handleSOCKS(conn net.Conn, dial func(addr string) (net.Conn, error)) error {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	// Greeting: only unauthenticated access is offered, the proxy listens
+	// on localhost
+	var header [2]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if header[0] != socksVersion {
+		return fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return fmt.Errorf("failed to read greeting: %w", err)
+	}
+	method := byte(socksNoAcceptable)
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil || method == socksNoAcceptable {
+		return err
+	}
+
+	// Request
+	var request [4]byte
+	if _, err := io.ReadFull(conn, request[:]); err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+	if request[1] != socksConnect {
+		reply(conn, socksNotSupported)
+		return fmt.Errorf("unsupported SOCKS command %d", request[1])
+	}
+	var host string
+	switch request[3] {
+	case socksIPv4, socksIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if request[3] == socksIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return fmt.Errorf("failed to read request: %w", err)
+		}
+		host = ip.String()
+	case socksDomain:
+		var length [1]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return fmt.Errorf("failed to read request: %w", err)
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return fmt.Errorf("failed to read request: %w", err)
+		}
+		host = string(name)
+	default:
+		reply(conn, socksNotSupported)
+		return fmt.Errorf("unsupported SOCKS address type %d", request[3])
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+
+	remote, err := dial(addr)
+	if err != nil {
+		reply(conn, socksFailure)
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	defer remote.Close()
+	if err := reply(conn, socksSucceeded); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	// Relay until either side closes
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		io.Copy(remote, conn)
+		remote.Close()
+	}()
+	io.Copy(conn, remote)
+	conn.Close()
+	wg.Wait()
+	return nil
+}
+
+// reply sends a SOCKS5 reply with an unspecified bound address
+func reply(conn net.Conn, status byte) error {
+	_, err := conn.Write([]byte{socksVersion, status, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,224 @@
+// Package tunnel reaches the portal on an isolated network through an SSH
+// jump host. It serves a local SOCKS5 proxy whose connections are forwarded
+// over the SSH connection, which is kept alive and reconnected when it
+// drops.
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/reski-rukmantiyo/bdx-parser-prometheus/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	upGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bdx_ssh_tunnel_up",
+		Help: "Whether the SSH tunnel to the jump host is connected",
+	})
+
+	connectsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bdx_ssh_tunnel_connects_total",
+		Help: "Number of attempts to connect the SSH tunnel, by outcome",
+	}, []string{"outcome"})
+)
+
+// maxBackoff caps the delay between reconnection attempts
+const maxBackoff = time.Minute
+
+// tunnel holds the SSH connection to the jump host
+type tunnel struct {
+	cfg       config.TunnelConfig
+	sshConfig *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+	// retryAt is when a failed connection may be retried
+	retryAt time.Time
+	backoff time.Duration
+}
+
+// Start connects to the jump host and serves the SOCKS5 proxy on
+// cfg.ListenAddress until the returned function is called. It does nothing
+// when no jump host is configured. A jump host that is unreachable at start
+// doesn't fail, the tunnel connects once it is reachable.
+func Start(ctx context.Context, cfg config.TunnelConfig, timeout time.Duration) (func(), error) {
+	if cfg.Host == "" {
+		return func() {}, nil
+	}
+
+	sshConfig, err := clientConfig(cfg, timeout)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", cfg.ListenAddress)
+	if errors.Is(err, syscall.EADDRINUSE) {
+		// One-off commands such as check share the tunnel of the exporter
+		// running on the same host
+		log.Printf("SSH tunnel proxy address %s is in use, assuming a running exporter serves the tunnel", cfg.ListenAddress)
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the SSH tunnel: %w", err)
+	}
+
+	t := &tunnel{cfg: cfg, sshConfig: sshConfig}
+	if _, err := t.connect(); err != nil {
+		log.Printf("Failed to connect SSH tunnel to %s, retrying on use: %v", cfg.Host, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		t.keepAlive(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		serveSOCKS(listener, t.dial)
+	}()
+	log.Printf("Forwarding portal connections from %s over SSH to %s", cfg.ListenAddress, cfg.Host)
+
+	return func() {
+		cancel()
+		listener.Close()
+		wg.Wait()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.client != nil {
+			t.client.Close()
+			t.client = nil
+		}
+		upGauge.Set(0)
+	}, nil
+}
+
+// clientConfig builds the SSH client configuration from the key and known
+// hosts files
+func clientConfig(cfg config.TunnelConfig, timeout time.Duration) (*ssh.ClientConfig, error) {
+	key, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH_TUNNEL_KEY_FILE: %w", err)
+	}
+	var signer ssh.Signer
+	if cfg.KeyPassphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(cfg.KeyPassphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH_TUNNEL_KEY_FILE %q: %w", cfg.KeyFile, err)
+	}
+
+	hostKeys, err := knownhosts.New(cfg.KnownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH_TUNNEL_KNOWN_HOSTS %q: %w", cfg.KnownHostsFile, err)
+	}
+
+	return &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeys,
+		Timeout:         timeout,
+	}, nil
+}
+
+// connect returns the SSH connection, connecting if there is none. After a
+// failed attempt it fails right away until the backoff passed, so scrapes
+// don't each wait for the jump host.
+func (t *tunnel) connect() (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+	if time.Now().Before(t.retryAt) {
+		return nil, fmt.Errorf("SSH tunnel to %s is down, retrying in %s", t.cfg.Host, time.Until(t.retryAt).Round(time.Second))
+	}
+
+	client, err := ssh.Dial("tcp", t.cfg.Host, t.sshConfig)
+	if err != nil {
+		connectsCounter.WithLabelValues("failure").Inc()
+		t.backoff = min(max(2*t.backoff, time.Second), maxBackoff)
+		t.retryAt = time.Now().Add(t.backoff)
+		return nil, err
+	}
+	connectsCounter.WithLabelValues("success").Inc()
+	upGauge.Set(1)
+	t.client, t.backoff, t.retryAt = client, 0, time.Time{}
+	log.Printf("SSH tunnel connected to %s", t.cfg.Host)
+
+	// Drop the connection as soon as it breaks, so the next use reconnects
+	go func() {
+		err := client.Wait()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.client == client {
+			t.client = nil
+			upGauge.Set(0)
+			log.Printf("SSH tunnel to %s disconnected: %v", t.cfg.Host, err)
+		}
+	}()
+	return client, nil
+}
+
+// dial opens a connection to addr on the far side of the jump host
+func (t *tunnel) dial(addr string) (net.Conn, error) {
+	client, err := t.connect()
+	if err != nil {
+		return nil, err
+	}
+	return client.Dial("tcp", addr)
+}
+
+// keepAlive sends an OpenSSH keepalive every interval, closing a connection
+// that doesn't answer so it is reconnected, and reconnects a dropped one so
+// the tunnel is up before the next scrape
+func (t *tunnel) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(t.cfg.KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		client, err := t.connect()
+		if err != nil {
+			log.Printf("Failed to reconnect SSH tunnel to %s: %v", t.cfg.Host, err)
+			continue
+		}
+		if err := ping(client, t.cfg.KeepAlive); err != nil {
+			log.Printf("SSH tunnel to %s is not responding, reconnecting: %v", t.cfg.Host, err)
+			client.Close()
+		}
+	}
+}
+
+// ping sends a keepalive request and waits up to timeout for the reply
+func ping(client *ssh.Client, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return errors.New("keepalive timed out")
+	}
+}
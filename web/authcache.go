@@ -0,0 +1,71 @@
+package web
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Bounds of the cache of checked credentials
+const (
+	authCacheSize = 256
+	authCacheTTL  = 5 * time.Minute
+)
+
+// authCache remembers credentials that passed the bcrypt check, so clients
+// sending the same ones on every request don't pay for it each time. It only
+// holds valid credentials, the least recently used ones are evicted once it
+// is full and entries expire after authCacheTTL, so wrong passwords can't
+// grow it.
+type authCache struct {
+	mu      sync.Mutex
+	entries map[[32]byte]*list.Element
+	order   *list.List
+}
+
+// authCacheEntry is an element of the order of an authCache
+type authCacheEntry struct {
+	key     [32]byte
+	expires time.Time
+}
+
+// newAuthCache creates an empty cache
+func newAuthCache() *authCache {
+	return &authCache{entries: make(map[[32]byte]*list.Element), order: list.New()}
+}
+
+// valid reports whether key was added and hasn't expired
+func (c *authCache) valid(key [32]byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(e.Value.(*authCacheEntry).expires) {
+		c.order.Remove(e)
+		delete(c.entries, key)
+		return false
+	}
+	c.order.MoveToFront(e)
+	return true
+}
+
+// add remembers key as valid, evicting the least recently used key when the
+// cache is full
+func (c *authCache) add(key [32]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Now().Add(authCacheTTL)
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*authCacheEntry).expires = expires
+		c.order.MoveToFront(e)
+		return
+	}
+	if c.order.Len() >= authCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*authCacheEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&authCacheEntry{key: key, expires: expires})
+}
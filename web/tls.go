@@ -0,0 +1,92 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"slices"
+)
+
+// TLSVersion is a TLS protocol version, configured as TLS10 to TLS13
+type TLSVersion uint16
+
+var tlsVersions = map[string]TLSVersion{
+	"TLS13": tls.VersionTLS13,
+	"TLS12": tls.VersionTLS12,
+	"TLS11": tls.VersionTLS11,
+	"TLS10": tls.VersionTLS10,
+}
+
+// UnmarshalYAML parses a TLS version name
+func (v *TLSVersion) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	version, ok := tlsVersions[s]
+	if !ok {
+		return fmt.Errorf("unknown TLS version: %s", s)
+	}
+	*v = version
+	return nil
+}
+
+// Cipher is a TLS cipher suite, configured by its Go name such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+type Cipher uint16
+
+// UnmarshalYAML parses a cipher suite name
+func (c *Cipher) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == s {
+			*c = Cipher(suite.ID)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown or insecure cipher suite: %s", s)
+}
+
+// clientAuthTypes maps the client_auth_type names to their crypto/tls value
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// verifiesClientCert reports whether the client auth type verifies client
+// certificates against the client CA
+func verifiesClientCert(t tls.ClientAuthType) bool {
+	return t == tls.VerifyClientCertIfGiven || t == tls.RequireAndVerifyClientCert
+}
+
+// verifyClientSAN accepts a verified client certificate only if one of its
+// subject alternative names is in client_allowed_sans
+func (c *Config) verifyClientSAN(_ [][]byte, chains [][]*x509.Certificate) error {
+	for _, chain := range chains {
+		if len(chain) == 0 {
+			continue
+		}
+		cert := chain[0]
+		sans := append([]string{}, cert.DNSNames...)
+		sans = append(sans, cert.EmailAddresses...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		for _, uri := range cert.URIs {
+			sans = append(sans, uri.String())
+		}
+		for _, san := range sans {
+			if slices.Contains(c.TLSConfig.ClientAllowedSANs, san) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("client certificate has no allowed subject alternative name")
+}
@@ -0,0 +1,229 @@
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"go.yaml.in/yaml/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config is the web configuration file, in the format used by the Prometheus
+// exporter-toolkit (--web.config.file)
+type Config struct {
+	TLSConfig  TLSConfig         `yaml:"tls_server_config"`
+	HTTPConfig HTTPConfig        `yaml:"http_server_config"`
+	Users      map[string]string `yaml:"basic_auth_users"`
+}
+
+// TLSConfig configures TLS on the listener
+type TLSConfig struct {
+	CertFile     string     `yaml:"cert_file"`
+	KeyFile      string     `yaml:"key_file"`
+	MinVersion   TLSVersion `yaml:"min_version"`
+	MaxVersion   TLSVersion `yaml:"max_version"`
+	CipherSuites []Cipher   `yaml:"cipher_suites"`
+	// ClientAuthType is one of the crypto/tls ClientAuthType names, such as
+	// RequireAndVerifyClientCert for mutual TLS
+	ClientAuthType    string   `yaml:"client_auth_type"`
+	ClientCAFile      string   `yaml:"client_ca_file"`
+	ClientAllowedSANs []string `yaml:"client_allowed_sans"`
+	// Accepted for compatibility with exporter-toolkit files, Go ignores it
+	PreferServerCipherSuites bool `yaml:"prefer_server_cipher_suites"`
+}
+
+// HTTPConfig configures the HTTP server
+type HTTPConfig struct {
+	Headers map[string]string `yaml:"headers"`
+}
+
+// LoadConfig reads and parses a web configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web config file: %w", err)
+	}
+
+	c := &Config{
+		TLSConfig: TLSConfig{MinVersion: tls.VersionTLS12},
+	}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse web config file %s: %w", path, err)
+	}
+
+	if (c.TLSConfig.CertFile == "") != (c.TLSConfig.KeyFile == "") {
+		return nil, fmt.Errorf("web config: cert_file and key_file must be set together")
+	}
+	if _, ok := clientAuthTypes[c.TLSConfig.ClientAuthType]; !ok {
+		return nil, fmt.Errorf("web config: unknown client_auth_type %q", c.TLSConfig.ClientAuthType)
+	}
+	if c.TLSConfig.ClientCAFile != "" && !c.TLSEnabled() {
+		return nil, fmt.Errorf("web config: client_ca_file requires cert_file and key_file")
+	}
+	if c.TLSConfig.ClientCAFile == "" && verifiesClientCert(clientAuthTypes[c.TLSConfig.ClientAuthType]) {
+		return nil, fmt.Errorf("web config: client_auth_type %s requires client_ca_file", c.TLSConfig.ClientAuthType)
+	}
+	// The names are checked on the verified chain, which the other types may
+	// leave empty or skip when no certificate is sent
+	if len(c.TLSConfig.ClientAllowedSANs) > 0 && clientAuthTypes[c.TLSConfig.ClientAuthType] != tls.RequireAndVerifyClientCert {
+		return nil, fmt.Errorf("web config: client_allowed_sans requires client_auth_type RequireAndVerifyClientCert")
+	}
+	for user, hash := range c.Users {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("web config: invalid bcrypt hash for user %q: %w", user, err)
+		}
+	}
+
+	return c, nil
+}
+
+// TLSEnabled reports whether the listener should serve HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSConfig.CertFile != ""
+}
+
+// ServerTLSConfig builds the crypto/tls configuration of the listener
+func (c *Config) ServerTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.TLSConfig.CertFile, c.TLSConfig.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   uint16(c.TLSConfig.MinVersion),
+		MaxVersion:   uint16(c.TLSConfig.MaxVersion),
+	}
+	for _, cipher := range c.TLSConfig.CipherSuites {
+		cfg.CipherSuites = append(cfg.CipherSuites, uint16(cipher))
+	}
+
+	// Client certificate (mutual TLS) authentication
+	cfg.ClientAuth = clientAuthTypes[c.TLSConfig.ClientAuthType]
+	if c.TLSConfig.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.TLSConfig.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", c.TLSConfig.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+	}
+	if len(c.TLSConfig.ClientAllowedSANs) > 0 {
+		cfg.VerifyPeerCertificate = c.verifyClientSAN
+	}
+
+	return cfg, nil
+}
+
+// ListenAndServe serves the server with the TLS and basic authentication
+// settings of the web configuration file. Without a file it behaves like
+// server.ListenAndServe. Like the exporter-toolkit, the file is read again
+// for every request and TLS handshake, so rotated certificates and changed
+// users apply without a restart; turning TLS on or off takes one.
+func ListenAndServe(server *http.Server, configFile string) error {
+	if configFile == "" {
+		return server.ListenAndServe()
+	}
+
+	c, err := LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	server.Handler = &handler{configFile: configFile, next: server.Handler, cache: newAuthCache()}
+
+	if !c.TLSEnabled() {
+		return server.ListenAndServe()
+	}
+
+	tlsConfig, err := c.ServerTLSConfig()
+	if err != nil {
+		return err
+	}
+	tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c, err := LoadConfig(configFile)
+		if err != nil {
+			log.Printf("Failed to load web config for a TLS handshake: %v", err)
+			return nil, err
+		}
+		if !c.TLSEnabled() {
+			return nil, fmt.Errorf("web config: TLS was turned off, which takes a restart")
+		}
+		config, err := c.ServerTLSConfig()
+		if err != nil {
+			log.Printf("Failed to load web config for a TLS handshake: %v", err)
+			return nil, err
+		}
+		config.NextProtos = tlsConfig.NextProtos
+		return config, nil
+	}
+	server.TLSConfig = tlsConfig
+	return server.ListenAndServeTLS("", "")
+}
+
+// handler adds the response headers and basic authentication of the web
+// configuration file to a handler, reading the file for every request
+type handler struct {
+	configFile string
+	next       http.Handler
+	// cache outlives the reads of the file, its keys include the hash of
+	// the password so changed users don't match old entries
+	cache *authCache
+}
+
+// ServeHTTP implements http.Handler
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := LoadConfig(h.configFile)
+	if err != nil {
+		log.Printf("Failed to load web config: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	for name, value := range c.HTTPConfig.Headers {
+		w.Header().Set(name, value)
+	}
+
+	if len(c.Users) == 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	user, pass, ok := r.BasicAuth()
+	if ok {
+		hash, known := c.Users[user]
+		if !known {
+			// Compare anyway so unknown users take as long as wrong passwords
+			hash = dummyHash
+		}
+
+		// bcrypt is slow on purpose, so remember credentials that were
+		// already found valid
+		key := sha256.Sum256([]byte(user + ":" + pass + ":" + hash))
+		valid := h.cache.valid(key)
+		if !valid {
+			valid = bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
+			if valid && known {
+				h.cache.add(key)
+			}
+		}
+		if valid && known {
+			h.next.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	w.Header().Set("WWW-Authenticate", `Basic realm="bdx_exporter"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// dummyHash is a bcrypt hash used to keep timing constant for unknown users
+var dummyHash = "$2y$10$QOauhQNbBCuQDKes6eFzPeMqBSjb7Mr5DUmpZ/VcEd00UAV/LDeSi"